@@ -1,16 +1,26 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"sync"
+	"net/http"
+	"time"
 
 	"github.com/go-pg/pg/v9"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.uber.org/zap"
 
 	"github.com/wavesplatform/transaction-broadcaster/internal/config"
 	"github.com/wavesplatform/transaction-broadcaster/internal/dispatcher"
+	"github.com/wavesplatform/transaction-broadcaster/internal/events"
 	"github.com/wavesplatform/transaction-broadcaster/internal/log"
+	"github.com/wavesplatform/transaction-broadcaster/internal/migrate"
 	"github.com/wavesplatform/transaction-broadcaster/internal/node"
 	"github.com/wavesplatform/transaction-broadcaster/internal/repository"
+	"github.com/wavesplatform/transaction-broadcaster/internal/shutdown"
+	"github.com/wavesplatform/transaction-broadcaster/internal/tracing"
+	"github.com/wavesplatform/transaction-broadcaster/internal/worker"
 )
 
 func main() {
@@ -26,6 +36,16 @@ func main() {
 	logger := log.Logger.Named("main.main")
 	logger.Info("successfull init")
 
+	closers := shutdown.NewSequence(logger)
+
+	shutdownTracing, tracingErr := tracing.Init(cfg.Tracing, "transaction-broadcaster-daemon", prometheus.DefaultRegisterer)
+	if tracingErr != nil {
+		panic(tracingErr)
+	}
+	closers.Add("tracing", func() error {
+		return shutdownTracing(context.Background())
+	})
+
 	db := pg.Connect(&pg.Options{
 		Addr:     fmt.Sprintf("%s:%d", cfg.Pg.Host, cfg.Pg.Port),
 		User:     cfg.Pg.User,
@@ -33,23 +53,107 @@ func main() {
 		Password: cfg.Pg.Password,
 	})
 
-	repo := repository.New(db)
+	if cfg.Pg.MigrateOnStart {
+		if err := migrate.Up(db); err != nil {
+			panic(err)
+		}
+	}
 
-	nodeInteractor := node.New(cfg.Node.NodeURL, cfg.Node.NodeAPIKey, cfg.Node.WaitForTxStatusDelay, cfg.Node.WaitForTxTimeout, cfg.Node.WaitForNextHeightDelay)
+	// dispatcher claims sequences, so it always works with the primary
+	repo := repository.New(db, nil, cfg.API.MaxSequenceTxs)
+	if cfg.Pg.QueryMetrics {
+		repoMetrics, err := repository.NewMetrics(prometheus.DefaultRegisterer)
+		if err != nil {
+			panic(err)
+		}
+		repo = repository.NewInstrumented(repo, repoMetrics)
+	}
+	closers.Add("repository", repo.Close)
 
-	disp := dispatcher.New(repo, nodeInteractor, cfg.Dispatcher.LoopDelay, cfg.Dispatcher.SequenceTTL, cfg.Worker.TxOutdateTime, cfg.Worker.TxProcessingTTL, cfg.Worker.HeightsAfterLastTx, cfg.Worker.WaitForNextHeightDelay)
+	nodeMetrics, metricsErr := node.NewMetrics(prometheus.DefaultRegisterer)
+	if metricsErr != nil {
+		panic(metricsErr)
+	}
 
-	var wg sync.WaitGroup
-	wg.Add(1)
+	nodeInteractorFactory, nodeErr := node.NewFactoryFromConfig(cfg.Node, nodeMetrics)
+	if nodeErr != nil {
+		panic(nodeErr)
+	}
+	nodeInteractor := nodeInteractorFactory(cfg.Node.NodeURL)
 
-	go func() {
-		defer wg.Done()
-		if err := disp.RunLoop(); err != nil {
-			panic(err)
+	if cfg.Node.WarmUp {
+		if err := node.WarmUp(nodeInteractor); err != nil {
+			logger.Error("node connection warm up failed", zap.Error(err))
 		}
+	}
+
+	// version dependent node responses are handled according to the version once it is fetched
+	if version, err := nodeInteractor.GetNodeVersion(); err != nil {
+		logger.Error("cannot get node version", zap.Error(err))
+	} else {
+		logger.Info("node version detected", zap.Stringer("version", version))
+	}
+
+	if err := nodeInteractor.CheckValidateEndpoint(); err != nil {
+		logger.Error("node validate endpoint check failed, the daemon is not ready", zap.Error(err))
+	}
+
+	clockSkewMonitor := node.NewClockSkewMonitor(nodeInteractor, cfg.Node.ClockSkewThreshold, cfg.Node.ClockSkewCheckInterval)
+	if err := clockSkewMonitor.CheckOnStart(cfg.Node.ClockSkewFailOnStart); err != nil {
+		panic(err)
+	}
+	go clockSkewMonitor.Run()
+
+	publisher, eventsErr := events.New(cfg.Events, prometheus.DefaultRegisterer)
+	if eventsErr != nil {
+		panic(eventsErr)
+	}
+	closers.Add("events publisher", publisher.Close)
+
+	if len(cfg.Worker.NodeErrorClassOverrides) > 0 {
+		logger.Info("node error class overrides", zap.Stringer("overrides", cfg.Worker.NodeErrorClassOverrides))
+	}
+
+	workerMetrics, metricsErr := worker.NewMetrics(prometheus.DefaultRegisterer)
+	if metricsErr != nil {
+		panic(metricsErr)
+	}
+
+	disp := dispatcher.New(repo, nodeInteractor, nodeInteractorFactory, publisher, cfg.Dispatcher, cfg.Worker, workerMetrics)
+
+	// callbacks pending on the shutdown get a single attempt timeout to be sent
+	closers.Add("tx callbacks", func() error {
+		return worker.CloseTxCallbacks(time.Duration(cfg.Worker.TxCallbackTimeout) * time.Millisecond)
+	})
+
+	// the daemon does not serve API, so metrics and readiness are exposed on a separate port
+	if cfg.MetricsPort > 0 {
+		go func() {
+			mux := http.NewServeMux()
+			mux.Handle("/metrics", promhttp.Handler())
+			mux.Handle("/ready", node.ReadinessHandler(nodeInteractor))
+			if err := http.ListenAndServe(fmt.Sprintf(":%d", cfg.MetricsPort), mux); err != nil {
+				panic(err)
+			}
+		}()
+	}
+
+	signals := shutdown.Signals()
+	loopErr := make(chan error, 1)
+
+	go func() {
+		loopErr <- disp.RunLoop()
 	}()
 
 	logger.Info("dispatcher started")
 
-	wg.Wait()
+	// sequences under processing are not waited for, they are taken over as hanging ones after the restart
+	select {
+	case sig := <-signals:
+		logger.Info("shutting down", zap.String("signal", sig.String()))
+		closers.Close()
+	case err := <-loopErr:
+		closers.Close()
+		panic(err)
+	}
 }
@@ -4,10 +4,10 @@ import (
 	"flag"
 	"fmt"
 	"os"
-	"path"
 
-	"github.com/go-pg/migrations/v7"
 	"github.com/go-pg/pg/v9"
+
+	"github.com/wavesplatform/transaction-broadcaster/internal/migrate"
 )
 
 const usageText = `This program runs command on the db. Supported commands are:
@@ -19,8 +19,10 @@ const usageText = `This program runs command on the db. Supported commands are:
   - version - prints current db version.
   - set_version [version] - sets db version without running migrations.
 
+Migrations are embedded into the binary.
+
 Usage:
-  go run *.go <command> [args]
+  migrate <command> [args]
 `
 
 func main() {
@@ -34,12 +36,7 @@ func main() {
 		Password: os.Getenv("PGPASSWORD"),
 	})
 
-	migrationsPath := path.Join(".", "db", "migrations")
-
-	fmt.Println("Discovering SQL Migrations from ", migrationsPath)
-	migrations.DefaultCollection.DiscoverSQLMigrations(migrationsPath)
-
-	oldVersion, newVersion, err := migrations.Run(db, flag.Args()...)
+	oldVersion, newVersion, err := migrate.Run(db, flag.Args()...)
 	if err != nil {
 		exitf(err.Error())
 	}
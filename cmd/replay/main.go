@@ -0,0 +1,87 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/go-pg/pg/v9"
+
+	"github.com/wavesplatform/transaction-broadcaster/internal/config"
+	"github.com/wavesplatform/transaction-broadcaster/internal/log"
+	"github.com/wavesplatform/transaction-broadcaster/internal/node"
+	"github.com/wavesplatform/transaction-broadcaster/internal/repository"
+	"github.com/wavesplatform/transaction-broadcaster/internal/worker"
+)
+
+const usageText = `This program replays a sequence without mutating its state.
+Sequence txs are read from the db and processed from scratch by the worker against the fake node,
+every worker decision and every state change it would make are printed.
+
+Usage:
+  replay -sequence <id> [-validate]
+`
+
+func main() {
+	sequenceID := flag.Int64("sequence", 0, "id of the sequence to replay")
+	validate := flag.Bool("validate", false, "validate txs using the real node (validate-only mode)")
+	flag.Usage = usage
+	flag.Parse()
+
+	if *sequenceID == 0 {
+		usage()
+	}
+
+	cfg, cfgErr := config.Load()
+	if cfgErr != nil {
+		panic(cfgErr)
+	}
+
+	// debug level is required to see worker decisions
+	if logInitErr := log.Init(true); logInitErr != nil {
+		panic(logInitErr)
+	}
+
+	db := pg.Connect(&pg.Options{
+		Addr:     fmt.Sprintf("%s:%d", cfg.Pg.Host, cfg.Pg.Port),
+		User:     cfg.Pg.User,
+		Database: cfg.Pg.Database,
+		Password: cfg.Pg.Password,
+	})
+
+	repo := repository.NewDryRun(repository.New(db, nil, cfg.API.MaxSequenceTxs), true)
+
+	options, err := repo.GetSequenceOptions(*sequenceID)
+	if err != nil {
+		exitf("cannot get sequence: %s", err.Error())
+	}
+
+	var validator node.Interactor
+	if *validate {
+		nodeInteractorFactory, nodeErr := node.NewFactoryFromConfig(cfg.Node, nil)
+		if nodeErr != nil {
+			panic(nodeErr)
+		}
+		validator = nodeInteractorFactory(cfg.Node.NodeURL)
+	}
+
+	w := worker.New("replay", "", repo, node.NewFakeInteractor(validator), *options, cfg.Worker, nil, nil)
+
+	if err := w.Run(*sequenceID); err != nil {
+		fmt.Printf("sequence %d failed: %s\n", *sequenceID, err.Error())
+		os.Exit(1)
+	}
+
+	fmt.Printf("sequence %d done\n", *sequenceID)
+}
+
+func usage() {
+	fmt.Print(usageText)
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func exitf(s string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, s+"\n", args...)
+	os.Exit(1)
+}
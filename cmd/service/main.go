@@ -1,19 +1,29 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"net/http"
+	"time"
 
 	"github.com/go-pg/pg/v9"
+	"github.com/prometheus/client_golang/prometheus"
 
 	"go.uber.org/zap"
 
 	"github.com/wavesplatform/transaction-broadcaster/internal/api"
 	"github.com/wavesplatform/transaction-broadcaster/internal/config"
 	"github.com/wavesplatform/transaction-broadcaster/internal/log"
+	"github.com/wavesplatform/transaction-broadcaster/internal/migrate"
 	"github.com/wavesplatform/transaction-broadcaster/internal/node"
 	"github.com/wavesplatform/transaction-broadcaster/internal/repository"
+	"github.com/wavesplatform/transaction-broadcaster/internal/shutdown"
+	"github.com/wavesplatform/transaction-broadcaster/internal/tracing"
 )
 
+// shutdownTimeout is the time given to in-flight requests to be finished on shutdown
+const shutdownTimeout = 10 * time.Second
+
 func main() {
 	cfg, cfgErr := config.Load()
 	if cfgErr != nil {
@@ -27,6 +37,16 @@ func main() {
 	logger := log.Logger.Named("main.main")
 	logger.Info("successfull init")
 
+	closers := shutdown.NewSequence(logger)
+
+	shutdownTracing, tracingErr := tracing.Init(cfg.Tracing, "transaction-broadcaster-service", prometheus.DefaultRegisterer)
+	if tracingErr != nil {
+		panic(tracingErr)
+	}
+	closers.Add("tracing", func() error {
+		return shutdownTracing(context.Background())
+	})
+
 	db := pg.Connect(&pg.Options{
 		Addr:     fmt.Sprintf("%s:%d", cfg.Pg.Host, cfg.Pg.Port),
 		User:     cfg.Pg.User,
@@ -34,15 +54,90 @@ func main() {
 		Password: cfg.Pg.Password,
 	})
 
-	repo := repository.New(db)
+	if cfg.Pg.MigrateOnStart {
+		if err := migrate.Up(db); err != nil {
+			panic(err)
+		}
+	}
 
-	nodeInteractor := node.New(cfg.Node.NodeURL, cfg.Node.NodeAPIKey, cfg.Node.WaitForTxStatusDelay, cfg.Node.WaitForTxTimeout, cfg.Node.WaitForNextHeightDelay)
+	var replicaDB *pg.DB
+	if cfg.Pg.ReplicaHost != "" {
+		replicaDB = pg.Connect(&pg.Options{
+			Addr:     fmt.Sprintf("%s:%d", cfg.Pg.ReplicaHost, cfg.Pg.ReplicaPort),
+			User:     cfg.Pg.User,
+			Database: cfg.Pg.Database,
+			Password: cfg.Pg.Password,
+		})
+	}
+
+	repo := repository.New(db, replicaDB, cfg.API.MaxSequenceTxs)
+	if cfg.Pg.QueryMetrics {
+		repoMetrics, err := repository.NewMetrics(prometheus.DefaultRegisterer)
+		if err != nil {
+			panic(err)
+		}
+		repo = repository.NewInstrumented(repo, repoMetrics)
+	}
+	closers.Add("repository", repo.Close)
+
+	nodeMetrics, metricsErr := node.NewMetrics(prometheus.DefaultRegisterer)
+	if metricsErr != nil {
+		panic(metricsErr)
+	}
+
+	nodeInteractorFactory, nodeErr := node.NewFactoryFromConfig(cfg.Node, nodeMetrics)
+	if nodeErr != nil {
+		panic(nodeErr)
+	}
+	nodeInteractor := nodeInteractorFactory(cfg.Node.NodeURL)
 
-	s := api.New(repo, nodeInteractor)
+	if cfg.Node.WarmUp {
+		if err := node.WarmUp(nodeInteractor); err != nil {
+			logger.Error("node connection warm up failed", zap.Error(err))
+		}
+	}
+
+	// version dependent node responses are handled according to the version once it is fetched
+	if version, err := nodeInteractor.GetNodeVersion(); err != nil {
+		logger.Error("cannot get node version", zap.Error(err))
+	} else {
+		logger.Info("node version detected", zap.Stringer("version", version))
+	}
+
+	if err := nodeInteractor.CheckValidateEndpoint(); err != nil {
+		logger.Error("node validate endpoint check failed, the service is not ready", zap.Error(err))
+	}
+
+	clockSkewMonitor := node.NewClockSkewMonitor(nodeInteractor, cfg.Node.ClockSkewThreshold, cfg.Node.ClockSkewCheckInterval)
+	if err := clockSkewMonitor.CheckOnStart(cfg.Node.ClockSkewFailOnStart); err != nil {
+		panic(err)
+	}
+	go clockSkewMonitor.Run()
+
+	s := api.New(cfg.API, repo, nodeInteractor, nodeInteractorFactory, cfg.Node.AllowedNodeURLs, cfg.Node.BlockTimeEstimationDepth, clockSkewMonitor, cfg.Effective())
 	addr := fmt.Sprintf(":%d", cfg.Port)
 
+	srv := &http.Server{Addr: addr, Handler: s}
+	closers.Add("REST API server", func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		return srv.Shutdown(ctx)
+	})
+
+	signals := shutdown.Signals()
+	runErr := make(chan error, 1)
+
 	logger.Info("starting REST API server", zap.Int("port", cfg.Port))
-	if runError := s.Run(addr); runError != nil {
-		panic(runError)
+	go func() {
+		runErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case sig := <-signals:
+		logger.Info("shutting down", zap.String("signal", sig.String()))
+		closers.Close()
+	case err := <-runErr:
+		closers.Close()
+		panic(err)
 	}
 }
@@ -0,0 +1,9 @@
+// Package db embeds SQL migrations, so binaries do not depend on the migration files
+package db
+
+import "embed"
+
+// Migrations contains migrations/*.sql files
+//
+//go:embed migrations/*.sql
+var Migrations embed.FS
@@ -0,0 +1 @@
+ALTER TABLE sequences ADD COLUMN trace_parent VARCHAR DEFAULT NULL;
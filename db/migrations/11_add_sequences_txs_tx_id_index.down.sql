@@ -0,0 +1 @@
+DROP INDEX IF EXISTS sequences_txs_tx_id_idx;
@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS sequences_txs_tx_id_idx ON sequences_txs (tx_id);
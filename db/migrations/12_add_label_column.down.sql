@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS sequences_label_state_idx;
+ALTER TABLE sequences DROP COLUMN label;
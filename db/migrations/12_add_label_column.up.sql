@@ -0,0 +1,2 @@
+ALTER TABLE sequences ADD COLUMN label VARCHAR DEFAULT NULL;
+CREATE INDEX IF NOT EXISTS sequences_label_state_idx ON sequences (label, state);
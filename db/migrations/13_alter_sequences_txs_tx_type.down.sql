@@ -0,0 +1 @@
+ALTER TABLE sequences_txs ALTER COLUMN tx TYPE VARCHAR;
@@ -0,0 +1 @@
+ALTER TABLE sequences_txs DROP COLUMN submitted_tx_id;
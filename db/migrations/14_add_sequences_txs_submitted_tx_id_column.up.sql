@@ -0,0 +1 @@
+ALTER TABLE sequences_txs ADD COLUMN submitted_tx_id VARCHAR;
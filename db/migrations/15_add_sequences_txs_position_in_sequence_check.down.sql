@@ -0,0 +1 @@
+ALTER TABLE sequences_txs DROP CONSTRAINT sequences_txs_position_in_sequence_check;
@@ -0,0 +1 @@
+ALTER TABLE sequences_txs ADD CONSTRAINT sequences_txs_position_in_sequence_check CHECK (position_in_sequence >= 0 AND position_in_sequence <= 32767);
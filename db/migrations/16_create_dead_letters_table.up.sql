@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS dead_letters (
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY,
+    sequence_id BIGINT NOT NULL,
+    error_message VARCHAR DEFAULT NULL,
+    error_code INTEGER DEFAULT NULL,
+    label VARCHAR DEFAULT NULL,
+    txs JSONB NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    CONSTRAINT dead_letters_pk PRIMARY KEY (id)
+);
+
+CREATE INDEX IF NOT EXISTS dead_letters_sequence_id_idx ON dead_letters (sequence_id);
@@ -0,0 +1 @@
+ALTER TABLE sequences DROP COLUMN last_confirmed_position;
@@ -0,0 +1 @@
+ALTER TABLE sequences ADD COLUMN last_confirmed_position INTEGER DEFAULT NULL;
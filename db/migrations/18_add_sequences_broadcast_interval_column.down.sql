@@ -0,0 +1 @@
+ALTER TABLE sequences DROP COLUMN broadcast_interval;
@@ -0,0 +1 @@
+ALTER TABLE sequences ADD COLUMN broadcast_interval INTEGER DEFAULT NULL;
@@ -0,0 +1 @@
+ALTER TABLE sequences ADD COLUMN mode VARCHAR DEFAULT NULL;
@@ -0,0 +1 @@
+ALTER TABLE sequences_txs ADD COLUMN validation_trace JSONB DEFAULT NULL;
@@ -0,0 +1,2 @@
+ALTER TABLE sequences DROP COLUMN lease_expires_at;
+ALTER TABLE sequences DROP COLUMN owner;
@@ -0,0 +1,2 @@
+ALTER TABLE sequences ADD COLUMN owner VARCHAR DEFAULT NULL;
+ALTER TABLE sequences ADD COLUMN lease_expires_at TIMESTAMP WITH TIME ZONE DEFAULT NULL;
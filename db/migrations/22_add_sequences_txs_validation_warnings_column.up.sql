@@ -0,0 +1 @@
+ALTER TABLE sequences_txs ADD COLUMN validation_warnings TEXT[] DEFAULT NULL;
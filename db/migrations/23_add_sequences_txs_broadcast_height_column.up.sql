@@ -0,0 +1 @@
+ALTER TABLE sequences_txs ADD COLUMN broadcast_height INTEGER DEFAULT NULL;
@@ -0,0 +1 @@
+ALTER TABLE sequences DROP COLUMN per_tx_callback_url;
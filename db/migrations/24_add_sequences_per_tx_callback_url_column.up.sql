@@ -0,0 +1 @@
+ALTER TABLE sequences ADD COLUMN per_tx_callback_url TEXT DEFAULT NULL;
@@ -0,0 +1 @@
+ALTER TABLE sequences DROP COLUMN retention_seconds;
@@ -0,0 +1 @@
+ALTER TABLE sequences ADD COLUMN retention_seconds INTEGER DEFAULT NULL;
@@ -0,0 +1,5 @@
+DROP TRIGGER sequences_txs_confirmed_count ON sequences_txs;
+DROP FUNCTION sequences_txs_confirmed_count();
+
+ALTER TABLE sequences DROP COLUMN confirmed_count;
+ALTER TABLE sequences DROP COLUMN txs_count;
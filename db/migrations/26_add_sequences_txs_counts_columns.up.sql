@@ -0,0 +1,21 @@
+ALTER TABLE sequences ADD COLUMN txs_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE sequences ADD COLUMN confirmed_count INTEGER NOT NULL DEFAULT 0;
+
+UPDATE sequences s SET
+    txs_count = (SELECT count(*) FROM sequences_txs st WHERE st.sequence_id = s.id),
+    confirmed_count = (SELECT count(*) FROM sequences_txs st WHERE st.sequence_id = s.id AND st.state = 4);
+
+-- confirmed_count follows the tx states, so sequences are read without counting their txs; 4 is the confirmed tx state
+CREATE FUNCTION sequences_txs_confirmed_count() RETURNS TRIGGER AS $$
+BEGIN
+    UPDATE sequences SET confirmed_count = confirmed_count
+        + (CASE WHEN NEW.state = 4 THEN 1 ELSE 0 END)
+        - (CASE WHEN OLD.state = 4 THEN 1 ELSE 0 END)
+    WHERE id = NEW.sequence_id;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER sequences_txs_confirmed_count AFTER UPDATE OF state ON sequences_txs
+    FOR EACH ROW WHEN (OLD.state IS DISTINCT FROM NEW.state AND (OLD.state = 4 OR NEW.state = 4))
+    EXECUTE PROCEDURE sequences_txs_confirmed_count();
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS sequences_state_label_idx;
@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS sequences_state_label_idx ON sequences (state, label);
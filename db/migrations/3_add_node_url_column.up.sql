@@ -0,0 +1 @@
+ALTER TABLE sequences ADD COLUMN node_url VARCHAR DEFAULT NULL;
@@ -0,0 +1 @@
+ALTER TABLE sequences DROP COLUMN skip_validation;
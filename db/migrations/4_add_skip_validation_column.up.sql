@@ -0,0 +1 @@
+ALTER TABLE sequences ADD COLUMN skip_validation BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1 @@
+ALTER TABLE sequences ADD COLUMN deadline TIMESTAMPTZ DEFAULT NULL;
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS sequences_txs_height_idx;
@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS sequences_txs_height_idx ON sequences_txs (height);
@@ -0,0 +1 @@
+ALTER TABLE sequences DROP COLUMN tx_outdate_time;
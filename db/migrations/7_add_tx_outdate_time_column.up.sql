@@ -0,0 +1 @@
+ALTER TABLE sequences ADD COLUMN tx_outdate_time INTEGER DEFAULT NULL;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS sequences_txs_updated_at_idx;
+DROP INDEX IF EXISTS sequences_created_at_idx;
@@ -0,0 +1,2 @@
+CREATE INDEX IF NOT EXISTS sequences_created_at_idx ON sequences (created_at);
+CREATE INDEX IF NOT EXISTS sequences_txs_updated_at_idx ON sequences_txs (updated_at);
@@ -0,0 +1 @@
+ALTER TABLE sequences_txs DROP COLUMN min_confirmations;
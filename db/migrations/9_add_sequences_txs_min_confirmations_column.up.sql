@@ -0,0 +1 @@
+ALTER TABLE sequences_txs ADD COLUMN min_confirmations INTEGER DEFAULT NULL;
@@ -1,18 +1,22 @@
 module github.com/wavesplatform/transaction-broadcaster
 
-go 1.14
+go 1.16
 
 require (
 	github.com/caarlos0/env/v6 v6.2.2
 	github.com/gin-gonic/gin v1.6.3
 	github.com/go-pg/migrations/v7 v7.1.10
 	github.com/go-pg/pg/v9 v9.1.6
-	github.com/golang/protobuf v1.4.1 // indirect
 	github.com/hnakamur/zap-ltsv v0.0.0-20170731143423-10a3dd1d839c
 	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
 	github.com/modern-go/reflect2 v1.0.1 // indirect
-	github.com/stretchr/testify v1.6.0
+	github.com/nats-io/nats.go v1.11.0
+	github.com/prometheus/client_golang v1.7.1
+	github.com/stretchr/testify v1.7.0
+	go.opentelemetry.io/otel v1.0.1
+	go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp v1.0.1
+	go.opentelemetry.io/otel/sdk v1.0.1
+	go.opentelemetry.io/otel/trace v1.0.1
 	go.uber.org/zap v1.15.0
-	golang.org/x/sys v0.0.0-20200513112337-417ce2331b5c // indirect
 	gopkg.in/yaml.v2 v2.3.0 // indirect
 )
@@ -1,11 +1,19 @@
 package api
 
 import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/wavesplatform/transaction-broadcaster/internal/log"
 	"github.com/wavesplatform/transaction-broadcaster/internal/node"
 	"github.com/wavesplatform/transaction-broadcaster/internal/repository"
+	"github.com/wavesplatform/transaction-broadcaster/internal/tracing"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 
 	"github.com/gin-gonic/gin"
@@ -13,12 +21,30 @@ import (
 
 type errorRenderer func(*gin.Context, int, Error)
 
-type txDto struct {
-	ID string `json:"id"`
+// txsRequest represents the envelope of create sequence request, options are decoded separately
+type txsRequest struct {
+	Txs []json.RawMessage `json:"transactions" binding:"required"`
 }
 
-type txsRequest struct {
-	Txs []string `json:"transactions" binding:"required"`
+type sequenceOptionsRequest struct {
+	NodeURL        string  `json:"nodeUrl"`
+	SkipValidation bool    `json:"skipValidation"`
+	Deadline       int64   `json:"deadline"`
+	TxOutdateTime  int32   `json:"txOutdateTime"`
+	Confirmations  []int32 `json:"confirmations"`
+	Label          string  `json:"label"`
+	WaitFirst      bool    `json:"waitFirst"`
+	CommonSender   bool    `json:"commonSender"`
+	// BroadcastInterval is min interval between broadcasts of the sequence txs in ms
+	BroadcastInterval int32 `json:"broadcastInterval"`
+	// Mode is "broadcast" (default) or "validate_only"
+	Mode string `json:"mode"`
+	// PerTxCallbackURL is http(s) url posted to after each tx of the sequence is confirmed
+	PerTxCallbackURL string `json:"perTxCallbackUrl"`
+	// RetentionSeconds is how long the sequence is kept after its completion, zero means the default retention
+	RetentionSeconds int32 `json:"retentionSeconds"`
+	// Order is "reverse" or positions of the request txs in the order they are processed in, see orderTxs
+	Order json.RawMessage `json:"order"`
 }
 
 func accessLog(logger *zap.Logger) func(*gin.Context) {
@@ -44,6 +70,24 @@ func accessLog(logger *zap.Logger) func(*gin.Context) {
 	}
 }
 
+// traceRequest starts span of the request continuing the trace passed in the request headers
+// the span is available to handlers via the request context
+func traceRequest() func(*gin.Context) {
+	return func(c *gin.Context) {
+		ctx := tracing.Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+		ctx, span := tracing.Tracer.Start(ctx, c.Request.Method+" "+c.FullPath(), trace.WithSpanKind(trace.SpanKindServer))
+		defer span.End()
+
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		if c.Writer.Status() >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(c.Writer.Status()))
+		}
+	}
+}
+
 func createErrorRenderer(logger *zap.Logger) errorRenderer {
 	return func(ctx *gin.Context, status int, err Error) {
 		logger.Warn("rendering http error",
@@ -55,19 +99,72 @@ func createErrorRenderer(logger *zap.Logger) errorRenderer {
 	}
 }
 
+// adminAuth allows requests with X-API-Key header equal to adminAPIKey
+// admin endpoints are disabled if adminAPIKey is empty
+func adminAuth(adminAPIKey string) func(*gin.Context) {
+	return func(c *gin.Context) {
+		if adminAPIKey == "" {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+				"message": "Admin API is disabled",
+			})
+			return
+		}
+
+		if subtle.ConstantTimeCompare([]byte(c.Request.Header.Get("X-API-Key")), []byte(adminAPIKey)) != 1 {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"message": "Forbidden",
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
+
 // New ...
-func New(repo repository.Repository, nodeInteractor node.Interactor) *gin.Engine {
+// effectiveConfig is exposed by the admin API as is, so it must not contain secrets
+func New(cfg Config, repo repository.Repository, nodeInteractor node.Interactor, nodeInteractorFactory node.InteractorFactory, allowedNodeURLs []string, blockTimeEstimationDepth int32, clockSkewMonitor *node.ClockSkewMonitor, effectiveConfig map[string]interface{}) *gin.Engine {
 	logger := log.Logger.Named("server.requestHandler")
 
 	renderError := createErrorRenderer(logger)
 
+	creator := &sequenceCreator{
+		cfg:                   cfg,
+		nodeInteractor:        nodeInteractor,
+		nodeInteractorFactory: nodeInteractorFactory,
+		allowedNodeURLs:       allowedNodeURLs,
+	}
+
 	r := gin.New()
 
 	gin.DisableConsoleColor()
 
-	r.Use(gin.Recovery(), accessLog(logger))
+	r.Use(gin.Recovery(), accessLog(logger), traceRequest())
+
+	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
+	r.GET("/ready", gin.WrapH(node.ReadinessHandler(nodeInteractor)))
+
+	// admin responses are rendered as is (e.g. config keys are env var names) unless they are wrapped by jsonNaming
+	public := r.Group("/", jsonNaming(renderError, cfg.JSONNaming))
+
+	public.GET("/stats", getStats(logger, nodeInteractor, blockTimeEstimationDepth, clockSkewMonitor))
+	public.GET("/stats/throughput", getThroughputStats(logger, renderError, repo, cfg.StatsMaxWindow))
+
+	public.GET("/sequences", listSequences(logger, renderError, repo, cfg.TimestampFormat, cfg.ListMaxRange, cfg.ListMaxLimit))
+
+	public.GET("/sequences/:id", getSequence(logger, renderError, repo, cfg.TimestampFormat)).POST("/sequences", createSequence(logger, renderError, repo, creator))
+
+	public.GET("/sequences/:id/wait", waitSequence(logger, renderError, repo, cfg.TimestampFormat, cfg.WaitMaxTimeout, cfg.WaitPollInterval))
+
+	public.GET("/sequences/:id/transactions/:position", getSequenceTx(logger, renderError, repo, cfg.TimestampFormat)).DELETE("/sequences/:id/transactions/:position", skipSequenceTx(logger, renderError, repo))
+
+	public.GET("/transactions/:txid/sequence", getSequenceByTxID(logger, renderError, repo, cfg.TimestampFormat))
 
-	r.GET("/sequences/:id", getSequence(logger, renderError, repo)).POST("/sequences", createSequence(logger, renderError, repo, nodeInteractor))
+	admin := r.Group("/admin", adminAuth(cfg.AdminAPIKey))
+	admin.GET("/blocks/:height/audit", jsonNaming(renderError, cfg.JSONNaming), auditBlock(logger, renderError, repo, nodeInteractor))
+	admin.GET("/txstatus/:id", getTxStatusRaw(logger, nodeInteractor))
+	admin.POST("/sequences/:id/skip-to/:position", skipSequenceTo(logger, renderError, repo))
+	admin.GET("/config", getConfig(effectiveConfig, nodeInteractor))
 
 	return r
 }
@@ -0,0 +1,172 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/wavesplatform/transaction-broadcaster/internal/log"
+	"github.com/wavesplatform/transaction-broadcaster/internal/node"
+	"github.com/wavesplatform/transaction-broadcaster/internal/repository"
+)
+
+// fakeRepo keeps created sequences in memory, methods which are not overridden panic
+type fakeRepo struct {
+	repository.Repository
+
+	mutex     sync.Mutex
+	sequences map[int64][]string
+	options   map[int64]repository.SequenceOptions
+	// inTx is set while CreateSequenceFromSource inserts txs, i.e. while the db transaction would be open
+	inTx bool
+}
+
+func (r *fakeRepo) isInTx() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.inTx
+}
+
+func (r *fakeRepo) setInTx(inTx bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.inTx = inTx
+}
+
+func newFakeRepo() *fakeRepo {
+	return &fakeRepo{
+		sequences: make(map[int64][]string),
+		options:   make(map[int64]repository.SequenceOptions),
+	}
+}
+
+func (r *fakeRepo) CreateSequence(txs []string, options repository.SequenceOptions) (int64, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	id := int64(len(r.sequences) + 1)
+	r.sequences[id] = txs
+	r.options[id] = options
+	return id, nil
+}
+
+func (r *fakeRepo) CreateSequenceFromSource(source repository.TxsSource, batchSize int, options func() (repository.SequenceOptions, error)) (int64, error) {
+	r.setInTx(true)
+	defer r.setInTx(false)
+
+	var txs []string
+	for {
+		tx, err := source.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		txs = append(txs, tx)
+	}
+
+	opts, err := options()
+	if err != nil {
+		return 0, err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	id := int64(len(r.sequences) + 1)
+	r.sequences[id] = txs
+	r.options[id] = opts
+	return id, nil
+}
+
+// CountSequencesInStates counts created sequences, they all are pending
+func (r *fakeRepo) CountSequencesInStates(states []repository.State) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, state := range states {
+		if state == repository.StatePending {
+			return len(r.sequences), nil
+		}
+	}
+	return 0, nil
+}
+
+// txCheckingInteractor records whether the node was requested while the db transaction was open
+type txCheckingInteractor struct {
+	node.Interactor
+	repo *fakeRepo
+
+	mutex          sync.Mutex
+	requestedInTx  bool
+	requestedCount int
+}
+
+func (i *txCheckingInteractor) record() {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	i.requestedCount++
+	if i.repo.isInTx() {
+		i.requestedInTx = true
+	}
+}
+
+func (i *txCheckingInteractor) ValidateTx(tx string) (*node.ValidationResult, node.Error) {
+	i.record()
+	return i.Interactor.ValidateTx(tx)
+}
+
+func (i *txCheckingInteractor) BroadcastTx(tx string) (string, node.Error) {
+	i.record()
+	return i.Interactor.BroadcastTx(tx)
+}
+
+func (i *txCheckingInteractor) WithContext(ctx context.Context) node.Interactor {
+	return i
+}
+
+func newTestAPI(cfg Config, repo repository.Repository, nodeInteractor node.Interactor) *gin.Engine {
+	log.Logger = zap.NewNop()
+	gin.SetMode(gin.TestMode)
+
+	if cfg.JSONNaming == "" {
+		cfg.JSONNaming = JSONNamingSnakeCase
+	}
+
+	return New(cfg, repo, nodeInteractor, nil, nil, 0, nil, nil)
+}
+
+// serve performs the request, body of unknown size is streamed
+func serve(h http.Handler, method, path, body string, knownSize bool) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if !knownSize {
+		req.ContentLength = -1
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+// requireInvalidParameter checks err is the bad request error of the parameter with the reason
+func requireInvalidParameter(t *testing.T, err error, parameter, reason string) {
+	t.Helper()
+
+	var reqErr *requestError
+	require.True(t, errors.As(err, &reqErr), "%v is not a request error", err)
+	require.Equal(t, http.StatusBadRequest, reqErr.status)
+	require.Equal(t, uint32(_invalidParameterValue), reqErr.err.Code())
+	require.Equal(t, parameter, reqErr.err.Details()["parameter"])
+	require.Equal(t, reason, reqErr.err.Details()["reason"])
+}
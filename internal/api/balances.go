@@ -0,0 +1,108 @@
+package api
+
+import (
+	"fmt"
+
+	"github.com/wavesplatform/transaction-broadcaster/internal/node"
+)
+
+// types of txs spending WAVES besides the fee
+const (
+	transferTxType     = 4
+	massTransferTxType = 11
+)
+
+// BalanceCheck defines txs of the sequence whose WAVES spendings are checked against sender balances on the sequence creation
+type BalanceCheck string
+
+// Enum of BalanceCheck
+const (
+	BalanceCheckNone  BalanceCheck = "none"
+	BalanceCheckFirst BalanceCheck = "first"
+	BalanceCheckAll   BalanceCheck = "all"
+)
+
+// UnmarshalText parses BalanceCheck from its name
+func (b *BalanceCheck) UnmarshalText(text []byte) error {
+	switch check := BalanceCheck(text); check {
+	case BalanceCheckNone, BalanceCheckFirst, BalanceCheckAll:
+		*b = check
+	default:
+		return fmt.Errorf("unknown balance check: %s", text)
+	}
+	return nil
+}
+
+// txSpendings returns WAVES amount the tx spends: the fee and transferred or leased amounts, empty asset ids mean WAVES
+func txSpendings(t *node.Tx) int64 {
+	var spendings int64
+	if t.FeeAssetID == "" {
+		spendings += t.Fee
+	}
+
+	if t.AssetID != "" {
+		return spendings
+	}
+
+	switch t.Type {
+	case transferTxType, leaseTxType:
+		spendings += t.Amount
+	case massTransferTxType:
+		for _, transfer := range t.Transfers {
+			spendings += transfer.Amount
+		}
+	}
+	return spendings
+}
+
+// balanceChecker sums WAVES spendings of txs by their senders
+// txs without sender address or not parsable ones are left to the node validation
+// txs are added in the sequence order
+type balanceChecker struct {
+	check     BalanceCheck
+	added     bool
+	spendings map[string]int64
+	// senders are ordered by their first txs
+	senders []string
+}
+
+func newBalanceChecker(check BalanceCheck) *balanceChecker {
+	return &balanceChecker{check: check, spendings: make(map[string]int64)}
+}
+
+// add sums spendings of the tx at the position idx, not parsed txs (nil) are skipped
+func (c *balanceChecker) add(idx int, tx *node.Tx) {
+	if c.check == BalanceCheckFirst && c.added {
+		return
+	}
+	c.added = true
+
+	if tx == nil || tx.Sender == "" {
+		return
+	}
+
+	if _, ok := c.spendings[tx.Sender]; !ok {
+		c.senders = append(c.senders, tx.Sender)
+	}
+	c.spendings[tx.Sender] += txSpendings(tx)
+}
+
+// err requests balances of the senders, returns an error naming the first sender whose balance does not cover its txs
+func (c *balanceChecker) err(nodeInteractor node.Interactor) error {
+	for _, sender := range c.senders {
+		spendings := c.spendings[sender]
+		if spendings == 0 {
+			continue
+		}
+
+		balance, wavesErr := nodeInteractor.GetAddressBalance(sender)
+		if wavesErr != nil {
+			return wavesErr
+		}
+
+		if balance < spendings {
+			return badRequest(InvalidParameterValue("transactions", fmt.Sprintf("Balance %d of the sender %s is less than %d the transactions spend.", balance, sender, spendings)))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/wavesplatform/transaction-broadcaster/internal/node"
+)
+
+// balancesInteractor returns balances of the known addresses and counts balance requests
+type balancesInteractor struct {
+	node.Interactor
+	balances  map[string]int64
+	requested []string
+}
+
+func (i *balancesInteractor) GetAddressBalance(address string) (int64, node.Error) {
+	i.requested = append(i.requested, address)
+	return i.balances[address], nil
+}
+
+func TestTxSpendings(t *testing.T) {
+	cases := []struct {
+		tx        string
+		spendings int64
+	}{
+		{`{"type":4,"fee":100,"amount":1000}`, 1100},
+		{`{"type":4,"fee":100,"amount":1000,"assetId":"asset"}`, 100},
+		{`{"type":4,"fee":100,"feeAssetId":"asset","amount":1000}`, 1000},
+		{`{"type":8,"fee":100,"amount":500}`, 600},
+		{`{"type":11,"fee":200,"transfers":[{"recipient":"a","amount":10},{"recipient":"b","amount":20}]}`, 230},
+		{`{"type":3,"fee":100000000,"quantity":1000}`, 100000000},
+	}
+
+	for _, c := range cases {
+		tx, err := node.ParseTx(c.tx)
+		require.NoError(t, err)
+		require.Equal(t, c.spendings, txSpendings(tx), c.tx)
+	}
+}
+
+func TestBalanceCheckerSumsSpendingsBySender(t *testing.T) {
+	txs := []string{
+		`{"type":4,"sender":"alice","fee":100,"amount":1000}`,
+		`{"type":4,"sender":"bob","fee":100,"amount":50}`,
+		`{"type":11,"sender":"alice","fee":200,"transfers":[{"amount":300},{"amount":400}]}`,
+		`{"type":4,"fee":100,"amount":1000}`,
+		`not a tx`,
+	}
+
+	add := func(c *balanceChecker) {
+		for idx, tx := range txs {
+			parsed, _ := node.ParseTx(tx)
+			c.add(idx, parsed)
+		}
+	}
+
+	c := newBalanceChecker(BalanceCheckAll)
+	add(c)
+	require.Equal(t, []string{"alice", "bob"}, c.senders)
+	require.Equal(t, map[string]int64{"alice": 2000, "bob": 150}, c.spendings)
+
+	nodeInteractor := &balancesInteractor{balances: map[string]int64{"alice": 2000, "bob": 149}}
+	err := c.err(nodeInteractor)
+	requireInvalidParameter(t, err, "transactions", "Balance 149 of the sender bob is less than 150 the transactions spend.")
+	require.Equal(t, []string{"alice", "bob"}, nodeInteractor.requested)
+
+	// only the first tx is summed
+	c = newBalanceChecker(BalanceCheckFirst)
+	add(c)
+	require.Equal(t, map[string]int64{"alice": 1100}, c.spendings)
+	require.NoError(t, c.err(&balancesInteractor{balances: map[string]int64{"alice": 1100}}))
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"time"
+
+	"github.com/wavesplatform/transaction-broadcaster/internal/repository"
+)
+
+// Config of the api package
+type Config struct {
+	TimestampFormat     repository.TimeFormat `env:"API_TIMESTAMP_FORMAT" envDefault:"unix_millis"`
+	AllowSkipValidation bool                  `env:"API_ALLOW_SKIP_VALIDATION" envDefault:"false"`
+	AdminAPIKey         string                `env:"API_ADMIN_KEY"`
+
+	// JSONNaming is the default naming of keys of the public API responses, requests may override it by X-JSON-Naming header
+	JSONNaming JSONNaming `env:"API_JSON_NAMING" envDefault:"snake_case"`
+
+	// requests with bigger or unknown body size are streamed
+	StreamingBodySize        int64 `env:"API_STREAMING_BODY_SIZE" envDefault:"1048576"`
+	StreamingInsertBatchSize int32 `env:"API_STREAMING_INSERT_BATCH_SIZE" envDefault:"100"`
+
+	StatsMaxWindow time.Duration `env:"API_STATS_MAX_WINDOW" envDefault:"24h"`
+
+	// max timeout of the sequence wait request and interval the sequence state is polled by while waiting
+	WaitMaxTimeout   time.Duration `env:"API_WAIT_MAX_TIMEOUT" envDefault:"1m"`
+	WaitPollInterval time.Duration `env:"API_WAIT_POLL_INTERVAL" envDefault:"1s"`
+
+	// max creation time range and max count of sequences a list request returns
+	ListMaxRange time.Duration `env:"API_LIST_MAX_RANGE" envDefault:"24h"`
+	ListMaxLimit int           `env:"API_LIST_MAX_LIMIT" envDefault:"1000"`
+
+	// sequences with more than MaxSequenceTxs txs are rejected, it cannot exceed repository.MaxSequenceTxs
+	// the repository is created with the same max, so sequences created bypassing the API are limited as well
+	MaxSequenceTxs int `env:"MAX_SEQUENCE_TXS" envDefault:"10000"`
+
+	// txs bigger than MaxTxSize bytes are rejected, 0 means no limit
+	MaxTxSize int `env:"API_MAX_TX_SIZE" envDefault:"1048576"`
+
+	// max time the create request with waitFirst waits for the first tx confirmation
+	WaitFirstTimeout time.Duration `env:"API_WAIT_FIRST_TIMEOUT" envDefault:"1m"`
+
+	// all txs of a sequence have to have the same sender, otherwise it can be required by the request
+	RequireCommonSender bool `env:"API_REQUIRE_COMMON_SENDER" envDefault:"false"`
+
+	// all duplicate groups are reported instead of the first duplicate pair
+	ReportAllDuplicates bool `env:"API_REPORT_ALL_DUPLICATES" envDefault:"false"`
+
+	// txs with timestamps later than MaxTxFutureTime from now are rejected, 0 means no limit
+	MaxTxFutureTime time.Duration `env:"API_MAX_TX_FUTURE_TIME" envDefault:"0"`
+
+	// txs with versions lower than MinTxVersion are rejected, txs without version are the first version txs, 0 means no limit
+	MinTxVersion int `env:"API_MIN_TX_VERSION" envDefault:"0"`
+
+	// sequences whose first tx is already in the blockchain are accepted, e.g. re-submitted ones, otherwise the first tx is invalid
+	AcceptConfirmedFirstTx bool `env:"API_ACCEPT_CONFIRMED_FIRST_TX" envDefault:"false"`
+
+	// WAVES spendings of the first or all txs are checked against the balances of their senders, it requires sender field of txs
+	CheckBalance BalanceCheck `env:"API_CHECK_BALANCE" envDefault:"none"`
+
+	// txs paying WAVES fees less than the node min fees of their types are rejected
+	CheckMinFees bool `env:"API_CHECK_MIN_FEES" envDefault:"false"`
+
+	// warnings the node reports validating the first tx are rendered in the create response
+	ReturnValidationWarnings bool `env:"API_RETURN_VALIDATION_WARNINGS" envDefault:"false"`
+
+	// txs referencing assets, leases or aliases produced by the later txs of the sequence are rejected
+	CheckTxDependencies bool `env:"API_CHECK_TX_DEPENDENCIES" envDefault:"false"`
+
+	// sequences are rejected with 503 while the node is not synced
+	RequireNodeSync    bool          `env:"API_REQUIRE_NODE_SYNC" envDefault:"false"`
+	NodeSyncMaxLag     time.Duration `env:"API_NODE_SYNC_MAX_LAG" envDefault:"5m"`
+	NodeSyncRetryAfter time.Duration `env:"API_NODE_SYNC_RETRY_AFTER" envDefault:"30s"`
+
+	// sequences are rejected with 429 while there are MaxQueueDepth or more pending and processing sequences, 0 means no limit
+	MaxQueueDepth   int           `env:"API_MAX_QUEUE_DEPTH" envDefault:"0"`
+	QueueRetryAfter time.Duration `env:"API_QUEUE_RETRY_AFTER" envDefault:"10s"`
+
+	// sequences are rejected with 503 while the db is read-only or has no free connections
+	DBRetryAfter time.Duration `env:"API_DB_RETRY_AFTER" envDefault:"5s"`
+}
@@ -2,19 +2,23 @@ package api
 
 import (
 	"bytes"
-	"crypto/md5"
-	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/wavesplatform/transaction-broadcaster/internal/node"
 	"github.com/wavesplatform/transaction-broadcaster/internal/repository"
+	"github.com/wavesplatform/transaction-broadcaster/internal/tracing"
 	"go.uber.org/zap"
 )
 
-func getSequence(logger *zap.Logger, renderError errorRenderer, repo repository.Repository) func(*gin.Context) {
+func getSequence(logger *zap.Logger, renderError errorRenderer, repo repository.Repository, timestampFormat repository.TimeFormat) func(*gin.Context) {
 	return func(c *gin.Context) {
 		rawID := c.Param("id")
 
@@ -45,76 +49,819 @@ func getSequence(logger *zap.Logger, renderError errorRenderer, repo repository.
 			return
 		}
 
-		c.JSON(http.StatusOK, sequence)
+		c.JSON(http.StatusOK, sequenceResponse(requestNaming(c), sequence, timestampFormat))
 	}
 }
 
-func createSequence(logger *zap.Logger, renderError errorRenderer, repo repository.Repository, nodeInteractor node.Interactor) func(*gin.Context) {
+// defaultWaitTimeout is used if the timeout query param of the wait request is not set
+const defaultWaitTimeout = 30 * time.Second
+
+// defaultWaitPollInterval is used if the poll interval is not configured
+const defaultWaitPollInterval = time.Second
+
+// waitSequence renders the sequence once it reaches a final state or the timeout elapses, X-Sequence-Final header tells which happened
+// the sequence is polled, so db connections are not held while waiting
+func waitSequence(logger *zap.Logger, renderError errorRenderer, repo repository.Repository, timestampFormat repository.TimeFormat, maxTimeout, pollInterval time.Duration) func(*gin.Context) {
+	if pollInterval <= 0 {
+		pollInterval = defaultWaitPollInterval
+	}
+
 	return func(c *gin.Context) {
-		// retrieve transactions from post request body
-		buf := bytes.Buffer{}
-		_, err := buf.ReadFrom(c.Request.Body)
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			renderError(c, http.StatusBadRequest, InvalidParameterValue("id", fmt.Sprintf("Error occured while parsing id: %s.", err.Error())))
+			return
+		}
+
+		timeout := defaultWaitTimeout
+		if rawTimeout := c.Query("timeout"); rawTimeout != "" {
+			timeout, err = time.ParseDuration(rawTimeout)
+			if err != nil || timeout < 0 {
+				renderError(c, http.StatusBadRequest, InvalidParameterValue("timeout", "Timeout has to be a non-negative duration, e.g. 30s."))
+				return
+			}
+		}
+		if timeout > maxTimeout {
+			timeout = maxTimeout
+		}
+
+		deadline := time.NewTimer(timeout)
+		defer deadline.Stop()
+
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+
+		for {
+			sequence, err := repo.GetSequenceByID(id)
+			if err != nil {
+				logger.Error("cannot get sequence from db", zap.String("req_id", c.Request.Header.Get("X-Request-Id")), zap.Error(err))
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"message": _internalServerErrorMessage,
+				})
+				return
+			}
+
+			if sequence == nil {
+				c.JSON(http.StatusNotFound, gin.H{
+					"message": "Sequence not found",
+				})
+				return
+			}
+
+			final := sequence.State.IsFinal()
+			if !final {
+				select {
+				case <-ticker.C:
+					continue
+				case <-c.Request.Context().Done():
+					return
+				case <-deadline.C:
+				}
+			}
+
+			c.Header("X-Sequence-Final", strconv.FormatBool(final))
+			c.JSON(http.StatusOK, sequenceResponse(requestNaming(c), sequence, timestampFormat))
+			return
+		}
+	}
+}
+
+// defaultListLimit is used if the limit query param is not set
+const defaultListLimit = 100
+
+// listSequences renders sequences created within [from, to), optionally filtered by comma separated states
+// from and to are RFC3339 strings or unix timestamps in ms
+func listSequences(logger *zap.Logger, renderError errorRenderer, repo repository.Repository, timestampFormat repository.TimeFormat, maxRange time.Duration, maxLimit int) func(*gin.Context) {
+	return func(c *gin.Context) {
+		from, ok := parseTimeParam(c, renderError, "from")
+		if !ok {
+			return
+		}
+		to, ok := parseTimeParam(c, renderError, "to")
+		if !ok {
+			return
+		}
+
+		if !from.Before(to) {
+			renderError(c, http.StatusBadRequest, InvalidParameterValue("to", "To has to be later than from."))
+			return
+		}
+		if to.Sub(from) > maxRange {
+			renderError(c, http.StatusBadRequest, InvalidParameterValue("to", fmt.Sprintf("Range must not exceed %s.", maxRange)))
+			return
+		}
+
+		var states []repository.State
+		if rawStates := c.Query("state"); rawStates != "" {
+			for _, rawState := range strings.Split(rawStates, ",") {
+				var state repository.State
+				if err := state.UnmarshalText([]byte(strings.TrimSpace(rawState))); err != nil {
+					renderError(c, http.StatusBadRequest, InvalidParameterValue("state", "State has to be one of pending, processing, done, error."))
+					return
+				}
+				states = append(states, state)
+			}
+		}
+
+		limit := defaultListLimit
+		if rawLimit := c.Query("limit"); rawLimit != "" {
+			var err error
+			limit, err = strconv.Atoi(rawLimit)
+			if err != nil || limit <= 0 || limit > maxLimit {
+				renderError(c, http.StatusBadRequest, InvalidParameterValue("limit", fmt.Sprintf("Limit has to be in range [1, %d].", maxLimit)))
+				return
+			}
+		}
+
+		sequences, err := repo.GetSequencesByCreatedAt(from, to, states, limit)
 		if err != nil {
-			logger.Error("cannot get request body", zap.String("req_id", c.Request.Header.Get("X-Request-Id")), zap.Error(err))
+			logger.Error("cannot get sequences from db", zap.String("req_id", c.Request.Header.Get("X-Request-Id")), zap.Error(err))
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"message": _internalServerErrorMessage,
 			})
 			return
 		}
 
-		transactions, err := parseTransactions(buf.String())
+		c.JSON(http.StatusOK, sequencesResponse(requestNaming(c), sequences, timestampFormat))
+	}
+}
+
+// parseTimeParam parses required query param given as RFC3339 string or unix timestamp in ms, renders error if it is invalid
+func parseTimeParam(c *gin.Context, renderError errorRenderer, name string) (time.Time, bool) {
+	raw := c.Query(name)
+	if raw == "" {
+		renderError(c, http.StatusBadRequest, MissingRequiredParameter(name))
+		return time.Time{}, false
+	}
+
+	if ms, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return time.Unix(0, ms*int64(time.Millisecond)), true
+	}
+
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		renderError(c, http.StatusBadRequest, InvalidParameterValue(name, "Time has to be RFC3339 string or unix timestamp in ms."))
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+func getSequenceByTxID(logger *zap.Logger, renderError errorRenderer, repo repository.Repository, timestampFormat repository.TimeFormat) func(*gin.Context) {
+	return func(c *gin.Context) {
+		txID := c.Param("txid")
+
+		if txID == "" {
+			renderError(c, http.StatusBadRequest, MissingRequiredParameter("txid"))
+			return
+		}
+
+		sequence, err := repo.GetSequenceByTxID(txID)
 		if err != nil {
+			logger.Error("cannot get sequence by tx id from db", zap.String("req_id", c.Request.Header.Get("X-Request-Id")), zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": _internalServerErrorMessage,
+			})
+			return
+		}
+
+		if sequence == nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "Sequence not found",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, sequenceResponse(requestNaming(c), sequence, timestampFormat))
+	}
+}
+
+// retryAfter renders d as the Retry-After seconds, fractions of a second are rounded up not to ask for an immediate retry
+func retryAfter(d time.Duration) string {
+	return strconv.Itoa(int((d + time.Second - 1) / time.Second))
+}
+
+func createSequence(logger *zap.Logger, renderError errorRenderer, repo repository.Repository, creator *sequenceCreator) func(*gin.Context) {
+	renderCreateError := func(c *gin.Context, err error) {
+		var reqErr *requestError
+		if errors.As(err, &reqErr) {
+			switch reqErr.status {
+			case http.StatusServiceUnavailable:
+				c.Header("Retry-After", retryAfter(creator.cfg.NodeSyncRetryAfter))
+			case http.StatusTooManyRequests:
+				c.Header("Retry-After", retryAfter(creator.cfg.QueueRetryAfter))
+			}
+			renderError(c, reqErr.status, reqErr.err)
+			return
+		}
+
+		var maxTxsErr *repository.MaxSequenceTxsError
+		if errors.As(err, &maxTxsErr) {
+			renderError(c, http.StatusBadRequest, InvalidParameterValue("transactions", fmt.Sprintf("There are more than %d transactions.", maxTxsErr.MaxTxs)))
+			return
+		}
+
+		var dbErr *repository.DBUnavailableError
+		if errors.As(err, &dbErr) {
+			logger.Warn("cannot create sequence, db is unavailable", zap.String("req_id", c.Request.Header.Get("X-Request-Id")), zap.Error(err))
+			c.Header("Retry-After", retryAfter(creator.cfg.DBRetryAfter))
+			renderError(c, http.StatusServiceUnavailable, DBUnavailableError("Database is temporarily unavailable."))
+			return
+		}
+
+		logger.Error("cannot create sequence", zap.String("req_id", c.Request.Header.Get("X-Request-Id")), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": _internalServerErrorMessage,
+		})
+	}
+
+	return func(c *gin.Context) {
+		if creator.cfg.RequireNodeSync {
+			if err := creator.checkNodeSync(); err != nil {
+				renderCreateError(c, err)
+				return
+			}
+		}
+
+		if err := creator.checkQueueDepth(repo); err != nil {
+			renderCreateError(c, err)
+			return
+		}
+
+		// retrieve transactions from post request body, large requests are not buffered
+		// requests of unknown size are buffered until they turn out to be large
+		buf := bytes.Buffer{}
+		if c.Request.ContentLength <= creator.cfg.StreamingBodySize {
+			_, err := buf.ReadFrom(io.LimitReader(c.Request.Body, creator.cfg.StreamingBodySize+1))
+			if err != nil {
+				logger.Error("cannot get request body", zap.String("req_id", c.Request.Header.Get("X-Request-Id")), zap.Error(err))
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"message": _internalServerErrorMessage,
+				})
+				return
+			}
+		}
+
+		if c.Request.ContentLength > creator.cfg.StreamingBodySize || int64(buf.Len()) > creator.cfg.StreamingBodySize {
+			createSequenceStreaming(c, renderCreateError, repo, creator, io.MultiReader(&buf, c.Request.Body))
+			return
+		}
+
+		transactions, err := parseTransactions(buf.Bytes())
+		if err != nil {
+			var envErr *envelopeError
+			if errors.As(err, &envErr) {
+				renderError(c, http.StatusBadRequest, envErr.apiError())
+				return
+			}
 			renderError(c, http.StatusBadRequest, InvalidParameterValue("transactions", "Invalid request."))
 			return
 		}
 
 		if len(transactions) == 0 {
-			renderError(c, http.StatusBadRequest, InvalidParameterValue("transactions", "There are not any transactions in the request."))
+			renderError(c, http.StatusBadRequest, EmptyTransactionsError())
+			return
+		}
+
+		if err := creator.checkTxsCount(len(transactions)); err != nil {
+			renderCreateError(c, err)
 			return
 		}
 
-		var txs []string
 		// for tx uniqueness checking
-		var txHashes = make(map[string]int)
+		deduplicator := newTxsDeduplicator(creator.cfg.ReportAllDuplicates)
+		// every tx is parsed once for all checks, not parsable ones are nil and left to the node validation
+		parsedTxs := make([]*node.Tx, len(transactions))
 		for idx, tx := range transactions {
-			txHash := md5.Sum([]byte(tx))
-			txHashString := hex.EncodeToString(txHash[:])
-			if _, ok := txHashes[txHashString]; ok {
+			if err := creator.checkTxSize(idx, tx); err != nil {
+				renderCreateError(c, err)
+				return
+			}
+
+			parsedTxs[idx], _ = node.ParseTx(tx)
+
+			if err := creator.checkTxTimestamp(idx, parsedTxs[idx]); err != nil {
+				renderCreateError(c, err)
+				return
+			}
+
+			if err := creator.checkTxVersion(idx, parsedTxs[idx]); err != nil {
+				renderCreateError(c, err)
+				return
+			}
+
+			if err := deduplicator.add(idx, tx); err != nil {
 				logger.Error("there are duplicates in the transactions array", zap.String("req_id", c.Request.Header.Get("X-Request-Id")), zap.Error(err))
-				renderError(c, http.StatusBadRequest, TxsDuplicatesError(errorDetails{
-					"duplicates": []int{txHashes[txHashString], idx},
-				}))
+				renderCreateError(c, err)
+				return
+			}
+		}
+		if err := deduplicator.err(); err != nil {
+			logger.Error("there are duplicates in the transactions array", zap.String("req_id", c.Request.Header.Get("X-Request-Id")), zap.Error(err))
+			renderCreateError(c, err)
+			return
+		}
+
+		optionsRequest := sequenceOptionsRequest{}
+		if err := json.Unmarshal(buf.Bytes(), &optionsRequest); err != nil {
+			renderError(c, http.StatusBadRequest, InvalidParameterValue("transactions", "Invalid request."))
+			return
+		}
+
+		// txs are checked above in the request order, the rest is done in the sequence order, errors point to request positions anyway
+		transactions, parsedTxs, positions, err := orderTxs(optionsRequest.Order, transactions, parsedTxs)
+		if err != nil {
+			renderCreateError(c, err)
+			return
+		}
+
+		options, sequenceNodeInteractor, err := creator.sequenceOptions(optionsRequest, len(transactions))
+		if err != nil {
+			renderCreateError(c, err)
+			return
+		}
+
+		if creator.requireCommonSender(optionsRequest) {
+			senders := newSendersChecker()
+			for idx, tx := range parsedTxs {
+				senders.add(requestPosition(positions, idx), tx)
+			}
+			if err := senders.err(); err != nil {
+				renderCreateError(c, err)
+				return
+			}
+		}
+		if creator.cfg.CheckTxDependencies {
+			dependencies := newDependenciesChecker()
+			for idx, tx := range parsedTxs {
+				dependencies.add(requestPosition(positions, idx), tx)
+			}
+			if err := dependencies.err(); err != nil {
+				renderCreateError(c, err)
+				return
+			}
+		}
+		options.TraceParent = tracing.TraceParent(c.Request.Context())
+
+		sequenceNodeInteractor = sequenceNodeInteractor.WithContext(c.Request.Context())
+
+		if creator.cfg.CheckBalance != BalanceCheckNone {
+			balances := newBalanceChecker(creator.cfg.CheckBalance)
+			for idx, tx := range parsedTxs {
+				balances.add(requestPosition(positions, idx), tx)
+			}
+			if err := balances.err(sequenceNodeInteractor); err != nil {
+				renderCreateError(c, err)
+				return
+			}
+		}
+
+		if creator.cfg.CheckMinFees {
+			fees := newFeesChecker()
+			for idx, tx := range parsedTxs {
+				fees.add(requestPosition(positions, idx), tx)
+			}
+			if err := fees.err(sequenceNodeInteractor); err != nil {
+				renderCreateError(c, err)
 				return
 			}
-			txs = append(txs, tx)
-			txHashes[txHashString] = idx
 		}
 
-		// validate the first tx
-		validationResult, wavesErr := nodeInteractor.ValidateTx(transactions[0])
+		var firstTxHeight int32
+		var firstTxWarnings []string
+		if optionsRequest.WaitFirst {
+			firstTxHeight, err = creator.confirmFirstTx(sequenceNodeInteractor, transactions[0])
+		} else {
+			firstTxWarnings, err = creator.validateFirstTx(sequenceNodeInteractor, options, transactions[0])
+		}
+		if err != nil {
+			renderCreateError(c, err)
+			return
+		}
+
+		sequenceID, err := repo.CreateSequence(transactions, options)
+		if err != nil {
+			renderCreateError(c, err)
+			return
+		}
+
+		renderCreated(c, sequenceID, optionsRequest.WaitFirst, firstTxHeight, creator.firstTxWarnings(firstTxWarnings))
+	}
+}
+
+// createSequenceStreaming creates sequence reading txs from the request body one by one
+// txs are spooled to a temp file while the body is being read, options and the first tx are validated at the end,
+// so the node is not requested while the db transaction inserting the spooled txs is open
+func createSequenceStreaming(c *gin.Context, renderCreateError func(*gin.Context, error), repo repository.Repository, creator *sequenceCreator, body io.Reader) {
+	decoder := newCreateSequenceRequestDecoder(body)
+	source := &streamingTxsSource{
+		creator:      creator,
+		decoder:      decoder,
+		deduplicator: newTxsDeduplicator(creator.cfg.ReportAllDuplicates),
+		senders:      newSendersChecker(),
+	}
+	if creator.cfg.CheckTxDependencies {
+		source.dependencies = newDependenciesChecker()
+	}
+	if creator.cfg.CheckBalance != BalanceCheckNone {
+		source.balances = newBalanceChecker(creator.cfg.CheckBalance)
+	}
+	if creator.cfg.CheckMinFees {
+		source.fees = newFeesChecker()
+	}
+
+	spool, err := newTxsSpool()
+	if err != nil {
+		renderCreateError(c, err)
+		return
+	}
+	defer spool.close()
+
+	if err := spool.readFrom(source); err != nil {
+		renderCreateError(c, err)
+		return
+	}
+
+	if source.count == 0 {
+		renderCreateError(c, badRequest(EmptyTransactionsError()))
+		return
+	}
+
+	// streamed txs are checked and stored in the request order, so they cannot be reordered
+	if isTxsOrderSet(decoder.Options().Order) {
+		reason := fmt.Sprintf("Order is supported only for requests up to %d bytes.", creator.cfg.StreamingBodySize)
+		if c.Request.ContentLength < 0 {
+			reason = fmt.Sprintf("Order is not supported for chunked requests of more than %d bytes, they are streamed.", creator.cfg.StreamingBodySize)
+		}
+		renderCreateError(c, badRequest(InvalidParameterValue("order", reason)))
+		return
+	}
+
+	options, sequenceNodeInteractor, err := creator.sequenceOptions(decoder.Options(), source.count)
+	if err != nil {
+		renderCreateError(c, err)
+		return
+	}
+
+	if creator.requireCommonSender(decoder.Options()) {
+		if err := source.senders.err(); err != nil {
+			renderCreateError(c, err)
+			return
+		}
+	}
+	options.TraceParent = tracing.TraceParent(c.Request.Context())
+
+	sequenceNodeInteractor = sequenceNodeInteractor.WithContext(c.Request.Context())
+
+	if source.balances != nil {
+		if err := source.balances.err(sequenceNodeInteractor); err != nil {
+			renderCreateError(c, err)
+			return
+		}
+	}
+
+	if source.fees != nil {
+		if err := source.fees.err(sequenceNodeInteractor); err != nil {
+			renderCreateError(c, err)
+			return
+		}
+	}
+
+	var firstTxHeight int32
+	var firstTxWarnings []string
+	if decoder.Options().WaitFirst {
+		firstTxHeight, err = creator.confirmFirstTx(sequenceNodeInteractor, source.firstTx)
+	} else {
+		firstTxWarnings, err = creator.validateFirstTx(sequenceNodeInteractor, options, source.firstTx)
+	}
+	if err != nil {
+		renderCreateError(c, err)
+		return
+	}
+
+	sequenceID, err := repo.CreateSequenceFromSource(spool, int(creator.cfg.StreamingInsertBatchSize), func() (repository.SequenceOptions, error) {
+		return options, nil
+	})
+	if err != nil {
+		renderCreateError(c, err)
+		return
+	}
+
+	renderCreated(c, sequenceID, decoder.Options().WaitFirst, firstTxHeight, creator.firstTxWarnings(firstTxWarnings))
+}
+
+// renderCreated renders the created sequence, see createdResponse
+func renderCreated(c *gin.Context, sequenceID int64, waitFirst bool, firstTxHeight int32, firstTxWarnings []string) {
+	c.JSON(http.StatusCreated, createdResponse(requestNaming(c), sequenceID, waitFirst, firstTxHeight, firstTxWarnings))
+}
+
+func getStats(logger *zap.Logger, nodeInteractor node.Interactor, blockTimeEstimationDepth int32, clockSkewMonitor *node.ClockSkewMonitor) func(*gin.Context) {
+	return func(c *gin.Context) {
+		blockTimes, wavesErr := nodeInteractor.GetRecentBlockTimes(int(blockTimeEstimationDepth))
 		if wavesErr != nil {
-			logger.Error("cannot validate the first tx of sequence", zap.String("req_id", c.Request.Header.Get("X-Request-Id")), zap.Error(wavesErr))
+			logger.Error("cannot get recent block times", zap.String("req_id", c.Request.Header.Get("X-Request-Id")), zap.Error(wavesErr))
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"message": _internalServerErrorMessage,
 			})
 			return
 		}
-		if !validationResult.IsValid {
-			renderError(c, http.StatusBadRequest, InvalidFirstTxError(validationResult.ErrorMessage))
+
+		// the version is cached, so it is requested only until the node responds once
+		var nodeVersion *node.Version
+		if version, wavesErr := nodeInteractor.GetNodeVersion(); wavesErr == nil {
+			nodeVersion = &version
+		}
+
+		c.JSON(http.StatusOK, statsResponse(requestNaming(c), node.AverageBlockTime(blockTimes), clockSkewMonitor.Skew(), nodeVersion))
+	}
+}
+
+// defaultStatsWindow is used if the window query param is not set
+const defaultStatsWindow = time.Hour
+
+func getThroughputStats(logger *zap.Logger, renderError errorRenderer, repo repository.Repository, maxWindow time.Duration) func(*gin.Context) {
+	return func(c *gin.Context) {
+		window := defaultStatsWindow
+		if rawWindow := c.Query("window"); rawWindow != "" {
+			var err error
+			window, err = time.ParseDuration(rawWindow)
+			if err != nil || window <= 0 {
+				renderError(c, http.StatusBadRequest, InvalidParameterValue("window", "Window has to be a positive duration, e.g. 1h."))
+				return
+			}
+		}
+
+		if window > maxWindow {
+			renderError(c, http.StatusBadRequest, InvalidParameterValue("window", fmt.Sprintf("Window must not exceed %s.", maxWindow)))
 			return
 		}
 
-		sequenceID, err := repo.CreateSequence(txs)
+		throughput, err := repo.GetThroughput(window)
 		if err != nil {
-			logger.Error("cannot create sequence", zap.String("req_id", c.Request.Header.Get("X-Request-Id")), zap.Error(err))
+			logger.Error("cannot get throughput from db", zap.String("req_id", c.Request.Header.Get("X-Request-Id")), zap.Error(err))
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"message": _internalServerErrorMessage,
 			})
 			return
 		}
 
-		c.JSON(http.StatusCreated, gin.H{
-			"id": sequenceID,
-		})
+		c.JSON(http.StatusOK, throughputResponse(requestNaming(c), window, throughput))
+	}
+}
+
+// auditBlock cross-checks txs confirmed at the given height against the node block contents
+func auditBlock(logger *zap.Logger, renderError errorRenderer, repo repository.Repository, nodeInteractor node.Interactor) func(*gin.Context) {
+	return func(c *gin.Context) {
+		height, err := strconv.ParseInt(c.Param("height"), 10, 32)
+		if err != nil || height <= 0 {
+			renderError(c, http.StatusBadRequest, InvalidParameterValue("height", "Height has to be a positive number."))
+			return
+		}
+
+		confirmedTxs, err := repo.GetConfirmedTxsByHeight(int32(height))
+		if err != nil {
+			logger.Error("cannot get confirmed txs from db", zap.String("req_id", c.Request.Header.Get("X-Request-Id")), zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": _internalServerErrorMessage,
+			})
+			return
+		}
+
+		blockTxIDs, wavesErr := nodeInteractor.GetBlockTransactions(int32(height))
+		if wavesErr != nil {
+			logger.Error("cannot get block txs", zap.String("req_id", c.Request.Header.Get("X-Request-Id")), zap.Error(wavesErr))
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": _internalServerErrorMessage,
+			})
+			return
+		}
+
+		inBlock := make(map[string]bool, len(blockTxIDs))
+		for _, txID := range blockTxIDs {
+			inBlock[txID] = true
+		}
+
+		confirmed := []string{}
+		missing := []string{}
+		for _, tx := range confirmedTxs {
+			if inBlock[tx.ID] {
+				confirmed = append(confirmed, tx.ID)
+			} else {
+				missing = append(missing, tx.ID)
+			}
+		}
+
+		c.JSON(http.StatusOK, auditBlockResponse(requestNaming(c), int32(height), len(blockTxIDs), confirmed, missing))
+	}
+}
+
+// getTxStatusRaw returns the node tx status response as is, it helps to diagnose status parsing issues
+func getTxStatusRaw(logger *zap.Logger, nodeInteractor node.Interactor) func(*gin.Context) {
+	return func(c *gin.Context) {
+		raw, wavesErr := nodeInteractor.GetTxStatusRaw(c.Param("id"))
+		if wavesErr != nil {
+			logger.Error("cannot get raw tx status", zap.String("req_id", c.Request.Header.Get("X-Request-Id")), zap.Error(wavesErr))
+			c.JSON(http.StatusBadGateway, gin.H{
+				"message": wavesErr.Error(),
+			})
+			return
+		}
+
+		c.Data(http.StatusOK, "application/json", raw)
+	}
+}
+
+// getConfig renders the effective config the app was started with, secrets are expected to be redacted already
+// the detected node version is rendered along with the config if the node has responded with it
+func getConfig(effectiveConfig map[string]interface{}, nodeInteractor node.Interactor) func(*gin.Context) {
+	return func(c *gin.Context) {
+		version, wavesErr := nodeInteractor.GetNodeVersion()
+		if wavesErr != nil {
+			c.JSON(http.StatusOK, effectiveConfig)
+			return
+		}
+
+		response := make(map[string]interface{}, len(effectiveConfig)+1)
+		for k, v := range effectiveConfig {
+			response[k] = v
+		}
+		response["node_version"] = version.String()
+
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+func getSequenceTx(logger *zap.Logger, renderError errorRenderer, repo repository.Repository, timestampFormat repository.TimeFormat) func(*gin.Context) {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			renderError(c, http.StatusBadRequest, InvalidParameterValue("id", fmt.Sprintf("Error occured while parsing id: %s.", err.Error())))
+			return
+		}
+
+		position, err := strconv.ParseInt(c.Param("position"), 10, 16)
+		if err != nil || position < 0 {
+			renderError(c, http.StatusBadRequest, InvalidParameterValue("position", "Position has to be a non-negative number."))
+			return
+		}
+
+		tx, err := repo.GetSequenceTx(id, int16(position))
+		if err != nil {
+			logger.Error("cannot get sequence tx from db", zap.String("req_id", c.Request.Header.Get("X-Request-Id")), zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": _internalServerErrorMessage,
+			})
+			return
+		}
+
+		if tx == nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "Transaction not found",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, sequenceTxResponse(requestNaming(c), tx, timestampFormat))
+	}
+}
+
+// skipSequenceTx cancels the not broadcasted tx of the running independent sequence, the rest of txs are processed as usual
+// txs of other sequences depend on the preceding ones, so they cannot be skipped
+func skipSequenceTx(logger *zap.Logger, renderError errorRenderer, repo repository.Repository) func(*gin.Context) {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			renderError(c, http.StatusBadRequest, InvalidParameterValue("id", fmt.Sprintf("Error occured while parsing id: %s.", err.Error())))
+			return
+		}
+
+		position, err := strconv.ParseInt(c.Param("position"), 10, 16)
+		if err != nil || position < 0 {
+			renderError(c, http.StatusBadRequest, InvalidParameterValue("position", "Position has to be a non-negative number."))
+			return
+		}
+
+		sequence, err := repo.GetSequenceByID(id)
+		if err != nil {
+			logger.Error("cannot get sequence from db", zap.String("req_id", c.Request.Header.Get("X-Request-Id")), zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": _internalServerErrorMessage,
+			})
+			return
+		}
+
+		if sequence == nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "Sequence not found",
+			})
+			return
+		}
+
+		if sequence.Mode != repository.SequenceModeIndependent {
+			renderError(c, http.StatusBadRequest, InvalidParameterValue("id", "Only txs of independent sequences can be skipped."))
+			return
+		}
+
+		if sequence.State != repository.StatePending && sequence.State != repository.StateProcessing {
+			c.JSON(http.StatusConflict, gin.H{
+				"message": "Sequence is already finished",
+			})
+			return
+		}
+
+		skipped, err := repo.SkipSequenceTx(id, int16(position))
+		if err != nil {
+			logger.Error("cannot skip sequence tx", zap.String("req_id", c.Request.Header.Get("X-Request-Id")), zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": _internalServerErrorMessage,
+			})
+			return
+		}
+
+		if !skipped {
+			if int(position) >= int(sequence.TotalCount) {
+				c.JSON(http.StatusNotFound, gin.H{
+					"message": "Transaction not found",
+				})
+				return
+			}
+
+			c.JSON(http.StatusConflict, gin.H{
+				"message": "Transaction is not pending, it may have been broadcasted",
+			})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// skipSequenceTo makes the stuck processing or failed sequence continue from the tx at the position, see repository.SkipSequenceTo
+// the sequence is pending again, the worker still processing it stops before its next broadcast
+func skipSequenceTo(logger *zap.Logger, renderError errorRenderer, repo repository.Repository) func(*gin.Context) {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			renderError(c, http.StatusBadRequest, InvalidParameterValue("id", fmt.Sprintf("Error occured while parsing id: %s.", err.Error())))
+			return
+		}
+
+		position, err := strconv.ParseInt(c.Param("position"), 10, 16)
+		if err != nil || position < 0 {
+			renderError(c, http.StatusBadRequest, InvalidParameterValue("position", "Position has to be a non-negative number."))
+			return
+		}
+
+		sequence, err := repo.GetSequenceByID(id)
+		if err != nil {
+			logger.Error("cannot get sequence from db", zap.String("req_id", c.Request.Header.Get("X-Request-Id")), zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": _internalServerErrorMessage,
+			})
+			return
+		}
+
+		if sequence == nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "Sequence not found",
+			})
+			return
+		}
+
+		if sequence.Mode == repository.SequenceModeValidateOnly {
+			renderError(c, http.StatusBadRequest, InvalidParameterValue("id", "Txs of validate_only sequences are not confirmed."))
+			return
+		}
+
+		if int(position) >= int(sequence.TotalCount) {
+			renderError(c, http.StatusBadRequest, InvalidParameterValue("position", fmt.Sprintf("Position has to be less than the count of sequence txs %d.", sequence.TotalCount)))
+			return
+		}
+
+		if sequence.State != repository.StateProcessing && sequence.State != repository.StateError {
+			c.JSON(http.StatusConflict, gin.H{
+				"message": "Sequence is neither processing nor failed",
+			})
+			return
+		}
+
+		skipped, err := repo.SkipSequenceTo(id, int16(position))
+		if err != nil {
+			logger.Error("cannot skip sequence txs", zap.String("req_id", c.Request.Header.Get("X-Request-Id")), zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": _internalServerErrorMessage,
+			})
+			return
+		}
+
+		if !skipped {
+			c.JSON(http.StatusConflict, gin.H{
+				"message": "Sequence state was changed concurrently",
+			})
+			return
+		}
+
+		logger.Info("sequence is skipped to the position", zap.String("req_id", c.Request.Header.Get("X-Request-Id")), zap.Int64("sequence_id", id), zap.Int64("position_in_sequence", position))
+
+		c.Status(http.StatusNoContent)
 	}
 }
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/wavesplatform/transaction-broadcaster/internal/node"
+	"github.com/wavesplatform/transaction-broadcaster/internal/repository"
+)
+
+// sequenceRepo keeps the single sequence and states of its txs
+type sequenceRepo struct {
+	*fakeRepo
+
+	sequence *repository.Sequence
+	txs      []*repository.SequenceTx
+}
+
+func newSequenceRepo(mode repository.SequenceMode, states ...repository.TransactionState) *sequenceRepo {
+	r := &sequenceRepo{
+		fakeRepo: newFakeRepo(),
+		sequence: &repository.Sequence{ID: 1, State: repository.StateProcessing, Mode: mode, TotalCount: uint32(len(states))},
+	}
+	for i, state := range states {
+		r.txs = append(r.txs, &repository.SequenceTx{SequenceID: 1, PositionInSequence: int16(i), State: state})
+	}
+	return r
+}
+
+func (r *sequenceRepo) GetSequenceByID(id int64) (*repository.Sequence, error) {
+	if id != r.sequence.ID {
+		return nil, nil
+	}
+	return r.sequence, nil
+}
+
+func (r *sequenceRepo) SkipSequenceTx(sequenceID int64, positionInSequence int16) (bool, error) {
+	if sequenceID != r.sequence.ID || int(positionInSequence) >= len(r.txs) {
+		return false, nil
+	}
+	tx := r.txs[positionInSequence]
+	if tx.State != repository.TransactionStatePending || tx.ID != "" {
+		return false, nil
+	}
+	tx.State = repository.TransactionStateSkipped
+	return true, nil
+}
+
+func TestSkipSequenceTx(t *testing.T) {
+	repo := newSequenceRepo(repository.SequenceModeIndependent, repository.TransactionStateUnconfirmed, repository.TransactionStatePending)
+	h := newTestAPI(Config{}, repo, node.NewFakeInteractor(nil))
+
+	w := serve(h, http.MethodDelete, "/sequences/1/transactions/1", "", true)
+	require.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+	require.Equal(t, repository.TransactionStateSkipped, repo.txs[1].State)
+
+	// the broadcasted tx cannot be skipped
+	w = serve(h, http.MethodDelete, "/sequences/1/transactions/0", "", true)
+	require.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+	require.Equal(t, repository.TransactionStateUnconfirmed, repo.txs[0].State)
+
+	w = serve(h, http.MethodDelete, "/sequences/1/transactions/2", "", true)
+	require.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+
+	w = serve(h, http.MethodDelete, "/sequences/2/transactions/0", "", true)
+	require.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+
+	w = serve(h, http.MethodDelete, "/sequences/1/transactions/-1", "", true)
+	require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+}
+
+func TestSkipSequenceTxOfDependentSequence(t *testing.T) {
+	for _, mode := range []repository.SequenceMode{repository.SequenceModeBroadcast, repository.SequenceModeValidateOnly} {
+		repo := newSequenceRepo(mode, repository.TransactionStatePending)
+		h := newTestAPI(Config{}, repo, node.NewFakeInteractor(nil))
+
+		w := serve(h, http.MethodDelete, "/sequences/1/transactions/0", "", true)
+		require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+		require.Equal(t, repository.TransactionStatePending, repo.txs[0].State)
+	}
+}
+
+func TestSkipSequenceTxOfFinishedSequence(t *testing.T) {
+	repo := newSequenceRepo(repository.SequenceModeIndependent, repository.TransactionStatePending)
+	repo.sequence.State = repository.StateDone
+	h := newTestAPI(Config{}, repo, node.NewFakeInteractor(nil))
+
+	w := serve(h, http.MethodDelete, "/sequences/1/transactions/0", "", true)
+	require.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+}
+
+func (r *sequenceRepo) SkipSequenceTo(sequenceID int64, positionInSequence int16) (bool, error) {
+	if r.sequence.State != repository.StateProcessing && r.sequence.State != repository.StateError {
+		return false, nil
+	}
+	for _, tx := range r.txs[:positionInSequence] {
+		if tx.ID == "" {
+			tx.State = repository.TransactionStateSkipped
+		}
+	}
+	r.sequence.State = repository.StatePending
+	return true, nil
+}
+
+func TestSkipSequenceTo(t *testing.T) {
+	repo := newSequenceRepo(repository.SequenceModeBroadcast, repository.TransactionStateError, repository.TransactionStatePending)
+	repo.sequence.State = repository.StateError
+	h := newTestAPI(Config{AdminAPIKey: "secret"}, repo, node.NewFakeInteractor(nil))
+
+	skipTo := func(path string) int {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		req.Header.Set("X-API-Key", "secret")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	require.Equal(t, http.StatusBadRequest, skipTo("/admin/sequences/1/skip-to/2"))
+	require.Equal(t, http.StatusNotFound, skipTo("/admin/sequences/2/skip-to/1"))
+
+	require.Equal(t, http.StatusNoContent, skipTo("/admin/sequences/1/skip-to/1"))
+	require.Equal(t, repository.StatePending, repo.sequence.State)
+	require.Equal(t, repository.TransactionStateSkipped, repo.txs[0].State)
+	require.Equal(t, repository.TransactionStatePending, repo.txs[1].State)
+
+	// the pending sequence is not stuck
+	require.Equal(t, http.StatusConflict, skipTo("/admin/sequences/1/skip-to/1"))
+
+	repo.sequence.State = repository.StateProcessing
+	repo.sequence.Mode = repository.SequenceModeValidateOnly
+	require.Equal(t, http.StatusBadRequest, skipTo("/admin/sequences/1/skip-to/1"))
+}
@@ -0,0 +1,515 @@
+package api
+
+import (
+	"bufio"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+	"unicode/utf8"
+
+	"github.com/wavesplatform/transaction-broadcaster/internal/node"
+	"github.com/wavesplatform/transaction-broadcaster/internal/repository"
+)
+
+// requestError is an error which has to be rendered to the client with the given status
+type requestError struct {
+	status int
+	err    Error
+}
+
+func (e *requestError) Error() string {
+	return e.err.Error()
+}
+
+func badRequest(err Error) error {
+	return &requestError{status: http.StatusBadRequest, err: err}
+}
+
+// maxLabelLength is max length of the sequence label in characters
+const maxLabelLength = 64
+
+// sequenceCreator validates create sequence requests
+type sequenceCreator struct {
+	cfg                   Config
+	nodeInteractor        node.Interactor
+	nodeInteractorFactory node.InteractorFactory
+	allowedNodeURLs       []string
+}
+
+// sequenceOptions validates request options of the sequence of txsCount txs
+// returns repository options and node interactor the sequence has to be validated with
+func (sc *sequenceCreator) sequenceOptions(options sequenceOptionsRequest, txsCount int) (repository.SequenceOptions, node.Interactor, error) {
+	// sequence may be processed by the node other than default one
+	sequenceNodeInteractor := sc.nodeInteractor
+	if options.NodeURL != "" {
+		nodeURL, err := url.Parse(options.NodeURL)
+		if err != nil || nodeURL.Scheme == "" || nodeURL.Host == "" {
+			return repository.SequenceOptions{}, nil, badRequest(InvalidParameterValue("nodeUrl", "Invalid node url."))
+		}
+
+		if !node.IsURLAllowed(*nodeURL, sc.allowedNodeURLs) {
+			return repository.SequenceOptions{}, nil, badRequest(InvalidParameterValue("nodeUrl", "Node url is not allowed."))
+		}
+
+		sequenceNodeInteractor = sc.nodeInteractorFactory(*nodeURL)
+	}
+
+	var deadline time.Time
+	if options.Deadline != 0 {
+		deadline = time.Unix(0, options.Deadline*int64(time.Millisecond))
+		if !deadline.After(time.Now()) {
+			return repository.SequenceOptions{}, nil, badRequest(InvalidParameterValue("deadline", "Deadline has already passed."))
+		}
+	}
+
+	if options.TxOutdateTime < 0 {
+		return repository.SequenceOptions{}, nil, badRequest(InvalidParameterValue("txOutdateTime", "Tx outdate time has to be a positive number."))
+	}
+
+	if options.BroadcastInterval < 0 {
+		return repository.SequenceOptions{}, nil, badRequest(InvalidParameterValue("broadcastInterval", "Broadcast interval has to be a positive number."))
+	}
+
+	if len(options.Confirmations) > txsCount {
+		return repository.SequenceOptions{}, nil, badRequest(InvalidParameterValue("confirmations", "There are more confirmations than transactions."))
+	}
+
+	for _, confirmations := range options.Confirmations {
+		if confirmations < 0 {
+			return repository.SequenceOptions{}, nil, badRequest(InvalidParameterValue("confirmations", "Confirmations have to be non-negative numbers."))
+		}
+	}
+
+	if utf8.RuneCountInString(options.Label) > maxLabelLength {
+		return repository.SequenceOptions{}, nil, badRequest(InvalidParameterValue("label", fmt.Sprintf("Label has to be at most %d characters long.", maxLabelLength)))
+	}
+
+	if options.SkipValidation && !sc.cfg.AllowSkipValidation {
+		return repository.SequenceOptions{}, nil, badRequest(InvalidParameterValue("skipValidation", "Skipping validation is not allowed."))
+	}
+
+	var mode repository.SequenceMode
+	if err := mode.UnmarshalText([]byte(options.Mode)); err != nil {
+		return repository.SequenceOptions{}, nil, badRequest(InvalidParameterValue("mode", "Unknown sequence mode."))
+	}
+
+	if mode == repository.SequenceModeValidateOnly && options.SkipValidation {
+		return repository.SequenceOptions{}, nil, badRequest(InvalidParameterValue("skipValidation", "Validation cannot be skipped in validate_only mode."))
+	}
+
+	if mode == repository.SequenceModeValidateOnly && options.WaitFirst {
+		return repository.SequenceOptions{}, nil, badRequest(InvalidParameterValue("waitFirst", "Txs are not broadcasted in validate_only mode."))
+	}
+
+	if options.PerTxCallbackURL != "" {
+		callbackURL, err := url.Parse(options.PerTxCallbackURL)
+		if err != nil || (callbackURL.Scheme != "http" && callbackURL.Scheme != "https") || callbackURL.Host == "" {
+			return repository.SequenceOptions{}, nil, badRequest(InvalidParameterValue("perTxCallbackUrl", "Callback url has to be an absolute http(s) url."))
+		}
+	}
+
+	if options.RetentionSeconds < 0 {
+		return repository.SequenceOptions{}, nil, badRequest(InvalidParameterValue("retentionSeconds", "Retention has to be a non-negative number."))
+	}
+
+	return repository.SequenceOptions{
+		NodeURL:        options.NodeURL,
+		SkipValidation: options.SkipValidation,
+		Deadline:       deadline,
+		TxOutdateTime:  options.TxOutdateTime,
+		Confirmations:  options.Confirmations,
+		Label:          options.Label,
+
+		BroadcastInterval: options.BroadcastInterval,
+		Mode:              mode,
+		PerTxCallbackURL:  options.PerTxCallbackURL,
+		RetentionSeconds:  options.RetentionSeconds,
+	}, sequenceNodeInteractor, nil
+}
+
+// firstTxWarnings returns warnings of the first tx to be rendered in the create response, they are not rendered unless enabled
+func (sc *sequenceCreator) firstTxWarnings(warnings []string) []string {
+	if !sc.cfg.ReturnValidationWarnings {
+		return nil
+	}
+	return warnings
+}
+
+// validateFirstTx validates the first tx of the sequence, trusted pre-validated sequences are not validated at all
+// returns warnings the node reported for the valid tx
+func (sc *sequenceCreator) validateFirstTx(nodeInteractor node.Interactor, options repository.SequenceOptions, tx string) ([]string, error) {
+	if options.SkipValidation {
+		return nil, nil
+	}
+
+	validationResult, wavesErr := nodeInteractor.ValidateTx(tx)
+	if wavesErr != nil {
+		return nil, wavesErr
+	}
+
+	if !validationResult.IsValid {
+		// the sequence is re-submitted after its first tx was confirmed, the worker confirms the tx without broadcasting it
+		if txID, _ := node.AlreadyInState(validationResult.ErrorMessage); txID != "" && sc.cfg.AcceptConfirmedFirstTx {
+			return nil, nil
+		}
+
+		return nil, badRequest(InvalidFirstTxError(NodeError{
+			Code:    validationResult.ErrorCode,
+			Message: validationResult.ErrorMessage,
+			Trace:   validationResult.Trace,
+		}))
+	}
+
+	return validationResult.Warnings, nil
+}
+
+// confirmFirstTx broadcasts the first tx of the sequence and waits for its confirmation, returns its height
+// the tx is validated by the broadcast, it is not an error if the tx is already in the blockchain, so the request can be retried
+// the rest of the sequence is processed as usual, the worker treats the first tx as already broadcasted one
+func (sc *sequenceCreator) confirmFirstTx(nodeInteractor node.Interactor, tx string) (int32, error) {
+	txID, wavesErr := nodeInteractor.BroadcastTx(tx)
+	if wavesErr != nil {
+		txID, _ = node.AlreadyInState(wavesErr.Error())
+		if txID == "" && wavesErr.Code() == node.BroadcastClientError {
+			return 0, badRequest(InvalidFirstTxError(NodeError{Code: wavesErr.NodeErrorCode(), Message: wavesErr.Error()}))
+		}
+		if txID == "" {
+			return 0, wavesErr
+		}
+	}
+
+	height, wavesErr := nodeInteractor.WaitForTxStatus(txID, node.TransactionStatusConfirmed, sc.cfg.WaitFirstTimeout)
+	if wavesErr != nil {
+		// the tx may still be confirmed, so the request can be retried
+		if wavesErr.Code() == node.WaitForTxStatusTimeoutError {
+			return 0, &requestError{status: http.StatusGatewayTimeout, err: FirstTxNotConfirmedError(wavesErr.Error())}
+		}
+		return 0, wavesErr
+	}
+
+	return height, nil
+}
+
+// checkNodeSync returns 503 error if the default node is not synced, so clients back off instead of piling up pending sequences
+// the node is considered not synced if its status is not available either
+func (sc *sequenceCreator) checkNodeSync() error {
+	status, wavesErr := sc.nodeInteractor.GetNodeStatus()
+	if wavesErr != nil {
+		return &requestError{status: http.StatusServiceUnavailable, err: NodeNotSyncedError(wavesErr.Error())}
+	}
+	if !status.IsSynced(sc.cfg.NodeSyncMaxLag) {
+		reason := fmt.Sprintf("State height is %d, blockchain height is %d, last update at %d.", status.StateHeight, status.BlockchainHeight, status.UpdatedTimestamp)
+		return &requestError{status: http.StatusServiceUnavailable, err: NodeNotSyncedError(reason)}
+	}
+	return nil
+}
+
+// checkQueueDepth returns 429 error if there are too many pending and processing sequences of all instances
+func (sc *sequenceCreator) checkQueueDepth(repo repository.Repository) error {
+	if sc.cfg.MaxQueueDepth <= 0 {
+		return nil
+	}
+
+	depth, err := repo.CountSequencesInStates([]repository.State{repository.StatePending, repository.StateProcessing})
+	if err != nil {
+		return err
+	}
+	if depth >= sc.cfg.MaxQueueDepth {
+		reason := fmt.Sprintf("There are %d pending and processing sequences, the limit is %d.", depth, sc.cfg.MaxQueueDepth)
+		return &requestError{status: http.StatusTooManyRequests, err: QueueFullError(reason)}
+	}
+	return nil
+}
+
+// checkTxsCount returns an error if count of the sequence txs exceeds the configured max count
+func (sc *sequenceCreator) checkTxsCount(count int) error {
+	maxTxs := sc.cfg.MaxSequenceTxs
+	if maxTxs <= 0 || maxTxs > repository.MaxSequenceTxs {
+		maxTxs = repository.MaxSequenceTxs
+	}
+
+	if count > maxTxs {
+		return badRequest(InvalidParameterValue("transactions", fmt.Sprintf("There are more than %d transactions.", maxTxs)))
+	}
+	return nil
+}
+
+// checkTxSize returns an error if the tx at the position idx is bigger than the configured max size
+// oversized txs otherwise fail the whole insert with a cryptic db error
+func (sc *sequenceCreator) checkTxSize(idx int, tx string) error {
+	if sc.cfg.MaxTxSize > 0 && len(tx) > sc.cfg.MaxTxSize {
+		return badRequest(InvalidParameterValue("transactions", fmt.Sprintf("Transaction at position %d exceeds %d bytes.", idx, sc.cfg.MaxTxSize)))
+	}
+	return nil
+}
+
+// checkTxTimestamp returns an error if the tx at the position idx is dated later than the configured max future time
+// the node rejects such txs only on broadcast, not parsed txs (nil) and txs without timestamp are left to the node validation
+func (sc *sequenceCreator) checkTxTimestamp(idx int, tx *node.Tx) error {
+	if sc.cfg.MaxTxFutureTime <= 0 || tx == nil || tx.Timestamp == 0 {
+		return nil
+	}
+
+	timestamp := time.Unix(0, tx.Timestamp*int64(time.Millisecond))
+	if ahead := time.Until(timestamp); ahead > sc.cfg.MaxTxFutureTime {
+		return badRequest(InvalidParameterValue("transactions", fmt.Sprintf("Transaction at position %d is dated %s ahead, max is %s.", idx, ahead.Round(time.Second), sc.cfg.MaxTxFutureTime)))
+	}
+	return nil
+}
+
+// checkTxVersion returns an error if the version of the tx at the position idx is lower than the configured min version
+// txs without version are the first version txs, not parsed txs (nil) are left to the node validation
+func (sc *sequenceCreator) checkTxVersion(idx int, tx *node.Tx) error {
+	if sc.cfg.MinTxVersion <= 0 || tx == nil {
+		return nil
+	}
+
+	if version := tx.TxVersion(); version < sc.cfg.MinTxVersion {
+		return badRequest(InvalidParameterValue("transactions", fmt.Sprintf("Transaction at position %d has version %d, min version is %d.", idx, version, sc.cfg.MinTxVersion)))
+	}
+	return nil
+}
+
+// txsDeduplicator checks txs uniqueness within the request
+// by default the first duplicate pair is reported, if reportAll is set all duplicate groups are collected
+type txsDeduplicator struct {
+	txHashes  map[string]int
+	reportAll bool
+	// groups are indices of duplicates by the index of their first occurrence
+	groups      map[int][]int
+	groupsOrder []int
+}
+
+func newTxsDeduplicator(reportAll bool) *txsDeduplicator {
+	return &txsDeduplicator{
+		txHashes:  make(map[string]int),
+		reportAll: reportAll,
+		groups:    make(map[int][]int),
+	}
+}
+
+// add remembers tx at the position idx, returns an error if the same tx was already added and not all duplicates are reported
+func (d *txsDeduplicator) add(idx int, tx string) error {
+	txHash := md5.Sum([]byte(tx))
+	txHashString := hex.EncodeToString(txHash[:])
+	if firstIdx, ok := d.txHashes[txHashString]; ok {
+		if !d.reportAll {
+			return badRequest(TxsDuplicatesError(errorDetails{
+				"duplicates": []int{firstIdx, idx},
+			}))
+		}
+
+		if _, ok := d.groups[firstIdx]; !ok {
+			d.groups[firstIdx] = []int{firstIdx}
+			d.groupsOrder = append(d.groupsOrder, firstIdx)
+		}
+		d.groups[firstIdx] = append(d.groups[firstIdx], idx)
+		return nil
+	}
+	d.txHashes[txHashString] = idx
+	return nil
+}
+
+// err returns an error with all collected duplicate groups, groups are ordered by the detection of their first duplicate
+func (d *txsDeduplicator) err() error {
+	if len(d.groupsOrder) == 0 {
+		return nil
+	}
+
+	groups := make([][]int, 0, len(d.groupsOrder))
+	for _, firstIdx := range d.groupsOrder {
+		groups = append(groups, d.groups[firstIdx])
+	}
+
+	return badRequest(TxsDuplicatesError(errorDetails{
+		"duplicates": groups,
+	}))
+}
+
+// requireCommonSender returns whether all txs of the requested sequence have to have the same sender
+func (sc *sequenceCreator) requireCommonSender(options sequenceOptionsRequest) bool {
+	return sc.cfg.RequireCommonSender || options.CommonSender
+}
+
+// sendersChecker remembers the first tx whose sender differs from the sender of the first tx
+// txs are added in the sequence order, idx is the position reported by the error
+type sendersChecker struct {
+	added       bool
+	sender      string
+	mismatchIdx int
+}
+
+func newSendersChecker() *sendersChecker {
+	return &sendersChecker{mismatchIdx: -1}
+}
+
+// add checks the tx at the position idx, not parsed txs (nil) have no known sender, so they mismatch
+func (c *sendersChecker) add(idx int, tx *node.Tx) {
+	if c.mismatchIdx >= 0 {
+		return
+	}
+
+	if tx == nil {
+		c.mismatchIdx = idx
+		return
+	}
+
+	if !c.added {
+		c.added = true
+		c.sender = tx.SenderPublicKey
+	} else if tx.SenderPublicKey != c.sender {
+		c.mismatchIdx = idx
+	}
+}
+
+// err returns an error pointing to the first tx with another sender
+func (c *sendersChecker) err() error {
+	if c.mismatchIdx < 0 {
+		return nil
+	}
+	return badRequest(InvalidParameterValue(fmt.Sprintf("transactions[%d]", c.mismatchIdx), "Transaction sender differs from the sender of the first transaction."))
+}
+
+// txsSpool keeps streamed txs in a temp file, so large sequences are neither buffered in memory
+// nor inserted by the db transaction which is open until the whole request is read
+type txsSpool struct {
+	file    *os.File
+	writer  *bufio.Writer
+	decoder *json.Decoder
+}
+
+func newTxsSpool() (*txsSpool, error) {
+	file, err := os.CreateTemp("", "sequence-txs-")
+	if err != nil {
+		return nil, err
+	}
+
+	writer := bufio.NewWriter(file)
+	return &txsSpool{file: file, writer: writer}, nil
+}
+
+// readFrom spools all txs of source
+func (s *txsSpool) readFrom(source repository.TxsSource) error {
+	encoder := json.NewEncoder(s.writer)
+	for {
+		tx, err := source.Next()
+		if err == io.EOF {
+			return s.writer.Flush()
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := encoder.Encode(tx); err != nil {
+			return err
+		}
+	}
+}
+
+// Next returns the spooled txs one by one from the first one
+func (s *txsSpool) Next() (string, error) {
+	if s.decoder == nil {
+		if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+			return "", err
+		}
+		s.decoder = json.NewDecoder(bufio.NewReader(s.file))
+	}
+
+	var tx string
+	if err := s.decoder.Decode(&tx); err != nil {
+		return "", err
+	}
+	return tx, nil
+}
+
+// close removes the spool file
+func (s *txsSpool) close() {
+	s.file.Close()
+	os.Remove(s.file.Name())
+}
+
+// streamingTxsSource reads txs from the request decoder checking their size and uniqueness
+type streamingTxsSource struct {
+	creator      *sequenceCreator
+	decoder      *createSequenceRequestDecoder
+	deduplicator *txsDeduplicator
+	// senders are checked for all requests, because options may follow txs
+	senders *sendersChecker
+	// dependencies and balances are checked only if it is configured
+	dependencies *dependenciesChecker
+	balances     *balanceChecker
+	fees         *feesChecker
+	count        int
+	firstTx      string
+}
+
+func (s *streamingTxsSource) Next() (string, error) {
+	tx, err := s.decoder.NextTransaction()
+	if err == io.EOF {
+		if err := s.deduplicator.err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	if err != nil {
+		var envErr *envelopeError
+		if errors.As(err, &envErr) {
+			return "", badRequest(envErr.apiError())
+		}
+		return "", badRequest(InvalidParameterValue("transactions", "Invalid request."))
+	}
+
+	if err := s.creator.checkTxsCount(s.count + 1); err != nil {
+		return "", err
+	}
+
+	if err := s.creator.checkTxSize(s.count, tx); err != nil {
+		return "", err
+	}
+
+	// the tx is parsed once for all checks, not parsable one is nil and left to the node validation
+	parsedTx, _ := node.ParseTx(tx)
+
+	if err := s.creator.checkTxTimestamp(s.count, parsedTx); err != nil {
+		return "", err
+	}
+
+	if err := s.creator.checkTxVersion(s.count, parsedTx); err != nil {
+		return "", err
+	}
+
+	if err := s.deduplicator.add(s.count, tx); err != nil {
+		return "", err
+	}
+
+	s.senders.add(s.count, parsedTx)
+
+	if s.dependencies != nil {
+		s.dependencies.add(s.count, parsedTx)
+		if err := s.dependencies.err(); err != nil {
+			return "", err
+		}
+	}
+
+	if s.balances != nil {
+		s.balances.add(s.count, parsedTx)
+	}
+
+	if s.fees != nil {
+		s.fees.add(s.count, parsedTx)
+	}
+
+	if s.count == 0 {
+		s.firstTx = tx
+	}
+	s.count++
+
+	return tx, nil
+}
@@ -0,0 +1,328 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/wavesplatform/transaction-broadcaster/internal/node"
+	"github.com/wavesplatform/transaction-broadcaster/internal/repository"
+)
+
+func newTestSequenceCreator(cfg Config) *sequenceCreator {
+	return &sequenceCreator{
+		cfg:            cfg,
+		nodeInteractor: node.NewFakeInteractor(nil),
+	}
+}
+
+func TestSequenceOptionsLabelLength(t *testing.T) {
+	sc := newTestSequenceCreator(Config{})
+
+	// multibyte characters are counted as single ones
+	_, _, err := sc.sequenceOptions(sequenceOptionsRequest{Label: strings.Repeat("ж", maxLabelLength)}, 1)
+	require.NoError(t, err)
+
+	_, _, err = sc.sequenceOptions(sequenceOptionsRequest{Label: strings.Repeat("ж", maxLabelLength+1)}, 1)
+	require.Error(t, err)
+}
+
+func TestCreateSequenceMaxTxs(t *testing.T) {
+	repo := newFakeRepo()
+	h := newTestAPI(Config{MaxSequenceTxs: 2, StreamingBodySize: 1 << 20}, repo, node.NewFakeInteractor(nil))
+
+	for _, knownSize := range []bool{true, false} {
+		w := serve(h, http.MethodPost, "/sequences", `{"transactions":[{"id":"1"},{"id":"2"},{"id":"3"}]}`, knownSize)
+		require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+		require.Contains(t, w.Body.String(), "There are more than 2 transactions.")
+
+		w = serve(h, http.MethodPost, "/sequences", `{"transactions":[{"id":"1"},{"id":"2"}]}`, knownSize)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	}
+	require.Len(t, repo.sequences, 2)
+}
+
+func TestCreateSequenceBuffersSmallBodyOfUnknownSize(t *testing.T) {
+	repo := newFakeRepo()
+	h := newTestAPI(Config{StreamingBodySize: 1 << 20}, repo, node.NewFakeInteractor(nil))
+
+	// order is supported only by the buffered path
+	w := serve(h, http.MethodPost, "/sequences", `{"transactions":[{"id":"1"},{"id":"2"}],"order":"reverse"}`, false)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	require.Equal(t, []string{`{"id":"2"}`, `{"id":"1"}`}, repo.sequences[1])
+}
+
+func TestCreateSequenceStreamingValidatesOutsideTransaction(t *testing.T) {
+	repo := newFakeRepo()
+	nodeInteractor := &txCheckingInteractor{Interactor: node.NewFakeInteractor(nil), repo: repo}
+	h := newTestAPI(Config{StreamingBodySize: 10, StreamingInsertBatchSize: 1}, repo, nodeInteractor)
+
+	txs := []string{`{"id":"1","attachment":"<a&b>"}`, `{"id":"2","data":"line\nbreak"}`}
+	w := serve(h, http.MethodPost, "/sequences", `{"transactions":[`+strings.Join(txs, ",")+`]}`, false)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	require.Equal(t, 1, nodeInteractor.requestedCount)
+	require.False(t, nodeInteractor.requestedInTx, "first tx is validated inside the db transaction")
+	require.Equal(t, txs, repo.sequences[1])
+}
+
+func TestCreateSequenceStreamingConfirmsFirstTxOutsideTransaction(t *testing.T) {
+	repo := newFakeRepo()
+	nodeInteractor := &txCheckingInteractor{Interactor: node.NewFakeInteractor(nil), repo: repo}
+	h := newTestAPI(Config{StreamingBodySize: 10, WaitFirstTimeout: time.Second}, repo, nodeInteractor)
+
+	w := serve(h, http.MethodPost, "/sequences", `{"transactions":[{"id":"1"},{"id":"2"}],"waitFirst":true}`, false)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	require.Contains(t, w.Body.String(), `"first_tx_height":`)
+
+	require.Equal(t, 1, nodeInteractor.requestedCount)
+	require.False(t, nodeInteractor.requestedInTx, "first tx is confirmed inside the db transaction")
+	require.Len(t, repo.sequences, 1)
+}
+
+func TestCheckTxTimestamp(t *testing.T) {
+	sc := newTestSequenceCreator(Config{MaxTxFutureTime: time.Hour})
+
+	millis := func(t time.Time) int64 {
+		return t.UnixNano() / int64(time.Millisecond)
+	}
+
+	require.NoError(t, sc.checkTxTimestamp(0, &node.Tx{Timestamp: millis(time.Now().Add(-24 * time.Hour))}))
+	require.NoError(t, sc.checkTxTimestamp(0, &node.Tx{Timestamp: millis(time.Now())}))
+	require.NoError(t, sc.checkTxTimestamp(0, &node.Tx{Timestamp: millis(time.Now().Add(30 * time.Minute))}))
+
+	err := sc.checkTxTimestamp(3, &node.Tx{Timestamp: millis(time.Now().Add(2*time.Hour + time.Minute))})
+	requireInvalidParameter(t, err, "transactions", "Transaction at position 3 is dated 2h1m0s ahead, max is 1h0m0s.")
+
+	// txs without timestamp are left to the node
+	require.NoError(t, sc.checkTxTimestamp(0, &node.Tx{}))
+	require.NoError(t, sc.checkTxTimestamp(0, nil))
+
+	// the check is disabled by default
+	sc = newTestSequenceCreator(Config{})
+	require.NoError(t, sc.checkTxTimestamp(0, &node.Tx{Timestamp: millis(time.Now().Add(24 * time.Hour))}))
+}
+
+func TestCheckTxVersion(t *testing.T) {
+	sc := newTestSequenceCreator(Config{MinTxVersion: 2})
+
+	version := func(v int) *int {
+		return &v
+	}
+
+	require.NoError(t, sc.checkTxVersion(0, &node.Tx{Version: version(2)}))
+	require.NoError(t, sc.checkTxVersion(0, &node.Tx{Version: version(3)}))
+	require.NoError(t, sc.checkTxVersion(0, nil))
+
+	err := sc.checkTxVersion(1, &node.Tx{Version: version(1)})
+	requireInvalidParameter(t, err, "transactions", "Transaction at position 1 has version 1, min version is 2.")
+
+	// txs without version are the first version ones
+	err = sc.checkTxVersion(2, &node.Tx{})
+	requireInvalidParameter(t, err, "transactions", "Transaction at position 2 has version 1, min version is 2.")
+}
+
+func TestCreateSequenceRejectsOldTxVersion(t *testing.T) {
+	repo := newFakeRepo()
+	h := newTestAPI(Config{MinTxVersion: 2, StreamingBodySize: 1 << 20}, repo, node.NewFakeInteractor(nil))
+
+	for _, knownSize := range []bool{true, false} {
+		w := serve(h, http.MethodPost, "/sequences", `{"transactions":[{"id":"1","version":2},{"id":"2","version":1}]}`, knownSize)
+		require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+		require.Contains(t, w.Body.String(), "Transaction at position 1 has version 1, min version is 2.")
+	}
+
+	w := serve(h, http.MethodPost, "/sequences", `{"transactions":[{"id":"1","version":2},{"id":"2","version":3}]}`, true)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	require.Len(t, repo.sequences, 1)
+}
+
+func TestCreateSequenceChecksMinFees(t *testing.T) {
+	repo := newFakeRepo()
+	h := newTestAPI(Config{CheckMinFees: true, StreamingBodySize: 1 << 20}, repo, node.NewFakeInteractor(nil))
+
+	// fees paid in sponsored assets are left to the node
+	body := `{"transactions":[{"id":"1","type":4,"fee":100000},{"id":"2","type":4,"fee":1,"feeAssetId":"asset"},{"id":"3","type":11,"fee":100000}]}`
+	for _, knownSize := range []bool{true, false} {
+		w := serve(h, http.MethodPost, "/sequences", body, knownSize)
+		require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+		require.Contains(t, w.Body.String(), "transactions[2]")
+		require.Contains(t, w.Body.String(), "Transaction fee 100000 is less than the min fee 150000 of the transaction type 11.")
+	}
+
+	w := serve(h, http.MethodPost, "/sequences", `{"transactions":[{"id":"1","type":4,"fee":100000},{"id":"3","type":11,"fee":150000}]}`, true)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	require.Len(t, repo.sequences, 1)
+}
+
+func TestCreateSequenceReportsDuplicates(t *testing.T) {
+	body := `{"transactions":[{"id":"a"},{"id":"b"},{"id":"a"},{"id":"c"},{"id":"b"},{"id":"a"}]}`
+
+	cases := []struct {
+		reportAll  bool
+		duplicates string
+	}{
+		{reportAll: false, duplicates: `"duplicates":[0,2]`},
+		{reportAll: true, duplicates: `"duplicates":[[0,2,5],[1,4]]`},
+	}
+
+	for _, c := range cases {
+		// both buffered and streamed requests
+		for _, streamingBodySize := range []int64{1 << 20, 20} {
+			repo := newFakeRepo()
+			h := newTestAPI(Config{ReportAllDuplicates: c.reportAll, StreamingBodySize: streamingBodySize}, repo, node.NewFakeInteractor(nil))
+
+			w := serve(h, http.MethodPost, "/sequences", body, true)
+			require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+			require.Contains(t, w.Body.String(), c.duplicates)
+			require.Empty(t, repo.sequences)
+		}
+	}
+}
+
+func TestCreateSequenceRejectsOversizedTx(t *testing.T) {
+	oversized := `{"id":"2","attachment":"` + strings.Repeat("a", 100) + `"}`
+	body := `{"transactions":[{"id":"1"},` + oversized + `]}`
+
+	// both buffered and streamed requests
+	for _, streamingBodySize := range []int64{1 << 20, 20} {
+		repo := newFakeRepo()
+		h := newTestAPI(Config{MaxTxSize: 100, StreamingBodySize: streamingBodySize}, repo, node.NewFakeInteractor(nil))
+
+		w := serve(h, http.MethodPost, "/sequences", body, true)
+		require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+		require.Contains(t, w.Body.String(), "Transaction at position 1 exceeds 100 bytes.")
+		require.Empty(t, repo.sequences)
+
+		w = serve(h, http.MethodPost, "/sequences", `{"transactions":[{"id":"1"},{"id":"2"}]}`, true)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	}
+}
+
+func TestCreateSequenceRejectedPastMaxQueueDepth(t *testing.T) {
+	repo := newFakeRepo()
+	h := newTestAPI(Config{MaxQueueDepth: 2, QueueRetryAfter: 15 * time.Second, StreamingBodySize: 1 << 20}, repo, node.NewFakeInteractor(nil))
+
+	for i := 0; i < 2; i++ {
+		w := serve(h, http.MethodPost, "/sequences", `{"transactions":[{"id":"1"}]}`, true)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	// both buffered and streamed requests
+	for _, knownSize := range []bool{true, false} {
+		w := serve(h, http.MethodPost, "/sequences", `{"transactions":[{"id":"1"}]}`, knownSize)
+		require.Equal(t, http.StatusTooManyRequests, w.Code, w.Body.String())
+		require.Equal(t, "15", w.Header().Get("Retry-After"))
+		require.Contains(t, w.Body.String(), "There are 2 pending and processing sequences, the limit is 2.")
+	}
+	require.Len(t, repo.sequences, 2)
+}
+
+// failingRepo fails creation of sequences with err
+type failingRepo struct {
+	*fakeRepo
+	err error
+}
+
+func (r *failingRepo) CreateSequence(txs []string, options repository.SequenceOptions) (int64, error) {
+	return 0, r.err
+}
+
+func (r *failingRepo) CreateSequenceFromSource(source repository.TxsSource, batchSize int, options func() (repository.SequenceOptions, error)) (int64, error) {
+	return 0, r.err
+}
+
+func TestCreateSequenceWhileDBIsUnavailable(t *testing.T) {
+	// both buffered and streamed requests
+	for _, streamingBodySize := range []int64{1 << 20, 10} {
+		repo := &failingRepo{fakeRepo: newFakeRepo(), err: &repository.DBUnavailableError{Err: errors.New("ERROR #25006")}}
+		h := newTestAPI(Config{DBRetryAfter: 7 * time.Second, StreamingBodySize: streamingBodySize}, repo, node.NewFakeInteractor(nil))
+
+		w := serve(h, http.MethodPost, "/sequences", `{"transactions":[{"id":"1"}]}`, true)
+		require.Equal(t, http.StatusServiceUnavailable, w.Code, w.Body.String())
+		require.Equal(t, "7", w.Header().Get("Retry-After"))
+		require.Contains(t, w.Body.String(), "Database is temporarily unavailable.")
+
+		// other db errors are internal ones
+		repo.err = errors.New("ERROR #22P02")
+		w = serve(h, http.MethodPost, "/sequences", `{"transactions":[{"id":"1"}]}`, true)
+		require.Equal(t, http.StatusInternalServerError, w.Code, w.Body.String())
+		require.Empty(t, w.Header().Get("Retry-After"))
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	require.Equal(t, "0", retryAfter(0))
+	require.Equal(t, "1", retryAfter(300*time.Millisecond))
+	require.Equal(t, "1", retryAfter(time.Second))
+	require.Equal(t, "2", retryAfter(1500*time.Millisecond))
+}
+
+// alreadyInStateValidator reports every tx as already confirmed at height 42
+type alreadyInStateValidator struct {
+	node.Interactor
+}
+
+func (v *alreadyInStateValidator) ValidateTx(tx string) (*node.ValidationResult, node.Error) {
+	t, _ := node.ParseTx(tx)
+	return &node.ValidationResult{IsValid: false, ErrorMessage: fmt.Sprintf("State check failed. Reason: Transaction %s is already in the state on a height of 42", t.ID)}, nil
+}
+
+func TestCreateSequenceWithConfirmedFirstTx(t *testing.T) {
+	body := `{"transactions":[{"id":"1"},{"id":"2"}]}`
+
+	// both buffered and streamed requests
+	for _, streamingBodySize := range []int64{1 << 20, 10} {
+		// the resumed sequence is accepted, the worker confirms the first tx without broadcasting it again
+		repo := newFakeRepo()
+		h := newTestAPI(Config{AcceptConfirmedFirstTx: true, StreamingBodySize: streamingBodySize}, repo, node.NewFakeInteractor(&alreadyInStateValidator{}))
+
+		w := serve(h, http.MethodPost, "/sequences", body, true)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		require.Equal(t, []string{`{"id":"1"}`, `{"id":"2"}`}, repo.sequences[1])
+
+		// the confirmed first tx is invalid by default
+		repo = newFakeRepo()
+		h = newTestAPI(Config{StreamingBodySize: streamingBodySize}, repo, node.NewFakeInteractor(&alreadyInStateValidator{}))
+
+		w = serve(h, http.MethodPost, "/sequences", body, true)
+		require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+		require.Contains(t, w.Body.String(), "is already in the state on a height of 42")
+		require.Contains(t, w.Body.String(), fmt.Sprintf(`"code":%d`, _invalidFirstTxError))
+		require.Empty(t, repo.sequences)
+	}
+}
+
+// warningValidator reports every tx as valid with warnings
+type warningValidator struct {
+	node.Interactor
+}
+
+func (v *warningValidator) ValidateTx(tx string) (*node.ValidationResult, node.Error) {
+	return &node.ValidationResult{IsValid: true, Warnings: []string{"complexity near limit"}}, nil
+}
+
+func TestCreateSequenceReturnsFirstTxWarnings(t *testing.T) {
+	body := `{"transactions":[{"id":"1"},{"id":"2"}]}`
+
+	// both buffered and streamed requests
+	for _, streamingBodySize := range []int64{1 << 20, 10} {
+		h := newTestAPI(Config{ReturnValidationWarnings: true, StreamingBodySize: streamingBodySize}, newFakeRepo(), node.NewFakeInteractor(&warningValidator{}))
+
+		w := serve(h, http.MethodPost, "/sequences", body, true)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		require.JSONEq(t, `{"id":1,"first_tx_warnings":["complexity near limit"]}`, w.Body.String())
+
+		// warnings are not rendered unless enabled
+		h = newTestAPI(Config{StreamingBodySize: streamingBodySize}, newFakeRepo(), node.NewFakeInteractor(&warningValidator{}))
+
+		w = serve(h, http.MethodPost, "/sequences", body, true)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		require.JSONEq(t, `{"id":1}`, w.Body.String())
+	}
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/wavesplatform/transaction-broadcaster/internal/node"
+)
+
+// types of txs producing entities other txs may reference
+const (
+	issueTxType       = 3
+	leaseTxType       = 8
+	createAliasTxType = 10
+)
+
+// txReference is an entity produced or consumed by a tx
+type txReference struct {
+	kind string
+	id   string
+}
+
+func (r txReference) String() string {
+	return r.kind + " " + r.id
+}
+
+// producedReference returns the entity created by the tx, its id is the tx id for issued assets and leases
+func producedReference(t *node.Tx) (txReference, bool) {
+	switch t.Type {
+	case issueTxType:
+		return txReference{kind: "asset", id: t.ID}, t.ID != ""
+	case leaseTxType:
+		return txReference{kind: "lease", id: t.ID}, t.ID != ""
+	case createAliasTxType:
+		return txReference{kind: "alias", id: t.Alias}, t.Alias != ""
+	}
+	return txReference{}, false
+}
+
+// consumedReferences returns the entities the tx refers to, issue txs do not refer to their own asset
+func consumedReferences(t *node.Tx) []txReference {
+	var refs []txReference
+
+	if t.AssetID != "" && t.Type != issueTxType {
+		refs = append(refs, txReference{kind: "asset", id: t.AssetID})
+	}
+	if t.FeeAssetID != "" {
+		refs = append(refs, txReference{kind: "asset", id: t.FeeAssetID})
+	}
+	for _, p := range t.Payment {
+		if p.AssetID != "" {
+			refs = append(refs, txReference{kind: "asset", id: p.AssetID})
+		}
+	}
+
+	if t.LeaseID != "" {
+		refs = append(refs, txReference{kind: "lease", id: t.LeaseID})
+	}
+
+	recipients := []string{t.Recipient, t.DApp}
+	for _, r := range t.Transfers {
+		recipients = append(recipients, r.Recipient)
+	}
+	for _, r := range recipients {
+		if alias, ok := recipientAlias(r); ok {
+			refs = append(refs, txReference{kind: "alias", id: alias})
+		}
+	}
+
+	return refs
+}
+
+// recipientAlias returns alias name of the recipient in the alias:<chain id>:<name> format
+func recipientAlias(recipient string) (string, bool) {
+	parts := strings.SplitN(recipient, ":", 3)
+	if len(parts) != 3 || parts[0] != "alias" {
+		return "", false
+	}
+	return parts[2], true
+}
+
+// dependenciesChecker remembers the first tx referencing an asset, a lease or an alias produced by a later tx of the sequence
+// such a tx would never be valid at its position, references to entities not produced by the sequence are not checked
+// txs are added in the sequence order, so the check works for streamed requests as well; idx is the position reported by the error
+type dependenciesChecker struct {
+	produced map[txReference]int
+	// unresolved are positions of the first txs consuming entities not produced yet
+	unresolved map[txReference]int
+
+	consumerIdx int
+	producerIdx int
+	ref         txReference
+}
+
+func newDependenciesChecker() *dependenciesChecker {
+	return &dependenciesChecker{
+		produced:    make(map[txReference]int),
+		unresolved:  make(map[txReference]int),
+		consumerIdx: -1,
+	}
+}
+
+// add checks the tx at the position idx, not parsed txs (nil) are skipped
+func (c *dependenciesChecker) add(idx int, tx *node.Tx) {
+	if c.consumerIdx >= 0 || tx == nil {
+		return
+	}
+
+	for _, ref := range consumedReferences(tx) {
+		if _, ok := c.produced[ref]; ok {
+			continue
+		}
+		if _, ok := c.unresolved[ref]; !ok {
+			c.unresolved[ref] = idx
+		}
+	}
+
+	if ref, ok := producedReference(tx); ok {
+		if consumerIdx, ok := c.unresolved[ref]; ok {
+			c.consumerIdx, c.producerIdx, c.ref = consumerIdx, idx, ref
+			return
+		}
+		c.produced[ref] = idx
+	}
+}
+
+// err returns an error pointing to the first tx referencing an entity produced by a later tx
+func (c *dependenciesChecker) err() error {
+	if c.consumerIdx < 0 {
+		return nil
+	}
+	return badRequest(InvalidParameterValue(fmt.Sprintf("transactions[%d]", c.consumerIdx), fmt.Sprintf("Transaction references %s produced by the transaction at position %d.", c.ref, c.producerIdx)))
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/wavesplatform/transaction-broadcaster/internal/node"
+)
+
+func checkDependencies(txs ...string) error {
+	c := newDependenciesChecker()
+	for idx, tx := range txs {
+		parsed, _ := node.ParseTx(tx)
+		c.add(idx, parsed)
+	}
+	return c.err()
+}
+
+func TestDependenciesCheckerValidChain(t *testing.T) {
+	err := checkDependencies(
+		`{"id":"asset","type":3}`,
+		`{"id":"alias-tx","type":10,"alias":"bob"}`,
+		`{"id":"lease","type":8,"recipient":"alias:W:bob","amount":1}`,
+		`{"type":4,"assetId":"asset","recipient":"alias:W:bob"}`,
+		`{"type":9,"leaseId":"lease"}`,
+		`{"type":16,"dApp":"alias:W:bob","payment":[{"assetId":"asset","amount":1}]}`,
+	)
+	require.NoError(t, err)
+}
+
+func TestDependenciesCheckerIgnoresExternalReferences(t *testing.T) {
+	err := checkDependencies(
+		`{"type":4,"assetId":"external","feeAssetId":"other","recipient":"alias:W:alice"}`,
+		`{"type":9,"leaseId":"external-lease"}`,
+		`not a tx`,
+	)
+	require.NoError(t, err)
+}
+
+func TestDependenciesCheckerForwardReferences(t *testing.T) {
+	cases := []struct {
+		name      string
+		txs       []string
+		parameter string
+		reason    string
+	}{
+		{
+			name:      "asset",
+			txs:       []string{`{"type":4,"recipient":"addr"}`, `{"type":4,"feeAssetId":"asset"}`, `{"id":"asset","type":3}`},
+			parameter: "transactions[1]",
+			reason:    "Transaction references asset asset produced by the transaction at position 2.",
+		},
+		{
+			name:      "lease",
+			txs:       []string{`{"type":9,"leaseId":"lease"}`, `{"id":"lease","type":8}`},
+			parameter: "transactions[0]",
+			reason:    "Transaction references lease lease produced by the transaction at position 1.",
+		},
+		{
+			name:      "alias in mass transfer",
+			txs:       []string{`{"type":11,"transfers":[{"recipient":"alias:W:bob","amount":1}]}`, `{"id":"alias-tx","type":10,"alias":"bob"}`},
+			parameter: "transactions[0]",
+			reason:    "Transaction references alias bob produced by the transaction at position 1.",
+		},
+		{
+			name:      "payment",
+			txs:       []string{`{"type":16,"payment":[{"assetId":"asset"}]}`, `{"type":4}`, `{"id":"asset","type":3}`},
+			parameter: "transactions[0]",
+			reason:    "Transaction references asset asset produced by the transaction at position 2.",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			requireInvalidParameter(t, checkDependencies(c.txs...), c.parameter, c.reason)
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"encoding/json"
 	"fmt"
 )
 
@@ -10,14 +11,28 @@ const (
 	// common validation errors
 	_missingRequiredParameter = 950200
 	_invalidParameterValue    = 950201
+	_emptyTransactions        = 950202
+	_transactionsNotArray     = 950203
 
 	// service errors
 	_txsDuplicatesError  = 950301
 	_invalidFirstTxError = 950302
+	_firstTxNotConfirmed = 950303
+	_nodeNotSynced       = 950304
+	_dbUnavailable       = 950305
+	_queueFull           = 950306
 )
 
 type errorDetails map[string]interface{}
 
+// NodeError is the node rejection of a tx rendered in error details, so clients can handle specific node errors
+type NodeError struct {
+	// Code is the node API error code, it is omitted if the node did not return it
+	Code    uint16          `json:"code,omitempty"`
+	Message string          `json:"message"`
+	Trace   json.RawMessage `json:"trace,omitempty"`
+}
+
 type apiErrorImpl struct {
 	code    uint32
 	details errorDetails
@@ -65,19 +80,70 @@ func InvalidParameterValue(parameterName string, reason string) Error {
 	return NewError(_invalidParameterValue, details)
 }
 
+// EmptyTransactionsError ...
+func EmptyTransactionsError() Error {
+	details := errorDetails{
+		"parameter": "transactions",
+		"reason":    "There are not any transactions in the request.",
+	}
+	return NewError(_emptyTransactions, details)
+}
+
+// TransactionsNotArrayError ...
+func TransactionsNotArrayError(reason string) Error {
+	details := errorDetails{
+		"parameter": "transactions",
+		"reason":    reason,
+	}
+	return NewError(_transactionsNotArray, details)
+}
+
 // TxsDuplicatesError ...
 func TxsDuplicatesError(meta errorDetails) Error {
 	return NewError(_txsDuplicatesError, meta)
 }
 
 // InvalidFirstTxError ...
-func InvalidFirstTxError(reason string) Error {
+func InvalidFirstTxError(nodeErr NodeError) Error {
 	details := errorDetails{
-		"reason": reason,
+		"reason":    nodeErr.Message,
+		"nodeError": nodeErr,
 	}
 	return NewError(_invalidFirstTxError, details)
 }
 
+// FirstTxNotConfirmedError ...
+func FirstTxNotConfirmedError(reason string) Error {
+	details := errorDetails{
+		"reason": reason,
+	}
+	return NewError(_firstTxNotConfirmed, details)
+}
+
+// NodeNotSyncedError ...
+func NodeNotSyncedError(reason string) Error {
+	details := errorDetails{
+		"reason": reason,
+	}
+	return NewError(_nodeNotSynced, details)
+}
+
+// DBUnavailableError ...
+func DBUnavailableError(reason string) Error {
+	details := errorDetails{
+		"reason": reason,
+	}
+	return NewError(_dbUnavailable, details)
+}
+
+// QueueFullError ...
+func QueueFullError(reason string) Error {
+	details := errorDetails{
+		"reason": reason,
+	}
+	return NewError(_queueFull, details)
+}
+
 // SingleHTTPError returns HTTPErrors build from single HTTPError
 func SingleHTTPError(err Error) HTTPErrors {
 	return HTTPErrors{
@@ -101,11 +167,23 @@ func (err *apiErrorImpl) Message() string {
 		return "Missing required parameter."
 	case _invalidParameterValue:
 		return "Invalid parameter value."
+	case _emptyTransactions:
+		return "The transactions array is empty."
+	case _transactionsNotArray:
+		return "The transactions are not an array."
 
 	case _txsDuplicatesError:
 		return "There are duplicates in the transactions array."
 	case _invalidFirstTxError:
 		return "The first transaction is invalid."
+	case _firstTxNotConfirmed:
+		return "The first transaction is not confirmed."
+	case _nodeNotSynced:
+		return "The node is not synced."
+	case _dbUnavailable:
+		return "The database is unavailable."
+	case _queueFull:
+		return "Too many sequences are queued."
 
 	default:
 		return _internalServerErrorMessage
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"fmt"
+
+	"github.com/wavesplatform/transaction-broadcaster/internal/node"
+)
+
+// txFee is the WAVES fee of the tx at the position idx
+type txFee struct {
+	idx    int
+	txType int32
+	fee    int64
+}
+
+// feesChecker remembers WAVES fees of txs, so they are checked against the node min fees once all txs are added
+// min fees are calculated for txs of accounts without scripts, so they are the lower bound of the required fees
+// txs paying fees in sponsored assets, not probed tx types and not parsed txs are left to the node validation
+type feesChecker struct {
+	fees []txFee
+}
+
+func newFeesChecker() *feesChecker {
+	return &feesChecker{}
+}
+
+// add remembers the fee of the tx at the position idx
+func (c *feesChecker) add(idx int, tx *node.Tx) {
+	if tx == nil || tx.FeeAssetID != "" {
+		return
+	}
+	c.fees = append(c.fees, txFee{idx: idx, txType: tx.Type, fee: tx.Fee})
+}
+
+// err requests min fees of the node, returns an error pointing to the first tx whose fee is less than the min fee of its type
+func (c *feesChecker) err(nodeInteractor node.Interactor) error {
+	if len(c.fees) == 0 {
+		return nil
+	}
+
+	minFees, wavesErr := nodeInteractor.GetMinFees()
+	if wavesErr != nil {
+		return wavesErr
+	}
+
+	for _, f := range c.fees {
+		if minFee, ok := minFees[f.txType]; ok && f.fee < minFee {
+			return badRequest(InvalidParameterValue(fmt.Sprintf("transactions[%d]", f.idx), fmt.Sprintf("Transaction fee %d is less than the min fee %d of the transaction type %d.", f.fee, minFee, f.txType)))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JSONNaming is the naming convention of keys of the API responses
+type JSONNaming string
+
+// Enum of JSONNaming
+const (
+	JSONNamingSnakeCase JSONNaming = "snake_case"
+	JSONNamingCamelCase JSONNaming = "camelCase"
+)
+
+// UnmarshalText parses JSONNaming from its name
+func (n *JSONNaming) UnmarshalText(text []byte) error {
+	switch naming := JSONNaming(text); naming {
+	case JSONNamingSnakeCase, JSONNamingCamelCase:
+		*n = naming
+	default:
+		return fmt.Errorf("unknown json naming: %s", text)
+	}
+	return nil
+}
+
+// key returns the snake_case key in the naming
+func (n JSONNaming) key(snakeCaseKey string) string {
+	if n == JSONNamingCamelCase {
+		return snakeToCamel(snakeCaseKey)
+	}
+	return snakeCaseKey
+}
+
+// jsonNamingHeader overrides the configured naming of the request response
+const jsonNamingHeader = "X-JSON-Naming"
+
+// jsonNamingKey is the context key of the naming of the request response
+const jsonNamingKey = "json_naming"
+
+// jsonNaming selects the naming of the response keys requested by X-JSON-Naming header or defaultNaming, see requestNaming
+func jsonNaming(renderError errorRenderer, defaultNaming JSONNaming) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		naming := defaultNaming
+		if header := c.GetHeader(jsonNamingHeader); header != "" {
+			if err := naming.UnmarshalText([]byte(header)); err != nil {
+				renderError(c, http.StatusBadRequest, InvalidParameterValue(jsonNamingHeader, "Naming has to be snake_case or camelCase."))
+				c.Abort()
+				return
+			}
+		}
+
+		c.Set(jsonNamingKey, naming)
+		c.Next()
+	}
+}
+
+// requestNaming returns the naming of the request response, it is snake_case if it was not selected
+func requestNaming(c *gin.Context) JSONNaming {
+	if naming, ok := c.Get(jsonNamingKey); ok {
+		return naming.(JSONNaming)
+	}
+	return JSONNamingSnakeCase
+}
+
+// snakeToCamel converts snake_case to camelCase, keys without underscores are returned as is
+func snakeToCamel(s string) string {
+	if !strings.Contains(s, "_") {
+		return s
+	}
+
+	parts := strings.Split(s, "_")
+	var b strings.Builder
+	b.WriteString(parts[0])
+	for _, part := range parts[1:] {
+		if part == "" {
+			continue
+		}
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
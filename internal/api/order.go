@@ -0,0 +1,83 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/wavesplatform/transaction-broadcaster/internal/node"
+)
+
+// txsOrderReverse puts the request txs into the sequence from the last to the first one
+const txsOrderReverse = "reverse"
+
+// isTxsOrderSet returns whether the order option is given, null means it is not
+func isTxsOrderSet(order json.RawMessage) bool {
+	trimmed := bytes.TrimSpace(order)
+	return len(trimmed) > 0 && !bytes.Equal(trimmed, []byte("null"))
+}
+
+// orderTxs returns txs and their parsed values in the order the sequence has to be processed in and request positions of them
+// order is either "reverse" or positions of the request txs in the sequence order, every position has to be listed exactly once
+// positions are nil if the order is not set, see requestPosition
+func orderTxs(order json.RawMessage, txs []string, parsed []*node.Tx) ([]string, []*node.Tx, []int, error) {
+	if !isTxsOrderSet(order) {
+		return txs, parsed, nil, nil
+	}
+
+	positions, err := txsOrderPositions(order, len(txs))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	orderedTxs := make([]string, len(txs))
+	orderedParsed := make([]*node.Tx, len(txs))
+	for i, position := range positions {
+		orderedTxs[i] = txs[position]
+		orderedParsed[i] = parsed[position]
+	}
+
+	return orderedTxs, orderedParsed, positions, nil
+}
+
+// requestPosition returns the request position of the tx at the sequence position idx, errors point to txs by their request positions
+func requestPosition(positions []int, idx int) int {
+	if positions == nil {
+		return idx
+	}
+	return positions[idx]
+}
+
+// txsOrderPositions returns request positions of count txs in the sequence order
+func txsOrderPositions(order json.RawMessage, count int) ([]int, error) {
+	var name string
+	if err := json.Unmarshal(order, &name); err == nil {
+		if name != txsOrderReverse {
+			return nil, badRequest(InvalidParameterValue("order", "Order has to be \"reverse\" or an array of positions of the transactions."))
+		}
+
+		positions := make([]int, count)
+		for i := range positions {
+			positions[i] = count - 1 - i
+		}
+		return positions, nil
+	}
+
+	var positions []int
+	if err := json.Unmarshal(order, &positions); err != nil {
+		return nil, badRequest(InvalidParameterValue("order", "Order has to be \"reverse\" or an array of positions of the transactions."))
+	}
+
+	if len(positions) != count {
+		return nil, badRequest(InvalidParameterValue("order", "Order has to contain every transaction position exactly once."))
+	}
+
+	listed := make([]bool, count)
+	for _, position := range positions {
+		if position < 0 || position >= count || listed[position] {
+			return nil, badRequest(InvalidParameterValue("order", "Order has to contain every transaction position exactly once."))
+		}
+		listed[position] = true
+	}
+
+	return positions, nil
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/wavesplatform/transaction-broadcaster/internal/node"
+)
+
+func TestOrderTxsKeepsParsedTxs(t *testing.T) {
+	txs := []string{`{"id":"a"}`, `{"id":"b"}`, `{"id":"c"}`}
+	parsed := []*node.Tx{{ID: "a"}, nil, {ID: "c"}}
+
+	ordered, orderedParsed, positions, err := orderTxs(json.RawMessage(`[2,0,1]`), txs, parsed)
+	require.NoError(t, err)
+	require.Equal(t, []string{`{"id":"c"}`, `{"id":"a"}`, `{"id":"b"}`}, ordered)
+	require.Equal(t, []*node.Tx{{ID: "c"}, {ID: "a"}, nil}, orderedParsed)
+	require.Equal(t, []int{2, 0, 1}, positions)
+
+	ordered, orderedParsed, positions, err = orderTxs(json.RawMessage(`"reverse"`), txs, parsed)
+	require.NoError(t, err)
+	require.Equal(t, []string{`{"id":"c"}`, `{"id":"b"}`, `{"id":"a"}`}, ordered)
+	require.Equal(t, []*node.Tx{{ID: "c"}, nil, {ID: "a"}}, orderedParsed)
+	require.Equal(t, []int{2, 1, 0}, positions)
+
+	ordered, _, positions, err = orderTxs(nil, txs, parsed)
+	require.NoError(t, err)
+	require.Equal(t, txs, ordered)
+	require.Nil(t, positions)
+	require.Equal(t, 1, requestPosition(positions, 1))
+
+	for _, order := range []string{`[0,0,1]`, `[0,1]`, `[0,1,3]`, `"forward"`} {
+		_, _, _, err = orderTxs(json.RawMessage(order), txs, parsed)
+		require.Error(t, err, order)
+	}
+}
+
+func TestCreateSequenceReorderedErrorsPointToRequestPositions(t *testing.T) {
+	cases := []struct {
+		name   string
+		cfg    Config
+		body   string
+		reason string
+	}{
+		{
+			name:   "dependencies",
+			cfg:    Config{CheckTxDependencies: true},
+			body:   `{"transactions":[{"id":"lease","type":8},{"id":"cancel","type":9,"leaseId":"lease"}],"order":"reverse"}`,
+			reason: `"transactions[1]"`,
+		},
+		{
+			name:   "senders",
+			cfg:    Config{RequireCommonSender: true},
+			body:   `{"transactions":[{"id":"1","senderPublicKey":"a"},{"id":"2","senderPublicKey":"a"},{"id":"3","senderPublicKey":"b"}],"order":[2,0,1]}`,
+			reason: `"transactions[0]"`,
+		},
+		{
+			name:   "fees",
+			cfg:    Config{CheckMinFees: true},
+			body:   `{"transactions":[{"id":"1","type":11,"fee":100000},{"id":"2","type":4,"fee":100000}],"order":"reverse"}`,
+			reason: `"transactions[0]"`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			c.cfg.StreamingBodySize = 1 << 20
+			repo := newFakeRepo()
+			h := newTestAPI(c.cfg, repo, node.NewFakeInteractor(nil))
+
+			w := serve(h, http.MethodPost, "/sequences", c.body, true)
+			require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+			require.Contains(t, w.Body.String(), c.reason)
+			require.Empty(t, repo.sequences)
+		})
+	}
+
+	// the producer at the request position 0 is processed after its consumer
+	h := newTestAPI(Config{CheckTxDependencies: true, StreamingBodySize: 1 << 20}, newFakeRepo(), node.NewFakeInteractor(nil))
+	w := serve(h, http.MethodPost, "/sequences", cases[0].body, true)
+	require.Contains(t, w.Body.String(), "produced by the transaction at position 0.")
+}
+
+func TestCreateSequenceStreamingRejectsOrder(t *testing.T) {
+	h := newTestAPI(Config{StreamingBodySize: 10}, newFakeRepo(), node.NewFakeInteractor(nil))
+	body := `{"transactions":[{"id":"1"},{"id":"2"}],"order":"reverse"}`
+
+	w := serve(h, http.MethodPost, "/sequences", body, true)
+	require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+	require.Contains(t, w.Body.String(), "Order is supported only for requests up to 10 bytes.")
+
+	// the size of chunked requests is not known, the limit is exceeded by what was read so far
+	w = serve(h, http.MethodPost, "/sequences", body, false)
+	require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+	require.Contains(t, w.Body.String(), "Order is not supported for chunked requests of more than 10 bytes, they are streamed.")
+}
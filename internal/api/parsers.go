@@ -3,39 +3,201 @@
 package api
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
-	"strings"
+	"fmt"
+	"io"
+
+	"github.com/gin-gonic/gin/binding"
 )
 
-func parseTransactions(request string) ([]string, error) {
-	var transactions []string
-	b := strings.Builder{}
-	skipStringLen := len(`{"transactions":[`)
-	if len(request) < skipStringLen {
-		return nil, errors.New("length of request string is too small")
-	}
-	transactionsString := request[skipStringLen : len(request)-1]
-	bracketsCount := 0
-	for _, ch := range transactionsString {
-		if ch == '{' {
-			bracketsCount++
+// envelopeError describes invalid shape of the create sequence request
+type envelopeError struct {
+	// parameter is the path of the invalid field, e.g. transactions[2]
+	parameter string
+	reason    string
+	missing   bool
+	// notArray means the transactions field is not an array, so the request shape is wrong unlike an empty array
+	notArray bool
+}
+
+func (e *envelopeError) Error() string {
+	if e.missing {
+		return fmt.Sprintf("%s is missing", e.parameter)
+	}
+	return fmt.Sprintf("%s: %s", e.parameter, e.reason)
+}
+
+// apiError returns API error describing the invalid field
+func (e *envelopeError) apiError() Error {
+	if e.missing {
+		return MissingRequiredParameter(e.parameter)
+	}
+	if e.notArray {
+		return TransactionsNotArrayError(e.reason)
+	}
+	return InvalidParameterValue(e.parameter, e.reason)
+}
+
+var errTransactionsNotArray = &envelopeError{parameter: "transactions", reason: "Transactions have to be an array of objects.", notArray: true}
+
+func notObjectTransactionError(idx int) error {
+	return &envelopeError{parameter: fmt.Sprintf("transactions[%d]", idx), reason: "Transaction has to be an object."}
+}
+
+// parseTransactions strictly validates the request envelope and returns raw txs as they are in the request
+func parseTransactions(request []byte) ([]string, error) {
+	req := txsRequest{}
+	if err := binding.JSON.BindBody(request, &req); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) && typeErr.Field == "transactions" {
+			return nil, errTransactionsNotArray
 		}
-		if ch == '}' {
-			bracketsCount--
-			// json object was closed
-			if bracketsCount == 0 {
-				// dont forget append the last bracket
-				b.WriteRune(ch)
-				transactions = append(transactions, b.String())
-				// reset for retrieving the next object
-				b.Reset()
-			}
+
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+			return nil, &envelopeError{parameter: "transactions", reason: "Invalid request."}
+		}
+
+		// the request is truncated or the binding validation failed, the field is either missing or null then
+		fields := map[string]json.RawMessage{}
+		if err := json.Unmarshal(request, &fields); err != nil {
+			return nil, &envelopeError{parameter: "transactions", reason: "Invalid request."}
 		}
-		// do not add runes between brackets
-		if bracketsCount > 0 {
-			b.WriteRune(ch)
+		if fields["transactions"] != nil {
+			return nil, errTransactionsNotArray
 		}
+		return nil, &envelopeError{parameter: "transactions", missing: true}
+	}
+
+	transactions := make([]string, 0, len(req.Txs))
+	for idx, tx := range req.Txs {
+		trimmedTx := bytes.TrimSpace(tx)
+		if len(trimmedTx) == 0 || trimmedTx[0] != '{' {
+			return nil, notObjectTransactionError(idx)
+		}
+		transactions = append(transactions, string(trimmedTx))
 	}
 
 	return transactions, nil
 }
+
+const (
+	decoderStateStart = iota
+	decoderStateKeys
+	decoderStateTransactions
+	decoderStateDone
+)
+
+// createSequenceRequestDecoder decodes create sequence request body token by token
+// so txs are not kept in memory all at once
+// options are available after all txs are read, because they may follow the transactions array
+type createSequenceRequestDecoder struct {
+	decoder              *json.Decoder
+	options              sequenceOptionsRequest
+	state                int
+	hasTransactionsField bool
+	txsCount             int
+}
+
+func newCreateSequenceRequestDecoder(r io.Reader) *createSequenceRequestDecoder {
+	return &createSequenceRequestDecoder{decoder: json.NewDecoder(r)}
+}
+
+// NextTransaction returns the next raw tx of the transactions array, io.EOF is returned after the request end
+func (d *createSequenceRequestDecoder) NextTransaction() (string, error) {
+	for {
+		switch d.state {
+		case decoderStateStart:
+			if err := d.expectDelim('{'); err != nil {
+				return "", err
+			}
+			d.state = decoderStateKeys
+		case decoderStateKeys:
+			if !d.decoder.More() {
+				if err := d.expectDelim('}'); err != nil {
+					return "", err
+				}
+				if !d.hasTransactionsField {
+					return "", &envelopeError{parameter: "transactions", missing: true}
+				}
+				d.state = decoderStateDone
+				continue
+			}
+
+			token, err := d.decoder.Token()
+			if err != nil {
+				return "", err
+			}
+
+			key, ok := token.(string)
+			if !ok {
+				return "", errors.New("invalid request key")
+			}
+
+			if key == "transactions" {
+				if err := d.expectDelim('['); err != nil {
+					return "", errTransactionsNotArray
+				}
+				d.hasTransactionsField = true
+				d.state = decoderStateTransactions
+				continue
+			}
+
+			// every other field is an option
+			var value json.RawMessage
+			if err := d.decoder.Decode(&value); err != nil {
+				return "", err
+			}
+			field, err := json.Marshal(map[string]json.RawMessage{key: value})
+			if err != nil {
+				return "", err
+			}
+			if err := json.Unmarshal(field, &d.options); err != nil {
+				return "", err
+			}
+		case decoderStateTransactions:
+			if !d.decoder.More() {
+				if err := d.expectDelim(']'); err != nil {
+					return "", err
+				}
+				d.state = decoderStateKeys
+				continue
+			}
+
+			var tx json.RawMessage
+			if err := d.decoder.Decode(&tx); err != nil {
+				return "", err
+			}
+
+			trimmedTx := bytes.TrimSpace(tx)
+			if len(trimmedTx) == 0 || trimmedTx[0] != '{' {
+				return "", notObjectTransactionError(d.txsCount)
+			}
+			d.txsCount++
+
+			return string(trimmedTx), nil
+		default:
+			return "", io.EOF
+		}
+	}
+}
+
+// Options returns decoded request options, they are complete only after NextTransaction returned io.EOF
+func (d *createSequenceRequestDecoder) Options() sequenceOptionsRequest {
+	return d.options
+}
+
+func (d *createSequenceRequestDecoder) expectDelim(delim json.Delim) error {
+	token, err := d.decoder.Token()
+	if err != nil {
+		return err
+	}
+
+	if token != delim {
+		return fmt.Errorf("expected %s", delim)
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/wavesplatform/transaction-broadcaster/internal/node"
+)
+
+var malformedCreateRequests = []struct {
+	name      string
+	body      string
+	code      uint32
+	parameter string
+}{
+	{"no transactions", `{"transactions":[]}`, _emptyTransactions, ""},
+	{"object", `{"transactions":{}}`, _transactionsNotArray, ""},
+	{"string", `{"transactions":"x"}`, _transactionsNotArray, ""},
+	{"null", `{"transactions":null}`, _transactionsNotArray, ""},
+	{"missing field", `{"label":"x"}`, _missingRequiredParameter, "transactions"},
+	{"truncated", `{"transactions":[{"id":"1"}`, _invalidParameterValue, "transactions"},
+	{"not object element", `{"transactions":[1]}`, _invalidParameterValue, "transactions[0]"},
+}
+
+func TestParseTransactions(t *testing.T) {
+	txs, err := parseTransactions([]byte(`{"transactions":[ {"id":"1"} ,{"id":"2"}]}`))
+	require.NoError(t, err)
+	require.Equal(t, []string{`{"id":"1"}`, `{"id":"2"}`}, txs)
+
+	for _, tt := range malformedCreateRequests {
+		txs, err := parseTransactions([]byte(tt.body))
+		if tt.code == _emptyTransactions {
+			// the empty array is a valid envelope, it is rejected by the controller
+			require.NoError(t, err, tt.name)
+			require.Empty(t, txs, tt.name)
+			continue
+		}
+
+		var envErr *envelopeError
+		require.True(t, errors.As(err, &envErr), tt.name)
+		require.Equal(t, tt.code, envErr.apiError().Code(), tt.name)
+	}
+}
+
+func TestCreateSequenceRejectsMalformedRequest(t *testing.T) {
+	// both buffered and streamed requests
+	for _, streamingBodySize := range []int64{1 << 20, 1} {
+		repo := newFakeRepo()
+		h := newTestAPI(Config{StreamingBodySize: streamingBodySize}, repo, node.NewFakeInteractor(nil))
+
+		for _, tt := range malformedCreateRequests {
+			w := serve(h, http.MethodPost, "/sequences", tt.body, true)
+			require.Equal(t, http.StatusBadRequest, w.Code, tt.name)
+
+			resp := HTTPErrors{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp), tt.name)
+			require.Len(t, resp.Errors, 1, tt.name)
+			require.Equal(t, tt.code, resp.Errors[0].Code, "%s: %s", tt.name, w.Body.String())
+			if tt.parameter != "" {
+				require.Equal(t, tt.parameter, resp.Errors[0].Details["parameter"], tt.name)
+			}
+		}
+		require.Empty(t, repo.sequences)
+	}
+}
@@ -0,0 +1,182 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	"github.com/wavesplatform/transaction-broadcaster/internal/node"
+	"github.com/wavesplatform/transaction-broadcaster/internal/repository"
+)
+
+// responseObject is the object of the public API response, keys are set in snake_case and rendered in the naming of the request
+// values are rendered as they are, so nested data returned by the node (e.g. validation traces) keeps its keys
+type responseObject struct {
+	naming JSONNaming
+	keys   []string
+	values []interface{}
+}
+
+func newResponseObject(naming JSONNaming) *responseObject {
+	return &responseObject{naming: naming}
+}
+
+// set adds the key, keys are rendered in the order they are set
+func (o *responseObject) set(key string, value interface{}) *responseObject {
+	o.keys = append(o.keys, key)
+	o.values = append(o.values, value)
+	return o
+}
+
+// MarshalJSON renders keys in the naming of the object
+func (o *responseObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		encodedKey, err := json.Marshal(o.naming.key(key))
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(encodedKey)
+		buf.WriteByte(':')
+
+		encodedValue, err := json.Marshal(o.values[i])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(encodedValue)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// sequenceResponse is the sequence rendered by the public API, its error is null unless the sequence failed
+func sequenceResponse(naming JSONNaming, s *repository.Sequence, timeFormat repository.TimeFormat) *responseObject {
+	response := newResponseObject(naming).
+		set("id", s.ID).
+		set("broadcasted_count", s.BroadcastedCount).
+		set("total_count", s.TotalCount).
+		set("last_confirmed_position", s.LastConfirmedPosition).
+		set("state", s.State)
+
+	if s.ErrorInfo == (repository.ErrorInfo{}) {
+		response.set("error", nil)
+	} else {
+		errorInfo := newResponseObject(naming)
+		if s.ErrorMessage != "" {
+			errorInfo.set("message", s.ErrorMessage)
+		}
+		if s.ErrorCode != 0 {
+			errorInfo.set("code", s.ErrorCode)
+		}
+		response.set("error", errorInfo)
+	}
+
+	if s.Mode != "" {
+		response.set("mode", s.Mode)
+	}
+	if s.NodeURL != "" {
+		response.set("node_url", s.NodeURL)
+	}
+	if s.Label != "" {
+		response.set("label", s.Label)
+	}
+
+	return response.
+		set("created_at", timeFormat.Format(s.CreatedAt)).
+		set("updated_at", timeFormat.Format(s.UpdatedAt))
+}
+
+// sequencesResponse is the list of sequences rendered by the public API
+func sequencesResponse(naming JSONNaming, sequences []*repository.Sequence, timeFormat repository.TimeFormat) *responseObject {
+	items := make([]*responseObject, 0, len(sequences))
+	for _, s := range sequences {
+		items = append(items, sequenceResponse(naming, s, timeFormat))
+	}
+	return newResponseObject(naming).set("sequences", items)
+}
+
+// sequenceTxResponse is the sequence tx rendered by the public API, the validation trace is rendered as the node returned it
+func sequenceTxResponse(naming JSONNaming, tx *repository.SequenceTx, timeFormat repository.TimeFormat) *responseObject {
+	response := newResponseObject(naming).set("id", tx.ID)
+	if tx.SubmittedID != "" {
+		response.set("submitted_id", tx.SubmittedID)
+	}
+	response.set("state", tx.State).set("height", tx.Height)
+	if tx.BroadcastHeight != 0 {
+		response.set("broadcast_height", tx.BroadcastHeight)
+	}
+	if tx.ErrorMessage != "" {
+		response.set("error_message", tx.ErrorMessage)
+	}
+	response.set("position_in_sequence", tx.PositionInSequence)
+	if tx.MinConfirmations != nil {
+		response.set("min_confirmations", *tx.MinConfirmations)
+	}
+	if len(tx.ValidationTrace) > 0 {
+		response.set("validation_trace", tx.ValidationTrace)
+	}
+	if len(tx.ValidationWarnings) > 0 {
+		response.set("validation_warnings", tx.ValidationWarnings)
+	}
+
+	return response.
+		set("tx", tx.Tx).
+		set("created_at", timeFormat.Format(tx.CreatedAt)).
+		set("updated_at", timeFormat.Format(tx.UpdatedAt))
+}
+
+// createdResponse is the id of the created sequence, the first tx height is rendered if the request waited for it
+// warnings of the first tx are rendered if there are any
+func createdResponse(naming JSONNaming, sequenceID int64, waitFirst bool, firstTxHeight int32, firstTxWarnings []string) *responseObject {
+	response := newResponseObject(naming).set("id", sequenceID)
+	if waitFirst {
+		response.set("first_tx_height", firstTxHeight)
+	}
+	if len(firstTxWarnings) > 0 {
+		response.set("first_tx_warnings", firstTxWarnings)
+	}
+	return response
+}
+
+// statsResponse is the node stats, the node version is rendered if the node has reported it
+func statsResponse(naming JSONNaming, averageBlockTime, clockSkew time.Duration, version *node.Version) *responseObject {
+	response := newResponseObject(naming).
+		set("average_block_time", averageBlockTime.Milliseconds()).
+		set("clock_skew", clockSkew.Milliseconds())
+	if version != nil {
+		response.set("node_version", version.String())
+	}
+	return response
+}
+
+// auditBlockResponse is the block audit, confirmed are ids of txs found in the block, missing are ids of the rest txs confirmed at its height
+func auditBlockResponse(naming JSONNaming, height int32, blockTxsCount int, confirmed, missing []string) *responseObject {
+	return newResponseObject(naming).
+		set("height", height).
+		set("block_txs_count", blockTxsCount).
+		set("confirmed", confirmed).
+		set("missing", missing)
+}
+
+// throughputResponse is the sequences processing statistics over the window
+func throughputResponse(naming JSONNaming, window time.Duration, throughput *repository.Throughput) *responseObject {
+	errorRate := float64(0)
+	if finished := throughput.DoneSequences + throughput.ErrorSequences; finished > 0 {
+		errorRate = float64(throughput.ErrorSequences) / float64(finished)
+	}
+
+	return newResponseObject(naming).
+		set("window", window.String()).
+		set("created_sequences", throughput.CreatedSequences).
+		set("done_sequences", throughput.DoneSequences).
+		set("error_sequences", throughput.ErrorSequences).
+		set("confirmed_txs", throughput.ConfirmedTxs).
+		set("error_rate", errorRate).
+		set("sequences_per_minute", float64(throughput.CreatedSequences)/window.Minutes()).
+		set("confirmed_txs_per_minute", float64(throughput.ConfirmedTxs)/window.Minutes())
+}
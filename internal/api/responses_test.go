@@ -0,0 +1,162 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/wavesplatform/transaction-broadcaster/internal/node"
+	"github.com/wavesplatform/transaction-broadcaster/internal/repository"
+)
+
+// responsesRepo has the failed sequence with the tx rejected by the node script
+type responsesRepo struct {
+	*sequenceRepo
+}
+
+func newResponsesRepo() *responsesRepo {
+	at := time.Unix(1600000000, 0)
+	lastConfirmedPosition := int16(0)
+	minConfirmations := int32(2)
+
+	r := &responsesRepo{sequenceRepo: newSequenceRepo(repository.SequenceModeIndependent, repository.TransactionStateConfirmed, repository.TransactionStateError)}
+	r.sequence.BroadcastedCount = 1
+	r.sequence.LastConfirmedPosition = &lastConfirmedPosition
+	r.sequence.State = repository.StateError
+	r.sequence.ErrorInfo = repository.ErrorInfo{ErrorMessage: "tx failed", ErrorCode: 3}
+	r.sequence.CreatedAt, r.sequence.UpdatedAt = at, at
+
+	tx := r.txs[1]
+	tx.ErrorMessage = "script failed"
+	tx.MinConfirmations = &minConfirmations
+	tx.ValidationTrace = json.RawMessage(`[{"id":"3P","result":{"error_message":"failed"}}]`)
+	tx.Tx = `{"id":"b","sender_public_key":"pk"}`
+	tx.CreatedAt, tx.UpdatedAt = at, at
+	return r
+}
+
+func (r *responsesRepo) GetSequenceTx(sequenceID int64, positionInSequence int16) (*repository.SequenceTx, error) {
+	if int(positionInSequence) >= len(r.txs) {
+		return nil, nil
+	}
+	return r.txs[positionInSequence], nil
+}
+
+// serveNaming performs the request asking for the response keys naming
+func serveNaming(h http.Handler, method, path, body string, naming JSONNaming) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(jsonNamingHeader, string(naming))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestResponsesShape(t *testing.T) {
+	h := newTestAPI(Config{}, newResponsesRepo(), node.NewFakeInteractor(nil))
+
+	for _, c := range []struct {
+		naming   JSONNaming
+		path     string
+		expected string
+	}{
+		{
+			naming:   JSONNamingSnakeCase,
+			path:     "/sequences/1",
+			expected: `{"id":1,"broadcasted_count":1,"total_count":2,"last_confirmed_position":0,"state":"error","error":{"message":"tx failed","code":3},"mode":"independent","created_at":1600000000000,"updated_at":1600000000000}`,
+		},
+		{
+			naming:   JSONNamingCamelCase,
+			path:     "/sequences/1",
+			expected: `{"id":1,"broadcastedCount":1,"totalCount":2,"lastConfirmedPosition":0,"state":"error","error":{"message":"tx failed","code":3},"mode":"independent","createdAt":1600000000000,"updatedAt":1600000000000}`,
+		},
+		// the validation trace and the tx are rendered as the node and the client gave them
+		{
+			naming:   JSONNamingSnakeCase,
+			path:     "/sequences/1/transactions/1",
+			expected: `{"id":"","state":"error","height":0,"error_message":"script failed","position_in_sequence":1,"min_confirmations":2,"validation_trace":[{"id":"3P","result":{"error_message":"failed"}}],"tx":"{\"id\":\"b\",\"sender_public_key\":\"pk\"}","created_at":1600000000000,"updated_at":1600000000000}`,
+		},
+		{
+			naming:   JSONNamingCamelCase,
+			path:     "/sequences/1/transactions/1",
+			expected: `{"id":"","state":"error","height":0,"errorMessage":"script failed","positionInSequence":1,"minConfirmations":2,"validationTrace":[{"id":"3P","result":{"error_message":"failed"}}],"tx":"{\"id\":\"b\",\"sender_public_key\":\"pk\"}","createdAt":1600000000000,"updatedAt":1600000000000}`,
+		},
+	} {
+		w := serveNaming(h, http.MethodGet, c.path, "", c.naming)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		require.Equal(t, c.expected, w.Body.String(), "%s in %s", c.path, c.naming)
+	}
+}
+
+func TestSequenceTxWarningsResponse(t *testing.T) {
+	repo := newResponsesRepo()
+	repo.txs[0].ValidationWarnings = []string{"complexity near limit"}
+	h := newTestAPI(Config{}, repo, node.NewFakeInteractor(nil))
+
+	w := serveNaming(h, http.MethodGet, "/sequences/1/transactions/0", "", JSONNamingSnakeCase)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Contains(t, w.Body.String(), `"validation_warnings":["complexity near limit"]`)
+
+	// txs without warnings do not have the key
+	w = serveNaming(h, http.MethodGet, "/sequences/1/transactions/1", "", JSONNamingSnakeCase)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NotContains(t, w.Body.String(), "validation_warnings")
+
+	w = serveNaming(h, http.MethodGet, "/sequences/1/transactions/2", "", JSONNamingSnakeCase)
+	require.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+}
+
+func TestCreatedResponseShape(t *testing.T) {
+	h := newTestAPI(Config{WaitFirstTimeout: time.Second}, newFakeRepo(), node.NewFakeInteractor(nil))
+
+	w := serveNaming(h, http.MethodPost, "/sequences", `{"transactions":[{"id":"1"}],"waitFirst":true}`, JSONNamingSnakeCase)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	require.Regexp(t, `^\{"id":1,"first_tx_height":\d+\}$`, w.Body.String())
+
+	w = serveNaming(h, http.MethodPost, "/sequences", `{"transactions":[{"id":"2"}],"waitFirst":true}`, JSONNamingCamelCase)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	require.Regexp(t, `^\{"id":2,"firstTxHeight":\d+\}$`, w.Body.String())
+}
+
+func TestUnknownNamingIsRejected(t *testing.T) {
+	h := newTestAPI(Config{}, newResponsesRepo(), node.NewFakeInteractor(nil))
+
+	w := serveNaming(h, http.MethodGet, "/sequences/1", "", "kebab-case")
+	require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+}
+
+// auditRepo has txs a and b confirmed at the height 1
+type auditRepo struct {
+	repository.Repository
+}
+
+func (r *auditRepo) GetConfirmedTxsByHeight(height int32) ([]*repository.SequenceTx, error) {
+	return []*repository.SequenceTx{{ID: "a", Height: height}, {ID: "b", Height: height}}, nil
+}
+
+func TestAuditBlockResponseShape(t *testing.T) {
+	nodeInteractor := node.NewFakeInteractor(nil)
+	_, err := nodeInteractor.BroadcastTx(`{"id":"a"}`)
+	require.Nil(t, err)
+	h := newTestAPI(Config{AdminAPIKey: "admin"}, &auditRepo{}, nodeInteractor)
+
+	for naming, expected := range map[JSONNaming]string{
+		JSONNamingSnakeCase: `{"height":1,"block_txs_count":1,"confirmed":["a"],"missing":["b"]}`,
+		JSONNamingCamelCase: `{"height":1,"blockTxsCount":1,"confirmed":["a"],"missing":["b"]}`,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/admin/blocks/1/audit", nil)
+		req.Header.Set("X-API-Key", "admin")
+		req.Header.Set(jsonNamingHeader, string(naming))
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		require.Equal(t, expected, w.Body.String())
+	}
+}
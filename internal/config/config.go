@@ -1,11 +1,16 @@
 package config
 
 import (
+	"errors"
+
 	"github.com/caarlos0/env/v6"
 
+	"github.com/wavesplatform/transaction-broadcaster/internal/api"
 	"github.com/wavesplatform/transaction-broadcaster/internal/dispatcher"
+	"github.com/wavesplatform/transaction-broadcaster/internal/events"
 	"github.com/wavesplatform/transaction-broadcaster/internal/node"
 	"github.com/wavesplatform/transaction-broadcaster/internal/repository"
+	"github.com/wavesplatform/transaction-broadcaster/internal/tracing"
 	"github.com/wavesplatform/transaction-broadcaster/internal/worker"
 )
 
@@ -14,10 +19,16 @@ type Config struct {
 	Port int  `env:"PORT" envDefault:"3000"`
 	Dev  bool `env:"DEV" envDefault:"false"`
 
+	// port the daemon exposes metrics on, 0 means metrics are not exposed
+	MetricsPort int `env:"METRICS_PORT" envDefault:"0"`
+
+	API        api.Config
 	Pg         repository.PgConfig
 	Dispatcher dispatcher.Config
 	Worker     worker.Config
 	Node       node.Config
+	Tracing    tracing.Config
+	Events     events.Config
 }
 
 // Load returns config from environment variables
@@ -28,6 +39,10 @@ func Load() (*Config, error) {
 		return nil, err
 	}
 
+	if err := env.Parse(&c.API); err != nil {
+		return nil, err
+	}
+
 	if err := env.Parse(&c.Pg); err != nil {
 		return nil, err
 	}
@@ -44,5 +59,17 @@ func Load() (*Config, error) {
 		return nil, err
 	}
 
+	if err := env.Parse(&c.Tracing); err != nil {
+		return nil, err
+	}
+
+	if err := env.Parse(&c.Events); err != nil {
+		return nil, err
+	}
+
+	if c.Node.ValidateWithAPIKey && c.Node.NodeAPIKey == "" {
+		return nil, errors.New("WAVES_NODE_API_KEY is required when WAVES_NODE_VALIDATE_WITH_API_KEY is enabled")
+	}
+
 	return &c, nil
 }
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// redacted replaces values of secret variables
+const redacted = "[REDACTED]"
+
+// secrets are variables whose values are never exposed
+var secrets = map[string]bool{
+	"PGPASSWORD":         true,
+	"WAVES_NODE_API_KEY": true,
+	"API_ADMIN_KEY":      true,
+	"NODE_EXTRA_HEADERS": true,
+}
+
+// Effective returns values of all config variables by their names, secrets are redacted
+// credentials are removed from URLs, so the result can be exposed for debugging
+func (c *Config) Effective() map[string]interface{} {
+	values := make(map[string]interface{})
+	collectEffective(reflect.ValueOf(*c), values)
+	return values
+}
+
+func collectEffective(v reflect.Value, values map[string]interface{}) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field, value := t.Field(i), v.Field(i)
+
+		name := strings.Split(field.Tag.Get("env"), ",")[0]
+		if name == "" {
+			if value.Kind() == reflect.Struct {
+				collectEffective(value, values)
+			}
+			continue
+		}
+
+		if secrets[name] {
+			if !value.IsZero() {
+				values[name] = redacted
+			} else {
+				values[name] = ""
+			}
+			continue
+		}
+
+		values[name] = effectiveValue(value.Interface())
+	}
+}
+
+func effectiveValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case time.Duration:
+		return v.String()
+	case url.URL:
+		return v.Redacted()
+	case string:
+		// e.g. proxy and NATS URLs may carry credentials
+		if u, err := url.Parse(v); err == nil && u.User != nil {
+			return u.Redacted()
+		}
+		return v
+	default:
+		return v
+	}
+}
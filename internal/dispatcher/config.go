@@ -1,7 +1,68 @@
 package dispatcher
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
 // Config of the dispatcher package
 type Config struct {
 	LoopDelay   int64 `env:"DISPATCHER_LOOP_DELAY" envDefault:"1000"`
 	SequenceTTL int64 `env:"DISPATCHER_SEQUENCE_TTL" envDefault:"5000"`
+
+	MaxProcessingSequencesPerLabel int          `env:"DISPATCHER_MAX_PROCESSING_SEQUENCES_PER_LABEL" envDefault:"0"`
+	LabelWeights                   LabelWeights `env:"DISPATCHER_LABEL_WEIGHTS"`
+	// max count of pending sequences claimed per loop, labels get their weighted share of every loop, 0 means all of them
+	ClaimBatchSize int `env:"DISPATCHER_CLAIM_BATCH_SIZE" envDefault:"0"`
+
+	// whether failed sequences are copied to the dead letters table
+	DeadLetter bool `env:"DISPATCHER_DEAD_LETTER" envDefault:"false"`
+
+	// whether the dispatcher keeps running if a worker fails with the error fatal only for its sequence (e.g. a failed query),
+	// the sequence fails instead, errors of the unavailable db always stop the dispatcher
+	ContinueOnFatal bool `env:"DISPATCHER_CONTINUE_ON_FATAL" envDefault:"false"`
+
+	// whether states of all txs are checked before the completed sequence is marked done
+	VerifyCompletion bool `env:"DISPATCHER_VERIFY_COMPLETION" envDefault:"false"`
+
+	// processing sequences are leased by the instance for LeaseTTL ms, sequences with expired leases are reclaimed
+	// by other instances, 0 means leases are not used; InstanceID defaults to hostname-pid
+	LeaseTTL   int64  `env:"DISPATCHER_LEASE_TTL" envDefault:"0"`
+	InstanceID string `env:"DISPATCHER_INSTANCE_ID"`
+
+	// done and failed sequences are deleted after RetentionSeconds since their completion unless they have their own retention,
+	// 0 means they are kept forever; expired sequences are looked up every RetentionCheckInterval ms
+	RetentionSeconds       int64 `env:"DISPATCHER_RETENTION_SECONDS" envDefault:"0"`
+	RetentionCheckInterval int64 `env:"DISPATCHER_RETENTION_CHECK_INTERVAL" envDefault:"60000"`
+}
+
+// LabelWeights represents map of label:weight
+type LabelWeights map[string]int
+
+// UnmarshalText parses comma separated list of label:weight pairs, e.g. tenant-a:3,tenant-b:1
+func (w *LabelWeights) UnmarshalText(text []byte) error {
+	weights := LabelWeights{}
+
+	for _, pair := range strings.Split(string(text), ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		idx := strings.LastIndex(pair, ":")
+		if idx <= 0 {
+			return fmt.Errorf("invalid label weight: %s", pair)
+		}
+
+		weight, err := strconv.Atoi(pair[idx+1:])
+		if err != nil || weight <= 0 {
+			return fmt.Errorf("invalid label weight: %s", pair)
+		}
+
+		weights[pair[:idx]] = weight
+	}
+
+	*w = weights
+	return nil
 }
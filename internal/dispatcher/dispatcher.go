@@ -1,11 +1,17 @@
 package dispatcher
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
 	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/wavesplatform/transaction-broadcaster/internal/events"
 	"github.com/wavesplatform/transaction-broadcaster/internal/log"
 	"github.com/wavesplatform/transaction-broadcaster/internal/node"
 	"github.com/wavesplatform/transaction-broadcaster/internal/repository"
@@ -27,20 +33,33 @@ type Dispatcher interface {
 	RunLoop() error
 }
 
-type workerParams struct {
-	txOutdatedTime, txProcessingTTL, heightsAfterLastTx, waitForNextHeightDelay int32
-}
-
 type dispatcherImpl struct {
 	repo                  repository.Repository
 	nodeInteractor        node.Interactor
+	nodeInteractorFactory node.InteractorFactory
+	publisher             events.Publisher
 	logger                *zap.Logger
 	completedSequenceChan chan int64
 	errorsChan            chan workerError
 	loopDelay             time.Duration
 	sequenceTTL           time.Duration
-
-	worker workerParams
+	maxProcessingPerLabel int
+	labelWeights          LabelWeights
+	claimBatchSize        int
+	deadLetter            bool
+	verifyCompletion      bool
+	continueOnFatal       bool
+	leaseTTL              time.Duration
+	instanceID            string
+
+	retention              time.Duration
+	retentionCheckInterval time.Duration
+	lastRetentionCheck     time.Time
+
+	workerCfg     worker.Config
+	workerMetrics *worker.Metrics
+	// progress of the sequences processed by the instance, it is kept while their workers are restarted
+	progress *worker.ProgressTracker
 
 	mutex                    *sync.Mutex
 	sequencesUnderProcessing map[int64]bool
@@ -48,27 +67,40 @@ type dispatcherImpl struct {
 }
 
 // New returns instance of Dispatcher interface implementation
-func New(repo repository.Repository, nodeInteractor node.Interactor, loopDelay, sequenceTTL int64, txOutdatedTime, txProcessingTTL, heightsAfterLastTx, waitForNextHeightDelay int32) Dispatcher {
+// publisher receives events of sequences reaching the terminal state, workerMetrics is optional
+func New(repo repository.Repository, nodeInteractor node.Interactor, nodeInteractorFactory node.InteractorFactory, publisher events.Publisher, cfg Config, workerCfg worker.Config, workerMetrics *worker.Metrics) Dispatcher {
 	logger := log.Logger.Named("dispatcher")
 
+	instanceID := processInstanceID(cfg.InstanceID)
+
 	completedSequenceChan := make(chan int64)
 	errorsChan := make(chan workerError)
 
 	return &dispatcherImpl{
 		repo:                  repo,
 		nodeInteractor:        nodeInteractor,
+		nodeInteractorFactory: nodeInteractorFactory,
+		publisher:             publisher,
 		logger:                logger,
 		completedSequenceChan: completedSequenceChan,
 		errorsChan:            errorsChan,
-		loopDelay:             time.Duration(loopDelay) * time.Millisecond,
-		sequenceTTL:           time.Duration(sequenceTTL) * time.Millisecond,
-
-		worker: workerParams{
-			txOutdatedTime:         txOutdatedTime,
-			txProcessingTTL:        txProcessingTTL,
-			heightsAfterLastTx:     heightsAfterLastTx,
-			waitForNextHeightDelay: waitForNextHeightDelay,
-		},
+		loopDelay:             time.Duration(cfg.LoopDelay) * time.Millisecond,
+		sequenceTTL:           time.Duration(cfg.SequenceTTL) * time.Millisecond,
+		maxProcessingPerLabel: cfg.MaxProcessingSequencesPerLabel,
+		labelWeights:          cfg.LabelWeights,
+		claimBatchSize:        cfg.ClaimBatchSize,
+		deadLetter:            cfg.DeadLetter,
+		verifyCompletion:      cfg.VerifyCompletion,
+		continueOnFatal:       cfg.ContinueOnFatal,
+		leaseTTL:              time.Duration(cfg.LeaseTTL) * time.Millisecond,
+		instanceID:            instanceID,
+
+		retention:              time.Duration(cfg.RetentionSeconds) * time.Second,
+		retentionCheckInterval: time.Duration(cfg.RetentionCheckInterval) * time.Millisecond,
+
+		workerCfg:     workerCfg,
+		workerMetrics: workerMetrics,
+		progress:      worker.NewProgressTracker(),
 
 		mutex:                    &sync.Mutex{},
 		sequencesUnderProcessing: make(map[int64]bool),
@@ -87,38 +119,81 @@ func (d *dispatcherImpl) RunLoop() error {
 
 			d.finishWorker(e.SequenceID)
 
+			// the progress is kept only if the sequence is restarted by the instance
+			restarted := false
+
 			switch e.Err.(type) {
 			case worker.RecoverableError:
 				d.logger.Debug("recoverable error", zap.String("message", e.Err.Error()))
 
-				// refresh sequence status
-				if err := d.repo.SetSequenceStateByID(e.SequenceID, repository.StateProcessing); err != nil {
+				// refresh sequence status, the sequence is not restarted if it was cancelled or taken over by another instance meanwhile
+				updated, err := d.repo.RefreshSequence(e.SequenceID, d.instanceID)
+				if err != nil {
 					d.logger.Error("error occured while setting sequence processing state", zap.Error(err))
 					return err
 				}
-				d.runWorker(e.SequenceID)
+				if !updated {
+					d.logger.Debug("sequence state was changed concurrently, skip it", zap.Int64("sequence_id", e.SequenceID))
+					break
+				}
+				if err := d.runWorker(e.SequenceID); err != nil {
+					return err
+				}
+				restarted = d.isUnderProcessing(e.SequenceID)
 			case worker.NonRecoverableError:
 				d.logger.Debug("non-recoverable error", zap.String("message", e.Err.Error()))
 
-				if err := d.repo.SetSequenceErrorStateByID(e.SequenceID, e.Err.Reason(), e.Err.(worker.ErrorWithReasonAndCode).ErrorCode()); err != nil {
+				updated, err := d.repo.SetOwnedSequenceErrorStateIf(e.SequenceID, d.instanceID, repository.StateProcessing, e.Err.Reason(), e.Err.(worker.ErrorWithReasonAndCode).ErrorCode())
+				if err != nil {
 					d.logger.Error("error occured while setting sequence error state", zap.Error(err))
 					return err
 				}
+				if !updated {
+					d.logDroppedTransition(e.SequenceID, repository.StateError)
+				} else {
+					d.sequenceFailed(e.SequenceID, e.Err.Reason(), e.Err.(worker.ErrorWithReasonAndCode).ErrorCode())
+				}
 			case worker.FatalError:
 				d.logger.Debug("fatal error", zap.String("message", e.Err.Error()))
 
-				return e.Err
+				if !d.continueOnFatal || !e.Err.(worker.FatalError).SequenceOnly() {
+					return e.Err
+				}
+
+				d.logger.Error("sequence failed with fatal error, dispatcher keeps running", zap.Int64("sequence_id", e.SequenceID), zap.Error(e.Err))
+
+				updated, err := d.repo.SetOwnedSequenceErrorStateIf(e.SequenceID, d.instanceID, repository.StateProcessing, e.Err.Reason(), worker.FatalErrorCode)
+				if err != nil {
+					d.logger.Error("error occured while setting sequence error state", zap.Error(err))
+					return err
+				}
+				if !updated {
+					d.logDroppedTransition(e.SequenceID, repository.StateError)
+				} else {
+					d.sequenceFailed(e.SequenceID, e.Err.Reason(), worker.FatalErrorCode)
+				}
 			default:
 			}
+
+			if !restarted {
+				d.progress.Forget(e.SequenceID)
+			}
 		case seqID := <-d.completedSequenceChan:
 			d.logger.Debug("got new completed sequence", zap.Int64("sequence_id", seqID))
 
 			d.finishWorker(seqID)
+			d.progress.Forget(seqID)
 
-			if err := d.repo.SetSequenceStateByID(seqID, repository.StateDone); err != nil {
+			updated, err := d.repo.SetOwnedSequenceStateIf(seqID, d.instanceID, repository.StateProcessing, repository.StateDone)
+			if err != nil {
 				d.logger.Error("error occured while setting sequence done state", zap.Error(err))
 				return err
 			}
+			if !updated {
+				d.logDroppedTransition(seqID, repository.StateDone)
+			} else {
+				d.publish(events.NewEvent(seqID, repository.StateDone, "", 0))
+			}
 		case <-ticker.C:
 			d.logger.Debug("next ticker tick")
 
@@ -131,6 +206,18 @@ func (d *dispatcherImpl) RunLoop() error {
 			}
 			d.mutex.Unlock()
 
+			if d.leaseTTL > 0 {
+				if err := d.reclaimExpiredLeases(sequenceIDsUnderProcessing); err != nil {
+					return err
+				}
+			}
+
+			if time.Since(d.lastRetentionCheck) >= d.retentionCheckInterval {
+				if err := d.deleteExpiredSequences(); err != nil {
+					return err
+				}
+			}
+
 			hangingSequenceIds, err := d.repo.GetHangingSequenceIds(d.sequenceTTL, sequenceIDsUnderProcessing)
 			if err != nil {
 				d.logger.Error("error occured while getting hangins sequence ids", zap.Error(err))
@@ -141,18 +228,30 @@ func (d *dispatcherImpl) RunLoop() error {
 				d.logger.Debug("processing hanging sequences", zap.Int("count", len(hangingSequenceIds)), zap.Int64s("hanging_sequence_ids", hangingSequenceIds))
 
 				for _, seqID := range hangingSequenceIds {
-					// refresh sequence status
-					if err := d.repo.SetSequenceStateByID(seqID, repository.StateProcessing); err != nil {
+					// take over the sequence, it may have been taken over by another instance since it was looked up
+					updated, err := d.repo.TakeOverHangingSequence(seqID, d.instanceID, d.sequenceTTL)
+					if err != nil {
 						d.logger.Error("error occurred while updating sequence state", zap.Error(err), zap.Int64("sequence_id", seqID))
 						return err
 					}
+					if !updated {
+						continue
+					}
 
-					d.runWorker(seqID)
+					if err := d.runWorker(seqID); err != nil {
+						return err
+					}
 				}
 			}
 		default:
+			claimOptions := repository.ClaimOptions{
+				MaxProcessingPerLabel: d.maxProcessingPerLabel,
+				LabelWeights:          d.labelWeights,
+				Limit:                 d.claimBatchSize,
+			}
+
 			d.logger.Debug("getting new sequences")
-			newSequenceIds, err := d.repo.GetNewSequenceIds()
+			newSequenceIds, err := d.repo.GetNewSequenceIds(claimOptions)
 			if err != nil {
 				d.logger.Error("error occured while getting new sequences ids", zap.Error(err))
 				return err
@@ -162,31 +261,75 @@ func (d *dispatcherImpl) RunLoop() error {
 				d.logger.Debug("processing new sequences", zap.Int("count", len(newSequenceIds)), zap.Int64s("new_sequence_ids", newSequenceIds))
 
 				for _, seqID := range newSequenceIds {
-					// refresh sequence status
-					if err := d.repo.SetSequenceStateByID(seqID, repository.StateProcessing); err != nil {
+					// the sequence was moved back to pending while its worker still runs, e.g. it was skipped to a position,
+					// it is claimed after the worker stops
+					if d.isUnderProcessing(seqID) {
+						continue
+					}
+
+					// refresh sequence status, the sequence may have been taken by another instance
+					updated, err := d.repo.ClaimSequence(seqID, d.instanceID)
+					if err != nil {
 						d.logger.Error("error occurred while updating sequence state", zap.Error(err), zap.Int64("sequence_id", seqID))
 						return err
 					}
+					if !updated {
+						continue
+					}
 
-					d.runWorker(seqID)
+					if err := d.runWorker(seqID); err != nil {
+						return err
+					}
 				}
 			}
+
+			// more sequences may be pending after the full batch, they are claimed without waiting for the loop delay
+			if d.claimBatchSize > 0 && len(newSequenceIds) >= d.claimBatchSize {
+				continue
+			}
 			time.Sleep(d.loopDelay)
 		}
 	}
 }
 
-func (d *dispatcherImpl) runWorker(seqID int64) {
+func (d *dispatcherImpl) runWorker(seqID int64) error {
+	options, err := d.repo.GetSequenceOptions(seqID)
+	if err != nil {
+		d.logger.Error("error occurred while getting sequence options", zap.Error(err), zap.Int64("sequence_id", seqID))
+		return err
+	}
+
+	nodeInteractor, err := d.sequenceNodeInteractor(seqID, options)
+	if err != nil {
+		return err
+	}
+
+	if nodeInteractor == nil {
+		// sequence cannot be processed, it was already moved to the error state
+		return nil
+	}
+
+	if d.leaseTTL > 0 {
+		if err := d.repo.SetSequencesLease([]int64{seqID}, d.instanceID, d.leaseTTL); err != nil {
+			d.logger.Error("error occurred while setting sequence lease", zap.Error(err), zap.Int64("sequence_id", seqID))
+			return err
+		}
+	}
+
 	newWorkersCount := atomic.AddInt64(&d.workersCounter, 1)
 
-	w := worker.New(strconv.FormatInt(newWorkersCount, 10), d.repo, d.nodeInteractor, d.worker.txOutdatedTime, d.worker.txProcessingTTL, d.worker.heightsAfterLastTx, d.worker.waitForNextHeightDelay)
+	w := worker.New(strconv.FormatInt(newWorkersCount, 10), d.instanceID, d.repo, nodeInteractor, *options, d.workerCfg, d.workerMetrics, d.progress)
+
+	d.mutex.Lock()
+	d.sequencesUnderProcessing[seqID] = true
+	d.mutex.Unlock()
 
 	go func() {
-		d.mutex.Lock()
-		d.sequencesUnderProcessing[seqID] = true
-		d.mutex.Unlock()
 
 		err := w.Run(seqID)
+		if err == nil && d.verifyCompletion {
+			err = d.verifyCompleted(seqID, options.Mode)
+		}
 
 		if err != nil {
 			d.errorsChan <- workerError{
@@ -198,6 +341,163 @@ func (d *dispatcherImpl) runWorker(seqID int64) {
 
 		d.completedSequenceChan <- seqID
 	}()
+
+	return nil
+}
+
+// reclaimExpiredLeases renews leases of the sequences processed by the instance
+// and takes over sequences whose owners have not renewed their leases, e.g. because the owner instance is dead
+func (d *dispatcherImpl) reclaimExpiredLeases(sequenceIDsUnderProcessing []int64) error {
+	if err := d.repo.SetSequencesLease(sequenceIDsUnderProcessing, d.instanceID, d.leaseTTL); err != nil {
+		d.logger.Error("error occurred while renewing sequences leases", zap.Error(err))
+		return err
+	}
+
+	expiredSequenceIds, err := d.repo.GetSequencesOwnedByExpiredLease(d.instanceID)
+	if err != nil {
+		d.logger.Error("error occurred while getting sequences with expired leases", zap.Error(err))
+		return err
+	}
+
+	for _, seqID := range expiredSequenceIds {
+		reclaimed, err := d.repo.ReclaimSequence(seqID, d.instanceID, d.leaseTTL)
+		if err != nil {
+			d.logger.Error("error occurred while reclaiming sequence", zap.Error(err), zap.Int64("sequence_id", seqID))
+			return err
+		}
+		if !reclaimed {
+			continue
+		}
+
+		d.logger.Info("reclaimed sequence with expired lease", zap.Int64("sequence_id", seqID), zap.String("owner", d.instanceID))
+
+		if err := d.runWorker(seqID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deleteExpiredSequences deletes completed sequences kept longer than their retention
+func (d *dispatcherImpl) deleteExpiredSequences() error {
+	d.lastRetentionCheck = time.Now()
+
+	count, err := d.repo.DeleteExpiredSequences(d.retention)
+	if err != nil {
+		d.logger.Error("error occurred while deleting expired sequences", zap.Error(err))
+		return err
+	}
+	if count > 0 {
+		d.logger.Info("deleted expired sequences", zap.Int("count", count))
+	}
+
+	return nil
+}
+
+// verifyCompleted checks all txs of the sequence the worker completed are in the final state of its mode
+// sequence with unfinished txs is reprocessed instead of being marked done
+func (d *dispatcherImpl) verifyCompleted(seqID int64, mode repository.SequenceMode) worker.ErrorWithReason {
+	finalState := repository.TransactionStateConfirmed
+	if mode == repository.SequenceModeValidateOnly {
+		finalState = repository.TransactionStateValidated
+	}
+
+	count, err := d.repo.CountSequenceTxsNotInState(seqID, finalState)
+	if err != nil {
+		d.logger.Error("error occurred while counting unfinished sequence txs", zap.Error(err), zap.Int64("sequence_id", seqID))
+		return worker.NewDBError(err)
+	}
+
+	if count > 0 {
+		d.logger.Warn("completed sequence has unfinished txs, it is reprocessed", zap.Int64("sequence_id", seqID), zap.Int("count", count))
+		return worker.NewRecoverableError(fmt.Sprintf("%d txs of the completed sequence are not finished", count))
+	}
+
+	return nil
+}
+
+// sequenceNodeInteractor returns node interactor the sequence has to be processed with
+// returns nil interactor if the sequence node url is invalid, the sequence is moved to the error state in this case
+func (d *dispatcherImpl) sequenceNodeInteractor(seqID int64, options *repository.SequenceOptions) (node.Interactor, error) {
+	if options.NodeURL == "" {
+		return d.nodeInteractor, nil
+	}
+
+	nodeURL, err := url.Parse(options.NodeURL)
+	if err != nil {
+		d.logger.Error("invalid sequence node url", zap.Error(err), zap.Int64("sequence_id", seqID), zap.String("node_url", options.NodeURL))
+
+		errorMessage := "invalid node url: " + err.Error()
+		updated, err := d.repo.SetOwnedSequenceErrorStateIf(seqID, d.instanceID, repository.StateProcessing, errorMessage, 0)
+		if err != nil {
+			d.logger.Error("error occured while setting sequence error state", zap.Error(err))
+			return nil, err
+		}
+		if updated {
+			d.sequenceFailed(seqID, errorMessage, 0)
+		}
+		return nil, nil
+	}
+
+	return d.nodeInteractorFactory(*nodeURL), nil
+}
+
+// logDroppedTransition logs the sequence state the transition to newState was dropped in
+// completion never overwrites a final state, e.g. the error set after a previous run of the sequence
+func (d *dispatcherImpl) logDroppedTransition(seqID int64, newState repository.State) {
+	seq, err := d.repo.GetSequenceByID(seqID)
+	if err != nil || seq == nil {
+		d.logger.Debug("sequence state was changed concurrently, state is not set", zap.Int64("sequence_id", seqID), zap.Uint8("new_state", uint8(newState)), zap.Error(err))
+		return
+	}
+
+	if seq.State.IsFinal() {
+		d.logger.Warn("sequence has already reached a final state, state is not set", zap.Int64("sequence_id", seqID), zap.Uint8("state", uint8(seq.State)), zap.Uint8("new_state", uint8(newState)))
+		return
+	}
+
+	d.logger.Debug("sequence state was changed concurrently, state is not set", zap.Int64("sequence_id", seqID), zap.Uint8("state", uint8(seq.State)), zap.Uint8("new_state", uint8(newState)))
+}
+
+// sequenceFailed publishes the error event and copies the sequence to dead letters if it is enabled
+// dead letter errors do not affect sequences processing, the sequence is already in the error state
+func (d *dispatcherImpl) sequenceFailed(seqID int64, errorMessage string, errorCode uint16) {
+	d.publish(events.NewEvent(seqID, repository.StateError, errorMessage, errorCode))
+
+	if d.deadLetter {
+		if err := d.repo.CreateDeadLetter(seqID); err != nil {
+			d.logger.Error("error occurred while creating dead letter", zap.Error(err), zap.Int64("sequence_id", seqID))
+		}
+	}
+}
+
+// processInstanceID returns the owner id of sequences processed by the process, instanceID defaults to hostname-pid
+// the id gets a random suffix, so the restarted instance with the same id reclaims leases of its previous process
+func processInstanceID(instanceID string) string {
+	if instanceID == "" {
+		hostname, _ := os.Hostname()
+		instanceID = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return fmt.Sprintf("%s-%d", instanceID, time.Now().UnixNano())
+	}
+	return instanceID + "-" + hex.EncodeToString(suffix)
+}
+
+// publish publishes the event, publishing errors do not affect sequences processing
+func (d *dispatcherImpl) publish(event events.Event) {
+	if err := d.publisher.Publish(event); err != nil {
+		d.logger.Error("error occurred while publishing sequence event", zap.Error(err), zap.Int64("sequence_id", event.SequenceID))
+	}
+}
+
+func (d *dispatcherImpl) isUnderProcessing(seqID int64) bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.sequencesUnderProcessing[seqID]
 }
 
 func (d *dispatcherImpl) finishWorker(seqID int64) {
@@ -0,0 +1,20 @@
+package dispatcher
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessInstanceID(t *testing.T) {
+	id := processInstanceID("instance")
+	require.True(t, strings.HasPrefix(id, "instance-"), id)
+	// the restarted process does not own leases of the previous one
+	require.NotEqual(t, id, processInstanceID("instance"))
+
+	hostname, _ := os.Hostname()
+	require.True(t, strings.HasPrefix(processInstanceID(""), fmt.Sprintf("%s-%d-", hostname, os.Getpid())))
+}
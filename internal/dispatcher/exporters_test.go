@@ -0,0 +1,163 @@
+package dispatcher
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+
+	"github.com/wavesplatform/transaction-broadcaster/internal/events"
+	"github.com/wavesplatform/transaction-broadcaster/internal/repository"
+	"github.com/wavesplatform/transaction-broadcaster/internal/tracing"
+	"github.com/wavesplatform/transaction-broadcaster/internal/worker"
+)
+
+// hangingEndpoint returns the address accepting connections which never respond, as a stuck collector does
+func hangingEndpoint(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	var (
+		mutex sync.Mutex
+		conns []net.Conn
+	)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			mutex.Lock()
+			conns = append(conns, conn)
+			mutex.Unlock()
+		}
+	}()
+	t.Cleanup(func() {
+		listener.Close()
+		mutex.Lock()
+		defer mutex.Unlock()
+		for _, conn := range conns {
+			conn.Close()
+		}
+	})
+
+	return listener.Addr().String()
+}
+
+// refusingEndpoint returns the address nothing listens on, as a stopped server has
+func refusingEndpoint(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	require.NoError(t, listener.Close())
+	return addr
+}
+
+// gatheredSum returns the sum of all the series of the counter registered in registry
+func gatheredSum(t *testing.T, registry *prometheus.Registry, name string) float64 {
+	families, err := registry.Gather()
+	require.NoError(t, err)
+
+	sum := float64(0)
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			sum += metric.GetCounter().GetValue()
+		}
+	}
+	return sum
+}
+
+// timingPublisher keeps the longest duration of the Publish call
+type timingPublisher struct {
+	events.Publisher
+
+	mutex   sync.Mutex
+	longest time.Duration
+}
+
+func (p *timingPublisher) Publish(event events.Event) error {
+	start := time.Now()
+	err := p.Publisher.Publish(event)
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if elapsed := time.Since(start); elapsed > p.longest {
+		p.longest = elapsed
+	}
+	return err
+}
+
+func TestDeadExportersDoNotSlowDownSequences(t *testing.T) {
+	registry := prometheus.NewRegistry()
+
+	shutdown, err := tracing.Init(tracing.Config{
+		OTLPEndpoint:  hangingEndpoint(t),
+		OTLPInsecure:  true,
+		SampleRatio:   1,
+		ExportTimeout: 10000,
+		QueueSize:     2,
+	}, "test", registry)
+	require.NoError(t, err)
+	defer func() {
+		// the stuck export is not waited for
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		shutdown(ctx)
+	}()
+	// tracing.Tracer is bound to the provider set first, the provider of this run is taken explicitly, so the test may be repeated
+	tracer := otel.Tracer("test")
+
+	dead, err := events.New(events.Config{NATSURL: "nats://" + refusingEndpoint(t), NATSSubject: "test"}, registry)
+	require.NoError(t, err)
+	publisher := &timingPublisher{Publisher: dead}
+
+	// every event is about 64KB, so the 8MB reconnect buffer of the publisher overflows
+	const sequencesCount = 200
+	errorMessage := strings.Repeat("x", 64<<10)
+
+	states := make(map[int64]repository.State, sequencesCount)
+	for id := int64(1); id <= sequencesCount; id++ {
+		states[id] = repository.StateProcessing
+	}
+	repo := newLoopRepo(states)
+	d, _ := newLoopDispatcher(repo, publisher, Config{})
+	stop := runLoop(d, repo)
+
+	longestSpans := time.Duration(0)
+	for id := int64(1); id <= sequencesCount; id++ {
+		start := time.Now()
+		ctx, span := tracer.Start(context.Background(), "worker.Run")
+		for i := 0; i < 5; i++ {
+			_, child := tracer.Start(ctx, "node.Broadcast")
+			child.End()
+		}
+		span.End()
+		if elapsed := time.Since(start); elapsed > longestSpans {
+			longestSpans = elapsed
+		}
+
+		d.errorsChan <- workerError{SequenceID: id, Err: worker.NewNonRecoverableError(errorMessage, 1)}
+	}
+	require.Equal(t, errLoopStopped, stop())
+
+	for id := int64(1); id <= sequencesCount; id++ {
+		require.Equal(t, repository.StateError, repo.state(id).State)
+	}
+
+	// waiting for the dead collector would take the export timeout, waiting for the dead bus would never end
+	require.Less(t, int64(longestSpans), int64(100*time.Millisecond), "spans are blocked by the dead collector")
+	// publishing copies the event to the reconnect buffer, which takes long only while the buffer grows
+	require.Less(t, int64(publisher.longest), int64(time.Second), "events are blocked by the dead message bus")
+
+	require.Greater(t, gatheredSum(t, registry, "tracing_dropped_spans_total"), float64(0))
+	require.Greater(t, gatheredSum(t, registry, "events_dropped_total"), float64(0))
+}
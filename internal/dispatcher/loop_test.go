@@ -0,0 +1,240 @@
+package dispatcher
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/wavesplatform/transaction-broadcaster/internal/events"
+	"github.com/wavesplatform/transaction-broadcaster/internal/log"
+	"github.com/wavesplatform/transaction-broadcaster/internal/repository"
+	"github.com/wavesplatform/transaction-broadcaster/internal/worker"
+)
+
+var errLoopStopped = errors.New("loop stopped")
+
+// loopRepo keeps states of sequences in memory, methods which are not overridden panic
+// there are no new, hanging or expired sequences, the loop is stopped by failing the claim
+type loopRepo struct {
+	repository.Repository
+
+	mutex     sync.Mutex
+	sequences map[int64]*repository.Sequence
+	stopped   bool
+	// unfinished are counts of txs which are not in the final state by sequence
+	unfinished map[int64]int
+	// claims are options of the new sequences lookups
+	claims []repository.ClaimOptions
+}
+
+func newLoopRepo(states map[int64]repository.State) *loopRepo {
+	r := &loopRepo{sequences: make(map[int64]*repository.Sequence)}
+	for id, state := range states {
+		r.sequences[id] = &repository.Sequence{ID: id, State: state}
+	}
+	return r
+}
+
+func (r *loopRepo) stop() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.stopped = true
+}
+
+func (r *loopRepo) state(id int64) repository.Sequence {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return *r.sequences[id]
+}
+
+func (r *loopRepo) GetNewSequenceIds(options repository.ClaimOptions) ([]int64, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.stopped {
+		return nil, errLoopStopped
+	}
+	r.claims = append(r.claims, options)
+	return nil, nil
+}
+
+func (r *loopRepo) CountSequenceTxsNotInState(id int64, state repository.TransactionState) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.unfinished[id], nil
+}
+
+func (r *loopRepo) GetHangingSequenceIds(ttl time.Duration, excluding []int64) ([]int64, error) {
+	return nil, nil
+}
+
+func (r *loopRepo) DeleteExpiredSequences(defaultRetention time.Duration) (int, error) {
+	return 0, nil
+}
+
+func (r *loopRepo) GetSequenceByID(id int64) (*repository.Sequence, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	s := *r.sequences[id]
+	return &s, nil
+}
+
+func (r *loopRepo) SetOwnedSequenceStateIf(id int64, owner string, expectedState, newState repository.State) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.sequences[id].State != expectedState {
+		return false, nil
+	}
+	r.sequences[id].State = newState
+	return true, nil
+}
+
+func (r *loopRepo) SetOwnedSequenceErrorStateIf(id int64, owner string, expectedState repository.State, errorMessage string, errorCode uint16) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.sequences[id].State != expectedState {
+		return false, nil
+	}
+	r.sequences[id].State = repository.StateError
+	r.sequences[id].ErrorMessage = errorMessage
+	r.sequences[id].ErrorCode = int16(errorCode)
+	return true, nil
+}
+
+// recordingPublisher keeps published events
+type recordingPublisher struct {
+	mutex  sync.Mutex
+	events []events.Event
+}
+
+func (p *recordingPublisher) Publish(event events.Event) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.events = append(p.events, event)
+	return nil
+}
+
+func (p *recordingPublisher) Close() error {
+	return nil
+}
+
+func (p *recordingPublisher) states() []repository.State {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	states := make([]repository.State, 0, len(p.events))
+	for _, event := range p.events {
+		states = append(states, event.State)
+	}
+	return states
+}
+
+// newLoopDispatcher returns the dispatcher looping every ms and its logs
+func newLoopDispatcher(repo repository.Repository, publisher events.Publisher, cfg Config) (*dispatcherImpl, *observer.ObservedLogs) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	log.Logger = zap.New(core)
+
+	cfg.LoopDelay = 1
+	return New(repo, nil, nil, publisher, cfg, worker.Config{}, nil).(*dispatcherImpl), logs
+}
+
+// runLoop runs the loop until the returned function stops it, the loop error is returned
+func runLoop(d *dispatcherImpl, repo *loopRepo) func() error {
+	loopErr := make(chan error, 1)
+	go func() {
+		loopErr <- d.RunLoop()
+	}()
+
+	return func() error {
+		repo.stop()
+		return <-loopErr
+	}
+}
+
+func TestCompletionDoesNotOverwriteError(t *testing.T) {
+	repo := newLoopRepo(map[int64]repository.State{1: repository.StateProcessing})
+	publisher := &recordingPublisher{}
+	d, logs := newLoopDispatcher(repo, publisher, Config{})
+	stop := runLoop(d, repo)
+
+	// the error of the previous run of the sequence is handled before its completion
+	d.errorsChan <- workerError{SequenceID: 1, Err: worker.NewNonRecoverableError("failed", 1)}
+	d.completedSequenceChan <- 1
+
+	require.Equal(t, errLoopStopped, stop())
+	require.Equal(t, repository.StateError, repo.state(1).State)
+	require.Equal(t, "failed", repo.state(1).ErrorMessage)
+	require.Equal(t, []repository.State{repository.StateError}, publisher.states())
+	require.Equal(t, 1, logs.FilterMessage("sequence has already reached a final state, state is not set").FilterField(zap.Int64("sequence_id", 1)).Len())
+}
+
+func TestVerifyCompleted(t *testing.T) {
+	repo := newLoopRepo(map[int64]repository.State{1: repository.StateProcessing, 2: repository.StateProcessing})
+	repo.unfinished = map[int64]int{1: 1}
+	d, logs := newLoopDispatcher(repo, &recordingPublisher{}, Config{VerifyCompletion: true})
+
+	// the tx is unexpectedly still unconfirmed at the completion, so the sequence is reprocessed
+	err := d.verifyCompleted(1, repository.SequenceModeBroadcast)
+	require.IsType(t, worker.RecoverableError{}, err)
+	require.Equal(t, "1 txs of the completed sequence are not finished", err.Reason())
+	require.Equal(t, 1, logs.FilterMessage("completed sequence has unfinished txs, it is reprocessed").Len())
+
+	require.Nil(t, d.verifyCompleted(2, repository.SequenceModeBroadcast))
+}
+
+func TestContinueOnFatal(t *testing.T) {
+	repo := newLoopRepo(map[int64]repository.State{1: repository.StateProcessing})
+	publisher := &recordingPublisher{}
+	d, logs := newLoopDispatcher(repo, publisher, Config{ContinueOnFatal: true})
+	stop := runLoop(d, repo)
+
+	d.errorsChan <- workerError{SequenceID: 1, Err: worker.NewSequenceFatalError("broken tx data")}
+
+	require.Equal(t, errLoopStopped, stop())
+	require.Equal(t, repository.StateError, repo.state(1).State)
+	require.Equal(t, "broken tx data", repo.state(1).ErrorMessage)
+	require.Equal(t, int16(worker.FatalErrorCode), repo.state(1).ErrorCode)
+	require.Equal(t, []repository.State{repository.StateError}, publisher.states())
+	require.Equal(t, 1, logs.FilterMessage("sequence failed with fatal error, dispatcher keeps running").Len())
+}
+
+func TestFatalErrorStopsLoop(t *testing.T) {
+	for name, tc := range map[string]struct {
+		continueOnFatal bool
+		err             worker.ErrorWithReason
+	}{
+		"sequence fatal error": {continueOnFatal: false, err: worker.NewSequenceFatalError("broken tx data")},
+		"process fatal error":  {continueOnFatal: true, err: worker.NewFatalError("pg: database is closed")},
+	} {
+		t.Run(name, func(t *testing.T) {
+			repo := newLoopRepo(map[int64]repository.State{1: repository.StateProcessing})
+			d, _ := newLoopDispatcher(repo, &recordingPublisher{}, Config{ContinueOnFatal: tc.continueOnFatal})
+			stop := runLoop(d, repo)
+
+			d.errorsChan <- workerError{SequenceID: 1, Err: tc.err}
+
+			require.Equal(t, tc.err, stop())
+			require.Equal(t, repository.StateProcessing, repo.state(1).State)
+		})
+	}
+}
+
+func TestClaimIsBounded(t *testing.T) {
+	repo := newLoopRepo(nil)
+	weights := LabelWeights{"a": 2}
+	d, _ := newLoopDispatcher(repo, &recordingPublisher{}, Config{LabelWeights: weights, ClaimBatchSize: 10, MaxProcessingSequencesPerLabel: 5})
+	stop := runLoop(d, repo)
+
+	require.Eventually(t, func() bool {
+		repo.mutex.Lock()
+		defer repo.mutex.Unlock()
+		return len(repo.claims) > 0
+	}, time.Second, time.Millisecond)
+	require.Equal(t, errLoopStopped, stop())
+
+	require.Equal(t, repository.ClaimOptions{MaxProcessingPerLabel: 5, LabelWeights: weights, Limit: 10}, repo.claims[0])
+}
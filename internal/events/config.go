@@ -0,0 +1,7 @@
+package events
+
+// Config of the events package
+type Config struct {
+	NATSURL     string `env:"EVENTS_NATS_URL"`
+	NATSSubject string `env:"EVENTS_NATS_SUBJECT" envDefault:"transaction-broadcaster.sequences"`
+}
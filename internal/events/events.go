@@ -0,0 +1,129 @@
+package events
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/wavesplatform/transaction-broadcaster/internal/repository"
+)
+
+// Event represents sequence state change
+type Event struct {
+	SequenceID   int64            `json:"sequence_id"`
+	State        repository.State `json:"state"`
+	ErrorMessage string           `json:"error_message,omitempty"`
+	ErrorCode    uint16           `json:"error_code,omitempty"`
+	Timestamp    int64            `json:"timestamp"`
+}
+
+// NewEvent returns Event of the sequence state change happened now
+func NewEvent(sequenceID int64, state repository.State, errorMessage string, errorCode uint16) Event {
+	return Event{
+		SequenceID:   sequenceID,
+		State:        state,
+		ErrorMessage: errorMessage,
+		ErrorCode:    errorCode,
+		Timestamp:    time.Now().UnixNano() / int64(time.Millisecond),
+	}
+}
+
+// Publisher publishes sequence events to a message bus
+type Publisher interface {
+	Publish(Event) error
+	Close() error
+}
+
+// New returns Publisher configured by cfg, events are not published anywhere if the bus is not configured
+// events failed to be published are counted by events_dropped_total registered in registerer
+func New(cfg Config, registerer prometheus.Registerer) (Publisher, error) {
+	if cfg.NATSURL == "" {
+		return NewNoopPublisher(), nil
+	}
+
+	dropped := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "events_dropped_total",
+		Help: "Count of sequence events dropped because they could not be published",
+	})
+	if err := registerer.Register(dropped); err != nil {
+		return nil, err
+	}
+
+	publisher, err := NewNATSPublisher(cfg.NATSURL, cfg.NATSSubject)
+	if err != nil {
+		return nil, err
+	}
+	return &countingPublisher{Publisher: publisher, dropped: dropped}, nil
+}
+
+type countingPublisher struct {
+	Publisher
+	dropped prometheus.Counter
+}
+
+func (p *countingPublisher) Publish(event Event) error {
+	err := p.Publisher.Publish(event)
+	if err != nil {
+		p.dropped.Inc()
+	}
+	return err
+}
+
+type noopPublisher struct{}
+
+// NewNoopPublisher returns Publisher dropping all events
+func NewNoopPublisher() Publisher {
+	return noopPublisher{}
+}
+
+func (noopPublisher) Publish(Event) error {
+	return nil
+}
+
+func (noopPublisher) Close() error {
+	return nil
+}
+
+type natsPublisher struct {
+	conn      *nats.Conn
+	subject   string
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// NewNATSPublisher returns Publisher sending events as JSON messages to the NATS subject
+// publishing never blocks: while the server is unavailable events are buffered by the connection
+// and they are dropped with an error once the buffer is full
+func NewNATSPublisher(url, subject string) (Publisher, error) {
+	conn, err := nats.Connect(
+		url,
+		nats.Name("transaction-broadcaster"),
+		nats.MaxReconnects(-1),
+		nats.RetryOnFailedConnect(true),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &natsPublisher{conn: conn, subject: subject}, nil
+}
+
+func (p *natsPublisher) Publish(event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return p.conn.Publish(p.subject, data)
+}
+
+// Close flushes buffered events and closes the connection, it is idempotent
+func (p *natsPublisher) Close() error {
+	p.closeOnce.Do(func() {
+		p.closeErr = p.conn.Flush()
+		p.conn.Close()
+	})
+	return p.closeErr
+}
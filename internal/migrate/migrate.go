@@ -0,0 +1,63 @@
+package migrate
+
+import (
+	"net/http"
+
+	"github.com/go-pg/migrations/v7"
+	"github.com/go-pg/pg/v9"
+	"go.uber.org/zap"
+
+	"github.com/wavesplatform/transaction-broadcaster/db"
+	"github.com/wavesplatform/transaction-broadcaster/internal/log"
+)
+
+// migrationsDir is the migrations dir of the embedded filesystem
+// it has to be absolute, because the discovery makes the dir absolute before opening it
+const migrationsDir = "/migrations"
+
+// newCollection returns collection of the embedded migrations
+func newCollection() (*migrations.Collection, error) {
+	collection := migrations.NewCollection().DisableSQLAutodiscover(true)
+	if err := collection.DiscoverSQLMigrationsFromFilesystem(http.FS(db.Migrations), migrationsDir); err != nil {
+		return nil, err
+	}
+	return collection, nil
+}
+
+// Run runs the migrations command (init, up, down, reset, version, set_version), see go-pg/migrations
+func Run(conn *pg.DB, args ...string) (int64, int64, error) {
+	collection, err := newCollection()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return collection.Run(conn, args...)
+}
+
+// Up creates the version table if it does not exist and runs all migrations which were not run yet
+// concurrent runs are safe, the version table is locked while migrations are running
+func Up(conn *pg.DB) error {
+	logger := log.Logger.Named("migrate")
+
+	collection, err := newCollection()
+	if err != nil {
+		return err
+	}
+
+	if _, _, err := collection.Run(conn, "init"); err != nil {
+		return err
+	}
+
+	oldVersion, newVersion, err := collection.Run(conn, "up")
+	if err != nil {
+		return err
+	}
+
+	if newVersion != oldVersion {
+		logger.Info("db is migrated", zap.Int64("old_version", oldVersion), zap.Int64("new_version", newVersion))
+	} else {
+		logger.Debug("db is up to date", zap.Int64("version", oldVersion))
+	}
+
+	return nil
+}
@@ -0,0 +1,87 @@
+package node
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// BroadcastResult is the result of broadcasting one of the txs by BroadcastTxs
+type BroadcastResult struct {
+	ID  string
+	Err Error
+}
+
+type batchBroadcastResponseItem struct {
+	ID      string
+	Error   uint16
+	Message string
+}
+
+// BroadcastTxs broadcasts txs by a single request to the batch broadcast endpoint, results are in the order of txs
+// the node accepts or rejects every tx on its own, so some of the results may have errors
+// falls back to broadcasting txs one by one if the batch endpoint is not configured or the node does not have it
+func (r *impl) BroadcastTxs(txs []string) (results []BroadcastResult, wavesErr Error) {
+	if r.batchBroadcastPath == "" {
+		return r.broadcastTxsOneByOne(txs), nil
+	}
+
+	r, span := r.startSpan("node.BroadcastTxs")
+	defer func() { endSpan(span, wavesErr) }()
+
+	batchBroadcastURL := r.nodeURL
+	batchBroadcastURL.Path = r.batchBroadcastPath
+
+	body := "[" + strings.Join(txs, ",") + "]"
+	resp, err := r.post("batch_broadcast", batchBroadcastURL.String(), "application/json", strings.NewReader(body))
+	if err != nil {
+		return nil, NewError(InternalError, err.Error())
+	}
+
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		r.logger.Debug("batch broadcast endpoint is not found, fallback to broadcasting txs one by one", zap.String("path", r.batchBroadcastPath))
+		return r.broadcastTxsOneByOne(txs), nil
+	case http.StatusBadRequest:
+		errorResponseDto := errorResponse{}
+		if err = json.NewDecoder(resp.Body).Decode(&errorResponseDto); err != nil {
+			return nil, NewError(InternalError, err.Error())
+		}
+		return nil, WithNodeError(NewError(BroadcastClientError, errorResponseDto.Message), errorResponseDto.Error)
+	default:
+		return nil, NewError(BroadcastServerError, resp.Status)
+	}
+
+	var items []batchBroadcastResponseItem
+	if err = json.NewDecoder(resp.Body).Decode(&items); err != nil {
+		return nil, NewError(InternalError, err.Error())
+	}
+	if len(items) != len(txs) {
+		return nil, NewError(InternalError, fmt.Sprintf("batch broadcast returned %d results for %d txs", len(items), len(txs)))
+	}
+
+	results = make([]BroadcastResult, len(items))
+	for i, item := range items {
+		if item.Error != 0 || item.ID == "" {
+			results[i].Err = WithNodeError(NewError(BroadcastClientError, item.Message), item.Error)
+			continue
+		}
+		results[i].ID = item.ID
+	}
+
+	return results, nil
+}
+
+func (r *impl) broadcastTxsOneByOne(txs []string) []BroadcastResult {
+	results := make([]BroadcastResult, len(txs))
+	for i, tx := range txs {
+		results[i].ID, results[i].Err = r.BroadcastTx(tx)
+	}
+	return results
+}
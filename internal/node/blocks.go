@@ -0,0 +1,282 @@
+package node
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// maxBlockEventSize is the max size of the blocks stream line, events may carry whole block headers
+const maxBlockEventSize = 1024 * 1024
+
+// blockEvent is the data of the blocks stream event, other fields of the event are ignored
+type blockEvent struct {
+	Height int32 `json:"height"`
+}
+
+// blockStreams are the node blocks streams shared by all subscribers of the same stream url
+var blockStreams = &blocksHub{streams: make(map[string]*blocksStream)}
+
+type blocksHub struct {
+	mutex   sync.Mutex
+	streams map[string]*blocksStream
+}
+
+// blocksStream is the single connection to the node blocks stream, its heights are sent to all subscribers
+type blocksStream struct {
+	cancel      context.CancelFunc
+	subscribers map[chan int32]struct{}
+}
+
+// SubscribeBlocks returns heights of new blocks read from the node blocks stream (server-sent events)
+// subscribers of the same stream url share the connection, it is closed after the last subscriber's ctx is done
+// the channel is closed when ctx is done, the stream ends or it stalls, heights seen before the subscription are not sent
+// a slow subscriber gets the latest height only
+// BlocksStreamUnavailableError is returned if the stream is not configured or the node does not serve it
+func (r *impl) SubscribeBlocks(ctx context.Context) (<-chan int32, Error) {
+	if r.blocksStreamPath == "" {
+		return nil, NewError(BlocksStreamUnavailableError, "blocks stream is not configured")
+	}
+
+	streamURL := r.nodeURL
+	streamURL.Path = r.blocksStreamPath
+	key := streamURL.String()
+
+	stream := blockStreams.get(key)
+	if stream == nil {
+		// the connection is opened outside the lock, another subscriber may open it meanwhile
+		body, err := r.openBlocksStream(key)
+		if err != nil {
+			return nil, err
+		}
+		if stream, body = blockStreams.add(key, body); body != nil {
+			go r.readBlocksStream(key, stream, body)
+		}
+	}
+
+	heights := blockStreams.subscribe(stream)
+	go func() {
+		<-ctx.Done()
+		blockStreams.unsubscribe(key, stream, heights)
+	}()
+
+	return heights, nil
+}
+
+// openBlocksStream connects to the node blocks stream, the connection is not bound to the context of the subscriber
+func (r *impl) openBlocksStream(streamURL string) (*streamBody, Error) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	req, err := http.NewRequestWithContext(ctx, "GET", streamURL, nil)
+	if err != nil {
+		cancel()
+		return nil, NewError(InternalError, err.Error())
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	// the node not responding for the stall timeout is not waited for, the height is polled instead
+	if r.blocksStreamStallTimeout > 0 {
+		stall := time.AfterFunc(r.blocksStreamStallTimeout, cancel)
+		defer stall.Stop()
+	}
+
+	resp, err := r.do("blocks_stream", req)
+	if err != nil {
+		cancel()
+		return nil, NewError(BlocksStreamUnavailableError, err.Error())
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		cancel()
+		return nil, NewError(BlocksStreamUnavailableError, fmt.Sprintf("unexpected blocks stream status code: %d", resp.StatusCode))
+	}
+
+	return &streamBody{ReadCloser: resp.Body, cancel: cancel}, nil
+}
+
+// streamBody is the body of the blocks stream response, cancel aborts reading it
+type streamBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// readBlocksStream sends new heights to the stream subscribers until the stream ends or it stalls
+func (r *impl) readBlocksStream(key string, stream *blocksStream, body *streamBody) {
+	defer blockStreams.close(key, stream)
+	defer body.Close()
+
+	// no line for the stall timeout means the connection was dropped silently, keep-alive comments are lines too
+	resetStall := func() {}
+	if r.blocksStreamStallTimeout > 0 {
+		stall := time.AfterFunc(r.blocksStreamStallTimeout, func() {
+			r.logger.Debug("blocks stream stalled", zap.String("url", key), zap.Duration("stall_timeout", r.blocksStreamStallTimeout))
+			body.cancel()
+		})
+		defer stall.Stop()
+		resetStall = func() { stall.Reset(r.blocksStreamStallTimeout) }
+	}
+
+	var lastHeight int32
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxBlockEventSize)
+	for scanner.Scan() {
+		resetStall()
+
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+
+		event := blockEvent{}
+		if err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &event); err != nil {
+			r.logger.Warn("cannot decode blocks stream event", zap.Int("size", len(line)), zap.Error(err))
+			continue
+		}
+		// microblocks and repeated events of the same height are not new blocks
+		if event.Height <= lastHeight {
+			continue
+		}
+		lastHeight = event.Height
+
+		blockStreams.send(stream, event.Height)
+	}
+
+	if err := scanner.Err(); err != nil {
+		r.logger.Debug("blocks stream ended with error", zap.String("url", key), zap.Error(err))
+	}
+}
+
+// get returns the open stream of the url, nil if there is no one
+func (h *blocksHub) get(key string) *blocksStream {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.streams[key]
+}
+
+// add registers the stream of the opened body, the stream opened concurrently is returned instead and the body is closed
+// the body is returned only if its stream is registered, so it has to be read
+func (h *blocksHub) add(key string, body *streamBody) (*blocksStream, *streamBody) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if stream, ok := h.streams[key]; ok {
+		body.cancel()
+		body.Close()
+		return stream, nil
+	}
+
+	stream := &blocksStream{cancel: body.cancel, subscribers: make(map[chan int32]struct{})}
+	h.streams[key] = stream
+	return stream, body
+}
+
+// subscribe adds the subscriber of the stream, the channel of the closed stream is closed at once
+func (h *blocksHub) subscribe(stream *blocksStream) chan int32 {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	heights := make(chan int32, 1)
+	if stream.subscribers == nil {
+		close(heights)
+		return heights
+	}
+	stream.subscribers[heights] = struct{}{}
+	return heights
+}
+
+// send sends the height to all subscribers, the height not read yet is replaced by the newer one
+func (h *blocksHub) send(stream *blocksStream, height int32) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for heights := range stream.subscribers {
+		select {
+		case heights <- height:
+		default:
+			select {
+			case <-heights:
+			default:
+			}
+			heights <- height
+		}
+	}
+}
+
+// unsubscribe closes the channel of the subscriber, the stream without subscribers is closed
+func (h *blocksHub) unsubscribe(key string, stream *blocksStream, heights chan int32) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if _, ok := stream.subscribers[heights]; !ok {
+		return
+	}
+	delete(stream.subscribers, heights)
+	close(heights)
+
+	if len(stream.subscribers) == 0 {
+		h.remove(key, stream)
+	}
+}
+
+// close closes channels of all subscribers of the ended stream
+func (h *blocksHub) close(key string, stream *blocksStream) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for heights := range stream.subscribers {
+		close(heights)
+	}
+	stream.subscribers = nil
+	h.remove(key, stream)
+}
+
+// remove forgets the stream and aborts its reading, the stream of the url may have been replaced by the new one
+func (h *blocksHub) remove(key string, stream *blocksStream) {
+	if h.streams[key] == stream {
+		delete(h.streams, key)
+	}
+	stream.cancel()
+}
+
+// waitForTargetHeightByStream waits for the target height by the blocks stream
+// it returns false if the stream is unavailable, ends or stalls before the target height, so the height has to be polled
+func (r *impl) waitForTargetHeightByStream(targetHeight int32) (bool, Error) {
+	if r.blocksStreamPath == "" {
+		return false, nil
+	}
+
+	ctx, cancel := context.WithCancel(r.ctx)
+	defer cancel()
+
+	heights, err := r.SubscribeBlocks(ctx)
+	if err != nil {
+		r.logger.Debug("blocks stream is unavailable, height is polled", zap.Error(err))
+		return false, nil
+	}
+
+	// the target height may be reached before the subscription
+	currentHeight, err := r.GetCurrentHeight()
+	if err != nil {
+		return false, err
+	}
+	if currentHeight > targetHeight {
+		return true, nil
+	}
+
+	for height := range heights {
+		if height > targetHeight {
+			return true, nil
+		}
+	}
+
+	r.logger.Debug("blocks stream ended, height is polled")
+	return false, nil
+}
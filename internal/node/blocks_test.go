@@ -0,0 +1,133 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/wavesplatform/transaction-broadcaster/internal/log"
+)
+
+// newBlocksNode returns the node streaming the given events, the stream stays open until the request is done
+// the stream without events does not respond at all, connections to the stream are counted
+func newBlocksNode(t *testing.T, connections *int32, stallTimeout time.Duration, events ...string) Interactor {
+	log.Logger = zap.NewNop()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/blocks/stream", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(connections, 1)
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range events {
+			fmt.Fprintf(w, "data: %s\n\n", event)
+			w.(http.Flusher).Flush()
+			time.Sleep(10 * time.Millisecond)
+		}
+		<-r.Context().Done()
+	})
+	var height int32
+	mux.HandleFunc("/blocks/height", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"height":%d}`, atomic.AddInt32(&height, 1))
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	nodeURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	return New(server.Client(), *nodeURL, Config{BlocksStreamPath: "/blocks/stream", BlocksStreamStallTimeout: int32(stallTimeout / time.Millisecond), WaitForNextHeightDelay: 10}, nil)
+}
+
+// nextHeight returns the next height of the channel, 0 if it is closed
+func nextHeight(t *testing.T, heights <-chan int32) int32 {
+	t.Helper()
+	select {
+	case height := <-heights:
+		return height
+	case <-time.After(5 * time.Second):
+		t.Fatal("no height is received")
+		return 0
+	}
+}
+
+func TestSubscribeBlocksSharesStream(t *testing.T) {
+	var connections int32
+	nodeInteractor := newBlocksNode(t, &connections, time.Minute, `{"height":2}`)
+
+	// interactors of the same node share the stream
+	ctx, cancel := context.WithCancel(context.Background())
+	first, err := nodeInteractor.SubscribeBlocks(ctx)
+	require.Nil(t, err)
+	second, err := nodeInteractor.WithContext(ctx).SubscribeBlocks(ctx)
+	require.Nil(t, err)
+
+	require.Equal(t, int32(2), nextHeight(t, first))
+	require.Equal(t, int32(1), atomic.LoadInt32(&connections))
+
+	// channels are closed with the subscription ctx, the stream without subscribers is closed
+	cancel()
+	for range second {
+	}
+	require.Zero(t, nextHeight(t, first))
+
+	require.Eventually(t, func() bool {
+		blockStreams.mutex.Lock()
+		defer blockStreams.mutex.Unlock()
+		return len(blockStreams.streams) == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestSubscribeBlocksReadsBigEvents(t *testing.T) {
+	var connections int32
+	header := strings.Repeat("a", 100*1024)
+	nodeInteractor := newBlocksNode(t, &connections, time.Minute, fmt.Sprintf(`{"height":2,"header":"%s"}`, header), `{"height":3}`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	heights, err := nodeInteractor.SubscribeBlocks(ctx)
+	require.Nil(t, err)
+
+	require.Equal(t, int32(2), nextHeight(t, heights))
+	require.Equal(t, int32(3), nextHeight(t, heights))
+}
+
+func TestSubscribeBlocksClosesStalledStream(t *testing.T) {
+	var connections int32
+	nodeInteractor := newBlocksNode(t, &connections, 50*time.Millisecond, `{"height":2}`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	heights, err := nodeInteractor.SubscribeBlocks(ctx)
+	require.Nil(t, err)
+
+	require.Equal(t, int32(2), nextHeight(t, heights))
+	// the node sends nothing after the first event
+	require.Zero(t, nextHeight(t, heights))
+}
+
+func TestWaitForTargetHeightPollsAfterStreamStalls(t *testing.T) {
+	var connections int32
+	nodeInteractor := newBlocksNode(t, &connections, 50*time.Millisecond)
+
+	// the stream does not even respond, the height grows by every request
+	done := make(chan Error, 1)
+	go func() {
+		done <- nodeInteractor.WaitForTargetHeight(2)
+	}()
+
+	select {
+	case err := <-done:
+		require.Nil(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("target height is not reached")
+	}
+}
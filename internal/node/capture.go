@@ -0,0 +1,115 @@
+package node
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// defaultDebugCaptureSize is the max size of captured bodies if it is not configured
+const defaultDebugCaptureSize = 4096
+
+const redacted = "***"
+
+// debugCapture logs request and response bodies of failed node calls at debug level
+// calls fail if the request is not sent or the node responds with an error status
+type debugCapture struct {
+	maxSize int
+	// secrets are redacted from captured urls, headers and bodies
+	secrets []string
+}
+
+// newDebugCapture returns nil if the capture is disabled, nil capture captures nothing
+func newDebugCapture(cfg Config) *debugCapture {
+	if !cfg.DebugCapture {
+		return nil
+	}
+
+	maxSize := cfg.DebugCaptureMaxSize
+	if maxSize <= 0 {
+		maxSize = defaultDebugCaptureSize
+	}
+
+	var secrets []string
+	if cfg.NodeAPIKey != "" {
+		secrets = append(secrets, cfg.NodeAPIKey)
+	}
+	for _, value := range cfg.ExtraHeaders {
+		if value != "" {
+			secrets = append(secrets, value)
+		}
+	}
+
+	return &debugCapture{maxSize: maxSize, secrets: secrets}
+}
+
+// captureRequest reads up to maxSize bytes of the request body, the body is restored to be sent as is
+func (c *debugCapture) captureRequest(req *http.Request) []byte {
+	if c == nil || req.Body == nil {
+		return nil
+	}
+
+	captured, rest := c.capture(req.Body)
+	req.Body = rest
+	return captured
+}
+
+// captureResponse reads up to maxSize bytes of the response body, the body is restored to be decoded as is
+func (c *debugCapture) captureResponse(resp *http.Response) []byte {
+	if c == nil || resp == nil || resp.Body == nil {
+		return nil
+	}
+
+	captured, rest := c.capture(resp.Body)
+	resp.Body = rest
+	return captured
+}
+
+func (c *debugCapture) capture(body io.ReadCloser) ([]byte, io.ReadCloser) {
+	captured, _ := ioutil.ReadAll(io.LimitReader(body, int64(c.maxSize)))
+	return captured, readCloser{Reader: io.MultiReader(bytes.NewReader(captured), body), Closer: body}
+}
+
+// log logs the failed call, resp is nil if the request was not sent
+func (c *debugCapture) log(logger *zap.Logger, method string, req *http.Request, reqBody []byte, resp *http.Response, respBody []byte, err error) {
+	if c == nil {
+		return
+	}
+
+	headers := make(map[string]string, len(req.Header))
+	for name := range req.Header {
+		headers[name] = c.redact(req.Header.Get(name))
+	}
+
+	fields := []zap.Field{
+		zap.String("method", method),
+		zap.String("url", c.redact(req.URL.String())),
+		zap.Any("request_headers", headers),
+		zap.String("request_body", c.redact(string(reqBody))),
+	}
+	if resp != nil {
+		fields = append(fields, zap.Int("status", resp.StatusCode), zap.String("response_body", c.redact(string(respBody))))
+	}
+	// errors of unsent requests include the url
+	if err != nil {
+		fields = append(fields, zap.String("error", c.redact(err.Error())))
+	}
+
+	logger.Debug("node call failed", fields...)
+}
+
+func (c *debugCapture) redact(s string) string {
+	for _, secret := range c.secrets {
+		s = strings.ReplaceAll(s, secret, redacted)
+	}
+	return s
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
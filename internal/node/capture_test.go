@@ -0,0 +1,117 @@
+package node
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/wavesplatform/transaction-broadcaster/internal/log"
+)
+
+// newCapturingNode returns the node echoing the api key and the authorization header in responses of failed calls
+func newCapturingNode(t *testing.T, cfg Config) (*impl, *observer.ObservedLogs, *httptest.Server) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	log.Logger = zap.New(core)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ok" {
+			w.Write([]byte(`{"height":10}`))
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":1,"message":"bad key ` + r.Header.Get("X-Api-Key") + `, auth ` + r.Header.Get("Authorization") + `"}`))
+	}))
+	t.Cleanup(server.Close)
+
+	nodeURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	client, err := NewHTTPClient("", cfg.ExtraHeaders)
+	require.NoError(t, err)
+
+	return New(client, *nodeURL, cfg, nil).(*impl), logs, server
+}
+
+func postWithAPIKey(t *testing.T, r *impl, path, body string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodPost, r.nodeURL.String()+path+"?key="+r.nodeAPIKey, strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("X-API-Key", r.nodeAPIKey)
+	return r.do("test", req)
+}
+
+func TestDebugCaptureOfFailedCalls(t *testing.T) {
+	cfg := Config{DebugCapture: true, NodeAPIKey: "secret-key", ExtraHeaders: Headers{"Authorization": "Bearer token"}}
+	r, logs, server := newCapturingNode(t, cfg)
+
+	// successful calls are not captured
+	resp, err := postWithAPIKey(t, r, "/ok", `{"id":"a"}`)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Zero(t, logs.FilterMessage("node call failed").Len())
+
+	resp, err = postWithAPIKey(t, r, "/fail", `{"id":"a","proof":"secret-key"}`)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	// the captured body is still decoded as it was sent by the node
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, `{"error":1,"message":"bad key secret-key, auth Bearer token"}`, string(body))
+
+	captured := logs.FilterMessage("node call failed").All()
+	require.Len(t, captured, 1)
+	fields := captured[0].ContextMap()
+	require.Equal(t, "test", fields["method"])
+	require.Equal(t, server.URL+"/fail?key=***", fields["url"])
+	require.Equal(t, "***", fields["request_headers"].(map[string]string)["X-Api-Key"])
+	require.Equal(t, `{"id":"a","proof":"***"}`, fields["request_body"])
+	require.Equal(t, int64(http.StatusBadRequest), fields["status"])
+	require.Equal(t, `{"error":1,"message":"bad key ***, auth ***"}`, fields["response_body"])
+}
+
+func TestDebugCaptureIsCapped(t *testing.T) {
+	r, logs, _ := newCapturingNode(t, Config{DebugCapture: true, DebugCaptureMaxSize: 8})
+
+	resp, err := postWithAPIKey(t, r, "/fail", `{"id":"abcdefgh"}`)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, `{"error":1,"message":"bad key , auth "}`, string(body))
+
+	fields := logs.FilterMessage("node call failed").All()[0].ContextMap()
+	require.Equal(t, `{"id":"a`, fields["request_body"])
+	require.Equal(t, `{"error"`, fields["response_body"])
+}
+
+func TestDebugCaptureOfUnsentRequests(t *testing.T) {
+	r, logs, server := newCapturingNode(t, Config{DebugCapture: true, NodeAPIKey: "secret-key"})
+	server.Close()
+
+	_, err := postWithAPIKey(t, r, "/ok", `{"id":"a"}`)
+	require.Error(t, err)
+
+	fields := logs.FilterMessage("node call failed").All()[0].ContextMap()
+	require.Equal(t, `{"id":"a"}`, fields["request_body"])
+	require.NotContains(t, fields, "status")
+	require.NotContains(t, fields, "response_body")
+	require.NotContains(t, fields["error"], "secret-key")
+	require.Contains(t, fields["error"], "key=***")
+}
+
+func TestDebugCaptureIsDisabledByDefault(t *testing.T) {
+	r, logs, _ := newCapturingNode(t, Config{NodeAPIKey: "secret-key"})
+
+	resp, err := postWithAPIKey(t, r, "/fail", `{"id":"a"}`)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Zero(t, logs.FilterMessage("node call failed").Len())
+}
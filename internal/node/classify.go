@@ -0,0 +1,51 @@
+package node
+
+import (
+	"regexp"
+	"strconv"
+)
+
+// ErrorClass represents class of the node tx rejection reason
+type ErrorClass uint8
+
+// Enum of ErrorClass
+const (
+	ErrorClassUnknown ErrorClass = iota
+	// ErrorClassScriptedAccount means tx was rejected by the sender account script
+	// such txs have to carry proofs expected by the script, plain signature is not enough
+	ErrorClassScriptedAccount
+	// ErrorClassUtxFull means tx was rejected because the node utx pool is full, the same tx may be accepted later
+	ErrorClassUtxFull
+)
+
+var scriptedAccountErrorRE = regexp.MustCompile(`(?i)(TransactionNotAllowedByScript|not allowed by account-script|proof doesn't validate|scripted account)`)
+
+var utxFullErrorRE = regexp.MustCompile(`(?i)(transaction pool (bytes )?size limit is reached|utx pool is full)`)
+
+var alreadyInStateErrorRE = regexp.MustCompile(`State check failed. Reason: Transaction (\w+) is already in the state on a height of (\d+)`)
+
+// AlreadyInState returns id and height of the tx if the message says it is already in the blockchain, empty id otherwise
+func AlreadyInState(message string) (string, int32) {
+	matches := alreadyInStateErrorRE.FindStringSubmatch(message)
+	if len(matches) < 3 {
+		return "", 0
+	}
+
+	height, err := strconv.ParseInt(matches[2], 10, 32)
+	if err != nil {
+		return matches[1], 0
+	}
+
+	return matches[1], int32(height)
+}
+
+// ClassifyError returns class of the node error message, e.g. validation error or broadcast error
+func ClassifyError(message string) ErrorClass {
+	if scriptedAccountErrorRE.MatchString(message) {
+		return ErrorClassScriptedAccount
+	}
+	if utxFullErrorRE.MatchString(message) {
+		return ErrorClassUtxFull
+	}
+	return ErrorClassUnknown
+}
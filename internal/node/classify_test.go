@@ -0,0 +1,42 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlreadyInState(t *testing.T) {
+	txID, height := AlreadyInState("State check failed. Reason: Transaction 8Yr5SZhzVGjHS7vtMeGKCK4LJPSCPyuxGYvfwd6rMsmX is already in the state on a height of 2803421")
+	require.Equal(t, "8Yr5SZhzVGjHS7vtMeGKCK4LJPSCPyuxGYvfwd6rMsmX", txID)
+	require.Equal(t, int32(2803421), height)
+
+	// the height out of range is not reported, the tx is still the duplicate
+	txID, height = AlreadyInState("State check failed. Reason: Transaction abc is already in the state on a height of 99999999999")
+	require.Equal(t, "abc", txID)
+	require.Equal(t, int32(0), height)
+
+	txID, height = AlreadyInState("State check failed. Reason: negative waves balance")
+	require.Empty(t, txID)
+	require.Equal(t, int32(0), height)
+}
+
+func TestClassifyError(t *testing.T) {
+	require.Equal(t, ErrorClassUtxFull, ClassifyError("Transaction pool size limit is reached"))
+	require.Equal(t, ErrorClassUtxFull, ClassifyError("Transaction pool bytes size limit is reached"))
+	require.Equal(t, ErrorClassUnknown, ClassifyError("State check failed. Reason: negative waves balance"))
+}
+
+func TestClassifyScriptedAccountError(t *testing.T) {
+	for _, message := range []string{
+		"Transaction is not allowed by account-script",
+		"State check failed. Reason: TransactionNotAllowedByScript(List(),Left(false))",
+		"Proof doesn't validate as signature for the tx",
+		"Transaction sent from scripted account requires extra fee",
+	} {
+		require.Equal(t, ErrorClassScriptedAccount, ClassifyError(message), message)
+	}
+
+	// the plain account rejection is not the scripted account one
+	require.Equal(t, ErrorClassUnknown, ClassifyError("Script doesn't exist and proof doesn't exist"))
+}
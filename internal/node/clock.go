@@ -0,0 +1,99 @@
+package node
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/wavesplatform/transaction-broadcaster/internal/log"
+)
+
+// ClockSkewMonitor periodically measures difference between the local clock and the latest block timestamp
+// tx outdate checks rely on the local clock, so a skewed clock makes them wrong
+type ClockSkewMonitor struct {
+	nodeInteractor Interactor
+	logger         *zap.Logger
+	threshold      time.Duration
+	interval       time.Duration
+	// skew in ns, accessed atomically
+	skew int64
+}
+
+// NewClockSkewMonitor returns ClockSkewMonitor, threshold and interval are in ms
+func NewClockSkewMonitor(nodeInteractor Interactor, threshold, interval int32) *ClockSkewMonitor {
+	return &ClockSkewMonitor{
+		nodeInteractor: nodeInteractor,
+		logger:         log.Logger.Named("clockSkewMonitor"),
+		threshold:      time.Duration(threshold) * time.Millisecond,
+		interval:       time.Duration(interval) * time.Millisecond,
+	}
+}
+
+// Check measures the clock skew, stores it and logs a warning if it exceeds the threshold
+// the latest block is at most one block interval behind, so the skew includes it
+func (m *ClockSkewMonitor) Check() (time.Duration, Error) {
+	blockTimes, err := m.nodeInteractor.GetRecentBlockTimes(1)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(blockTimes) == 0 {
+		return 0, NewError(InternalError, "there are no blocks")
+	}
+
+	skew := time.Since(time.Unix(0, blockTimes[len(blockTimes)-1]*int64(time.Millisecond)))
+	atomic.StoreInt64(&m.skew, int64(skew))
+
+	if m.IsExceeded(skew) {
+		m.logger.Warn("local clock skew exceeds the threshold", zap.Duration("skew", skew), zap.Duration("threshold", m.threshold))
+	} else {
+		m.logger.Debug("local clock skew", zap.Duration("skew", skew))
+	}
+
+	return skew, nil
+}
+
+// CheckOnStart checks the clock skew, returns an error if failOnSkew is set and the skew exceeds the threshold
+// node errors are only logged, they must not prevent the start
+func (m *ClockSkewMonitor) CheckOnStart(failOnSkew bool) error {
+	skew, err := m.Check()
+	if err != nil {
+		m.logger.Warn("cannot measure local clock skew", zap.Error(err))
+		return nil
+	}
+
+	if failOnSkew && m.IsExceeded(skew) {
+		return fmt.Errorf("local clock skew %s exceeds the threshold %s", skew, m.threshold)
+	}
+
+	return nil
+}
+
+// Run checks the clock skew every interval, it never returns unless periodic checks are disabled by non-positive interval
+func (m *ClockSkewMonitor) Run() {
+	if m.interval <= 0 {
+		m.logger.Info("periodic clock skew checks are disabled")
+		return
+	}
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if _, err := m.Check(); err != nil {
+			m.logger.Warn("cannot measure local clock skew", zap.Error(err))
+		}
+	}
+}
+
+// Skew returns the last measured clock skew
+func (m *ClockSkewMonitor) Skew() time.Duration {
+	return time.Duration(atomic.LoadInt64(&m.skew))
+}
+
+// IsExceeded checks whether the skew exceeds the threshold in any direction
+func (m *ClockSkewMonitor) IsExceeded(skew time.Duration) bool {
+	return skew > m.threshold || skew < -m.threshold
+}
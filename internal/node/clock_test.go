@@ -0,0 +1,30 @@
+package node
+
+import (
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/wavesplatform/transaction-broadcaster/internal/log"
+)
+
+func TestClockSkewMonitorRunDisabled(t *testing.T) {
+	log.Logger = zap.NewNop()
+
+	for _, interval := range []int32{0, -1} {
+		m := NewClockSkewMonitor(NewFakeInteractor(nil), 1000, interval)
+
+		done := make(chan struct{})
+		go func() {
+			m.Run()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatalf("Run with interval %d did not return", interval)
+		}
+	}
+}
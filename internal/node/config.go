@@ -4,9 +4,35 @@ import "net/url"
 
 // Config of the node package
 type Config struct {
-	NodeURL                url.URL `env:"WAVES_NODE_URL,required"`
-	NodeAPIKey             string  `env:"WAVES_NODE_API_KEY,required"`
-	WaitForTxStatusDelay   int32   `env:"WAVES_WAIT_FOR_TX_STATUS_DELAY" envDefault:"1000"`
-	WaitForTxTimeout       int32   `env:"WAVES_WAIT_FOR_TX_TIMEOUT" envDefault:"90000"`
-	WaitForNextHeightDelay int32   `env:"WAVES_WAIT_FOR_NEXT_HEIGHT_DELAY" envDefaul:"1000"`
+	NodeURL                  url.URL  `env:"WAVES_NODE_URL,required"`
+	NodeAPIKey               string   `env:"WAVES_NODE_API_KEY"`
+	WaitForTxStatusDelay     int32    `env:"WAVES_WAIT_FOR_TX_STATUS_DELAY" envDefault:"1000"`
+	WaitForTxTimeout         int32    `env:"WAVES_WAIT_FOR_TX_TIMEOUT" envDefault:"90000"`
+	WaitForNextHeightDelay   int32    `env:"WAVES_WAIT_FOR_NEXT_HEIGHT_DELAY" envDefaul:"1000"`
+	AllowedNodeURLs          []string `env:"WAVES_ALLOWED_NODE_URLS" envSeparator:","`
+	BlockTimeEstimationDepth int32    `env:"WAVES_BLOCK_TIME_ESTIMATION_DEPTH" envDefault:"10"`
+	HTTPProxy                string   `env:"WAVES_NODE_HTTP_PROXY"`
+	TxsStatusBatchSize       int32    `env:"WAVES_TXS_STATUS_BATCH_SIZE" envDefault:"100"`
+	ValidatePath             string   `env:"WAVES_NODE_VALIDATE_PATH" envDefault:"/debug/validate"`
+	ValidateWithAPIKey       bool     `env:"WAVES_NODE_VALIDATE_WITH_API_KEY" envDefault:"true"`
+	ClockSkewThreshold       int32    `env:"WAVES_CLOCK_SKEW_THRESHOLD" envDefault:"120000"`
+	ClockSkewCheckInterval   int32    `env:"WAVES_CLOCK_SKEW_CHECK_INTERVAL" envDefault:"600000"`
+	ClockSkewFailOnStart     bool     `env:"WAVES_CLOCK_SKEW_FAIL_ON_START" envDefault:"false"`
+	WarmUp                   bool     `env:"WAVES_NODE_WARM_UP" envDefault:"false"`
+	TestBroadcastPath        string   `env:"WAVES_NODE_TEST_BROADCAST_PATH"`
+	BatchBroadcastPath       string   `env:"WAVES_NODE_BATCH_BROADCAST_PATH"`
+	MinFeesTTL               int32    `env:"WAVES_MIN_FEES_TTL" envDefault:"600000"`
+	ExtraHeaders             Headers  `env:"NODE_EXTRA_HEADERS"`
+	// node height is cached for HeightCacheTTL ms, it is capped by WaitForNextHeightDelay, 0 means no cache
+	HeightCacheTTL int32 `env:"WAVES_HEIGHT_CACHE_TTL" envDefault:"0"`
+	// bodies of failed node calls are logged at debug level, api key and extra headers values are redacted
+	DebugCapture        bool `env:"NODE_DEBUG_CAPTURE" envDefault:"false"`
+	DebugCaptureMaxSize int  `env:"NODE_DEBUG_CAPTURE_MAX_SIZE" envDefault:"4096"`
+	// path of the node stream of new blocks (server-sent events), heights are polled if it is empty or unavailable
+	BlocksStreamPath string `env:"WAVES_NODE_BLOCKS_STREAM_PATH"`
+	// the blocks stream without events and keep-alive comments for BlocksStreamStallTimeout ms is closed and heights are polled
+	BlocksStreamStallTimeout int32 `env:"WAVES_NODE_BLOCKS_STREAM_STALL_TIMEOUT" envDefault:"180000"`
+	// whether heights lower than the max seen one by more than HeightRegressionTolerance are ignored
+	MonotonicHeight           bool  `env:"WAVES_MONOTONIC_HEIGHT" envDefault:"false"`
+	HeightRegressionTolerance int32 `env:"WAVES_HEIGHT_REGRESSION_TOLERANCE" envDefault:"1"`
 }
@@ -7,9 +7,23 @@ const (
 	GetTxStatusError
 	WaitForTxStatusTimeoutError
 	TxNotFoundError
+	ValidateEndpointUnavailableError
+	BlocksStreamUnavailableError
 	InternalError = 999
 )
 
+// ErrorCodes lists all Waves error codes, new codes have to be added here to be classified by the worker
+var ErrorCodes = []uint16{
+	BroadcastClientError,
+	BroadcastServerError,
+	GetTxStatusError,
+	WaitForTxStatusTimeoutError,
+	TxNotFoundError,
+	ValidateEndpointUnavailableError,
+	BlocksStreamUnavailableError,
+	InternalError,
+}
+
 type wavesErrorImpl struct {
 	code          uint16
 	nodeErrorCode uint16
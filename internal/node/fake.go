@@ -0,0 +1,264 @@
+package node
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// FakeInteractor simulates the node without broadcasting anything, it is used for dry runs
+// every broadcasted tx is confirmed immediately, every height request produces a new block
+type FakeInteractor struct {
+	validator Interactor
+
+	mutex  sync.Mutex
+	height int32
+	txs    map[string]int32
+}
+
+// NewFakeInteractor returns FakeInteractor starting at height 1
+// validator is optional, if it is given ValidateTx is delegated to it (validate-only mode), otherwise all txs are valid
+func NewFakeInteractor(validator Interactor) *FakeInteractor {
+	return &FakeInteractor{
+		validator: validator,
+		height:    1,
+		txs:       make(map[string]int32),
+	}
+}
+
+// ValidateTx validates tx using validator if it is set
+// the result has the same id the tx gets when it is broadcasted
+func (f *FakeInteractor) ValidateTx(tx string) (*ValidationResult, Error) {
+	result := &ValidationResult{IsValid: true}
+	if f.validator != nil {
+		var err Error
+		if result, err = f.validator.ValidateTx(tx); err != nil {
+			return nil, err
+		}
+	}
+
+	if result.ID == "" {
+		if t, err := ParseTx(tx); err == nil {
+			result.ID = t.ID
+			if result.ID == "" {
+				result.ID = fakeTxID(tx)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// BroadcastTx puts tx to the current block and returns its id
+// tx without id gets the one derived from its json, so distinct txs never share the same id
+func (f *FakeInteractor) BroadcastTx(tx string) (string, Error) {
+	t, err := ParseTx(tx)
+	if err != nil {
+		return "", NewError(BroadcastClientError, err.Error())
+	}
+	txID := t.ID
+	if txID == "" {
+		txID = fakeTxID(tx)
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.txs[txID] = f.height
+
+	return txID, nil
+}
+
+// fakeTxID returns the id derived from the tx json
+func fakeTxID(tx string) string {
+	sum := sha256.Sum256([]byte(tx))
+	return hex.EncodeToString(sum[:])
+}
+
+// BroadcastTxs puts txs to the current block one by one
+func (f *FakeInteractor) BroadcastTxs(txs []string) ([]BroadcastResult, Error) {
+	results := make([]BroadcastResult, len(txs))
+	for i, tx := range txs {
+		results[i].ID, results[i].Err = f.BroadcastTx(tx)
+	}
+	return results, nil
+}
+
+// WaitForTxStatus returns height of the broadcasted tx, all broadcasted txs are confirmed
+func (f *FakeInteractor) WaitForTxStatus(txID string, waitForStatus TransactionStatus, timeout time.Duration) (int32, Error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	height, ok := f.txs[txID]
+	if !ok {
+		return 0, NewError(TxNotFoundError, "tx not found")
+	}
+
+	return height, nil
+}
+
+// WaitForTxsStatus returns heights of the broadcasted txs
+func (f *FakeInteractor) WaitForTxsStatus(txIDs []string, waitForStatus TransactionStatus, timeout time.Duration) (map[string]int32, Error) {
+	heights := make(map[string]int32, len(txIDs))
+	for _, txID := range txIDs {
+		height, err := f.WaitForTxStatus(txID, waitForStatus, timeout)
+		if err != nil {
+			return heights, err
+		}
+		heights[txID] = height
+	}
+	return heights, nil
+}
+
+// GetCurrentHeight produces a new block and returns its height
+func (f *FakeInteractor) GetCurrentHeight() (int32, Error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.height++
+
+	return f.height, nil
+}
+
+// WaitForTargetHeight moves the chain to the target height
+func (f *FakeInteractor) WaitForTargetHeight(targetHeight int32) Error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.height <= targetHeight {
+		f.height = targetHeight + 1
+	}
+
+	return nil
+}
+
+// WaitForNextHeight produces a new block
+func (f *FakeInteractor) WaitForNextHeight() Error {
+	_, err := f.GetCurrentHeight()
+	return err
+}
+
+// SubscribeBlocks is not supported, WaitForTargetHeight moves the chain immediately
+func (f *FakeInteractor) SubscribeBlocks(ctx context.Context) (<-chan int32, Error) {
+	return nil, NewError(BlocksStreamUnavailableError, "blocks stream is not available")
+}
+
+// GetTxsAvailability returns availability of the broadcasted txs
+func (f *FakeInteractor) GetTxsAvailability(txIDs []string) (Availability, Error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	availability := Availability{}
+	for _, txID := range txIDs {
+		height, ok := f.txs[txID]
+		availability[txID] = TxAvailability{
+			IsAvailable:   ok,
+			Confirmations: f.height - height,
+		}
+	}
+
+	return availability, nil
+}
+
+// GetBlockTransactions returns ids of txs broadcasted at the given height
+func (f *FakeInteractor) GetBlockTransactions(height int32) ([]string, Error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	txIDs := []string{}
+	for txID, txHeight := range f.txs {
+		if txHeight == height {
+			txIDs = append(txIDs, txID)
+		}
+	}
+
+	return txIDs, nil
+}
+
+// GetRecentBlockTimes returns empty timestamps, fake blocks have no time
+func (f *FakeInteractor) GetRecentBlockTimes(n int) ([]int64, Error) {
+	return []int64{}, nil
+}
+
+// GetTxStatusRaw returns tx status in the node response format
+func (f *FakeInteractor) GetTxStatusRaw(txID string) ([]byte, Error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	status := map[string]interface{}{"id": txID, "status": TransactionStatusNotFound}
+	if height, ok := f.txs[txID]; ok {
+		status["status"] = TransactionStatusConfirmed
+		status["height"] = height
+		status["confirmations"] = f.height - height
+	}
+
+	raw, err := json.Marshal([]interface{}{status})
+	if err != nil {
+		return nil, NewError(InternalError, err.Error())
+	}
+
+	return raw, nil
+}
+
+// TestBroadcast validates the tx, fake utx has no conflicting txs
+func (f *FakeInteractor) TestBroadcast(tx string) (*ValidationResult, Error) {
+	return f.ValidateTx(tx)
+}
+
+// GetMinFees returns default min fees of the probed tx types
+func (f *FakeInteractor) GetMinFees() (MinFees, Error) {
+	return MinFees{3: 100000000, 4: 100000, 8: 100000, 11: 150000, 12: 100000}, nil
+}
+
+// CheckValidateEndpoint checks validator endpoint if it is set
+func (f *FakeInteractor) CheckValidateEndpoint() Error {
+	if f.validator != nil {
+		return f.validator.CheckValidateEndpoint()
+	}
+	return nil
+}
+
+// GetNodeStatus returns status of the synced node at the current fake height
+func (f *FakeInteractor) GetNodeStatus() (*Status, Error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return &Status{
+		BlockchainHeight: f.height,
+		StateHeight:      f.height,
+		UpdatedTimestamp: time.Now().UnixNano() / int64(time.Millisecond),
+	}, nil
+}
+
+// GetBlockSignatureAtHeight returns a signature derived from the height, fake blocks are never replaced
+func (f *FakeInteractor) GetBlockSignatureAtHeight(height int32) (string, Error) {
+	return fmt.Sprintf("fake-block-%d", height), nil
+}
+
+// GetAddressBalance returns the balance known to the validator node, addresses of the fake node have no balance limits
+func (f *FakeInteractor) GetAddressBalance(address string) (int64, Error) {
+	if f.validator != nil {
+		return f.validator.GetAddressBalance(address)
+	}
+
+	return math.MaxInt64, nil
+}
+
+// GetNodeVersion returns version of the validator node, the fake node itself has no version
+func (f *FakeInteractor) GetNodeVersion() (Version, Error) {
+	if f.validator != nil {
+		return f.validator.GetNodeVersion()
+	}
+
+	return Version{}, NewError(InternalError, "fake node has no version")
+}
+
+// WithContext returns the same interactor, fake calls are not traced
+func (f *FakeInteractor) WithContext(ctx context.Context) Interactor {
+	return f
+}
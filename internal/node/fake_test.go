@@ -0,0 +1,46 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFakeBroadcastTxWithoutID(t *testing.T) {
+	f := NewFakeInteractor(nil)
+
+	firstID, err := f.BroadcastTx(`{"type":4,"timestamp":1}`)
+	require.Nil(t, err)
+	require.NotEmpty(t, firstID)
+
+	secondID, err := f.BroadcastTx(`{"type":4,"timestamp":2}`)
+	require.Nil(t, err)
+	require.NotEmpty(t, secondID)
+	require.NotEqual(t, firstID, secondID)
+
+	_, err = f.WaitForTxStatus(firstID, TransactionStatusConfirmed, 0)
+	require.Nil(t, err)
+	_, err = f.WaitForTxStatus("", TransactionStatusConfirmed, 0)
+	require.NotNil(t, err)
+}
+
+func TestFakeBroadcastTxWithID(t *testing.T) {
+	f := NewFakeInteractor(nil)
+
+	id, err := f.BroadcastTx(`{"id":"abc","type":4}`)
+	require.Nil(t, err)
+	require.Equal(t, "abc", id)
+}
+
+func TestFakeValidateTxReturnsBroadcastID(t *testing.T) {
+	f := NewFakeInteractor(nil)
+
+	tx := `{"type":4,"timestamp":1}`
+	result, err := f.ValidateTx(tx)
+	require.Nil(t, err)
+	require.True(t, result.IsValid)
+
+	id, err := f.BroadcastTx(tx)
+	require.Nil(t, err)
+	require.Equal(t, id, result.ID)
+}
@@ -0,0 +1,152 @@
+package node
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MinFees represents min fees of txs (in wavelets) by tx type
+type MinFees map[int32]int64
+
+// feeProbeSenderPublicKey is public key of the account fee probes are made for, it is an ordinary account without a script
+const feeProbeSenderPublicKey = "4wBqpZM9xaSheZzJSMawUKKwhdpChKbZ5eu5ky4Vigw"
+
+// feeProbes are txs templates fees are calculated for, the only argument is an address of the node network
+// txs which need an existing state (assets, scripts) are not probed
+var feeProbes = map[int32]string{
+	3:  `{"type":3,"version":2,"senderPublicKey":"` + feeProbeSenderPublicKey + `","name":"probe","description":"","quantity":1,"decimals":0,"reissuable":false}`,
+	4:  `{"type":4,"version":2,"senderPublicKey":"` + feeProbeSenderPublicKey + `","recipient":"%s","amount":1}`,
+	8:  `{"type":8,"version":2,"senderPublicKey":"` + feeProbeSenderPublicKey + `","recipient":"%s","amount":1}`,
+	11: `{"type":11,"version":1,"senderPublicKey":"` + feeProbeSenderPublicKey + `","transfers":[{"recipient":"%s","amount":1}]}`,
+	12: `{"type":12,"version":1,"senderPublicKey":"` + feeProbeSenderPublicKey + `","data":[{"key":"probe","type":"integer","value":1}]}`,
+}
+
+type addressResponse struct {
+	Address string
+}
+
+type calculateFeeResponse struct {
+	FeeAssetID *string `json:"feeAssetId"`
+	FeeAmount  int64   `json:"feeAmount"`
+}
+
+// minFeesCache keeps min fees for ttl, it is shared by interactors derived by WithContext
+type minFeesCache struct {
+	mutex     sync.Mutex
+	ttl       time.Duration
+	fees      MinFees
+	fetchedAt time.Time
+}
+
+func (c *minFeesCache) get() MinFees {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.fees != nil && time.Since(c.fetchedAt) < c.ttl {
+		return c.fees
+	}
+	return nil
+}
+
+func (c *minFeesCache) set(fees MinFees) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.fees = fees
+	c.fetchedAt = time.Now()
+}
+
+// GetMinFees returns min fees of the node by tx type, they are cached for the configured ttl
+// fees are calculated by the node for probe txs of an account without a script, so scripts extra fees are not included
+// the cache is not locked while fees are fetched, so concurrent calls with the expired cache may fetch them at the same time
+func (r *impl) GetMinFees() (MinFees, Error) {
+	if fees := r.minFees.get(); fees != nil {
+		return fees, nil
+	}
+
+	fees, err := r.fetchMinFees()
+	if err != nil {
+		return nil, err
+	}
+	r.minFees.set(fees)
+
+	return fees, nil
+}
+
+func (r *impl) fetchMinFees() (MinFees, Error) {
+	address, err := r.probeAddress()
+	if err != nil {
+		return nil, err
+	}
+
+	fees := MinFees{}
+	for txType, probe := range feeProbes {
+		if strings.Contains(probe, "%s") {
+			probe = fmt.Sprintf(probe, address)
+		}
+
+		fee, err := r.calculateFee(probe)
+		if err != nil {
+			return nil, err
+		}
+		fees[txType] = fee
+	}
+
+	return fees, nil
+}
+
+// probeAddress returns address of the probe account in the node network
+func (r *impl) probeAddress() (string, Error) {
+	addressURL := r.nodeURL
+	addressURL.Path = "/addresses/publicKey/" + feeProbeSenderPublicKey
+
+	resp, err := r.get("fees", addressURL.String())
+	if err != nil {
+		return "", NewError(InternalError, err.Error())
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", NewError(InternalError, resp.Status)
+	}
+
+	address := addressResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&address); err != nil {
+		return "", NewError(InternalError, err.Error())
+	}
+
+	return address.Address, nil
+}
+
+func (r *impl) calculateFee(tx string) (int64, Error) {
+	calculateFeeURL := r.nodeURL
+	calculateFeeURL.Path = "/transactions/calculateFee"
+
+	resp, err := r.post("fees", calculateFeeURL.String(), "application/json", bytes.NewBufferString(tx))
+	if err != nil {
+		return 0, NewError(InternalError, err.Error())
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		errorResponseDto := errorResponse{}
+		if err := json.NewDecoder(resp.Body).Decode(&errorResponseDto); err != nil {
+			return 0, NewError(InternalError, resp.Status)
+		}
+		return 0, WithNodeError(NewError(InternalError, errorResponseDto.Message), errorResponseDto.Error)
+	}
+
+	fee := calculateFeeResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&fee); err != nil {
+		return 0, NewError(InternalError, err.Error())
+	}
+
+	return fee.FeeAmount, nil
+}
@@ -0,0 +1,72 @@
+package node
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/wavesplatform/transaction-broadcaster/internal/log"
+)
+
+// recordedMinFees are fees the node calculated for the probe txs
+var recordedMinFees = map[int32]string{
+	3:  `{"feeAssetId":null,"feeAmount":100000000}`,
+	4:  `{"feeAssetId":null,"feeAmount":100000}`,
+	8:  `{"feeAssetId":null,"feeAmount":100000}`,
+	11: `{"feeAssetId":null,"feeAmount":150000}`,
+	12: `{"feeAssetId":null,"feeAmount":100000}`,
+}
+
+// newFeesNode returns the node serving recorded responses of fee probes, calculateFee calls are counted
+func newFeesNode(t *testing.T, calls *int32) Interactor {
+	log.Logger = zap.NewNop()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/addresses/publicKey/"+feeProbeSenderPublicKey, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"address":"3PAtGGSLnHJ3wuK8jWPvAA487pKamvQHyQw"}`))
+	})
+	mux.HandleFunc("/transactions/calculateFee", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+
+		tx := struct {
+			Type      int32  `json:"type"`
+			Recipient string `json:"recipient"`
+		}{}
+		// probes have to be sent to the address of the probe account
+		if err := json.NewDecoder(r.Body).Decode(&tx); err != nil || (tx.Type == 4 && tx.Recipient != "3PAtGGSLnHJ3wuK8jWPvAA487pKamvQHyQw") {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":199,"message":"invalid probe"}`))
+			return
+		}
+		w.Write([]byte(recordedMinFees[tx.Type]))
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	nodeURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	return New(server.Client(), *nodeURL, Config{MinFeesTTL: 60000}, nil)
+}
+
+func TestGetMinFees(t *testing.T) {
+	var calls int32
+	nodeInteractor := newFeesNode(t, &calls)
+
+	fees, err := nodeInteractor.GetMinFees()
+	require.Nil(t, err)
+	require.Equal(t, MinFees{3: 100000000, 4: 100000, 8: 100000, 11: 150000, 12: 100000}, fees)
+	require.Equal(t, int32(len(feeProbes)), atomic.LoadInt32(&calls))
+
+	// fees are cached
+	_, err = nodeInteractor.GetMinFees()
+	require.Nil(t, err)
+	require.Equal(t, int32(len(feeProbes)), atomic.LoadInt32(&calls))
+}
@@ -0,0 +1,62 @@
+package node
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// protectedHeaders are set by the interactor itself, they cannot be configured as extra headers
+var protectedHeaders = map[string]bool{
+	"Content-Type": true,
+	"X-Api-Key":    true,
+}
+
+// Headers represents static headers added to all node requests
+type Headers map[string]string
+
+// UnmarshalText parses comma separated list of name:value pairs, e.g. Authorization:Bearer token,X-Tenant:a
+// the value is everything after the first colon
+func (h *Headers) UnmarshalText(text []byte) error {
+	headers := Headers{}
+
+	for _, pair := range strings.Split(string(text), ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		parts := strings.SplitN(pair, ":", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return fmt.Errorf("invalid node header: %s", pair)
+		}
+
+		name := http.CanonicalHeaderKey(strings.TrimSpace(parts[0]))
+		if protectedHeaders[name] {
+			return fmt.Errorf("node header %s cannot be overridden", name)
+		}
+
+		headers[name] = strings.TrimSpace(parts[1])
+	}
+
+	*h = headers
+	return nil
+}
+
+// headersTransport adds headers to requests which do not have them set already
+type headersTransport struct {
+	next    http.RoundTripper
+	headers Headers
+}
+
+func (t *headersTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTripper must not modify the request
+	req = req.Clone(req.Context())
+	for name, value := range t.headers {
+		if protectedHeaders[http.CanonicalHeaderKey(name)] || req.Header.Get(name) != "" {
+			continue
+		}
+		req.Header.Set(name, value)
+	}
+	return t.next.RoundTrip(req)
+}
@@ -0,0 +1,61 @@
+package node
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/wavesplatform/transaction-broadcaster/internal/log"
+)
+
+func TestHeadersUnmarshalText(t *testing.T) {
+	headers := Headers{}
+	require.NoError(t, headers.UnmarshalText([]byte("authorization:Bearer a:b, x-tenant: t1")))
+	require.Equal(t, Headers{"Authorization": "Bearer a:b", "X-Tenant": "t1"}, headers)
+
+	for _, text := range []string{"X-Tenant", ":value", "content-type:text/plain", "X-API-KEY:other"} {
+		require.Error(t, headers.UnmarshalText([]byte(text)), text)
+	}
+}
+
+func TestExtraHeadersAreSent(t *testing.T) {
+	log.Logger = zap.NewNop()
+
+	requests := make(chan http.Header, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- r.Header.Clone()
+		switch r.URL.Path {
+		case "/blocks/height":
+			w.Write([]byte(`{"height":10}`))
+		default:
+			w.Write([]byte(`{"valid":true,"transaction":{"id":"abc"}}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	nodeURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	// protected headers given by the code are not applied either
+	client, err := NewHTTPClient("", Headers{"Authorization": "Bearer token", "X-Tenant": "t1", "Content-Type": "text/plain", "X-Api-Key": "other"})
+	require.NoError(t, err)
+	r := New(client, *nodeURL, Config{NodeAPIKey: "key", ValidateWithAPIKey: true}, nil)
+
+	_, nodeErr := r.GetCurrentHeight()
+	require.Nil(t, nodeErr)
+	header := <-requests
+	require.Equal(t, "Bearer token", header.Get("Authorization"))
+	require.Equal(t, "t1", header.Get("X-Tenant"))
+	require.Empty(t, header.Get("X-Api-Key"))
+
+	_, nodeErr = r.ValidateTx(`{"id":"abc"}`)
+	require.Nil(t, nodeErr)
+	header = <-requests
+	require.Equal(t, "Bearer token", header.Get("Authorization"))
+	require.Equal(t, "application/json", header.Get("Content-Type"))
+	require.Equal(t, "key", header.Get("X-Api-Key"))
+}
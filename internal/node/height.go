@@ -0,0 +1,70 @@
+package node
+
+import (
+	"sync"
+	"time"
+)
+
+// heightCache keeps the node height for ttl, concurrent callers missing the cache share a single request
+// it is shared by interactors derived by WithContext, so all workers of the default node use the same cache
+type heightCache struct {
+	ttl time.Duration
+
+	mutex     sync.Mutex
+	height    int32
+	fetchedAt time.Time
+	// call is the height request in flight, it is nil if the height is not being fetched
+	call *heightCall
+}
+
+type heightCall struct {
+	done   chan struct{}
+	height int32
+	err    Error
+}
+
+// newHeightCache returns nil if ttl is not positive, nil cache fetches the height on every call
+func newHeightCache(ttl time.Duration) *heightCache {
+	if ttl <= 0 {
+		return nil
+	}
+	return &heightCache{ttl: ttl}
+}
+
+// get returns the cached height if it is fresh, otherwise it is fetched once for all concurrent callers
+// failed fetches are not cached
+func (c *heightCache) get(fetch func() (int32, Error)) (int32, Error) {
+	if c == nil {
+		return fetch()
+	}
+
+	c.mutex.Lock()
+	if !c.fetchedAt.IsZero() && time.Since(c.fetchedAt) < c.ttl {
+		height := c.height
+		c.mutex.Unlock()
+		return height, nil
+	}
+
+	if call := c.call; call != nil {
+		c.mutex.Unlock()
+		<-call.done
+		return call.height, call.err
+	}
+
+	call := &heightCall{done: make(chan struct{})}
+	c.call = call
+	c.mutex.Unlock()
+
+	call.height, call.err = fetch()
+
+	c.mutex.Lock()
+	if call.err == nil {
+		c.height = call.height
+		c.fetchedAt = time.Now()
+	}
+	c.call = nil
+	c.mutex.Unlock()
+
+	close(call.done)
+	return call.height, call.err
+}
@@ -0,0 +1,116 @@
+package node
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/wavesplatform/transaction-broadcaster/internal/log"
+)
+
+func TestHeightCacheSingleFlight(t *testing.T) {
+	c := newHeightCache(time.Minute)
+
+	var fetches int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	fetch := func() (int32, Error) {
+		atomic.AddInt32(&fetches, 1)
+		close(started)
+		<-release
+		return 10, nil
+	}
+
+	heights := make(chan int32, 10)
+	go func() {
+		height, _ := c.get(fetch)
+		heights <- height
+	}()
+	<-started
+
+	// callers arriving while the height is fetched wait for the same request
+	var wg sync.WaitGroup
+	for i := 0; i < 9; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			height, _ := c.get(fetch)
+			heights <- height
+		}()
+	}
+	close(release)
+	wg.Wait()
+
+	require.Equal(t, int32(1), atomic.LoadInt32(&fetches))
+	for i := 0; i < 10; i++ {
+		require.Equal(t, int32(10), <-heights)
+	}
+}
+
+func TestHeightCacheTTL(t *testing.T) {
+	c := newHeightCache(50 * time.Millisecond)
+
+	fetches := 0
+	fetch := func() (int32, Error) {
+		fetches++
+		if fetches == 1 {
+			return 0, NewError(InternalError, "node is unavailable")
+		}
+		return int32(fetches), nil
+	}
+
+	// failed fetches are not cached
+	_, err := c.get(fetch)
+	require.NotNil(t, err)
+
+	height, err := c.get(fetch)
+	require.Nil(t, err)
+	require.Equal(t, int32(2), height)
+	height, _ = c.get(fetch)
+	require.Equal(t, int32(2), height)
+
+	time.Sleep(60 * time.Millisecond)
+	height, _ = c.get(fetch)
+	require.Equal(t, int32(3), height)
+
+	// nil cache fetches the height on every call
+	disabled := newHeightCache(0)
+	height, _ = disabled.get(fetch)
+	require.Equal(t, int32(4), height)
+	height, _ = disabled.get(fetch)
+	require.Equal(t, int32(5), height)
+}
+
+func TestHeightCacheIsSharedByDerivedInteractors(t *testing.T) {
+	log.Logger = zap.NewNop()
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Write([]byte(`{"height":10}`))
+	}))
+	t.Cleanup(server.Close)
+
+	nodeURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	r := New(server.Client(), *nodeURL, Config{HeightCacheTTL: 60000, WaitForNextHeightDelay: 60000}, nil)
+
+	for i := 0; i < 3; i++ {
+		height, err := r.WithContext(context.Background()).GetCurrentHeight()
+		require.Nil(t, err)
+		require.Equal(t, int32(10), height)
+	}
+	require.Equal(t, int32(1), atomic.LoadInt32(&requests))
+
+	// the height waited for is polled at least as often as it is cached
+	r = New(server.Client(), *nodeURL, Config{HeightCacheTTL: 60000, WaitForNextHeightDelay: 100}, nil)
+	require.Equal(t, 100*time.Millisecond, r.(*impl).height.ttl)
+}
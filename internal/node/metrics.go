@@ -0,0 +1,77 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Outcomes of node calls
+const (
+	outcomeOK          = "ok"
+	outcomeClientError = "client_error"
+	outcomeServerError = "server_error"
+	outcomeTimeout     = "timeout"
+)
+
+// Metrics collects node calls statistics by call method
+type Metrics struct {
+	calls   *prometheus.CounterVec
+	latency *prometheus.HistogramVec
+}
+
+// NewMetrics returns Metrics registered in registerer
+// registerer is optional, metrics are collected but not exposed if it is nil
+func NewMetrics(registerer prometheus.Registerer) (*Metrics, error) {
+	m := &Metrics{
+		calls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "node_calls_total",
+			Help: "Count of node calls by method and outcome.",
+		}, []string{"method", "outcome"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "node_call_duration_seconds",
+			Help:    "Latency of node calls by method.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method"}),
+	}
+
+	if registerer != nil {
+		if err := registerer.Register(m.calls); err != nil {
+			return nil, err
+		}
+		if err := registerer.Register(m.latency); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// observe records the node call result
+func (m *Metrics) observe(method string, duration time.Duration, resp *http.Response, err error) {
+	m.calls.WithLabelValues(method, callOutcome(resp, err)).Inc()
+	m.latency.WithLabelValues(method).Observe(duration.Seconds())
+}
+
+func callOutcome(resp *http.Response, err error) string {
+	if err != nil {
+		var netErr net.Error
+		if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+			return outcomeTimeout
+		}
+		return outcomeServerError
+	}
+
+	switch {
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return outcomeServerError
+	case resp.StatusCode >= http.StatusBadRequest:
+		return outcomeClientError
+	default:
+		return outcomeOK
+	}
+}
@@ -0,0 +1,46 @@
+package node
+
+import (
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// monotonicHeight guards against heights lower than the max seen one, e.g. of a lagging node behind a load balancer
+// regressions within tolerance are real rollbacks and are accepted, deeper ones are ignored and the max seen height is returned
+// it is shared by interactors derived by WithContext, so all workers of the same node see the same max height
+type monotonicHeight struct {
+	tolerance int32
+	logger    *zap.Logger
+
+	mutex     sync.Mutex
+	maxHeight int32
+}
+
+// newMonotonicHeight returns nil if the guard is disabled, nil guard returns heights as is
+func newMonotonicHeight(cfg Config, logger *zap.Logger) *monotonicHeight {
+	if !cfg.MonotonicHeight {
+		return nil
+	}
+	return &monotonicHeight{tolerance: cfg.HeightRegressionTolerance, logger: logger}
+}
+
+// check returns the height to be used instead of the reported one
+func (m *monotonicHeight) check(height int32) int32 {
+	if m == nil {
+		return height
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if height < m.maxHeight-m.tolerance {
+		m.logger.Warn("node reported height lower than the seen one, it is ignored", zap.Int32("height", height), zap.Int32("max_height", m.maxHeight), zap.Int32("tolerance", m.tolerance))
+		return m.maxHeight
+	}
+
+	if height > m.maxHeight {
+		m.maxHeight = height
+	}
+	return height
+}
@@ -0,0 +1,61 @@
+package node
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/wavesplatform/transaction-broadcaster/internal/log"
+)
+
+func TestMonotonicHeight(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+	m := newMonotonicHeight(Config{MonotonicHeight: true, HeightRegressionTolerance: 2}, zap.New(core))
+
+	require.Equal(t, int32(100), m.check(100))
+	// rollbacks within the tolerance are accepted
+	require.Equal(t, int32(98), m.check(98))
+	// the lagging node is ignored
+	require.Equal(t, int32(100), m.check(97))
+	require.Equal(t, int32(101), m.check(101))
+	require.Equal(t, 1, logs.FilterMessage("node reported height lower than the seen one, it is ignored").Len())
+
+	// the guard is disabled by default
+	m = newMonotonicHeight(Config{HeightRegressionTolerance: 2}, zap.NewNop())
+	require.Equal(t, int32(100), m.check(100))
+	require.Equal(t, int32(90), m.check(90))
+}
+
+func TestDecreasingNodeHeightIsIgnored(t *testing.T) {
+	log.Logger = zap.NewNop()
+
+	var mutex sync.Mutex
+	reported := []int32{100, 95, 99, 101}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		fmt.Fprintf(w, `{"height":%d}`, reported[0])
+		reported = reported[1:]
+	}))
+	t.Cleanup(server.Close)
+
+	nodeURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	r := New(server.Client(), *nodeURL, Config{MonotonicHeight: true, HeightRegressionTolerance: 1}, nil)
+
+	var heights []int32
+	for i := 0; i < 4; i++ {
+		height, err := r.GetCurrentHeight()
+		require.Nil(t, err)
+		heights = append(heights, height)
+	}
+	require.Equal(t, []int32{100, 100, 99, 101}, heights)
+}
@@ -2,13 +2,20 @@ package node
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strings"
 	"time"
 
 	"github.com/wavesplatform/transaction-broadcaster/internal/log"
+	"github.com/wavesplatform/transaction-broadcaster/internal/tracing"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
@@ -17,8 +24,12 @@ type transactionModel struct {
 }
 
 type validateTxResponse struct {
-	Valid bool
-	Error string
+	Valid       bool
+	Error       string
+	Trace       json.RawMessage
+	Transaction struct {
+		ID string
+	}
 }
 
 type broadcastResponse struct {
@@ -42,15 +53,74 @@ type blocksHeightResponse struct {
 	Height int32
 }
 
+type blockHeaderResponse struct {
+	Height    int32
+	Timestamp int64
+}
+
+type blockHeadersResponse []blockHeaderResponse
+
+type addressBalanceResponse struct {
+	Balance int64
+}
+
+// blockSignatureResponse has id of the block, nodes before 1.2 return its signature instead
+type blockSignatureResponse struct {
+	ID        string
+	Signature string
+}
+
+type blockTransactionResponse struct {
+	ID string
+}
+
+type blockResponse struct {
+	Height       int32
+	Transactions []blockTransactionResponse
+}
+
 type errorResponse struct {
 	Message string
 	Error   uint16
+	Trace   json.RawMessage
 }
 
 // ValidationResult represents result of ValidateTx
 type ValidationResult struct {
 	IsValid      bool
 	ErrorMessage string
+	// ErrorCode is the node API error code, it is set only if the node rejected the validation request
+	ErrorCode uint16
+	// Trace is the script execution trace returned by the node, it is empty if there is no trace
+	Trace json.RawMessage
+	// Warnings are reported by the node for valid txs as trace entries with the warning field
+	Warnings []string
+	// ID is the tx id computed by the node, it is empty if the node does not return the validated tx
+	ID string
+}
+
+type traceEntryWithWarning struct {
+	Warning string
+}
+
+// traceWarnings returns warnings of the trace entries, trace of unknown format has no warnings
+func traceWarnings(trace json.RawMessage) []string {
+	if len(trace) == 0 {
+		return nil
+	}
+
+	var entries []traceEntryWithWarning
+	if err := json.Unmarshal(trace, &entries); err != nil {
+		return nil
+	}
+
+	var warnings []string
+	for _, entry := range entries {
+		if entry.Warning != "" {
+			warnings = append(warnings, entry.Warning)
+		}
+	}
+	return warnings
 }
 
 // TransactionStatus represents current status of transaction
@@ -65,41 +135,257 @@ const (
 	TransactionStatusConfirmed = "confirmed"
 )
 
-// Availability represents map of string:isTransactionAvailable (tx has status not TransactionStatusNotFound)
-type Availability map[string]bool
+// TxAvailability represents tx presence in the blockchain
+type TxAvailability struct {
+	// IsAvailable is true if tx has status not TransactionStatusNotFound
+	IsAvailable bool
+	// Confirmations is count of blocks after the tx block, 0 for unconfirmed txs
+	Confirmations int32
+}
+
+// Availability represents map of txID:TxAvailability
+type Availability map[string]TxAvailability
 
 // Interactor ...
 type Interactor interface {
 	ValidateTx(string) (*ValidationResult, Error)
+	TestBroadcast(string) (*ValidationResult, Error)
 	BroadcastTx(string) (string, Error)
-	WaitForTxStatus(string, TransactionStatus) (int32, Error)
+	// BroadcastTxs broadcasts txs at once, results are in the order of txs
+	BroadcastTxs([]string) ([]BroadcastResult, Error)
+	// WaitForTxStatus waits for the tx status, zero timeout means the configured one
+	WaitForTxStatus(string, TransactionStatus, time.Duration) (int32, Error)
+	// WaitForTxsStatus waits for the status of all txs polling them by batches, returns heights by tx id
+	WaitForTxsStatus([]string, TransactionStatus, time.Duration) (map[string]int32, Error)
 	GetCurrentHeight() (int32, Error)
 	WaitForTargetHeight(int32) Error
 	WaitForNextHeight() Error
+	// SubscribeBlocks returns heights of new blocks until ctx is done, the channel is closed if the subscription ends
+	SubscribeBlocks(context.Context) (<-chan int32, Error)
 	GetTxsAvailability([]string) (Availability, Error)
+	GetRecentBlockTimes(int) ([]int64, Error)
+	GetBlockTransactions(int32) ([]string, Error)
+	// GetBlockSignatureAtHeight returns id of the block at the height, it changes if the block is replaced by a rollback
+	GetBlockSignatureAtHeight(int32) (string, Error)
+	GetTxStatusRaw(string) ([]byte, Error)
+	// GetAddressBalance returns the regular WAVES balance of the address
+	GetAddressBalance(string) (int64, Error)
+	CheckValidateEndpoint() Error
+	GetMinFees() (MinFees, Error)
+	GetNodeStatus() (*Status, Error)
+	// GetNodeVersion returns the node version, it is cached after the first successful request
+	GetNodeVersion() (Version, Error)
+	// WithContext returns Interactor making node requests within ctx, node calls are traced as children of ctx span
+	WithContext(context.Context) Interactor
 }
 
+// maxBlockHeadersRange is the max number of blocks the node returns headers for in a single request
+const maxBlockHeadersRange = 100
+
+// defaultTxsStatusBatchSize is used if txs status batch size is not configured
+const defaultTxsStatusBatchSize = 100
+
+// maxRawTxStatusSize is the max size of the node tx status response returned by GetTxStatusRaw
+const maxRawTxStatusSize = 64 * 1024
+
+// defaultValidatePath is used if validate path is not configured
+const defaultValidatePath = "/debug/validate"
+
 type impl struct {
+	ctx                    context.Context
+	client                 *http.Client
 	nodeURL                url.URL
 	nodeAPIKey             string
 	logger                 *zap.Logger
 	waitForTxStatusDelay   time.Duration
 	waitForTxTimeout       time.Duration
 	waitForNextHeightDelay time.Duration
+	txsStatusBatchSize     int
+	validatePath           string
+	validateWithAPIKey     bool
+	testBroadcastPath      string
+	batchBroadcastPath     string
+	blocksStreamPath       string
+	// blocks stream without lines for blocksStreamStallTimeout is closed, 0 means it is never considered stalled
+	blocksStreamStallTimeout time.Duration
+	metrics                  *Metrics
+	minFees                  *minFeesCache
+	version                  *versionCache
+	debugCapture             *debugCapture
+	height                   *heightCache
+	monotonicHeight          *monotonicHeight
 }
 
 // New returns instance of Interactor interface implementation
-func New(nodeURL url.URL, nodeAPIKey string, waitForTxStatusDelay, waitForTxTimeout, waitForNextHeightDelay int32) Interactor {
+// nodeURL overrides cfg.NodeURL, so the same config can be used for different nodes
+// metrics is optional, node calls are not measured if it is nil
+func New(client *http.Client, nodeURL url.URL, cfg Config, metrics *Metrics) Interactor {
 	logger := log.Logger.Named("nodeInteractor")
 
+	txsStatusBatchSize := int(cfg.TxsStatusBatchSize)
+	if txsStatusBatchSize <= 0 {
+		txsStatusBatchSize = defaultTxsStatusBatchSize
+	}
+
+	validatePath := cfg.ValidatePath
+	if validatePath == "" {
+		validatePath = defaultValidatePath
+	}
+
+	// heights have to be fresh for every check of WaitForTargetHeight
+	waitForNextHeightDelay := time.Duration(cfg.WaitForNextHeightDelay) * time.Millisecond
+	heightCacheTTL := time.Duration(cfg.HeightCacheTTL) * time.Millisecond
+	if waitForNextHeightDelay > 0 && heightCacheTTL > waitForNextHeightDelay {
+		heightCacheTTL = waitForNextHeightDelay
+	}
+
 	return &impl{
-		nodeURL:                nodeURL,
-		nodeAPIKey:             nodeAPIKey,
-		logger:                 logger,
-		waitForTxStatusDelay:   time.Duration(waitForTxStatusDelay) * time.Millisecond,
-		waitForTxTimeout:       time.Duration(waitForTxTimeout) * time.Millisecond,
-		waitForNextHeightDelay: time.Duration(waitForNextHeightDelay) * time.Millisecond,
+		ctx:                      context.Background(),
+		client:                   client,
+		nodeURL:                  nodeURL,
+		nodeAPIKey:               cfg.NodeAPIKey,
+		logger:                   logger,
+		waitForTxStatusDelay:     time.Duration(cfg.WaitForTxStatusDelay) * time.Millisecond,
+		waitForTxTimeout:         time.Duration(cfg.WaitForTxTimeout) * time.Millisecond,
+		waitForNextHeightDelay:   waitForNextHeightDelay,
+		txsStatusBatchSize:       txsStatusBatchSize,
+		validatePath:             validatePath,
+		validateWithAPIKey:       cfg.ValidateWithAPIKey,
+		testBroadcastPath:        cfg.TestBroadcastPath,
+		batchBroadcastPath:       cfg.BatchBroadcastPath,
+		blocksStreamPath:         cfg.BlocksStreamPath,
+		blocksStreamStallTimeout: time.Duration(cfg.BlocksStreamStallTimeout) * time.Millisecond,
+		metrics:                  metrics,
+		minFees:                  &minFeesCache{ttl: time.Duration(cfg.MinFeesTTL) * time.Millisecond},
+		version:                  &versionCache{},
+		debugCapture:             newDebugCapture(cfg),
+		height:                   newHeightCache(heightCacheTTL),
+		monotonicHeight:          newMonotonicHeight(cfg, logger),
+	}
+}
+
+// InteractorFactory creates Interactor pointed at the given node URL
+type InteractorFactory func(nodeURL url.URL) Interactor
+
+// NewFactory returns InteractorFactory sharing the given config, http client and metrics between all created interactors
+func NewFactory(client *http.Client, cfg Config, metrics *Metrics) InteractorFactory {
+	return func(nodeURL url.URL) Interactor {
+		return New(client, nodeURL, cfg, metrics)
+	}
+}
+
+// NewFactoryFromConfig returns InteractorFactory configured by cfg
+func NewFactoryFromConfig(cfg Config, metrics *Metrics) (InteractorFactory, error) {
+	client, err := NewHTTPClient(cfg.HTTPProxy, cfg.ExtraHeaders)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewFactory(client, cfg, metrics), nil
+}
+
+// NewHTTPClient returns http client for node requests
+// proxyURL is optional, both http(s):// and socks5:// proxies are supported
+// extraHeaders are added to all requests, headers set by the interactor are not overridden
+func NewHTTPClient(proxyURL string, extraHeaders Headers) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if proxyURL != "" {
+		proxy, err := url.Parse(proxyURL)
+		if err != nil {
+			return nil, err
+		}
+
+		switch proxy.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return nil, fmt.Errorf("unsupported proxy scheme: %s", proxy.Scheme)
+		}
+
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	if len(extraHeaders) > 0 {
+		return &http.Client{Transport: &headersTransport{next: transport, headers: extraHeaders}}, nil
+	}
+
+	return &http.Client{Transport: transport}, nil
+}
+
+// IsURLAllowed checks whether nodeURL matches one of allowedURLs
+// scheme, host and path are compared, trailing slashes are ignored
+func IsURLAllowed(nodeURL url.URL, allowedURLs []string) bool {
+	for _, rawAllowedURL := range allowedURLs {
+		allowedURL, err := url.Parse(strings.TrimSpace(rawAllowedURL))
+		if err != nil {
+			continue
+		}
+
+		if strings.EqualFold(allowedURL.Scheme, nodeURL.Scheme) &&
+			strings.EqualFold(allowedURL.Host, nodeURL.Host) &&
+			strings.TrimRight(allowedURL.Path, "/") == strings.TrimRight(nodeURL.Path, "/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// WithContext returns copy of the interactor bound to ctx
+func (r *impl) WithContext(ctx context.Context) Interactor {
+	c := *r
+	c.ctx = ctx
+	return &c
+}
+
+// startSpan starts span of the node call, returned interactor makes requests within the span
+func (r *impl) startSpan(name string) (*impl, trace.Span) {
+	ctx, span := tracing.Tracer.Start(r.ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+	c := *r
+	c.ctx = ctx
+	return &c, span
+}
+
+// endSpan ends span marking it as failed if err is not nil
+func endSpan(span trace.Span, err Error) {
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// do sends the request, method is the node call name metrics are collected by
+func (r *impl) do(method string, req *http.Request) (*http.Response, error) {
+	reqBody := r.debugCapture.captureRequest(req)
+
+	start := time.Now()
+	resp, err := r.client.Do(req)
+	if r.metrics != nil {
+		r.metrics.observe(method, time.Since(start), resp, err)
+	}
+
+	if err != nil || resp.StatusCode >= http.StatusBadRequest {
+		respBody := r.debugCapture.captureResponse(resp)
+		r.debugCapture.log(r.logger, method, req, reqBody, resp, respBody, err)
+	}
+	return resp, err
+}
+
+func (r *impl) get(method, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(r.ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return r.do(method, req)
+}
+
+func (r *impl) post(method, url, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(r.ctx, "POST", url, body)
+	if err != nil {
+		return nil, err
 	}
+	req.Header.Set("Content-Type", contentType)
+	return r.do(method, req)
 }
 
 // GetCurrentHeight returns current blockhain height
@@ -107,7 +393,17 @@ func (r *impl) GetCurrentHeight() (int32, Error) {
 	blocksHeightURL := r.nodeURL
 	blocksHeightURL.Path = "/blocks/height"
 
-	resp, err := http.Get(blocksHeightURL.String())
+	return r.height.get(func() (int32, Error) {
+		height, err := r.fetchCurrentHeight(blocksHeightURL)
+		if err != nil {
+			return 0, err
+		}
+		return r.monotonicHeight.check(height), nil
+	})
+}
+
+func (r *impl) fetchCurrentHeight(blocksHeightURL url.URL) (int32, Error) {
+	resp, err := r.get("height", blocksHeightURL.String())
 	if err != nil {
 		return 0, NewError(InternalError, err.Error())
 	}
@@ -122,55 +418,165 @@ func (r *impl) GetCurrentHeight() (int32, Error) {
 }
 
 // ValidateTx validates given tx using node
-func (r *impl) ValidateTx(tx string) (*ValidationResult, Error) {
+func (r *impl) ValidateTx(tx string) (result *ValidationResult, wavesErr Error) {
+	r, span := r.startSpan("node.ValidateTx")
+	defer func() { endSpan(span, wavesErr) }()
+
 	validateURL := r.nodeURL
-	validateURL.Path = "/debug/validate"
+	validateURL.Path = r.validatePath
 
-	req, err := http.NewRequest("POST", validateURL.String(), strings.NewReader(tx))
+	req, err := http.NewRequestWithContext(r.ctx, "POST", validateURL.String(), strings.NewReader(tx))
 	if err != nil {
 		r.logger.Error("Cannot create validateTx request", zap.Error(err))
 		return nil, NewError(InternalError, err.Error())
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-API-Key", r.nodeAPIKey)
+	if r.sendValidateAPIKey() {
+		req.Header.Set("X-API-Key", r.nodeAPIKey)
+	}
+
+	resp, err := r.do("validate", req)
+	if err != nil {
+		return nil, NewError(InternalError, err.Error())
+	}
+
+	defer resp.Body.Close()
+
+	if err := validateEndpointError(resp); err != nil {
+		return nil, err
+	}
+
+	return decodeValidationResult(resp)
+}
+
+// TestBroadcast checks whether the node would accept the tx to utx, including conflicts with txs already in utx
+// falls back to ValidateTx if the test broadcast endpoint is not configured or the node does not have it
+func (r *impl) TestBroadcast(tx string) (result *ValidationResult, wavesErr Error) {
+	if r.testBroadcastPath == "" {
+		return r.ValidateTx(tx)
+	}
+
+	r, span := r.startSpan("node.TestBroadcast")
+	defer func() { endSpan(span, wavesErr) }()
+
+	testBroadcastURL := r.nodeURL
+	testBroadcastURL.Path = r.testBroadcastPath
+
+	req, err := http.NewRequestWithContext(r.ctx, "POST", testBroadcastURL.String(), strings.NewReader(tx))
+	if err != nil {
+		r.logger.Error("Cannot create testBroadcast request", zap.Error(err))
+		return nil, NewError(InternalError, err.Error())
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if r.sendValidateAPIKey() {
+		req.Header.Set("X-API-Key", r.nodeAPIKey)
+	}
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := r.do("test_broadcast", req)
 	if err != nil {
 		return nil, NewError(InternalError, err.Error())
 	}
 
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotFound {
+		r.logger.Debug("test broadcast endpoint is not found, fallback to validation", zap.String("path", r.testBroadcastPath))
+		return r.ValidateTx(tx)
+	}
+
+	if err := validateEndpointError(resp); err != nil {
+		return nil, err
+	}
+
+	return decodeValidationResult(resp)
+}
+
+// decodeValidationResult decodes validation response, non-200 responses are node rejections of the tx
+func decodeValidationResult(resp *http.Response) (*ValidationResult, Error) {
 	if resp.StatusCode == http.StatusOK {
 		validateTx := validateTxResponse{}
-		if err = json.NewDecoder(resp.Body).Decode(&validateTx); err != nil {
+		if err := json.NewDecoder(resp.Body).Decode(&validateTx); err != nil {
 			return nil, NewError(InternalError, err.Error())
 		}
 
 		return &ValidationResult{
 			IsValid:      validateTx.Valid,
 			ErrorMessage: validateTx.Error,
+			Trace:        validateTx.Trace,
+			Warnings:     traceWarnings(validateTx.Trace),
+			ID:           validateTx.Transaction.ID,
 		}, nil
 	}
 
 	validateTxError := errorResponse{}
-	if err = json.NewDecoder(resp.Body).Decode(&validateTxError); err != nil {
+	if err := json.NewDecoder(resp.Body).Decode(&validateTxError); err != nil {
 		return nil, NewError(InternalError, err.Error())
 	}
 
 	return &ValidationResult{
 		IsValid:      false,
 		ErrorMessage: validateTxError.Message,
+		ErrorCode:    validateTxError.Error,
+		Trace:        validateTxError.Trace,
 	}, nil
 }
 
+// CheckValidateEndpoint checks that the validate endpoint is available and accepts the API key
+// an empty object is validated, so any response but 403 and 404 means the endpoint works
+func (r *impl) CheckValidateEndpoint() Error {
+	validateURL := r.nodeURL
+	validateURL.Path = r.validatePath
+
+	req, err := http.NewRequestWithContext(r.ctx, "POST", validateURL.String(), strings.NewReader("{}"))
+	if err != nil {
+		return NewError(InternalError, err.Error())
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if r.sendValidateAPIKey() {
+		req.Header.Set("X-API-Key", r.nodeAPIKey)
+	}
+
+	resp, err := r.do("validate", req)
+	if err != nil {
+		return NewError(InternalError, err.Error())
+	}
+
+	defer resp.Body.Close()
+
+	return validateEndpointError(resp)
+}
+
+// sendValidateAPIKey returns whether validation requests carry the API key
+// nodes older than 1.2 reject validation requests without the key, so it is sent to them if it is set even if it is not configured to be sent
+func (r *impl) sendValidateAPIKey() bool {
+	if r.validateWithAPIKey {
+		return true
+	}
+	version, ok := r.version.cached()
+	return ok && r.nodeAPIKey != "" && !version.AtLeast(validateAPIKeyRequiredBefore.Major, validateAPIKeyRequiredBefore.Minor)
+}
+
+// validateEndpointError returns ValidateEndpointUnavailableError if the node has no validate endpoint (e.g. debug API is disabled) or rejects the API key
+// such responses are not validation results, so they must not be decoded as ones
+func validateEndpointError(resp *http.Response) Error {
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusForbidden {
+		return NewError(ValidateEndpointUnavailableError, fmt.Sprintf("node validation endpoint unavailable / bad API key: %s", resp.Status))
+	}
+	return nil
+}
+
 // BroadcastTx broadcasts given tx to blockhain
-func (r *impl) BroadcastTx(tx string) (string, Error) {
+func (r *impl) BroadcastTx(tx string) (txID string, wavesErr Error) {
+	r, span := r.startSpan("node.BroadcastTx")
+	defer func() { endSpan(span, wavesErr) }()
+
 	broadcastURL := r.nodeURL
 	broadcastURL.Path = "/transactions/broadcast"
 
-	resp, err := http.Post(broadcastURL.String(), "application/json", strings.NewReader(tx))
+	resp, err := r.post("broadcast", broadcastURL.String(), "application/json", strings.NewReader(tx))
 	if err != nil {
 		return "", NewError(InternalError, err.Error())
 	}
@@ -213,7 +619,14 @@ func (r *impl) BroadcastTx(tx string) (string, Error) {
 }
 
 // WaitForTx waits for tx status appearance in the blockchain
-func (r *impl) WaitForTxStatus(txID string, waitForStatus TransactionStatus) (int32, Error) {
+func (r *impl) WaitForTxStatus(txID string, waitForStatus TransactionStatus, timeout time.Duration) (height int32, wavesErr Error) {
+	r, span := r.startSpan("node.WaitForTxStatus")
+	defer func() { endSpan(span, wavesErr) }()
+
+	if timeout <= 0 {
+		timeout = r.waitForTxTimeout
+	}
+
 	start := time.Now()
 	for {
 		status, err := r.getTxStatus(txID)
@@ -228,7 +641,7 @@ func (r *impl) WaitForTxStatus(txID string, waitForStatus TransactionStatus) (in
 		}
 
 		now := time.Now()
-		if now.Sub(start) > r.waitForTxTimeout {
+		if now.Sub(start) > timeout {
 			return 0, NewError(WaitForTxStatusTimeoutError, "wait for tx status time deadline is reached")
 		}
 
@@ -236,8 +649,65 @@ func (r *impl) WaitForTxStatus(txID string, waitForStatus TransactionStatus) (in
 	}
 }
 
-// WaitForNHeights waits for n heights in the blockchain
+// WaitForTxsStatus waits for the status of all txs, statuses are polled by a single request per batch
+// heights of the txs which got the status are returned with TxNotFoundError and WaitForTxStatusTimeoutError too
+func (r *impl) WaitForTxsStatus(txIDs []string, waitForStatus TransactionStatus, timeout time.Duration) (heights map[string]int32, wavesErr Error) {
+	r, span := r.startSpan("node.WaitForTxsStatus")
+	defer func() { endSpan(span, wavesErr) }()
+
+	if timeout <= 0 {
+		timeout = r.waitForTxTimeout
+	}
+
+	heights = make(map[string]int32, len(txIDs))
+	pending := append([]string(nil), txIDs...)
+
+	start := time.Now()
+	for {
+		stillPending := make([]string, 0, len(pending))
+		for batchStart := 0; batchStart < len(pending); batchStart += r.txsStatusBatchSize {
+			batchEnd := batchStart + r.txsStatusBatchSize
+			if batchEnd > len(pending) {
+				batchEnd = len(pending)
+			}
+
+			statuses, err := r.getTxsStatusesBatch("status", pending[batchStart:batchEnd])
+			if err != nil {
+				return heights, err
+			}
+
+			for _, status := range statuses {
+				switch status.Status {
+				case waitForStatus:
+					heights[status.ID] = status.Height
+				case TransactionStatusNotFound:
+					return heights, NewError(TxNotFoundError, fmt.Sprintf("tx %s not found", status.ID))
+				default:
+					stillPending = append(stillPending, status.ID)
+				}
+			}
+		}
+
+		pending = stillPending
+		if len(pending) == 0 {
+			return heights, nil
+		}
+
+		if time.Since(start) > timeout {
+			return heights, NewError(WaitForTxStatusTimeoutError, "wait for tx status time deadline is reached")
+		}
+
+		time.Sleep(r.waitForTxStatusDelay)
+	}
+}
+
+// WaitForTargetHeight waits for the height after targetHeight
+// new blocks are read from the blocks stream if it is available, otherwise the height is polled
 func (r *impl) WaitForTargetHeight(targetHeight int32) Error {
+	if reached, err := r.waitForTargetHeightByStream(targetHeight); reached || err != nil {
+		return err
+	}
+
 	done := make(chan bool, 1)
 
 	ticker := time.NewTicker(r.waitForNextHeightDelay)
@@ -269,8 +739,52 @@ func (r *impl) WaitForNextHeight() Error {
 	return r.WaitForTargetHeight(currentHeight + 1)
 }
 
-// GetTxsAvailability
-func (r *impl) GetTxsAvailability(txIDs []string) (Availability, Error) {
+// GetTxsAvailability returns availability of the given txs
+// the node limits count of ids per request, so ids are requested in batches of txsStatusBatchSize
+func (r *impl) GetTxsAvailability(txIDs []string) (txsAvailability Availability, wavesErr Error) {
+	r, span := r.startSpan("node.GetTxsAvailability")
+	defer func() { endSpan(span, wavesErr) }()
+
+	availability := Availability{}
+
+	for start := 0; start < len(txIDs); start += r.txsStatusBatchSize {
+		end := start + r.txsStatusBatchSize
+		if end > len(txIDs) {
+			end = len(txIDs)
+		}
+
+		batchAvailability, err := r.getTxsAvailabilityBatch(txIDs[start:end])
+		if err != nil {
+			return nil, err
+		}
+
+		for txID, txAvailability := range batchAvailability {
+			availability[txID] = txAvailability
+		}
+	}
+
+	return availability, nil
+}
+
+func (r *impl) getTxsAvailabilityBatch(txIDs []string) (Availability, Error) {
+	txStatuses, err := r.getTxsStatusesBatch("availability", txIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	availability := Availability{}
+	for _, txStatus := range txStatuses {
+		availability[txStatus.ID] = TxAvailability{
+			IsAvailable:   txStatus.Status != TransactionStatusNotFound,
+			Confirmations: txStatus.Confirmations,
+		}
+	}
+
+	return availability, nil
+}
+
+// getTxsStatusesBatch requests statuses of txs by a single request, method is the metrics label of the call
+func (r *impl) getTxsStatusesBatch(method string, txIDs []string) (transactionStatusesResponse, Error) {
 	txsStatusURL := r.nodeURL
 	txsStatusURL.Path = "/transactions/status"
 
@@ -281,7 +795,7 @@ func (r *impl) GetTxsAvailability(txIDs []string) (Availability, Error) {
 		return nil, NewError(InternalError, err.Error())
 	}
 
-	resp, err := http.Post(txsStatusURL.String(), "application/json", bytes.NewBuffer(req))
+	resp, err := r.post(method, txsStatusURL.String(), "application/json", bytes.NewBuffer(req))
 	if err != nil {
 		return nil, NewError(InternalError, err.Error())
 	}
@@ -301,12 +815,177 @@ func (r *impl) GetTxsAvailability(txIDs []string) (Availability, Error) {
 		return nil, NewError(InternalError, err.Error())
 	}
 
-	availability := Availability{}
-	for _, txStatus := range txStatuses {
-		availability[txStatus.ID] = txStatus.Status != TransactionStatusNotFound
+	return txStatuses, nil
+}
+
+// GetRecentBlockTimes returns timestamps (in ms) of the last n blocks ordered by height
+func (r *impl) GetRecentBlockTimes(n int) ([]int64, Error) {
+	if n <= 0 || n > maxBlockHeadersRange {
+		return nil, NewError(InternalError, fmt.Sprintf("blocks count has to be in range [1, %d]", maxBlockHeadersRange))
 	}
 
-	return availability, nil
+	currentHeight, wavesErr := r.GetCurrentHeight()
+	if wavesErr != nil {
+		return nil, wavesErr
+	}
+
+	fromHeight := currentHeight - int32(n) + 1
+	if fromHeight < 1 {
+		fromHeight = 1
+	}
+
+	blockHeadersURL := r.nodeURL
+	blockHeadersURL.Path = fmt.Sprintf("/blocks/headers/seq/%d/%d", fromHeight, currentHeight)
+
+	resp, err := r.get("block_headers", blockHeadersURL.String())
+	if err != nil {
+		return nil, NewError(InternalError, err.Error())
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, NewError(InternalError, resp.Status)
+	}
+
+	blockHeaders := blockHeadersResponse{}
+	if err = json.NewDecoder(resp.Body).Decode(&blockHeaders); err != nil {
+		return nil, NewError(InternalError, err.Error())
+	}
+
+	timestamps := make([]int64, 0, len(blockHeaders))
+	for _, blockHeader := range blockHeaders {
+		timestamps = append(timestamps, blockHeader.Timestamp)
+	}
+
+	return timestamps, nil
+}
+
+// GetBlockTransactions returns ids of txs included in the block at the given height
+func (r *impl) GetBlockTransactions(height int32) ([]string, Error) {
+	blockURL := r.nodeURL
+	blockURL.Path = fmt.Sprintf("/blocks/at/%d", height)
+
+	resp, err := r.get("block", blockURL.String())
+	if err != nil {
+		return nil, NewError(InternalError, err.Error())
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		errorResponseDto := errorResponse{}
+		if err = json.NewDecoder(resp.Body).Decode(&errorResponseDto); err != nil {
+			return nil, NewError(InternalError, resp.Status)
+		}
+		return nil, NewError(InternalError, errorResponseDto.Message)
+	}
+
+	block := blockResponse{}
+	if err = json.NewDecoder(resp.Body).Decode(&block); err != nil {
+		return nil, NewError(InternalError, err.Error())
+	}
+
+	txIDs := make([]string, 0, len(block.Transactions))
+	for _, tx := range block.Transactions {
+		txIDs = append(txIDs, tx.ID)
+	}
+
+	return txIDs, nil
+}
+
+// GetAddressBalance returns the regular WAVES balance of the address including unconfirmed txs
+func (r *impl) GetAddressBalance(address string) (int64, Error) {
+	balanceURL := r.nodeURL
+	balanceURL.Path = "/addresses/balance/" + address
+
+	resp, err := r.get("address_balance", balanceURL.String())
+	if err != nil {
+		return 0, NewError(InternalError, err.Error())
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, NewError(InternalError, resp.Status)
+	}
+
+	balance := addressBalanceResponse{}
+	if err = json.NewDecoder(resp.Body).Decode(&balance); err != nil {
+		return 0, NewError(InternalError, err.Error())
+	}
+	return balance.Balance, nil
+}
+
+// GetBlockSignatureAtHeight returns id (signature for older nodes) of the block at the height
+func (r *impl) GetBlockSignatureAtHeight(height int32) (string, Error) {
+	blockHeaderURL := r.nodeURL
+	blockHeaderURL.Path = fmt.Sprintf("/blocks/headers/at/%d", height)
+
+	resp, err := r.get("block_header", blockHeaderURL.String())
+	if err != nil {
+		return "", NewError(InternalError, err.Error())
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", NewError(InternalError, resp.Status)
+	}
+
+	header := blockSignatureResponse{}
+	if err = json.NewDecoder(resp.Body).Decode(&header); err != nil {
+		return "", NewError(InternalError, err.Error())
+	}
+
+	if header.ID != "" {
+		return header.ID, nil
+	}
+	return header.Signature, nil
+}
+
+// AverageBlockTime calculates average time between blocks with the given timestamps (in ms)
+// returns 0 if there are less than 2 timestamps
+func AverageBlockTime(timestamps []int64) time.Duration {
+	if len(timestamps) < 2 {
+		return 0
+	}
+
+	total := timestamps[len(timestamps)-1] - timestamps[0]
+	return time.Duration(total/int64(len(timestamps)-1)) * time.Millisecond
+}
+
+// GetTxStatusRaw returns unparsed node response of the tx status, it is used for diagnostics
+// responses bigger than maxRawTxStatusSize are not returned
+func (r *impl) GetTxStatusRaw(txID string) ([]byte, Error) {
+	txStatusURL := r.nodeURL
+	txStatusURL.Path = "/transactions/status"
+
+	q := url.Values{}
+	q.Set("id", txID)
+	txStatusURL.RawQuery = q.Encode()
+
+	resp, err := r.get("status", txStatusURL.String())
+	if err != nil {
+		return nil, NewError(InternalError, err.Error())
+	}
+
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxRawTxStatusSize+1))
+	if err != nil {
+		return nil, NewError(InternalError, err.Error())
+	}
+
+	if len(body) > maxRawTxStatusSize {
+		return nil, NewError(InternalError, fmt.Sprintf("tx status response exceeds %d bytes", maxRawTxStatusSize))
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, NewError(GetTxStatusError, fmt.Sprintf("%s: %s", resp.Status, body))
+	}
+
+	return body, nil
 }
 
 func (r *impl) getTxStatus(txID string) (*transactionStatusResponse, Error) {
@@ -317,7 +996,7 @@ func (r *impl) getTxStatus(txID string) (*transactionStatusResponse, Error) {
 	q.Set("id", txID)
 	txStatusURL.RawQuery = q.Encode()
 
-	resp, err := http.Get(txStatusURL.String())
+	resp, err := r.get("status", txStatusURL.String())
 	if err != nil {
 		return nil, NewError(InternalError, err.Error())
 	}
@@ -328,10 +1007,29 @@ func (r *impl) getTxStatus(txID string) (*transactionStatusResponse, Error) {
 		return nil, NewError(GetTxStatusError, resp.Status)
 	}
 
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, NewError(InternalError, err.Error())
+	}
+
+	return decodeTxStatus(body)
+}
+
+// decodeTxStatus decodes status of a single tx
+// depending on the version the node responds with a single-element array or with an object
+func decodeTxStatus(body []byte) (*transactionStatusResponse, Error) {
 	txStatuses := transactionStatusesResponse{}
-	if err = json.NewDecoder(resp.Body).Decode(&txStatuses); err != nil {
+	if arrErr := json.Unmarshal(body, &txStatuses); arrErr == nil {
+		if len(txStatuses) == 0 {
+			return nil, NewError(GetTxStatusError, "empty tx status response")
+		}
+		return &txStatuses[0], nil
+	}
+
+	txStatus := transactionStatusResponse{}
+	if err := json.Unmarshal(body, &txStatus); err != nil {
 		return nil, NewError(InternalError, err.Error())
 	}
 
-	return &txStatuses[0], nil
+	return &txStatus, nil
 }
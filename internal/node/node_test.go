@@ -0,0 +1,215 @@
+package node
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/wavesplatform/transaction-broadcaster/internal/log"
+)
+
+// newRecordedNode returns the node responding with the recorded responses by request paths, other paths are not found
+func newRecordedNode(t *testing.T, responses map[string]string) *impl {
+	log.Logger = zap.NewNop()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response, ok := responses[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(response))
+	}))
+	t.Cleanup(server.Close)
+
+	nodeURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	return New(server.Client(), *nodeURL, Config{}, nil).(*impl)
+}
+
+func TestGetRecentBlockTimes(t *testing.T) {
+	r := newRecordedNode(t, map[string]string{
+		"/blocks/height": `{"height":2803421}`,
+		// recorded mainnet response, transactions and features are cut
+		"/blocks/headers/seq/2803419/2803421": `[
+			{"version":5,"timestamp":1633072981247,"reference":"8Fp4uAmEtwaFtrWEKvUMdbEXJWBtjuJaQAoUD9kWb1w9","nxt-consensus":{"base-target":62,"generation-signature":"r4uC2iNC2vUy"},"transactionsRoot":"HwUU","id":"3rA9ZyfNPCZktvDezfAhoXyhRaz5QhUEntQ3s5DsjpFp","features":[],"desiredReward":-1,"generator":"3PMj3yGPBEa1Sx9X4TSBFeJCMMaE3wvKR4N","signature":"52YP","blocksize":1040,"transactionCount":3,"totalFee":500000,"reward":600000000,"VRF":"2Pmb","height":2803419},
+			{"version":5,"timestamp":1633073041811,"reference":"3rA9ZyfNPCZktvDezfAhoXyhRaz5QhUEntQ3s5DsjpFp","nxt-consensus":{"base-target":62,"generation-signature":"3ohy5JbKmZ4v"},"transactionsRoot":"6cN2","id":"HRz3sGQmB7p93w3d7eeTaNDTGWaKvfWUkdcSqTVE3YeA","features":[],"desiredReward":-1,"generator":"3P9DEDP5VbyXQyKtXDUt2crRPn5B7gs6ujc","signature":"4iQ6","blocksize":2289,"transactionCount":7,"totalFee":1400000,"reward":600000000,"VRF":"3kWN","height":2803420},
+			{"version":5,"timestamp":1633073100923,"reference":"HRz3sGQmB7p93w3d7eeTaNDTGWaKvfWUkdcSqTVE3YeA","nxt-consensus":{"base-target":62,"generation-signature":"4bYT4XZBnSpj"},"transactionsRoot":"Ce3x","id":"8yRJ2Fs4NpBb3qDL8wQ6W5vBe1rxRvdUYeT8s7Jw7KJH","features":[],"desiredReward":-1,"generator":"3PEDjtNLey4mWFH5sMLDHdrRT7DNNBfUGQp","signature":"2Ra7","blocksize":631,"transactionCount":1,"totalFee":100000,"reward":600000000,"VRF":"5mTq","height":2803421}
+		]`,
+	})
+
+	timestamps, err := r.GetRecentBlockTimes(3)
+	require.NoError(t, err)
+	require.Equal(t, []int64{1633072981247, 1633073041811, 1633073100923}, timestamps)
+	require.Equal(t, 59838*time.Millisecond, AverageBlockTime(timestamps))
+
+	for _, n := range []int{0, maxBlockHeadersRange + 1} {
+		_, err = r.GetRecentBlockTimes(n)
+		require.Error(t, err, n)
+	}
+
+	// headers of the missing range are not found
+	_, err = r.GetRecentBlockTimes(2)
+	require.Error(t, err)
+}
+
+func TestAverageBlockTime(t *testing.T) {
+	require.Equal(t, time.Duration(0), AverageBlockTime(nil))
+	require.Equal(t, time.Duration(0), AverageBlockTime([]int64{1000}))
+	require.Equal(t, 1500*time.Millisecond, AverageBlockTime([]int64{1000, 2000, 4000}))
+}
+
+func TestDecodeValidationResult(t *testing.T) {
+	response := func(status int, body string) *http.Response {
+		return &http.Response{StatusCode: status, Body: ioutil.NopCloser(strings.NewReader(body))}
+	}
+
+	// recorded response of /debug/validate, the node returns the validated tx with its computed id
+	result, err := decodeValidationResult(response(http.StatusOK, `{"valid":true,"validationTime":1,"trace":[],"height":2000000,"transaction":{"type":4,"id":"8Tz7vbF4xKr4XPWRvsvkuS2DUKG2WbS4uWhYVhrKc8Ac","fee":100000}}`))
+	require.Nil(t, err)
+	require.True(t, result.IsValid)
+	require.Equal(t, "8Tz7vbF4xKr4XPWRvsvkuS2DUKG2WbS4uWhYVhrKc8Ac", result.ID)
+
+	// older nodes do not return the tx
+	result, err = decodeValidationResult(response(http.StatusOK, `{"valid":false,"error":"Attempt to transfer unavailable funds"}`))
+	require.Nil(t, err)
+	require.False(t, result.IsValid)
+	require.Empty(t, result.ID)
+
+	// the valid tx has warnings in the trace
+	result, err = decodeValidationResult(response(http.StatusOK, `{"valid":true,"trace":[{"id":"3P","warning":"complexity near limit"},{"id":"3Q"}]}`))
+	require.Nil(t, err)
+	require.True(t, result.IsValid)
+	require.Equal(t, []string{"complexity near limit"}, result.Warnings)
+	require.JSONEq(t, `[{"id":"3P","warning":"complexity near limit"},{"id":"3Q"}]`, string(result.Trace))
+}
+
+func TestTraceWarnings(t *testing.T) {
+	for trace, expected := range map[string][]string{
+		``:              nil,
+		`[]`:            nil,
+		`[{"id":"3P"}]`: nil,
+		`[{"id":"3P","warning":"complexity near limit"},{"id":"3Q","warning":""},{"id":"3R","warning":"low fee"}]`: {"complexity near limit", "low fee"},
+		// trace of unknown shape has no warnings
+		`{"warning":"complexity near limit"}`: nil,
+		`"complexity near limit"`:             nil,
+		`[{"warning":1}]`:                     nil,
+	} {
+		require.Equal(t, expected, traceWarnings(json.RawMessage(trace)), trace)
+	}
+}
+
+func TestGetTxsAvailabilityInBatches(t *testing.T) {
+	log.Logger = zap.NewNop()
+
+	var batches [][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := transactionsStatusRequest{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		// the node rejects arrays bigger than its limit
+		if len(req.IDs) > 3 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":10,"message":"Too big sequence requested: max limit is 3 entries"}`))
+			return
+		}
+		batches = append(batches, req.IDs)
+
+		statuses := make([]map[string]interface{}, 0, len(req.IDs))
+		for _, id := range req.IDs {
+			if id == "missing" {
+				statuses = append(statuses, map[string]interface{}{"id": id, "status": TransactionStatusNotFound})
+				continue
+			}
+			statuses = append(statuses, map[string]interface{}{"id": id, "status": TransactionStatusConfirmed, "height": 10, "confirmations": 2})
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(statuses))
+	}))
+	t.Cleanup(server.Close)
+
+	nodeURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	txIDs := []string{"tx0", "tx1", "tx2", "tx3", "missing", "tx5", "tx6"}
+
+	_, nodeErr := New(server.Client(), *nodeURL, Config{TxsStatusBatchSize: 10}, nil).GetTxsAvailability(txIDs)
+	require.NotNil(t, nodeErr)
+	require.Contains(t, nodeErr.Error(), "Too big sequence requested")
+
+	availability, nodeErr := New(server.Client(), *nodeURL, Config{TxsStatusBatchSize: 3}, nil).GetTxsAvailability(txIDs)
+	require.Nil(t, nodeErr)
+	require.Equal(t, [][]string{{"tx0", "tx1", "tx2"}, {"tx3", "missing", "tx5"}, {"tx6"}}, batches)
+	require.Len(t, availability, len(txIDs))
+	require.Equal(t, TxAvailability{IsAvailable: true, Confirmations: 2}, availability["tx6"])
+	require.False(t, availability["missing"].IsAvailable)
+}
+
+func TestValidatePath(t *testing.T) {
+	log.Logger = zap.NewNop()
+
+	var path, apiKey string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, apiKey = r.URL.Path, r.Header.Get("X-API-Key")
+		w.Write([]byte(`{"valid":true,"transaction":{"id":"abc"}}`))
+	}))
+	t.Cleanup(server.Close)
+
+	nodeURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	cases := []struct {
+		cfg    Config
+		path   string
+		apiKey string
+	}{
+		{cfg: Config{NodeAPIKey: "key"}, path: "/debug/validate"},
+		{cfg: Config{NodeAPIKey: "key", ValidatePath: "/transactions/validate"}, path: "/transactions/validate"},
+		{cfg: Config{NodeAPIKey: "key", ValidatePath: "/debug/validate", ValidateWithAPIKey: true}, path: "/debug/validate", apiKey: "key"},
+	}
+
+	for _, c := range cases {
+		result, nodeErr := New(server.Client(), *nodeURL, c.cfg, nil).ValidateTx(`{"id":"abc"}`)
+		require.Nil(t, nodeErr)
+		require.True(t, result.IsValid)
+		require.Equal(t, c.path, path)
+		require.Equal(t, c.apiKey, apiKey)
+	}
+}
+
+func TestDecodeTxStatus(t *testing.T) {
+	status := `{"id":"abc","status":"confirmed","height":10,"confirmations":2}`
+
+	// nodes respond with a single-element array or with an object depending on the version
+	for _, body := range []string{"[" + status + "]", status} {
+		txStatus, err := decodeTxStatus([]byte(body))
+		require.Nil(t, err, body)
+		require.Equal(t, "abc", txStatus.ID)
+		require.Equal(t, TransactionStatus(TransactionStatusConfirmed), txStatus.Status)
+		require.Equal(t, int32(10), txStatus.Height)
+	}
+
+	_, err := decodeTxStatus([]byte(`[]`))
+	require.NotNil(t, err)
+	require.Equal(t, uint16(GetTxStatusError), err.Code())
+
+	_, err = decodeTxStatus([]byte(`"abc"`))
+	require.NotNil(t, err)
+}
+
+func TestGetTxStatusShapes(t *testing.T) {
+	for _, body := range []string{`[{"id":"abc","status":"confirmed","height":10}]`, `{"id":"abc","status":"confirmed","height":10}`} {
+		r := newRecordedNode(t, map[string]string{"/transactions/status": body})
+
+		txStatus, err := r.getTxStatus("abc")
+		require.Nil(t, err, body)
+		require.Equal(t, int32(10), txStatus.Height)
+	}
+}
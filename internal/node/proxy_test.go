@@ -0,0 +1,93 @@
+package node
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"github.com/caarlos0/env/v6"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/wavesplatform/transaction-broadcaster/internal/log"
+)
+
+// setEnv sets the env var for the test
+func setEnv(t *testing.T, name, value string) {
+	previous, ok := os.LookupEnv(name)
+	require.NoError(t, os.Setenv(name, value))
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(name, previous)
+		} else {
+			os.Unsetenv(name)
+		}
+	})
+}
+
+// requireProxy checks requests of the client are sent to the proxy
+func requireProxy(t *testing.T, client *http.Client, proxy string) {
+	transport := client.Transport
+	if headers, ok := transport.(*headersTransport); ok {
+		transport = headers.next
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "http://node.example/blocks/height", nil)
+	require.NoError(t, err)
+
+	proxyURL, err := transport.(*http.Transport).Proxy(req)
+	require.NoError(t, err)
+	require.Equal(t, proxy, proxyURL.String())
+}
+
+func TestHTTPProxyFromEnv(t *testing.T) {
+	for _, proxy := range []string{"http://proxy.example:3128", "socks5://proxy.example:1080"} {
+		setEnv(t, "WAVES_NODE_HTTP_PROXY", proxy)
+		setEnv(t, "WAVES_NODE_URL", "http://node.example")
+
+		cfg := Config{}
+		require.NoError(t, env.Parse(&cfg))
+
+		client, err := NewHTTPClient(cfg.HTTPProxy, nil)
+		require.NoError(t, err)
+		requireProxy(t, client, proxy)
+
+		// the proxy is kept when requests get extra headers
+		client, err = NewHTTPClient(cfg.HTTPProxy, Headers{"X-Env": "test"})
+		require.NoError(t, err)
+		requireProxy(t, client, proxy)
+	}
+
+	_, err := NewHTTPClient("ftp://proxy.example", nil)
+	require.EqualError(t, err, "unsupported proxy scheme: ftp")
+}
+
+func TestNodeRequestsGoThroughProxy(t *testing.T) {
+	log.Logger = zap.NewNop()
+
+	var proxied int32
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// proxies get absolute urls of the node
+		if r.URL.Host == "node.example" && r.URL.Path == "/blocks/height" {
+			atomic.AddInt32(&proxied, 1)
+			w.Write([]byte(`{"height":7}`))
+			return
+		}
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	t.Cleanup(proxy.Close)
+
+	client, err := NewHTTPClient(proxy.URL, nil)
+	require.NoError(t, err)
+
+	nodeURL, err := url.Parse("http://node.example")
+	require.NoError(t, err)
+
+	height, nodeErr := New(client, *nodeURL, Config{}, nil).GetCurrentHeight()
+	require.Nil(t, nodeErr)
+	require.Equal(t, int32(7), height)
+	require.Equal(t, int32(1), atomic.LoadInt32(&proxied))
+}
@@ -0,0 +1,28 @@
+package node
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+type readinessResponse struct {
+	Ready  bool   `json:"ready"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// ReadinessHandler responds 200 if the node validate endpoint is available, 503 with the reason otherwise
+// the endpoint is checked on every request, so the readiness recovers as soon as the node debug API or the API key is fixed
+func ReadinessHandler(nodeInteractor Interactor) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := http.StatusOK
+		response := readinessResponse{Ready: true}
+		if err := nodeInteractor.WithContext(r.Context()).CheckValidateEndpoint(); err != nil {
+			status = http.StatusServiceUnavailable
+			response = readinessResponse{Reason: err.Error()}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(response)
+	})
+}
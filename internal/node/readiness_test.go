@@ -0,0 +1,74 @@
+package node
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/wavesplatform/transaction-broadcaster/internal/log"
+)
+
+// newValidateNode returns the node responding to validation requests with the given status
+func newValidateNode(t *testing.T, status *int) *impl {
+	log.Logger = zap.NewNop()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/debug/validate" {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(*status)
+		w.Write([]byte(`{"valid":true,"trace":[]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	nodeURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	return New(server.Client(), *nodeURL, Config{ValidatePath: "/debug/validate", NodeAPIKey: "key", ValidateWithAPIKey: true}, nil).(*impl)
+}
+
+func TestValidateEndpointUnavailable(t *testing.T) {
+	for _, status := range []int{http.StatusForbidden, http.StatusNotFound} {
+		r := newValidateNode(t, &status)
+
+		// the response is not decoded as the validation result
+		result, err := r.ValidateTx(`{"id":"abc"}`)
+		require.Nil(t, result, status)
+		require.NotNil(t, err, status)
+		require.Equal(t, uint16(ValidateEndpointUnavailableError), err.Code())
+		require.Contains(t, err.Error(), "node validation endpoint unavailable / bad API key")
+
+		err = r.CheckValidateEndpoint()
+		require.NotNil(t, err, status)
+		require.Equal(t, uint16(ValidateEndpointUnavailableError), err.Code())
+	}
+
+	status := http.StatusOK
+	r := newValidateNode(t, &status)
+	result, err := r.ValidateTx(`{"id":"abc"}`)
+	require.Nil(t, err)
+	require.True(t, result.IsValid)
+	require.Nil(t, r.CheckValidateEndpoint())
+}
+
+func TestReadinessHandler(t *testing.T) {
+	status := http.StatusForbidden
+	h := ReadinessHandler(newValidateNode(t, &status))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.Contains(t, w.Body.String(), "node validation endpoint unavailable / bad API key: 403 Forbidden")
+
+	// the node debug API is fixed without restarting the broadcaster
+	status = http.StatusOK
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `{"ready":true}`, w.Body.String())
+}
@@ -0,0 +1,60 @@
+package node
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// Status is the node blockchain state
+type Status struct {
+	BlockchainHeight int32 `json:"blockchainHeight"`
+	StateHeight      int32 `json:"stateHeight"`
+	// UpdatedTimestamp is the time of the last state update in ms
+	UpdatedTimestamp int64 `json:"updatedTimestamp"`
+}
+
+// IsSynced returns true if the node state is applied up to the blockchain height
+// and it was updated not earlier than maxLag ago
+func (s *Status) IsSynced(maxLag time.Duration) bool {
+	if s.StateHeight < s.BlockchainHeight {
+		return false
+	}
+	updated := time.Unix(0, s.UpdatedTimestamp*int64(time.Millisecond))
+	return time.Since(updated) <= maxLag
+}
+
+// GetNodeStatus returns the node blockchain state
+func (r *impl) GetNodeStatus() (*Status, Error) {
+	nodeStatusURL := r.nodeURL
+	nodeStatusURL.Path = "/node/status"
+
+	resp, err := r.get("node_status", nodeStatusURL.String())
+	if err != nil {
+		return nil, NewError(InternalError, err.Error())
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, NewError(InternalError, resp.Status)
+	}
+
+	// the response shape depends on the node version, the current one is expected if the version is not known yet
+	if version, ok := r.version.cached(); ok && !version.AtLeast(statusAsArrayBefore.Major, statusAsArrayBefore.Minor) {
+		statuses := []Status{}
+		if err = json.NewDecoder(resp.Body).Decode(&statuses); err != nil {
+			return nil, NewError(InternalError, err.Error())
+		}
+		if len(statuses) == 0 {
+			return nil, NewError(InternalError, "empty node status response")
+		}
+		return &statuses[0], nil
+	}
+
+	status := Status{}
+	if err = json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return nil, NewError(InternalError, err.Error())
+	}
+	return &status, nil
+}
@@ -0,0 +1,53 @@
+package node
+
+import "encoding/json"
+
+// TxTransfer is a transfer of the mass transfer tx
+type TxTransfer struct {
+	Recipient string `json:"recipient"`
+	Amount    int64  `json:"amount"`
+}
+
+// TxPayment is a payment attached to the invoke script tx
+type TxPayment struct {
+	AssetID string `json:"assetId"`
+	Amount  int64  `json:"amount"`
+}
+
+// Tx contains fields of all tx types the broadcaster checks and tracks txs by, fields absent in the tx json are zero
+// e.g. Amount is the transferred amount of transfer txs and the leased one of lease txs
+type Tx struct {
+	ID              string       `json:"id"`
+	Type            int32        `json:"type"`
+	Version         *int         `json:"version"`
+	Timestamp       int64        `json:"timestamp"`
+	SenderPublicKey string       `json:"senderPublicKey"`
+	Sender          string       `json:"sender"`
+	Fee             int64        `json:"fee"`
+	FeeAssetID      string       `json:"feeAssetId"`
+	Amount          int64        `json:"amount"`
+	AssetID         string       `json:"assetId"`
+	LeaseID         string       `json:"leaseId"`
+	Alias           string       `json:"alias"`
+	Recipient       string       `json:"recipient"`
+	DApp            string       `json:"dApp"`
+	Transfers       []TxTransfer `json:"transfers"`
+	Payment         []TxPayment  `json:"payment"`
+}
+
+// ParseTx parses the tx json once, so its fields are not unmarshalled again by every check
+func ParseTx(tx string) (*Tx, error) {
+	t := &Tx{}
+	if err := json.Unmarshal([]byte(tx), t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// TxVersion returns the tx version, txs without version are the first version txs
+func (t *Tx) TxVersion() int {
+	if t.Version == nil {
+		return 1
+	}
+	return *t.Version
+}
@@ -0,0 +1,24 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTx(t *testing.T) {
+	tx, err := ParseTx(`{"id":"abc","type":11,"version":2,"timestamp":1000,"sender":"addr","fee":100,"transfers":[{"recipient":"alias:W:bob","amount":5}]}`)
+	require.NoError(t, err)
+	require.Equal(t, "abc", tx.ID)
+	require.Equal(t, int32(11), tx.Type)
+	require.Equal(t, 2, tx.TxVersion())
+	require.Equal(t, int64(1000), tx.Timestamp)
+	require.Equal(t, []TxTransfer{{Recipient: "alias:W:bob", Amount: 5}}, tx.Transfers)
+
+	tx, err = ParseTx(`{"type":4}`)
+	require.NoError(t, err)
+	require.Equal(t, 1, tx.TxVersion())
+
+	_, err = ParseTx(`{"type":"transfer"}`)
+	require.Error(t, err)
+}
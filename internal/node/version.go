@@ -0,0 +1,126 @@
+package node
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+)
+
+// Version is the node application version
+type Version struct {
+	Major int `json:"major"`
+	Minor int `json:"minor"`
+	Patch int `json:"patch"`
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// AtLeast returns true if the version is not older than major.minor
+func (v Version) AtLeast(major, minor int) bool {
+	if v.Major != major {
+		return v.Major > major
+	}
+	return v.Minor >= minor
+}
+
+var versionRegexp = regexp.MustCompile(`(\d+)\.(\d+)(?:\.(\d+))?`)
+
+// ParseVersion parses version reported by the node, e.g. "Waves v1.4.8"
+func ParseVersion(s string) (Version, error) {
+	m := versionRegexp.FindStringSubmatch(s)
+	if m == nil {
+		return Version{}, fmt.Errorf("invalid node version %q", s)
+	}
+
+	v := Version{}
+	v.Major, _ = strconv.Atoi(m[1])
+	v.Minor, _ = strconv.Atoi(m[2])
+	if m[3] != "" {
+		v.Patch, _ = strconv.Atoi(m[3])
+	}
+	return v, nil
+}
+
+// statusAsArrayBefore is the first version the node status is rendered as an object by, older nodes wrap it into an array
+var statusAsArrayBefore = Version{Major: 1, Minor: 2}
+
+// validateAPIKeyRequiredBefore is the first version the validate endpoint is public in, older nodes serve it to requests with the API key only
+var validateAPIKeyRequiredBefore = Version{Major: 1, Minor: 2}
+
+type nodeVersionResponse struct {
+	Version string
+}
+
+// versionCache keeps the node version once it is fetched, the version of a running node does not change
+// it is shared by interactors derived by WithContext
+type versionCache struct {
+	mutex   sync.Mutex
+	version *Version
+}
+
+// cached returns the node version if it was fetched already
+func (c *versionCache) cached() (Version, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.version == nil {
+		return Version{}, false
+	}
+	return *c.version, true
+}
+
+// set caches the fetched node version
+func (c *versionCache) set(version Version) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.version = &version
+}
+
+// GetNodeVersion returns the node version, it is requested only until the first successful response
+// the request is made outside the lock, so the slow node does not block callers reading the cached version
+func (r *impl) GetNodeVersion() (Version, Error) {
+	if version, ok := r.version.cached(); ok {
+		return version, nil
+	}
+
+	version, err := r.fetchNodeVersion()
+	if err != nil {
+		return Version{}, err
+	}
+
+	r.version.set(version)
+	return version, nil
+}
+
+func (r *impl) fetchNodeVersion() (Version, Error) {
+	nodeVersionURL := r.nodeURL
+	nodeVersionURL.Path = "/node/version"
+
+	resp, err := r.get("node_version", nodeVersionURL.String())
+	if err != nil {
+		return Version{}, NewError(InternalError, err.Error())
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Version{}, NewError(InternalError, resp.Status)
+	}
+
+	versionResponse := nodeVersionResponse{}
+	if err = json.NewDecoder(resp.Body).Decode(&versionResponse); err != nil {
+		return Version{}, NewError(InternalError, err.Error())
+	}
+
+	version, err := ParseVersion(versionResponse.Version)
+	if err != nil {
+		return Version{}, NewError(InternalError, err.Error())
+	}
+	return version, nil
+}
@@ -0,0 +1,127 @@
+package node
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/wavesplatform/transaction-broadcaster/internal/log"
+)
+
+// newVersionNode returns the node of the given version, version requests are counted and validation requests are rejected without the API key
+// the node status is wrapped into an array by nodes older than 1.2
+func newVersionNode(t *testing.T, version string, calls *int32, release <-chan struct{}) *impl {
+	log.Logger = zap.NewNop()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/node/version", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		if release != nil {
+			<-release
+		}
+		w.Write([]byte(fmt.Sprintf(`{"version":"Waves %s"}`, version)))
+	})
+	mux.HandleFunc("/node/status", func(w http.ResponseWriter, r *http.Request) {
+		status := `{"blockchainHeight":10,"stateHeight":10,"updatedTimestamp":1000}`
+		if v, _ := ParseVersion(version); !v.AtLeast(1, 2) {
+			status = "[" + status + "]"
+		}
+		w.Write([]byte(status))
+	})
+	mux.HandleFunc("/debug/validate", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-API-Key") != "key" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"valid":true,"transaction":{"id":"abc"}}`))
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	nodeURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	return New(server.Client(), *nodeURL, Config{NodeAPIKey: "key", ValidatePath: "/debug/validate"}, nil).(*impl)
+}
+
+func TestGetNodeVersion(t *testing.T) {
+	var calls int32
+	r := newVersionNode(t, "v1.4.8", &calls, nil)
+
+	for i := 0; i < 2; i++ {
+		version, err := r.GetNodeVersion()
+		require.Nil(t, err)
+		require.Equal(t, Version{Major: 1, Minor: 4, Patch: 8}, version)
+	}
+	require.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestGetNodeVersionDoesNotBlockCachedVersion(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	r := newVersionNode(t, "v1.4.8", &calls, release)
+
+	fetched := make(chan Error)
+	go func() {
+		_, err := r.GetNodeVersion()
+		fetched <- err
+	}()
+
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&calls) == 1 }, time.Second, time.Millisecond)
+
+	// requests of the version dependent calls are not blocked by the slow version request
+	done := make(chan bool)
+	go func() {
+		_, ok := r.version.cached()
+		done <- ok
+	}()
+	select {
+	case ok := <-done:
+		require.False(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("cached version is blocked by the version request")
+	}
+
+	close(release)
+	require.Nil(t, <-fetched)
+	_, ok := r.version.cached()
+	require.True(t, ok)
+}
+
+func TestVersionDependentRequests(t *testing.T) {
+	for _, c := range []struct {
+		version string
+		valid   bool
+	}{
+		// the API key is not configured to be sent, but old nodes do not validate without it
+		{version: "v1.1.10", valid: true},
+		{version: "v1.4.8", valid: false},
+	} {
+		var calls int32
+		r := newVersionNode(t, c.version, &calls, nil)
+
+		_, err := r.GetNodeVersion()
+		require.Nil(t, err)
+
+		status, err := r.GetNodeStatus()
+		require.Nil(t, err, c.version)
+		require.Equal(t, int32(10), status.BlockchainHeight)
+
+		result, err := r.ValidateTx(`{"id":"abc"}`)
+		if c.valid {
+			require.Nil(t, err, c.version)
+			require.True(t, result.IsValid)
+		} else {
+			require.NotNil(t, err, c.version)
+			require.Equal(t, uint16(ValidateEndpointUnavailableError), err.Code())
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package node
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/wavesplatform/transaction-broadcaster/internal/log"
+)
+
+// WarmUp establishes a connection to the node before it is needed by workers and logs the node latency
+// the first call pays connection (and TLS) setup, the second one is made over the established connection
+func WarmUp(nodeInteractor Interactor) Error {
+	logger := log.Logger.Named("node.warmUp")
+
+	start := time.Now()
+	height, err := nodeInteractor.GetCurrentHeight()
+	if err != nil {
+		return err
+	}
+	coldLatency := time.Since(start)
+
+	start = time.Now()
+	if _, err := nodeInteractor.GetCurrentHeight(); err != nil {
+		return err
+	}
+	warmLatency := time.Since(start)
+
+	logger.Info("node connection is warmed up",
+		zap.Int32("height", height),
+		zap.Duration("cold_latency", coldLatency),
+		zap.Duration("latency", warmLatency),
+	)
+
+	return nil
+}
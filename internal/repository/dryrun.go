@@ -0,0 +1,258 @@
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/wavesplatform/transaction-broadcaster/internal/log"
+)
+
+// dryRunImpl delegates reads to the underlying repository and only logs writes
+type dryRunImpl struct {
+	repo     Repository
+	logger   *zap.Logger
+	resetTxs bool
+}
+
+// NewDryRun returns Repository which never mutates state
+// reads are delegated to repo, writes are logged and ignored
+// if resetTxs is true, txs are read as pending ones, so the sequence can be replayed from scratch
+func NewDryRun(repo Repository, resetTxs bool) Repository {
+	return &dryRunImpl{
+		repo:     repo,
+		logger:   log.Logger.Named("dryRunRepository"),
+		resetTxs: resetTxs,
+	}
+}
+
+func (r *dryRunImpl) GetSequenceByID(sequenceID int64) (*Sequence, error) {
+	return r.repo.GetSequenceByID(sequenceID)
+}
+
+func (r *dryRunImpl) GetSequenceByTxID(txID string) (*Sequence, error) {
+	return r.repo.GetSequenceByTxID(txID)
+}
+
+func (r *dryRunImpl) GetSequenceTxsByID(sequenceID int64) ([]*SequenceTx, error) {
+	txs, err := r.repo.GetSequenceTxsByID(sequenceID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tx := range txs {
+		r.reset(tx)
+	}
+
+	return txs, nil
+}
+
+func (r *dryRunImpl) GetSequenceTx(sequenceID int64, positionInSequence int16) (*SequenceTx, error) {
+	tx, err := r.repo.GetSequenceTx(sequenceID, positionInSequence)
+	if err != nil || tx == nil {
+		return tx, err
+	}
+
+	r.reset(tx)
+
+	return tx, nil
+}
+
+func (r *dryRunImpl) GetBroadcastedTxOfOtherSequence(txID string, sequenceID int64) (*SequenceTx, error) {
+	return r.repo.GetBroadcastedTxOfOtherSequence(txID, sequenceID)
+}
+
+func (r *dryRunImpl) CountSequencesInStates(states []State) (int, error) {
+	return r.repo.CountSequencesInStates(states)
+}
+
+func (r *dryRunImpl) CountSequenceTxsNotInState(sequenceID int64, state TransactionState) (int, error) {
+	return r.repo.CountSequenceTxsNotInState(sequenceID, state)
+}
+
+func (r *dryRunImpl) GetSequenceTxsWindow(sequenceID int64, window TxsWindow) ([]*SequenceTx, error) {
+	// txs are read as pending ones, so none of them is buried
+	if r.resetTxs {
+		return r.GetSequenceTxsByID(sequenceID)
+	}
+
+	return r.repo.GetSequenceTxsWindow(sequenceID, window)
+}
+
+func (r *dryRunImpl) GetConfirmedTxsByHeight(height int32) ([]*SequenceTx, error) {
+	return r.repo.GetConfirmedTxsByHeight(height)
+}
+
+func (r *dryRunImpl) GetNewSequenceIds(options ClaimOptions) ([]int64, error) {
+	return r.repo.GetNewSequenceIds(options)
+}
+
+func (r *dryRunImpl) GetHangingSequenceIds(ttl time.Duration, excluding []int64) ([]int64, error) {
+	return r.repo.GetHangingSequenceIds(ttl, excluding)
+}
+
+func (r *dryRunImpl) SetSequencesLease(sequenceIDs []int64, owner string, ttl time.Duration) error {
+	r.logger.Info("set sequences lease", zap.Int64s("sequence_ids", sequenceIDs), zap.String("owner", owner), zap.Duration("ttl", ttl))
+	return nil
+}
+
+func (r *dryRunImpl) GetSequencesOwnedByExpiredLease(owner string) ([]int64, error) {
+	return r.repo.GetSequencesOwnedByExpiredLease(owner)
+}
+
+func (r *dryRunImpl) ReclaimSequence(sequenceID int64, owner string, ttl time.Duration) (bool, error) {
+	r.logger.Info("reclaim sequence", zap.Int64("sequence_id", sequenceID), zap.String("owner", owner), zap.Duration("ttl", ttl))
+	return true, nil
+}
+
+func (r *dryRunImpl) ClaimSequence(sequenceID int64, owner string) (bool, error) {
+	r.logger.Info("claim sequence", zap.Int64("sequence_id", sequenceID), zap.String("owner", owner))
+	return true, nil
+}
+
+func (r *dryRunImpl) TakeOverHangingSequence(sequenceID int64, owner string, ttl time.Duration) (bool, error) {
+	r.logger.Info("take over hanging sequence", zap.Int64("sequence_id", sequenceID), zap.String("owner", owner), zap.Duration("ttl", ttl))
+	return true, nil
+}
+
+func (r *dryRunImpl) RefreshSequence(sequenceID int64, owner string) (bool, error) {
+	r.logger.Info("refresh sequence", zap.Int64("sequence_id", sequenceID), zap.String("owner", owner))
+	return true, nil
+}
+
+func (r *dryRunImpl) GetSequenceOptions(sequenceID int64) (*SequenceOptions, error) {
+	return r.repo.GetSequenceOptions(sequenceID)
+}
+
+func (r *dryRunImpl) GetThroughput(window time.Duration) (*Throughput, error) {
+	return r.repo.GetThroughput(window)
+}
+
+func (r *dryRunImpl) GetSequencesByCreatedAt(from, to time.Time, states []State, limit int) ([]*Sequence, error) {
+	return r.repo.GetSequencesByCreatedAt(from, to, states, limit)
+}
+
+func (r *dryRunImpl) CreateSequence(txs []string, options SequenceOptions) (int64, error) {
+	return 0, errors.New("sequence cannot be created in dry run mode")
+}
+
+func (r *dryRunImpl) CreateSequenceFromSource(source TxsSource, batchSize int, options func() (SequenceOptions, error)) (int64, error) {
+	return 0, errors.New("sequence cannot be created in dry run mode")
+}
+
+func (r *dryRunImpl) SetSequenceStateByID(sequenceID int64, newState State) error {
+	r.logger.Info("set sequence state", zap.Int64("sequence_id", sequenceID), zap.Uint8("state", uint8(newState)))
+	return nil
+}
+
+func (r *dryRunImpl) SetSequenceErrorStateByID(sequenceID int64, errorMessage string, errorCode uint16) error {
+	r.logger.Info("set sequence error state", zap.Int64("sequence_id", sequenceID), zap.String("error_message", errorMessage), zap.Uint16("error_code", errorCode))
+	return nil
+}
+
+func (r *dryRunImpl) SetSequenceStateByIDIf(sequenceID int64, expectedState, newState State) (bool, error) {
+	r.logger.Info("set sequence state if", zap.Int64("sequence_id", sequenceID), zap.Uint8("expected_state", uint8(expectedState)), zap.Uint8("state", uint8(newState)))
+	return true, nil
+}
+
+func (r *dryRunImpl) SetSequenceErrorStateByIDIf(sequenceID int64, expectedState State, errorMessage string, errorCode uint16) (bool, error) {
+	r.logger.Info("set sequence error state if", zap.Int64("sequence_id", sequenceID), zap.Uint8("expected_state", uint8(expectedState)), zap.String("error_message", errorMessage), zap.Uint16("error_code", errorCode))
+	return true, nil
+}
+
+func (r *dryRunImpl) SetOwnedSequenceStateIf(sequenceID int64, owner string, expectedState, newState State) (bool, error) {
+	r.logger.Info("set owned sequence state if", zap.Int64("sequence_id", sequenceID), zap.String("owner", owner), zap.Uint8("expected_state", uint8(expectedState)), zap.Uint8("state", uint8(newState)))
+	return true, nil
+}
+
+func (r *dryRunImpl) SetOwnedSequenceErrorStateIf(sequenceID int64, owner string, expectedState State, errorMessage string, errorCode uint16) (bool, error) {
+	r.logger.Info("set owned sequence error state if", zap.Int64("sequence_id", sequenceID), zap.String("owner", owner), zap.Uint8("expected_state", uint8(expectedState)), zap.String("error_message", errorMessage), zap.Uint16("error_code", errorCode))
+	return true, nil
+}
+
+func (r *dryRunImpl) SetSequenceTxID(sequenceID int64, positionInSequence int16, txID, submittedTxID string, broadcastHeight int32) error {
+	r.logger.Info("set tx id", zap.Int64("sequence_id", sequenceID), zap.Int16("position_in_sequence", positionInSequence), zap.String("tx_id", txID), zap.String("submitted_tx_id", submittedTxID), zap.Int32("broadcast_height", broadcastHeight))
+	return nil
+}
+
+func (r *dryRunImpl) SkipSequenceTx(sequenceID int64, positionInSequence int16) (bool, error) {
+	r.logger.Info("skip sequence tx", zap.Int64("sequence_id", sequenceID), zap.Int16("position_in_sequence", positionInSequence))
+	return true, nil
+}
+
+func (r *dryRunImpl) SkipSequenceTo(sequenceID int64, positionInSequence int16) (bool, error) {
+	r.logger.Info("skip sequence to", zap.Int64("sequence_id", sequenceID), zap.Int16("position_in_sequence", positionInSequence))
+	return true, nil
+}
+
+func (r *dryRunImpl) SetSequenceTxState(sequenceID int64, positionInSequence int16, newState TransactionState) (bool, error) {
+	r.logger.Info("set tx state", zap.Int64("sequence_id", sequenceID), zap.Int16("position_in_sequence", positionInSequence), zap.Uint8("state", uint8(newState)))
+	return true, nil
+}
+
+func (r *dryRunImpl) SetSequenceTxConfirmedState(sequenceID int64, positionInSequence int16, height int32) error {
+	r.logger.Info("set tx confirmed state", zap.Int64("sequence_id", sequenceID), zap.Int16("position_in_sequence", positionInSequence), zap.Int32("height", height))
+	return nil
+}
+
+func (r *dryRunImpl) SetSequenceTxsStateAfter(sequenceID int64, txID string, newState TransactionState) error {
+	if txID == "" {
+		return ErrEmptyTxID
+	}
+
+	r.logger.Info("set txs state after", zap.Int64("sequence_id", sequenceID), zap.String("tx_id", txID), zap.Uint8("state", uint8(newState)))
+	return nil
+}
+
+func (r *dryRunImpl) SetSequenceTxErrorMessage(sequenceID int64, positionInSequence int16, errorMessage string) error {
+	r.logger.Info("set tx error message", zap.Int64("sequence_id", sequenceID), zap.Int16("position_in_sequence", positionInSequence), zap.String("error_message", errorMessage))
+	return nil
+}
+
+func (r *dryRunImpl) SetSequenceTxValidationTrace(sequenceID int64, positionInSequence int16, trace json.RawMessage) error {
+	r.logger.Info("set tx validation trace", zap.Int64("sequence_id", sequenceID), zap.Int16("position_in_sequence", positionInSequence), zap.ByteString("trace", trace))
+	return nil
+}
+
+func (r *dryRunImpl) SetSequenceTxValidationWarnings(sequenceID int64, positionInSequence int16, warnings []string) error {
+	r.logger.Info("set tx validation warnings", zap.Int64("sequence_id", sequenceID), zap.Int16("position_in_sequence", positionInSequence), zap.Strings("warnings", warnings))
+	return nil
+}
+
+func (r *dryRunImpl) ResetSequenceTxErrorMessage(sequenceID int64, positionInSequence int16) error {
+	r.logger.Info("reset tx error message", zap.Int64("sequence_id", sequenceID), zap.Int16("position_in_sequence", positionInSequence))
+	return nil
+}
+
+// Close closes the underlying repository, the dry run does not mutate state but still holds its connections
+func (r *dryRunImpl) SetSequenceProgress(sequenceID int64, lastConfirmedPosition int16) error {
+	r.logger.Info("set sequence progress", zap.Int64("sequence_id", sequenceID), zap.Int16("last_confirmed_position", lastConfirmedPosition))
+	return nil
+}
+
+func (r *dryRunImpl) CreateDeadLetter(sequenceID int64) error {
+	r.logger.Info("create dead letter", zap.Int64("sequence_id", sequenceID))
+	return nil
+}
+
+func (r *dryRunImpl) DeleteExpiredSequences(defaultRetention time.Duration) (int, error) {
+	r.logger.Info("delete expired sequences", zap.Duration("default_retention", defaultRetention))
+	return 0, nil
+}
+
+func (r *dryRunImpl) Close() error {
+	return r.repo.Close()
+}
+
+func (r *dryRunImpl) reset(tx *SequenceTx) {
+	if !r.resetTxs {
+		return
+	}
+
+	tx.ID = ""
+	tx.State = TransactionStatePending
+	tx.Height = 0
+	tx.ErrorMessage = ""
+}
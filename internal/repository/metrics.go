@@ -0,0 +1,351 @@
+package repository
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics collects db query durations by repository operation
+type Metrics struct {
+	duration *prometheus.HistogramVec
+	errors   *prometheus.CounterVec
+}
+
+// NewMetrics returns Metrics registered in registerer
+// registerer is optional, metrics are collected but not exposed if it is nil
+func NewMetrics(registerer prometheus.Registerer) (*Metrics, error) {
+	m := &Metrics{
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "db_query_duration_seconds",
+			Help:    "Duration of db queries by repository operation.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"operation"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "db_query_errors_total",
+			Help: "Count of failed db queries by repository operation.",
+		}, []string{"operation"}),
+	}
+
+	if registerer != nil {
+		if err := registerer.Register(m.duration); err != nil {
+			return nil, err
+		}
+		if err := registerer.Register(m.errors); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+func (m *Metrics) observe(operation string, start time.Time, err error) {
+	m.duration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	if err != nil {
+		m.errors.WithLabelValues(operation).Inc()
+	}
+}
+
+// instrumentedImpl delegates all calls to the underlying repository measuring their durations
+type instrumentedImpl struct {
+	repo    Repository
+	metrics *Metrics
+}
+
+// NewInstrumented returns Repository recording durations of repo operations to metrics
+func NewInstrumented(repo Repository, metrics *Metrics) Repository {
+	return &instrumentedImpl{repo: repo, metrics: metrics}
+}
+
+func (r *instrumentedImpl) GetSequenceByID(sequenceID int64) (*Sequence, error) {
+	start := time.Now()
+	result, err := r.repo.GetSequenceByID(sequenceID)
+	r.metrics.observe("get_sequence", start, err)
+	return result, err
+}
+
+func (r *instrumentedImpl) GetSequenceByTxID(txID string) (*Sequence, error) {
+	start := time.Now()
+	result, err := r.repo.GetSequenceByTxID(txID)
+	r.metrics.observe("get_sequence_by_tx_id", start, err)
+	return result, err
+}
+
+func (r *instrumentedImpl) GetSequenceTxsByID(sequenceID int64) ([]*SequenceTx, error) {
+	start := time.Now()
+	result, err := r.repo.GetSequenceTxsByID(sequenceID)
+	r.metrics.observe("get_sequence_txs", start, err)
+	return result, err
+}
+
+func (r *instrumentedImpl) GetSequenceTx(sequenceID int64, positionInSequence int16) (*SequenceTx, error) {
+	start := time.Now()
+	result, err := r.repo.GetSequenceTx(sequenceID, positionInSequence)
+	r.metrics.observe("get_sequence_tx", start, err)
+	return result, err
+}
+
+func (r *instrumentedImpl) GetSequenceTxsWindow(sequenceID int64, window TxsWindow) ([]*SequenceTx, error) {
+	start := time.Now()
+	result, err := r.repo.GetSequenceTxsWindow(sequenceID, window)
+	r.metrics.observe("get_sequence_txs_window", start, err)
+	return result, err
+}
+
+func (r *instrumentedImpl) CountSequenceTxsNotInState(sequenceID int64, state TransactionState) (int, error) {
+	start := time.Now()
+	count, err := r.repo.CountSequenceTxsNotInState(sequenceID, state)
+	r.metrics.observe("count_sequence_txs_not_in_state", start, err)
+	return count, err
+}
+
+func (r *instrumentedImpl) CountSequencesInStates(states []State) (int, error) {
+	start := time.Now()
+	count, err := r.repo.CountSequencesInStates(states)
+	r.metrics.observe("count_sequences_in_states", start, err)
+	return count, err
+}
+
+func (r *instrumentedImpl) GetBroadcastedTxOfOtherSequence(txID string, sequenceID int64) (*SequenceTx, error) {
+	start := time.Now()
+	result, err := r.repo.GetBroadcastedTxOfOtherSequence(txID, sequenceID)
+	r.metrics.observe("get_broadcasted_tx_of_other_sequence", start, err)
+	return result, err
+}
+
+func (r *instrumentedImpl) GetConfirmedTxsByHeight(height int32) ([]*SequenceTx, error) {
+	start := time.Now()
+	result, err := r.repo.GetConfirmedTxsByHeight(height)
+	r.metrics.observe("get_confirmed_txs_by_height", start, err)
+	return result, err
+}
+
+func (r *instrumentedImpl) GetNewSequenceIds(options ClaimOptions) ([]int64, error) {
+	start := time.Now()
+	result, err := r.repo.GetNewSequenceIds(options)
+	r.metrics.observe("get_new_sequence_ids", start, err)
+	return result, err
+}
+
+func (r *instrumentedImpl) SetSequencesLease(sequenceIDs []int64, owner string, ttl time.Duration) error {
+	start := time.Now()
+	err := r.repo.SetSequencesLease(sequenceIDs, owner, ttl)
+	r.metrics.observe("set_sequences_lease", start, err)
+	return err
+}
+
+func (r *instrumentedImpl) GetSequencesOwnedByExpiredLease(owner string) ([]int64, error) {
+	start := time.Now()
+	result, err := r.repo.GetSequencesOwnedByExpiredLease(owner)
+	r.metrics.observe("get_sequences_owned_by_expired_lease", start, err)
+	return result, err
+}
+
+func (r *instrumentedImpl) ReclaimSequence(sequenceID int64, owner string, ttl time.Duration) (bool, error) {
+	start := time.Now()
+	reclaimed, err := r.repo.ReclaimSequence(sequenceID, owner, ttl)
+	r.metrics.observe("reclaim_sequence", start, err)
+	return reclaimed, err
+}
+
+func (r *instrumentedImpl) ClaimSequence(sequenceID int64, owner string) (bool, error) {
+	start := time.Now()
+	claimed, err := r.repo.ClaimSequence(sequenceID, owner)
+	r.metrics.observe("claim_sequence", start, err)
+	return claimed, err
+}
+
+func (r *instrumentedImpl) TakeOverHangingSequence(sequenceID int64, owner string, ttl time.Duration) (bool, error) {
+	start := time.Now()
+	takenOver, err := r.repo.TakeOverHangingSequence(sequenceID, owner, ttl)
+	r.metrics.observe("take_over_hanging_sequence", start, err)
+	return takenOver, err
+}
+
+func (r *instrumentedImpl) RefreshSequence(sequenceID int64, owner string) (bool, error) {
+	start := time.Now()
+	refreshed, err := r.repo.RefreshSequence(sequenceID, owner)
+	r.metrics.observe("refresh_sequence", start, err)
+	return refreshed, err
+}
+
+func (r *instrumentedImpl) GetHangingSequenceIds(ttl time.Duration, excluding []int64) ([]int64, error) {
+	start := time.Now()
+	result, err := r.repo.GetHangingSequenceIds(ttl, excluding)
+	r.metrics.observe("get_hanging_sequence_ids", start, err)
+	return result, err
+}
+
+func (r *instrumentedImpl) GetSequenceOptions(sequenceID int64) (*SequenceOptions, error) {
+	start := time.Now()
+	result, err := r.repo.GetSequenceOptions(sequenceID)
+	r.metrics.observe("get_sequence_options", start, err)
+	return result, err
+}
+
+func (r *instrumentedImpl) GetThroughput(window time.Duration) (*Throughput, error) {
+	start := time.Now()
+	result, err := r.repo.GetThroughput(window)
+	r.metrics.observe("get_throughput", start, err)
+	return result, err
+}
+
+func (r *instrumentedImpl) GetSequencesByCreatedAt(from, to time.Time, states []State, limit int) ([]*Sequence, error) {
+	start := time.Now()
+	result, err := r.repo.GetSequencesByCreatedAt(from, to, states, limit)
+	r.metrics.observe("get_sequences_by_created_at", start, err)
+	return result, err
+}
+
+func (r *instrumentedImpl) CreateSequence(txs []string, options SequenceOptions) (int64, error) {
+	start := time.Now()
+	result, err := r.repo.CreateSequence(txs, options)
+	r.metrics.observe("create_sequence", start, err)
+	return result, err
+}
+
+func (r *instrumentedImpl) CreateSequenceFromSource(source TxsSource, batchSize int, options func() (SequenceOptions, error)) (int64, error) {
+	start := time.Now()
+	result, err := r.repo.CreateSequenceFromSource(source, batchSize, options)
+	// includes reading of the source, e.g. the request body
+	r.metrics.observe("create_sequence_from_source", start, err)
+	return result, err
+}
+
+func (r *instrumentedImpl) SetSequenceStateByID(sequenceID int64, newState State) error {
+	start := time.Now()
+	err := r.repo.SetSequenceStateByID(sequenceID, newState)
+	r.metrics.observe("set_sequence_state", start, err)
+	return err
+}
+
+func (r *instrumentedImpl) SetSequenceErrorStateByID(sequenceID int64, errorMessage string, errorCode uint16) error {
+	start := time.Now()
+	err := r.repo.SetSequenceErrorStateByID(sequenceID, errorMessage, errorCode)
+	r.metrics.observe("set_sequence_error_state", start, err)
+	return err
+}
+
+func (r *instrumentedImpl) SetSequenceStateByIDIf(sequenceID int64, expectedState, newState State) (bool, error) {
+	start := time.Now()
+	result, err := r.repo.SetSequenceStateByIDIf(sequenceID, expectedState, newState)
+	r.metrics.observe("set_sequence_state", start, err)
+	return result, err
+}
+
+func (r *instrumentedImpl) SetSequenceErrorStateByIDIf(sequenceID int64, expectedState State, errorMessage string, errorCode uint16) (bool, error) {
+	start := time.Now()
+	result, err := r.repo.SetSequenceErrorStateByIDIf(sequenceID, expectedState, errorMessage, errorCode)
+	r.metrics.observe("set_sequence_error_state", start, err)
+	return result, err
+}
+
+func (r *instrumentedImpl) SetOwnedSequenceStateIf(sequenceID int64, owner string, expectedState, newState State) (bool, error) {
+	start := time.Now()
+	result, err := r.repo.SetOwnedSequenceStateIf(sequenceID, owner, expectedState, newState)
+	r.metrics.observe("set_sequence_state", start, err)
+	return result, err
+}
+
+func (r *instrumentedImpl) SetOwnedSequenceErrorStateIf(sequenceID int64, owner string, expectedState State, errorMessage string, errorCode uint16) (bool, error) {
+	start := time.Now()
+	result, err := r.repo.SetOwnedSequenceErrorStateIf(sequenceID, owner, expectedState, errorMessage, errorCode)
+	r.metrics.observe("set_sequence_error_state", start, err)
+	return result, err
+}
+
+func (r *instrumentedImpl) SetSequenceTxID(sequenceID int64, positionInSequence int16, txID, submittedTxID string, broadcastHeight int32) error {
+	start := time.Now()
+	err := r.repo.SetSequenceTxID(sequenceID, positionInSequence, txID, submittedTxID, broadcastHeight)
+	r.metrics.observe("set_tx_id", start, err)
+	return err
+}
+
+func (r *instrumentedImpl) SkipSequenceTx(sequenceID int64, positionInSequence int16) (bool, error) {
+	start := time.Now()
+	skipped, err := r.repo.SkipSequenceTx(sequenceID, positionInSequence)
+	r.metrics.observe("skip_sequence_tx", start, err)
+	return skipped, err
+}
+
+func (r *instrumentedImpl) SkipSequenceTo(sequenceID int64, positionInSequence int16) (bool, error) {
+	start := time.Now()
+	skipped, err := r.repo.SkipSequenceTo(sequenceID, positionInSequence)
+	r.metrics.observe("skip_sequence_to", start, err)
+	return skipped, err
+}
+
+func (r *instrumentedImpl) SetSequenceTxState(sequenceID int64, positionInSequence int16, newState TransactionState) (bool, error) {
+	start := time.Now()
+	updated, err := r.repo.SetSequenceTxState(sequenceID, positionInSequence, newState)
+	r.metrics.observe("set_tx_state", start, err)
+	return updated, err
+}
+
+func (r *instrumentedImpl) SetSequenceTxConfirmedState(sequenceID int64, positionInSequence int16, height int32) error {
+	start := time.Now()
+	err := r.repo.SetSequenceTxConfirmedState(sequenceID, positionInSequence, height)
+	r.metrics.observe("set_tx_confirmed_state", start, err)
+	return err
+}
+
+func (r *instrumentedImpl) SetSequenceTxsStateAfter(sequenceID int64, txID string, newState TransactionState) error {
+	start := time.Now()
+	err := r.repo.SetSequenceTxsStateAfter(sequenceID, txID, newState)
+	r.metrics.observe("set_txs_state_after", start, err)
+	return err
+}
+
+func (r *instrumentedImpl) SetSequenceTxValidationTrace(sequenceID int64, positionInSequence int16, trace json.RawMessage) error {
+	start := time.Now()
+	err := r.repo.SetSequenceTxValidationTrace(sequenceID, positionInSequence, trace)
+	r.metrics.observe("set_sequence_tx_validation_trace", start, err)
+	return err
+}
+
+func (r *instrumentedImpl) SetSequenceTxValidationWarnings(sequenceID int64, positionInSequence int16, warnings []string) error {
+	start := time.Now()
+	err := r.repo.SetSequenceTxValidationWarnings(sequenceID, positionInSequence, warnings)
+	r.metrics.observe("set_sequence_tx_validation_warnings", start, err)
+	return err
+}
+
+func (r *instrumentedImpl) SetSequenceTxErrorMessage(sequenceID int64, positionInSequence int16, errorMessage string) error {
+	start := time.Now()
+	err := r.repo.SetSequenceTxErrorMessage(sequenceID, positionInSequence, errorMessage)
+	r.metrics.observe("set_tx_error_message", start, err)
+	return err
+}
+
+func (r *instrumentedImpl) ResetSequenceTxErrorMessage(sequenceID int64, positionInSequence int16) error {
+	start := time.Now()
+	err := r.repo.ResetSequenceTxErrorMessage(sequenceID, positionInSequence)
+	r.metrics.observe("set_tx_error_message", start, err)
+	return err
+}
+
+func (r *instrumentedImpl) SetSequenceProgress(sequenceID int64, lastConfirmedPosition int16) error {
+	start := time.Now()
+	err := r.repo.SetSequenceProgress(sequenceID, lastConfirmedPosition)
+	r.metrics.observe("set_sequence_progress", start, err)
+	return err
+}
+
+func (r *instrumentedImpl) CreateDeadLetter(sequenceID int64) error {
+	start := time.Now()
+	err := r.repo.CreateDeadLetter(sequenceID)
+	r.metrics.observe("create_dead_letter", start, err)
+	return err
+}
+
+func (r *instrumentedImpl) DeleteExpiredSequences(defaultRetention time.Duration) (int, error) {
+	start := time.Now()
+	count, err := r.repo.DeleteExpiredSequences(defaultRetention)
+	r.metrics.observe("delete_expired_sequences", start, err)
+	return count, err
+}
+
+func (r *instrumentedImpl) Close() error {
+	return r.repo.Close()
+}
@@ -2,12 +2,110 @@ package repository
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
 	"time"
 
 	//
 	"github.com/go-pg/pg/v9"
 )
 
+// ErrEmptyTxID is returned when an operation requires tx id but an empty one was given
+var ErrEmptyTxID = errors.New("tx id is empty")
+
+// MaxSequenceTxsError is returned when a sequence being created has more txs than allowed
+type MaxSequenceTxsError struct {
+	MaxTxs int
+}
+
+func (e *MaxSequenceTxsError) Error() string {
+	return fmt.Sprintf("sequence has more than %d txs", e.MaxTxs)
+}
+
+// DBUnavailableError is returned when the db temporarily cannot accept writes, e.g. the primary is read-only during failover
+type DBUnavailableError struct {
+	Err error
+}
+
+func (e *DBUnavailableError) Error() string {
+	return "db is temporarily unavailable: " + e.Err.Error()
+}
+
+func (e *DBUnavailableError) Unwrap() error {
+	return e.Err
+}
+
+// unavailableSQLStates are classes of pg errors which go away after a while without any changes of the request
+var unavailableSQLStates = map[string]bool{
+	"25006": true, // read_only_sql_transaction, e.g. the primary is demoted or is in recovery
+	"53300": true, // too_many_connections
+	"57P03": true, // cannot_connect_now, e.g. the db is starting up
+}
+
+// connectionErrorMessages are messages of pg pool errors, the pool errors are not exported
+var connectionErrorMessages = map[string]bool{
+	"pg: database is closed":      true,
+	"pg: connection pool timeout": true,
+}
+
+// IsConnectionError returns whether err means the db cannot be reached or does not serve requests at all,
+// unlike errors of particular queries
+func IsConnectionError(err error) bool {
+	if connectionErrorMessages[err.Error()] || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	var pgErr pg.Error
+	if errors.As(err, &pgErr) {
+		code := pgErr.Field('C')
+		// class 08 is connection exceptions, FATAL errors terminate the connection
+		return strings.HasPrefix(code, "08") || unavailableSQLStates[code] || pgErr.Field('S') == "FATAL"
+	}
+
+	return false
+}
+
+// transientSQLStates are errors of a particular query which the same query may not fail with when it is repeated
+var transientSQLStates = map[string]bool{
+	"55P03": true, // lock_not_available
+	"57014": true, // query_canceled, e.g. by the statement timeout
+}
+
+// IsTransientError returns whether err is a failure of a particular query which is worth repeating,
+// class 40 is transaction rollbacks, e.g. serialization failures and deadlocks
+// connection errors are not transient, the db does not serve requests at all
+func IsTransientError(err error) bool {
+	if IsConnectionError(err) {
+		return false
+	}
+
+	var pgErr pg.Error
+	if errors.As(err, &pgErr) {
+		code := pgErr.Field('C')
+		return strings.HasPrefix(code, "40") || transientSQLStates[code]
+	}
+
+	return false
+}
+
+// wrapUnavailable wraps pg errors of the db temporarily unavailable for writes into DBUnavailableError, other errors are returned as is
+func wrapUnavailable(err error) error {
+	var pgErr pg.Error
+	if errors.As(err, &pgErr) && unavailableSQLStates[pgErr.Field('C')] {
+		return &DBUnavailableError{Err: err}
+	}
+	return err
+}
+
 // PgConfig represents application PostgreSQL config
 type PgConfig struct {
 	Host     string `env:"PGHOST,required"`
@@ -15,6 +113,16 @@ type PgConfig struct {
 	Database string `env:"PGDATABASE,required"`
 	User     string `env:"PGUSER,required"`
 	Password string `env:"PGPASSWORD,required"`
+
+	// optional read replica, shares database and credentials with the primary
+	ReplicaHost string `env:"PGHOST_REPLICA"`
+	ReplicaPort int    `env:"PGPORT_REPLICA" envDefault:"5432"`
+
+	// whether to run db migrations on start
+	MigrateOnStart bool `env:"PG_MIGRATE_ON_START" envDefault:"false"`
+
+	// whether to collect db query durations
+	QueryMetrics bool `env:"PG_QUERY_METRICS" envDefault:"false"`
 }
 
 type ErrorInfo struct {
@@ -27,14 +135,50 @@ type Sequence struct {
 	ID               int64  `json:"id"`
 	BroadcastedCount uint32 `json:"broadcasted_count"`
 	TotalCount       uint32 `json:"total_count"`
-	State            State  `json:"state"`
-	ErrorInfo        `json:"error"`
-	CreatedAt        time.Time `json:"created_at"`
-	UpdatedAt        time.Time `json:"updated_at"`
+	// LastConfirmedPosition is updated by the worker, so progress can be read without counting txs
+	LastConfirmedPosition *int16 `json:"last_confirmed_position"`
+	State                 State  `json:"state"`
+	ErrorInfo             `json:"error"`
+	Mode                  SequenceMode `json:"mode,omitempty"`
+	NodeURL               string       `json:"node_url,omitempty"`
+	Label                 string       `json:"label,omitempty"`
+	CreatedAt             time.Time    `json:"created_at"`
+	UpdatedAt             time.Time    `json:"updated_at"`
+	TimeFormat            TimeFormat   `json:"-" pg:"-"`
+}
+
+// TimeFormat represents serialization format of timestamps
+type TimeFormat uint8
+
+// Enum of TimeFormat
+const (
+	TimeFormatUnixMillis TimeFormat = iota
+	TimeFormatRFC3339
+)
+
+// UnmarshalText parses TimeFormat from its name
+func (f *TimeFormat) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "unix_millis":
+		*f = TimeFormatUnixMillis
+	case "rfc3339":
+		*f = TimeFormatRFC3339
+	default:
+		return fmt.Errorf("unknown time format: %s", text)
+	}
+	return nil
+}
+
+// Format returns serializable representation of t
+func (f TimeFormat) Format(t time.Time) interface{} {
+	if f == TimeFormatRFC3339 {
+		return t.UTC().Format(time.RFC3339Nano)
+	}
+	return t.Unix()*1000 + int64(t.Nanosecond()/1000000)
 }
 
 // MarshalJSON overrides default json serializer
-// Its serializes time as unix timestamp
+// Its serializes time according to TimeFormat (unix timestamp in ms by default)
 func (s *Sequence) MarshalJSON() ([]byte, error) {
 	type JSONSequence Sequence
 	var info *ErrorInfo
@@ -47,42 +191,157 @@ func (s *Sequence) MarshalJSON() ([]byte, error) {
 
 	return json.Marshal(&struct {
 		*JSONSequence
-		ErrorInfo *ErrorInfo `json:"error"`
-		CreatedAt int64      `json:"created_at"`
-		UpdatedAt int64      `json:"updated_at"`
+		ErrorInfo *ErrorInfo  `json:"error"`
+		CreatedAt interface{} `json:"created_at"`
+		UpdatedAt interface{} `json:"updated_at"`
 	}{
 		JSONSequence: (*JSONSequence)(s),
-		CreatedAt:    s.CreatedAt.Unix()*1000 + int64(s.CreatedAt.Nanosecond()/1000000),
-		UpdatedAt:    s.UpdatedAt.Unix()*1000 + int64(s.UpdatedAt.Nanosecond()/1000000),
+		CreatedAt:    s.TimeFormat.Format(s.CreatedAt),
+		UpdatedAt:    s.TimeFormat.Format(s.UpdatedAt),
 		ErrorInfo:    info,
 	})
 }
 
+// SequenceMode represents the way the sequence txs are processed
+type SequenceMode string
+
+// Enum of SequenceMode
+const (
+	// SequenceModeBroadcast is the default mode, txs are validated, broadcasted and confirmed one by one
+	SequenceModeBroadcast SequenceMode = ""
+	// SequenceModeValidateOnly makes the worker validate txs in order without broadcasting them
+	SequenceModeValidateOnly SequenceMode = "validate_only"
+	// SequenceModeIndependent is for txs not depending on each other, pending txs are validated and broadcasted at once
+	SequenceModeIndependent SequenceMode = "independent"
+)
+
+// UnmarshalText parses SequenceMode from its name
+func (m *SequenceMode) UnmarshalText(text []byte) error {
+	switch mode := SequenceMode(text); mode {
+	case SequenceModeBroadcast, "broadcast":
+		*m = SequenceModeBroadcast
+	case SequenceModeValidateOnly, SequenceModeIndependent:
+		*m = mode
+	default:
+		return fmt.Errorf("unknown sequence mode: %s", text)
+	}
+	return nil
+}
+
+// SequenceOptions represents optional per-sequence settings
+type SequenceOptions struct {
+	// NodeURL overrides the default node for the sequence, empty means default node
+	NodeURL string
+	// SkipValidation makes the worker broadcast txs without validating them
+	SkipValidation bool
+	// Deadline is the time after which txs must not be broadcasted, zero means no deadline
+	Deadline time.Time
+	// TxOutdateTime overrides the worker tx outdate time (ms), zero means default
+	TxOutdateTime int32
+	// Confirmations are per-position min confirmations of txs, txs beyond the array use the worker setting
+	// they are stored per tx, so they are not loaded back by GetSequenceOptions
+	Confirmations []int32 `pg:"-"`
+	// TraceParent is W3C traceparent of the create request span, the sequence processing continues its trace
+	TraceParent string
+	// Label groups sequences of the same tenant, e.g. to limit count of its sequences processed at the same time
+	Label string
+	// BroadcastInterval overrides the worker min interval between broadcasts of the sequence txs (ms), zero means default
+	BroadcastInterval int32
+	// Mode is the way the sequence txs are processed
+	Mode SequenceMode
+	// PerTxCallbackURL is posted to after each tx of the sequence is confirmed, empty means no callbacks
+	PerTxCallbackURL string
+	// RetentionSeconds is how long the completed sequence is kept, zero means the dispatcher default retention
+	RetentionSeconds int32
+}
+
+// ClaimOptions represents options of new sequences claiming
+type ClaimOptions struct {
+	// MaxProcessingPerLabel limits count of processing sequences with the same label, 0 means no limit
+	MaxProcessingPerLabel int
+	// LabelWeights are counts of sequences of the label claimed per round, labels without weight have weight 1
+	LabelWeights map[string]int
+	// Limit is max count of sequences claimed at once, labels get their weighted share of it, 0 means no limit
+	Limit int
+}
+
+// TxsWindow describes which confirmed txs cannot be pulled out by a rollback anymore:
+// the ones at least Depth heights below CurrentHeight having their min confirmations (MinConfirmations unless a tx has its own)
+type TxsWindow struct {
+	CurrentHeight int32
+	// Depth is the reorg window, all txs are within the window if it is not positive
+	Depth            int32
+	MinConfirmations int32
+}
+
+// Throughput represents sequences processing statistics over a time window
+type Throughput struct {
+	CreatedSequences int64
+	DoneSequences    int64
+	ErrorSequences   int64
+	ConfirmedTxs     int64
+}
+
+// TxsSource provides sequence txs one by one, Next returns io.EOF when there are no more txs
+type TxsSource interface {
+	Next() (string, error)
+}
+
+type sliceTxsSource struct {
+	txs []string
+}
+
+func (s *sliceTxsSource) Next() (string, error) {
+	if len(s.txs) == 0 {
+		return "", io.EOF
+	}
+
+	tx := s.txs[0]
+	s.txs = s.txs[1:]
+
+	return tx, nil
+}
+
+// defaultInsertBatchSize is count of txs inserted by a single query if batch size is not specified
+const defaultInsertBatchSize = 100
+
+// MaxSequenceTxs is the limit of position_in_sequence (int16) checked by the db as well, the configured max cannot exceed it
+const MaxSequenceTxs = 32768
+
 // SequenceTx represents sequence transaction type
 type SequenceTx struct {
-	ID                 string           `json:"id"`
-	SequenceID         int64            `json:"-"`
-	State              TransactionState `json:"state"`
-	Height             int32            `json:"height"`
-	ErrorMessage       string           `json:"error_message,omitempty"`
-	PositionInSequence int16            `json:"position_in_sequence"`
-	Tx                 string           `json:"tx"`
-	CreatedAt          time.Time        `json:"created_at"`
-	UpdatedAt          time.Time        `json:"updated_at"`
+	ID          string           `json:"id"`
+	SubmittedID string           `json:"submitted_id,omitempty"`
+	SequenceID  int64            `json:"-"`
+	State       TransactionState `json:"state"`
+	Height      int32            `json:"height"`
+	// BroadcastHeight is the node height at the tx broadcast, it is 0 if unknown
+	BroadcastHeight    int32  `json:"broadcast_height,omitempty"`
+	ErrorMessage       string `json:"error_message,omitempty"`
+	PositionInSequence int16  `json:"position_in_sequence"`
+	MinConfirmations   *int32 `json:"min_confirmations,omitempty"`
+	// ValidationTrace is the node script execution trace of the last failed validation, it is loaded only by GetSequenceTx
+	ValidationTrace json.RawMessage `json:"validation_trace,omitempty"`
+	// ValidationWarnings are reported by the node for the valid tx, they are loaded only by GetSequenceTx
+	ValidationWarnings []string   `json:"validation_warnings,omitempty" pg:",array"`
+	Tx                 string     `json:"tx"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+	TimeFormat         TimeFormat `json:"-" pg:"-"`
 }
 
 // MarshalJSON overrides default json serializer
-// Its serializes time as unix timestamp
+// Its serializes time according to TimeFormat (unix timestamp in ms by default)
 func (stx *SequenceTx) MarshalJSON() ([]byte, error) {
 	type JSONSequenceTx SequenceTx
 	return json.Marshal(&struct {
 		*JSONSequenceTx
-		CreatedAt int64 `json:"created_at"`
-		UpdatedAt int64 `json:"updated_at"`
+		CreatedAt interface{} `json:"created_at"`
+		UpdatedAt interface{} `json:"updated_at"`
 	}{
 		JSONSequenceTx: (*JSONSequenceTx)(stx),
-		CreatedAt:      stx.CreatedAt.Unix()*1000 + int64(stx.CreatedAt.Nanosecond()/1000000),
-		UpdatedAt:      stx.UpdatedAt.Unix()*1000 + int64(stx.UpdatedAt.Nanosecond()/1000000),
+		CreatedAt:      stx.TimeFormat.Format(stx.CreatedAt),
+		UpdatedAt:      stx.TimeFormat.Format(stx.UpdatedAt),
 	})
 }
 
@@ -103,6 +362,28 @@ const (
 	StateError
 )
 
+// IsFinal returns whether the sequence is not processed anymore in the state
+func (st State) IsFinal() bool {
+	return st == StateDone || st == StateError
+}
+
+// UnmarshalText parses State from its name
+func (st *State) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "pending":
+		*st = StatePending
+	case "processing":
+		*st = StateProcessing
+	case "done":
+		*st = StateDone
+	case "error":
+		*st = StateError
+	default:
+		return fmt.Errorf("unknown sequence state: %s", text)
+	}
+	return nil
+}
+
 // MarshalJSON override default serializaion of State type
 func (st State) MarshalJSON() ([]byte, error) {
 	var s string
@@ -133,6 +414,8 @@ const (
 	TransactionStateUnconfirmed
 	TransactionStateConfirmed
 	TransactionStateError
+	// TransactionStateSkipped is the final state of the tx cancelled before its broadcast, the rest of txs are processed as usual
+	TransactionStateSkipped
 )
 
 // MarshalJSON override default serializaion of TransactionState type
@@ -151,6 +434,8 @@ func (st TransactionState) MarshalJSON() ([]byte, error) {
 		s = "confirmed"
 	case TransactionStateError:
 		s = "error"
+	case TransactionStateSkipped:
+		s = "skipped"
 	default:
 		s = "pending"
 	}
@@ -161,34 +446,108 @@ func (st TransactionState) MarshalJSON() ([]byte, error) {
 // Repository ...
 type Repository interface {
 	GetSequenceByID(id int64) (*Sequence, error)
+	GetSequenceByTxID(txID string) (*Sequence, error)
 	GetSequenceTxsByID(sequenceID int64) ([]*SequenceTx, error)
 	GetSequenceTx(sequenceID int64, positionInSequence int16) (*SequenceTx, error)
-	GetNewSequenceIds() ([]int64, error)
+	GetSequenceTxsWindow(sequenceID int64, window TxsWindow) ([]*SequenceTx, error)
+	GetConfirmedTxsByHeight(height int32) ([]*SequenceTx, error)
+	GetBroadcastedTxOfOtherSequence(txID string, sequenceID int64) (*SequenceTx, error)
+	CountSequenceTxsNotInState(sequenceID int64, state TransactionState) (int, error)
+	GetNewSequenceIds(options ClaimOptions) ([]int64, error)
 	GetHangingSequenceIds(ttl time.Duration, excluding []int64) ([]int64, error)
-	CreateSequence(txs []string) (int64, error)
+	SetSequencesLease(sequenceIDs []int64, owner string, ttl time.Duration) error
+	GetSequencesOwnedByExpiredLease(owner string) ([]int64, error)
+	ReclaimSequence(sequenceID int64, owner string, ttl time.Duration) (bool, error)
+	ClaimSequence(sequenceID int64, owner string) (bool, error)
+	TakeOverHangingSequence(sequenceID int64, owner string, ttl time.Duration) (bool, error)
+	RefreshSequence(sequenceID int64, owner string) (bool, error)
+	GetSequenceOptions(sequenceID int64) (*SequenceOptions, error)
+	GetThroughput(window time.Duration) (*Throughput, error)
+	GetSequencesByCreatedAt(from, to time.Time, states []State, limit int) ([]*Sequence, error)
+	CountSequencesInStates(states []State) (int, error)
+	CreateSequence(txs []string, options SequenceOptions) (int64, error)
+	CreateSequenceFromSource(source TxsSource, batchSize int, options func() (SequenceOptions, error)) (int64, error)
 	SetSequenceStateByID(sequenceID int64, newState State) error
 	SetSequenceErrorStateByID(sequenceID int64, errorMessage string, errorCode uint16) error
-	SetSequenceTxID(sequenceID int64, positionInSequence int16, txID string) error
-	SetSequenceTxState(sequenceID int64, positionInSequence int16, newState TransactionState) error
+	SetSequenceStateByIDIf(sequenceID int64, expectedState, newState State) (bool, error)
+	SetOwnedSequenceStateIf(sequenceID int64, owner string, expectedState, newState State) (bool, error)
+	SetSequenceErrorStateByIDIf(sequenceID int64, expectedState State, errorMessage string, errorCode uint16) (bool, error)
+	SetOwnedSequenceErrorStateIf(sequenceID int64, owner string, expectedState State, errorMessage string, errorCode uint16) (bool, error)
+	SetSequenceTxID(sequenceID int64, positionInSequence int16, txID, submittedTxID string, broadcastHeight int32) error
+	SetSequenceTxState(sequenceID int64, positionInSequence int16, newState TransactionState) (bool, error)
+	SkipSequenceTx(sequenceID int64, positionInSequence int16) (bool, error)
+	SkipSequenceTo(sequenceID int64, positionInSequence int16) (bool, error)
 	SetSequenceTxConfirmedState(sequenceID int64, positionInSequence int16, height int32) error
 	SetSequenceTxsStateAfter(sequenceID int64, txID string, newState TransactionState) error
 	SetSequenceTxErrorMessage(sequenceID int64, positionInSequence int16, errorMessage string) error
+	SetSequenceTxValidationTrace(sequenceID int64, positionInSequence int16, trace json.RawMessage) error
+	SetSequenceTxValidationWarnings(sequenceID int64, positionInSequence int16, warnings []string) error
 	ResetSequenceTxErrorMessage(sequenceID int64, positionInSequence int16) error
+	SetSequenceProgress(sequenceID int64, lastConfirmedPosition int16) error
+	CreateDeadLetter(sequenceID int64) error
+	DeleteExpiredSequences(defaultRetention time.Duration) (int, error)
+	// Close releases db connections, it is idempotent
+	Close() error
 }
 
 type repoImpl struct {
 	Conn *pg.DB
+	// ReadConn is used by read-only queries of the API, it is Conn if there is no replica
+	ReadConn *pg.DB
+	// maxSequenceTxs is the max count of txs of the created sequence
+	maxSequenceTxs int
+
+	closeOnce sync.Once
+	closeErr  error
 }
 
 // New returns instance of Repository interface implementation
-func New(db *pg.DB) Repository {
-	return &repoImpl{Conn: db}
+// replica is optional, the primary db is used for all queries if it is nil
+// sequences with more than maxSequenceTxs txs are not created, 0 or a bigger value than MaxSequenceTxs means MaxSequenceTxs
+func New(db *pg.DB, replica *pg.DB, maxSequenceTxs int) Repository {
+	if replica == nil {
+		replica = db
+	}
+	if maxSequenceTxs <= 0 || maxSequenceTxs > MaxSequenceTxs {
+		maxSequenceTxs = MaxSequenceTxs
+	}
+	return &repoImpl{Conn: db, ReadConn: replica, maxSequenceTxs: maxSequenceTxs}
 }
 
+// GetSequenceByID reads sequence from the replica
+// the replica may lag behind the primary, so a just created sequence is looked up in the primary if it was not found
 func (r *repoImpl) GetSequenceByID(sequenceID int64) (*Sequence, error) {
+	seq, err := r.getSequenceByID(r.ReadConn, sequenceID)
+	if err != nil || seq != nil || r.ReadConn == r.Conn {
+		return seq, err
+	}
+
+	return r.getSequenceByID(r.Conn, sequenceID)
+}
+
+// GetSequenceByTxID returns the latest sequence containing the tx, nil if there is no such sequence
+func (r *repoImpl) GetSequenceByTxID(txID string) (*Sequence, error) {
+	if txID == "" {
+		return nil, ErrEmptyTxID
+	}
+
+	var sequenceIDs []int64
+	_, err := r.ReadConn.Query(&sequenceIDs, "select sequence_id from sequences_txs where tx_id=?0 order by sequence_id desc limit 1", txID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sequenceIDs) == 0 {
+		return nil, nil
+	}
+
+	return r.GetSequenceByID(sequenceIDs[0])
+}
+
+func (r *repoImpl) getSequenceByID(conn *pg.DB, sequenceID int64) (*Sequence, error) {
 	seq := Sequence{}
 
-	_, err := r.Conn.QueryOne(&seq, "select id, state, error_message, error_code, created_at, updated_at, coalesce((select count(*) from sequences_txs where sequence_id=?0 and state=?1), 0) as broadcasted_count, (select count(*) from sequences_txs where sequence_id=?0) as total_count from sequences where id=?0", sequenceID, TransactionStateConfirmed)
+	_, err := conn.QueryOne(&seq, "select id, state, error_message, error_code, node_url, label, mode, last_confirmed_position, created_at, updated_at, confirmed_count as broadcasted_count, txs_count as total_count from sequences where id=?0", sequenceID)
 	if err != nil {
 		if err.Error() == pg.ErrNoRows.Error() {
 			return nil, nil
@@ -202,7 +561,7 @@ func (r *repoImpl) GetSequenceByID(sequenceID int64) (*Sequence, error) {
 func (r *repoImpl) GetSequenceTxsByID(sequenceID int64) ([]*SequenceTx, error) {
 	var txs []*SequenceTx
 
-	_, err := r.Conn.Query(&txs, "select tx_id as id, sequence_id, state, height, error_message, position_in_sequence, tx, created_at, updated_at from sequences_txs where sequence_id=?0 order by position_in_sequence asc", sequenceID)
+	_, err := r.Conn.Query(&txs, "select tx_id as id, submitted_tx_id as submitted_id, sequence_id, state, height, broadcast_height, error_message, position_in_sequence, min_confirmations, tx, created_at, updated_at from sequences_txs where sequence_id=?0 order by position_in_sequence asc", sequenceID)
 	if err != nil {
 		return nil, err
 	}
@@ -210,23 +569,173 @@ func (r *repoImpl) GetSequenceTxsByID(sequenceID int64) ([]*SequenceTx, error) {
 	return txs, nil
 }
 
+// GetSequenceTx reads the tx from the replica, nil is returned if there is no such tx
+// the replica may lag behind the primary, so a tx of a just created sequence is looked up in the primary if it was not found
 func (r *repoImpl) GetSequenceTx(sequenceID int64, positionInSequence int16) (*SequenceTx, error) {
+	tx, err := r.getSequenceTx(r.ReadConn, sequenceID, positionInSequence)
+	if err != nil || tx != nil || r.ReadConn == r.Conn {
+		return tx, err
+	}
+
+	return r.getSequenceTx(r.Conn, sequenceID, positionInSequence)
+}
+
+func (r *repoImpl) getSequenceTx(conn *pg.DB, sequenceID int64, positionInSequence int16) (*SequenceTx, error) {
 	tx := SequenceTx{}
-	_, err := r.Conn.Query(&tx, "select tx_id as id, sequence_id, state, height, error_message, position_in_sequence, tx, created_at, updated_at from sequences_txs where sequence_id=?0 and position_in_sequence=?1", sequenceID, positionInSequence)
+	_, err := conn.QueryOne(&tx, "select tx_id as id, submitted_tx_id as submitted_id, sequence_id, state, height, broadcast_height, error_message, position_in_sequence, min_confirmations, validation_trace, validation_warnings, tx, created_at, updated_at from sequences_txs where sequence_id=?0 and position_in_sequence=?1", sequenceID, positionInSequence)
 	if err != nil {
+		if err.Error() == pg.ErrNoRows.Error() {
+			return nil, nil
+		}
 		return nil, err
 	}
 
 	return &tx, nil
 }
 
+// CountSequenceTxsNotInState returns count of the sequence txs which are in a state other than the given one
+// skipped txs are final in any state, so they are not counted
+func (r *repoImpl) CountSequenceTxsNotInState(sequenceID int64, state TransactionState) (int, error) {
+	var count int
+	_, err := r.Conn.QueryOne(pg.Scan(&count), "select count(*) from sequences_txs where sequence_id=?0 and state not in (?1, ?2)", sequenceID, state, TransactionStateSkipped)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// GetBroadcastedTxOfOtherSequence returns unconfirmed or confirmed tx with the given id of a processing sequence other than the given one
+// tx is nil if the tx is not broadcasted by other sequences, it reads the primary to see the latest broadcasts
+func (r *repoImpl) GetBroadcastedTxOfOtherSequence(txID string, sequenceID int64) (*SequenceTx, error) {
+	if txID == "" {
+		return nil, ErrEmptyTxID
+	}
+
+	var txs []*SequenceTx
+	_, err := r.Conn.Query(&txs, "select st.tx_id as id, st.submitted_tx_id as submitted_id, st.sequence_id, st.state, st.height, st.broadcast_height, st.position_in_sequence from sequences_txs st join sequences s on s.id=st.sequence_id where st.tx_id=?0 and st.sequence_id<>?1 and s.state=?2 and st.state in (?3, ?4) order by st.sequence_id asc limit 1", txID, sequenceID, StateProcessing, TransactionStateUnconfirmed, TransactionStateConfirmed)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(txs) == 0 {
+		return nil, nil
+	}
+
+	return txs[0], nil
+}
+
+// GetSequenceTxsWindow returns txs of the sequence starting from the last one of its leading txs buried by the window
+// so long mostly confirmed sequences are resumed without reading all txs, the only buried tx read is the last confirmed one before the rest
+func (r *repoImpl) GetSequenceTxsWindow(sequenceID int64, window TxsWindow) ([]*SequenceTx, error) {
+	if window.Depth <= 0 {
+		return r.GetSequenceTxsByID(sequenceID)
+	}
+
+	var txs []*SequenceTx
+	_, err := r.Conn.Query(&txs, `select tx_id as id, submitted_tx_id as submitted_id, sequence_id, state, height, broadcast_height, error_message, position_in_sequence, min_confirmations, tx, created_at, updated_at
+		from sequences_txs where sequence_id=?0 and position_in_sequence >= (
+			select greatest(coalesce(min(position_in_sequence) filter (where not (state=?1 and height > 0 and height <= ?2 - greatest(?3, coalesce(min_confirmations, ?4)))), max(position_in_sequence)) - 1, 0)
+			from sequences_txs where sequence_id=?0
+		) order by position_in_sequence asc`, sequenceID, TransactionStateConfirmed, window.CurrentHeight, window.Depth, window.MinConfirmations)
+	if err != nil {
+		return nil, err
+	}
+
+	return txs, nil
+}
+
+// GetConfirmedTxsByHeight returns confirmed txs of all sequences at the given height
+func (r *repoImpl) GetConfirmedTxsByHeight(height int32) ([]*SequenceTx, error) {
+	var txs []*SequenceTx
+
+	_, err := r.ReadConn.Query(&txs, "select tx_id as id, submitted_tx_id as submitted_id, sequence_id, state, height, error_message, position_in_sequence, tx, created_at, updated_at from sequences_txs where height=?0 and state=?1 order by sequence_id asc, position_in_sequence asc", height, TransactionStateConfirmed)
+	if err != nil {
+		return nil, err
+	}
+
+	return txs, nil
+}
+
+// GetThroughput returns statistics of sequences created within the window and txs confirmed within the window
+func (r *repoImpl) GetThroughput(window time.Duration) (*Throughput, error) {
+	throughput := Throughput{}
+
+	_, err := r.ReadConn.QueryOne(&throughput, "select count(*) as created_sequences, count(*) filter (where s.state=?1) as done_sequences, count(*) filter (where s.state=?2) as error_sequences, (select count(*) from sequences_txs where state=?3 and updated_at >= NOW() - interval '?0 seconds') as confirmed_txs from sequences s where s.created_at >= NOW() - interval '?0 seconds'", window.Seconds(), StateDone, StateError, TransactionStateConfirmed)
+	if err != nil {
+		return nil, err
+	}
+
+	return &throughput, nil
+}
+
+// GetSequencesByCreatedAt returns up to limit sequences created within [from, to) ordered by creation time
+// states filter the sequences if they are given
+func (r *repoImpl) GetSequencesByCreatedAt(from, to time.Time, states []State, limit int) ([]*Sequence, error) {
+	var seqs []*Sequence
+
+	statesFilter := ""
+	if len(states) > 0 {
+		statesFilter = " and s.state in (?3)"
+	}
+
+	_, err := r.ReadConn.Query(&seqs, "select s.id, s.state, s.error_message, s.error_code, s.node_url, s.label, s.mode, s.last_confirmed_position, s.created_at, s.updated_at, s.confirmed_count as broadcasted_count, s.txs_count as total_count from sequences s where s.created_at >= ?0 and s.created_at < ?1"+statesFilter+" order by s.created_at asc, s.id asc limit ?2", from, to, limit, pg.In(states))
+	if err != nil {
+		return nil, err
+	}
+
+	return seqs, nil
+}
+
+// CountSequencesInStates returns count of sequences in any of the given states
+// it reads the primary, so sequences created by all instances are counted
+func (r *repoImpl) CountSequencesInStates(states []State) (int, error) {
+	var count int
+	_, err := r.Conn.QueryOne(pg.Scan(&count), "select count(*) from sequences where state in (?0)", pg.In(states))
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (r *repoImpl) GetSequenceOptions(sequenceID int64) (*SequenceOptions, error) {
+	options := SequenceOptions{}
+
+	_, err := r.Conn.QueryOne(&options, "select node_url, skip_validation, deadline, tx_outdate_time, trace_parent, label, broadcast_interval, mode, per_tx_callback_url, retention_seconds from sequences where id=?0", sequenceID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &options, nil
+}
+
 // GetNewSequenceIds tries to new sequences ids
-func (r *repoImpl) GetNewSequenceIds() ([]int64, error) {
+// sequences are interleaved by labels (unlabeled sequences are a label too), so a label with many pending sequences does not starve the others:
+// each round takes up to label weight oldest sequences of every label, up to Limit sequences are returned
+// if MaxProcessingPerLabel is positive, labeled sequences are returned only while count of processing sequences with the same label is under it
+// the rest of them stay pending until some of the label sequences are finished
+func (r *repoImpl) GetNewSequenceIds(options ClaimOptions) ([]int64, error) {
 	var ids []int64
 
-	var err error
-	_, err = r.Conn.Query(&ids, "select s.id from sequences s where s.state=?0 order by s.id asc", StatePending)
+	labels := make([]string, 0, len(options.LabelWeights))
+	weights := make([]int, 0, len(options.LabelWeights))
+	for label, weight := range options.LabelWeights {
+		labels = append(labels, label)
+		weights = append(weights, weight)
+	}
 
+	// processing counts and weights are computed once per query, not per pending sequence
+	_, err := r.Conn.Query(&ids, `with
+		pending as (select s.id, s.label, row_number() over (partition by coalesce(s.label, '') order by s.id asc) as label_position from sequences s where s.state=?0),
+		processing as (select p.label, count(*) as count from sequences p where p.state=?1 and p.label is not null group by p.label),
+		weights as (select w.label, w.weight from unnest(?3::varchar[], ?4::integer[]) as w(label, weight))
+		select n.id from pending n
+		left join processing p on p.label=n.label
+		left join weights w on w.label=n.label
+		where ?2 <= 0 or n.label is null or n.label_position + coalesce(p.count, 0) <= ?2
+		order by (n.label_position - 1) / coalesce(w.weight, 1) asc, n.id asc
+		limit nullif(?5, 0)`, StatePending, StateProcessing, options.MaxProcessingPerLabel, pg.Array(labels), pg.Array(weights), options.Limit)
 	if err != nil {
 		return nil, err
 	}
@@ -253,33 +762,177 @@ func (r *repoImpl) GetHangingSequenceIds(ttl time.Duration, excluding []int64) (
 	return ids, nil
 }
 
-func (r *repoImpl) CreateSequence(txs []string) (int64, error) {
+// SetSequencesLease leases the processing sequences owned by owner for ttl, it is called again to renew the lease
+// leases of the sequences taken over by other owners are not renewed
+func (r *repoImpl) SetSequencesLease(sequenceIDs []int64, owner string, ttl time.Duration) error {
+	if len(sequenceIDs) == 0 {
+		return nil
+	}
+
+	_, err := r.Conn.Exec("update sequences set lease_expires_at=NOW() + interval '?1 milliseconds' where id in (?2) and state=?3 and owner=?0", owner, ttl.Milliseconds(), pg.In(sequenceIDs), StateProcessing)
+	return err
+}
+
+// GetSequencesOwnedByExpiredLease returns ids of processing sequences whose other than owner instance did not renew the lease
+func (r *repoImpl) GetSequencesOwnedByExpiredLease(owner string) ([]int64, error) {
+	var ids []int64
+
+	_, err := r.Conn.Query(&ids, "select id from sequences where state=?0 and lease_expires_at < NOW() and owner <> ?1 order by id asc", StateProcessing, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// ReclaimSequence takes over the processing sequence with the expired lease, returns false if it was reclaimed or renewed concurrently
+func (r *repoImpl) ReclaimSequence(sequenceID int64, owner string, ttl time.Duration) (bool, error) {
+	res, err := r.Conn.Exec("update sequences set owner=?1, lease_expires_at=NOW() + interval '?2 milliseconds', updated_at=NOW() where id=?0 and state=?3 and lease_expires_at < NOW()", sequenceID, owner, ttl.Milliseconds(), StateProcessing)
+	if err != nil {
+		return false, err
+	}
+
+	return res.RowsAffected() > 0, nil
+}
+
+// ClaimSequence moves the pending sequence to the processing state owned by owner, returns false if it was claimed concurrently
+func (r *repoImpl) ClaimSequence(sequenceID int64, owner string) (bool, error) {
+	res, err := r.Conn.Exec("update sequences set state=?1, owner=?2, updated_at=NOW() where id=?0 and state=?3", sequenceID, StateProcessing, owner, StatePending)
+	if err != nil {
+		return false, err
+	}
+
+	return res.RowsAffected() > 0, nil
+}
+
+// TakeOverHangingSequence makes owner the owner of the processing sequence not updated for ttl
+// returns false if it was taken over or updated concurrently
+func (r *repoImpl) TakeOverHangingSequence(sequenceID int64, owner string, ttl time.Duration) (bool, error) {
+	res, err := r.Conn.Exec("update sequences set owner=?1, updated_at=NOW() where id=?0 and state=?2 and updated_at < NOW() - interval '?3 seconds'", sequenceID, owner, StateProcessing, ttl.Seconds())
+	if err != nil {
+		return false, err
+	}
+
+	return res.RowsAffected() > 0, nil
+}
+
+// RefreshSequence refreshes the processing sequence owned by owner
+// returns false if its state was changed or it was taken over by another owner concurrently
+func (r *repoImpl) RefreshSequence(sequenceID int64, owner string) (bool, error) {
+	res, err := r.Conn.Exec("update sequences set updated_at=NOW() where id=?0 and state=?1 and owner=?2", sequenceID, StateProcessing, owner)
+	if err != nil {
+		return false, err
+	}
+
+	return res.RowsAffected() > 0, nil
+}
+
+func (r *repoImpl) CreateSequence(txs []string, options SequenceOptions) (int64, error) {
+	return r.CreateSequenceFromSource(&sliceTxsSource{txs: txs}, defaultInsertBatchSize, func() (SequenceOptions, error) {
+		return options, nil
+	})
+}
+
+// CreateSequenceFromSource creates sequence reading txs from source and inserting them in batches of batchSize
+// options are requested after all txs are read, so they may depend on the source contents
+// nothing is created if source or options return an error, the error is returned as is
+// MaxSequenceTxsError is returned if source has more txs than the max the repository is created with
+// DBUnavailableError is returned if the db is temporarily unavailable for writes
+func (r *repoImpl) CreateSequenceFromSource(source TxsSource, batchSize int, options func() (SequenceOptions, error)) (int64, error) {
+	if batchSize <= 0 {
+		batchSize = defaultInsertBatchSize
+	}
+
 	sequenceID := int64(0)
 
 	err := r.Conn.RunInTransaction(func(tr *pg.Tx) error {
 		_, err := tr.QueryOne(&sequenceID, "insert into sequences(state) values(?0) returning id;", StatePending)
 		if err != nil {
 			return err
-
 		}
 
-		for i, tx := range txs {
-			_, err := tr.Exec("insert into sequences_txs(sequence_id, state, position_in_sequence, tx) values(?0, ?1, ?2, ?3);", sequenceID, TransactionStatePending, i, tx)
+		position := 0
+		batch := make([]string, 0, batchSize)
+		for {
+			tx, err := source.Next()
+			if err == io.EOF {
+				break
+			}
 			if err != nil {
 				return err
 			}
+
+			if position+len(batch) >= r.maxSequenceTxs {
+				return &MaxSequenceTxsError{MaxTxs: r.maxSequenceTxs}
+			}
+
+			batch = append(batch, tx)
+			if len(batch) == batchSize {
+				if err := insertSequenceTxs(tr, sequenceID, position, batch); err != nil {
+					return err
+				}
+				position += len(batch)
+				batch = batch[:0]
+			}
 		}
 
-		return nil
+		if len(batch) > 0 {
+			if err := insertSequenceTxs(tr, sequenceID, position, batch); err != nil {
+				return err
+			}
+			position += len(batch)
+		}
+
+		// confirmed_count is maintained by the trigger on the tx states
+		if _, err := tr.Exec("update sequences set txs_count=?1 where id=?0", sequenceID, position); err != nil {
+			return err
+		}
+
+		sequenceOptions, err := options()
+		if err != nil {
+			return err
+		}
+
+		return setSequenceOptions(tr, sequenceID, sequenceOptions)
 	})
 
 	if err != nil {
-		return 0, err
+		return 0, wrapUnavailable(err)
 	}
 
 	return sequenceID, nil
 }
 
+// insertSequenceTxs inserts txs by a single query, positions start from firstPosition
+func insertSequenceTxs(tr *pg.Tx, sequenceID int64, firstPosition int, txs []string) error {
+	b := strings.Builder{}
+	b.WriteString("insert into sequences_txs(sequence_id, state, position_in_sequence, tx) values ")
+
+	params := []interface{}{sequenceID, TransactionStatePending}
+	for i, tx := range txs {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "(?0, ?1, ?%d, ?%d)", len(params), len(params)+1)
+		params = append(params, firstPosition+i, tx)
+	}
+
+	_, err := tr.Exec(b.String(), params...)
+	return err
+}
+
+func setSequenceOptions(tr *pg.Tx, sequenceID int64, options SequenceOptions) error {
+	_, err := tr.Exec("update sequences set node_url=nullif(?1, ''), skip_validation=?2, deadline=?3, tx_outdate_time=nullif(?4, 0), trace_parent=nullif(?5, ''), label=nullif(?6, ''), broadcast_interval=nullif(?7, 0), mode=nullif(?8, ''), per_tx_callback_url=nullif(?9, ''), retention_seconds=nullif(?10, 0) where id=?0", sequenceID, options.NodeURL, options.SkipValidation, pg.NullTime{Time: options.Deadline}, options.TxOutdateTime, options.TraceParent, options.Label, options.BroadcastInterval, string(options.Mode), options.PerTxCallbackURL, options.RetentionSeconds)
+	if err != nil {
+		return err
+	}
+
+	if len(options.Confirmations) > 0 {
+		_, err = tr.Exec("update sequences_txs set min_confirmations=(?1::integer[])[position_in_sequence+1] where sequence_id=?0 and position_in_sequence < ?2", sequenceID, pg.Array(options.Confirmations), len(options.Confirmations))
+	}
+	return err
+}
+
 func (r *repoImpl) SetSequenceStateByID(sequenceID int64, newState State) error {
 	_, err := r.Conn.Exec("update sequences set state=?1, updated_at=NOW() where id=?0", sequenceID, newState)
 	return err
@@ -290,23 +943,154 @@ func (r *repoImpl) SetSequenceErrorStateByID(sequenceID int64, errorMessage stri
 	return err
 }
 
-func (r *repoImpl) SetSequenceTxID(sequenceID int64, positionInSequence int16, txID string) error {
-	_, err := r.Conn.Exec("update sequences_txs set tx_id=?0, updated_at=NOW() where sequence_id=?1 and position_in_sequence=?2", txID, sequenceID, positionInSequence)
+// SetSequenceStateByIDIf sets newState only if the sequence is in expectedState
+// returns false if the sequence state was changed concurrently
+func (r *repoImpl) SetSequenceStateByIDIf(sequenceID int64, expectedState, newState State) (bool, error) {
+	res, err := r.Conn.Exec("update sequences set state=?1, updated_at=NOW() where id=?0 and state=?2", sequenceID, newState, expectedState)
+	if err != nil {
+		return false, err
+	}
+	return res.RowsAffected() > 0, nil
+}
+
+// SetSequenceErrorStateByIDIf sets the error state only if the sequence is in expectedState
+// returns false if the sequence state was changed concurrently
+func (r *repoImpl) SetSequenceErrorStateByIDIf(sequenceID int64, expectedState State, errorMessage string, errorCode uint16) (bool, error) {
+	res, err := r.Conn.Exec("update sequences set state=?0, error_message=?1, error_code=?2, updated_at=NOW() where id=?3 and state=?4", StateError, errorMessage, errorCode, sequenceID, expectedState)
+	if err != nil {
+		return false, err
+	}
+	return res.RowsAffected() > 0, nil
+}
+
+// SetOwnedSequenceStateIf sets the new state only if the sequence is in expectedState and it is owned by owner
+// returns false if the sequence state was changed or it was taken over by another owner concurrently
+func (r *repoImpl) SetOwnedSequenceStateIf(sequenceID int64, owner string, expectedState, newState State) (bool, error) {
+	res, err := r.Conn.Exec("update sequences set state=?1, updated_at=NOW() where id=?0 and state=?2 and owner=?3", sequenceID, newState, expectedState, owner)
+	if err != nil {
+		return false, err
+	}
+	return res.RowsAffected() > 0, nil
+}
+
+// SetOwnedSequenceErrorStateIf sets the error state only if the sequence is in expectedState and it is owned by owner
+// returns false if the sequence state was changed or it was taken over by another owner concurrently
+func (r *repoImpl) SetOwnedSequenceErrorStateIf(sequenceID int64, owner string, expectedState State, errorMessage string, errorCode uint16) (bool, error) {
+	res, err := r.Conn.Exec("update sequences set state=?0, error_message=?1, error_code=?2, updated_at=NOW() where id=?3 and state=?4 and owner=?5", StateError, errorMessage, errorCode, sequenceID, expectedState, owner)
+	if err != nil {
+		return false, err
+	}
+	return res.RowsAffected() > 0, nil
+}
+
+// SetSequenceProgress sets position of the last tx all txs up to which are confirmed or skipped, negative position means there is no such tx
+func (r *repoImpl) SetSequenceProgress(sequenceID int64, lastConfirmedPosition int16) error {
+	_, err := r.Conn.Exec("update sequences set last_confirmed_position=nullif(?0, -1) where id=?1", lastConfirmedPosition, sequenceID)
 	return err
 }
 
-func (r *repoImpl) SetSequenceTxState(sequenceID int64, positionInSequence int16, newState TransactionState) error {
-	_, err := r.Conn.Exec("update sequences_txs set state=?0, updated_at=NOW() where sequence_id=?1 and position_in_sequence=?2", newState, sequenceID, positionInSequence)
+// CreateDeadLetter copies the failed sequence with all its txs to dead_letters, so it can be inspected and re-submitted
+// txs are stored as the transactions array of the create sequence request
+func (r *repoImpl) CreateDeadLetter(sequenceID int64) error {
+	_, err := r.Conn.Exec(`insert into dead_letters(sequence_id, error_message, error_code, label, txs)
+		select s.id, s.error_message, s.error_code, s.label,
+			(select coalesce(json_agg(st.tx::json order by st.position_in_sequence), '[]'::json) from sequences_txs st where st.sequence_id=s.id)
+		from sequences s where s.id=?0`, sequenceID)
 	return err
 }
 
+// DeleteExpiredSequences deletes done and failed sequences not updated for their retention, txs are deleted by cascade
+// sequences without their own retention are kept for defaultRetention, zero defaultRetention means they are kept forever
+func (r *repoImpl) DeleteExpiredSequences(defaultRetention time.Duration) (int, error) {
+	res, err := r.Conn.Exec(`delete from sequences where state in (?0)
+		and coalesce(retention_seconds, ?1) > 0
+		and updated_at < NOW() - make_interval(secs => coalesce(retention_seconds, ?1))`, pg.In([]State{StateDone, StateError}), int64(defaultRetention.Seconds()))
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected(), nil
+}
+
+// SetSequenceTxID sets ids of the broadcasted tx and the node height at its broadcast, 0 broadcast height means unknown
+func (r *repoImpl) SetSequenceTxID(sequenceID int64, positionInSequence int16, txID, submittedTxID string, broadcastHeight int32) error {
+	_, err := r.Conn.Exec("update sequences_txs set tx_id=?0, submitted_tx_id=nullif(?3, ''), broadcast_height=nullif(?4, 0), updated_at=NOW() where sequence_id=?1 and position_in_sequence=?2", txID, sequenceID, positionInSequence, submittedTxID, broadcastHeight)
+	return err
+}
+
+// SetSequenceTxState sets the state of the not skipped tx, returns false if the tx was skipped, e.g. by the client while it was processed
+func (r *repoImpl) SetSequenceTxState(sequenceID int64, positionInSequence int16, newState TransactionState) (bool, error) {
+	res, err := r.Conn.Exec("update sequences_txs set state=?0, updated_at=NOW() where sequence_id=?1 and position_in_sequence=?2 and state<>?3", newState, sequenceID, positionInSequence, TransactionStateSkipped)
+	if err != nil {
+		return false, err
+	}
+
+	return res.RowsAffected() > 0, nil
+}
+
+// SkipSequenceTx moves the pending tx to the skipped state, returns false if the tx does not exist or it is not pending
+func (r *repoImpl) SkipSequenceTx(sequenceID int64, positionInSequence int16) (bool, error) {
+	res, err := r.Conn.Exec("update sequences_txs set state=?0, updated_at=NOW() where sequence_id=?1 and position_in_sequence=?2 and state=?3 and tx_id is null", TransactionStateSkipped, sequenceID, positionInSequence, TransactionStatePending)
+	if err != nil {
+		return false, err
+	}
+
+	return res.RowsAffected() > 0, nil
+}
+
+// SkipSequenceTo makes the processing or failed sequence continue from the tx at positionInSequence,
+// not broadcasted txs before it are skipped and not confirmed broadcasted ones are unconfirmed, so their heights are read from the node,
+// failed txs after it are pending again; the sequence is pending without the owner, so it is claimed by the dispatcher again
+// and the worker still processing it stops at its next check of the sequence state
+// returns false if the sequence does not exist or it is not processing or failed
+func (r *repoImpl) SkipSequenceTo(sequenceID int64, positionInSequence int16) (bool, error) {
+	skipped := false
+
+	err := r.Conn.RunInTransaction(func(tr *pg.Tx) error {
+		var state State
+		if _, err := tr.QueryOne(pg.Scan(&state), "select state from sequences where id=?0 for update", sequenceID); err != nil {
+			if err.Error() == pg.ErrNoRows.Error() {
+				return nil
+			}
+			return err
+		}
+		if state != StateProcessing && state != StateError {
+			return nil
+		}
+
+		if _, err := tr.Exec("update sequences_txs set state=?0, error_message=null, updated_at=NOW() where sequence_id=?1 and position_in_sequence<?2 and tx_id is not null and state not in (?0, ?3)", TransactionStateUnconfirmed, sequenceID, positionInSequence, TransactionStateConfirmed); err != nil {
+			return err
+		}
+		if _, err := tr.Exec("update sequences_txs set state=?0, error_message=null, updated_at=NOW() where sequence_id=?1 and position_in_sequence<?2 and tx_id is null", TransactionStateSkipped, sequenceID, positionInSequence); err != nil {
+			return err
+		}
+		if _, err := tr.Exec("update sequences_txs set state=?0, error_message=null, updated_at=NOW() where sequence_id=?1 and position_in_sequence>=?2 and state=?3", TransactionStatePending, sequenceID, positionInSequence, TransactionStateError); err != nil {
+			return err
+		}
+		if _, err := tr.Exec("update sequences set state=?0, owner=null, lease_expires_at=null, error_message=null, error_code=null, last_confirmed_position=nullif(coalesce((select min(position_in_sequence) from sequences_txs where sequence_id=?1 and state not in (?2, ?3)), txs_count) - 1, -1), updated_at=NOW() where id=?1", StatePending, sequenceID, TransactionStateConfirmed, TransactionStateSkipped); err != nil {
+			return err
+		}
+
+		skipped = true
+		return nil
+	})
+
+	return skipped, err
+}
+
 func (r *repoImpl) SetSequenceTxConfirmedState(sequenceID int64, positionInSequence int16, height int32) error {
 	_, err := r.Conn.Exec("update sequences_txs set state=?0, height=?1, updated_at=NOW() where sequence_id=?2 and position_in_sequence=?3", TransactionStateConfirmed, height, sequenceID, positionInSequence)
 	return err
 }
 
+// SetSequenceTxsStateAfter sets newState to the tx with txID and all txs after it, skipped txs are kept skipped
+// txID must not be empty: txs that were never broadcasted have no id and cannot be used as a starting point
 func (r *repoImpl) SetSequenceTxsStateAfter(sequenceID int64, txID string, newState TransactionState) error {
-	_, err := r.Conn.Exec("update sequences_txs set state=?0, updated_at=NOW() where sequence_id=?1 and position_in_sequence>=(select position_in_sequence from sequences_txs where sequence_id=?1 and tx_id=?2)", newState, sequenceID, txID)
+	if txID == "" {
+		return ErrEmptyTxID
+	}
+
+	_, err := r.Conn.Exec("update sequences_txs set state=?0, updated_at=NOW() where sequence_id=?1 and position_in_sequence>=(select position_in_sequence from sequences_txs where sequence_id=?1 and tx_id=?2) and state<>?3", newState, sequenceID, txID, TransactionStateSkipped)
 	return err
 }
 
@@ -315,7 +1099,32 @@ func (r *repoImpl) SetSequenceTxErrorMessage(sequenceID int64, positionInSequenc
 	return err
 }
 
+// SetSequenceTxValidationTrace stores the node validation trace of the tx, it is reset with the error message
+func (r *repoImpl) SetSequenceTxValidationTrace(sequenceID int64, positionInSequence int16, trace json.RawMessage) error {
+	_, err := r.Conn.Exec("update sequences_txs set validation_trace=?0::jsonb, updated_at=NOW() where sequence_id=?1 and position_in_sequence=?2", string(trace), sequenceID, positionInSequence)
+	return err
+}
+
+// SetSequenceTxValidationWarnings stores warnings the node reported validating the tx
+func (r *repoImpl) SetSequenceTxValidationWarnings(sequenceID int64, positionInSequence int16, warnings []string) error {
+	_, err := r.Conn.Exec("update sequences_txs set validation_warnings=?0, updated_at=NOW() where sequence_id=?1 and position_in_sequence=?2", pg.Array(warnings), sequenceID, positionInSequence)
+	return err
+}
+
 func (r *repoImpl) ResetSequenceTxErrorMessage(sequenceID int64, positionInSequence int16) error {
-	_, err := r.Conn.Exec("update sequences_txs set error_message=null, updated_at=NOW() where sequence_id=?0 and position_in_sequence=?1", sequenceID, positionInSequence)
+	_, err := r.Conn.Exec("update sequences_txs set error_message=null, validation_trace=null, updated_at=NOW() where sequence_id=?0 and position_in_sequence=?1", sequenceID, positionInSequence)
 	return err
 }
+
+// Close closes the primary and the replica connections
+func (r *repoImpl) Close() error {
+	r.closeOnce.Do(func() {
+		r.closeErr = r.Conn.Close()
+		if r.ReadConn != r.Conn {
+			if err := r.ReadConn.Close(); err != nil && r.closeErr == nil {
+				r.closeErr = err
+			}
+		}
+	})
+	return r.closeErr
+}
@@ -0,0 +1,503 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/wavesplatform/transaction-broadcaster/internal/log"
+	"github.com/wavesplatform/transaction-broadcaster/internal/migrate"
+)
+
+// newTestRepo returns the repository of the migrated empty db given by PG* env vars, the test is skipped if PGHOST is not set
+func newTestRepo(t testing.TB) *repoImpl {
+	t.Helper()
+
+	if os.Getenv("PGHOST") == "" {
+		t.Skip("PGHOST is not set")
+	}
+
+	log.Logger = zap.NewNop()
+
+	port := os.Getenv("PGPORT")
+	if port == "" {
+		port = "5432"
+	}
+
+	db := pg.Connect(&pg.Options{
+		Addr:     fmt.Sprintf("%s:%s", os.Getenv("PGHOST"), port),
+		Database: os.Getenv("PGDATABASE"),
+		User:     os.Getenv("PGUSER"),
+		Password: os.Getenv("PGPASSWORD"),
+	})
+	t.Cleanup(func() { db.Close() })
+
+	require.NoError(t, migrate.Up(db))
+
+	_, err := db.Exec("truncate sequences, sequences_txs, dead_letters restart identity")
+	require.NoError(t, err)
+
+	return New(db, nil, 0).(*repoImpl)
+}
+
+func TestSequenceOwnership(t *testing.T) {
+	repo := newTestRepo(t)
+
+	seqID, err := repo.CreateSequence([]string{`{"id":"tx1"}`}, SequenceOptions{})
+	require.NoError(t, err)
+
+	claimed, err := repo.ClaimSequence(seqID, "a")
+	require.NoError(t, err)
+	require.True(t, claimed)
+
+	claimed, err = repo.ClaimSequence(seqID, "b")
+	require.NoError(t, err)
+	require.False(t, claimed, "processing sequence is claimed again")
+
+	refreshed, err := repo.RefreshSequence(seqID, "b")
+	require.NoError(t, err)
+	require.False(t, refreshed, "sequence is refreshed by not its owner")
+
+	takenOver, err := repo.TakeOverHangingSequence(seqID, "b", time.Hour)
+	require.NoError(t, err)
+	require.False(t, takenOver, "recently updated sequence is taken over")
+
+	time.Sleep(10 * time.Millisecond)
+
+	takenOver, err = repo.TakeOverHangingSequence(seqID, "b", 0)
+	require.NoError(t, err)
+	require.True(t, takenOver)
+
+	refreshed, err = repo.RefreshSequence(seqID, "a")
+	require.NoError(t, err)
+	require.False(t, refreshed, "sequence is refreshed by the previous owner")
+
+	refreshed, err = repo.RefreshSequence(seqID, "b")
+	require.NoError(t, err)
+	require.True(t, refreshed)
+}
+
+func TestSequenceLeases(t *testing.T) {
+	repo := newTestRepo(t)
+
+	seqID, err := repo.CreateSequence([]string{`{"id":"tx1"}`}, SequenceOptions{})
+	require.NoError(t, err)
+
+	claimed, err := repo.ClaimSequence(seqID, "a")
+	require.NoError(t, err)
+	require.True(t, claimed)
+	require.NoError(t, repo.SetSequencesLease([]int64{seqID}, "a", 0))
+
+	time.Sleep(10 * time.Millisecond)
+
+	// the expired lease of the owner is reclaimed by the restarted instance with another process id
+	ids, err := repo.GetSequencesOwnedByExpiredLease("a-2")
+	require.NoError(t, err)
+	require.Equal(t, []int64{seqID}, ids)
+
+	reclaimed, err := repo.ReclaimSequence(seqID, "a-2", time.Hour)
+	require.NoError(t, err)
+	require.True(t, reclaimed)
+
+	// the previous owner does not renew the lease it lost and cannot move the sequence
+	require.NoError(t, repo.SetSequencesLease([]int64{seqID}, "a", 0))
+	ids, err = repo.GetSequencesOwnedByExpiredLease("b")
+	require.NoError(t, err)
+	require.Empty(t, ids)
+
+	refreshed, err := repo.RefreshSequence(seqID, "a")
+	require.NoError(t, err)
+	require.False(t, refreshed)
+
+	updated, err := repo.SetOwnedSequenceStateIf(seqID, "a", StateProcessing, StateDone)
+	require.NoError(t, err)
+	require.False(t, updated)
+
+	updated, err = repo.SetOwnedSequenceErrorStateIf(seqID, "a", StateProcessing, "failed", 1)
+	require.NoError(t, err)
+	require.False(t, updated)
+
+	updated, err = repo.SetOwnedSequenceStateIf(seqID, "a-2", StateProcessing, StateDone)
+	require.NoError(t, err)
+	require.True(t, updated)
+}
+
+// createConfirmedSequence creates sequence of count txs, all of them but the last pending one are confirmed at heights 1, 2, ...
+func createConfirmedSequence(t testing.TB, repo *repoImpl, count int) int64 {
+	t.Helper()
+
+	txs := make([]string, count)
+	for i := range txs {
+		txs[i] = fmt.Sprintf(`{"id":"tx%d"}`, i)
+	}
+
+	seqID, err := repo.CreateSequence(txs, SequenceOptions{})
+	require.NoError(t, err)
+
+	_, err = repo.Conn.Exec("update sequences_txs set tx_id='tx' || position_in_sequence, state=?0, height=position_in_sequence + 1 where sequence_id=?1 and position_in_sequence < ?2", TransactionStateConfirmed, seqID, count-1)
+	require.NoError(t, err)
+
+	return seqID
+}
+
+func TestGetSequenceTxsWindow(t *testing.T) {
+	repo := newTestRepo(t)
+
+	seqID := createConfirmedSequence(t, repo, 10)
+
+	// txs at heights up to 5 are buried, the last one of them is read
+	txs, err := repo.GetSequenceTxsWindow(seqID, TxsWindow{CurrentHeight: 10, Depth: 5, MinConfirmations: 1})
+	require.NoError(t, err)
+	require.Len(t, txs, 6)
+	require.Equal(t, int16(4), txs[0].PositionInSequence)
+	require.Equal(t, TransactionStatePending, txs[5].State)
+
+	// min confirmations deeper than the window keep txs within it
+	txs, err = repo.GetSequenceTxsWindow(seqID, TxsWindow{CurrentHeight: 10, Depth: 5, MinConfirmations: 7})
+	require.NoError(t, err)
+	require.Len(t, txs, 8)
+
+	// all txs are read without the window
+	txs, err = repo.GetSequenceTxsWindow(seqID, TxsWindow{})
+	require.NoError(t, err)
+	require.Len(t, txs, 10)
+}
+
+func BenchmarkGetSequenceTxs(b *testing.B) {
+	repo := newTestRepo(b)
+
+	seqID := createConfirmedSequence(b, repo, 10000)
+
+	b.Run("all", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := repo.GetSequenceTxsByID(seqID); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("window", func(b *testing.B) {
+		window := TxsWindow{CurrentHeight: 10000, Depth: 100}
+		for i := 0; i < b.N; i++ {
+			if _, err := repo.GetSequenceTxsWindow(seqID, window); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestSequenceCountsFollowTxStates(t *testing.T) {
+	repo := newTestRepo(t)
+
+	seqID, err := repo.CreateSequence([]string{`{"id":"tx0"}`, `{"id":"tx1"}`, `{"id":"tx2"}`}, SequenceOptions{})
+	require.NoError(t, err)
+
+	requireCounts := func(broadcasted, total uint32) {
+		t.Helper()
+		seq, err := repo.GetSequenceByID(seqID)
+		require.NoError(t, err)
+		require.Equal(t, broadcasted, seq.BroadcastedCount)
+		require.Equal(t, total, seq.TotalCount)
+	}
+
+	requireCounts(0, 3)
+
+	for position := int16(0); position < 2; position++ {
+		require.NoError(t, repo.SetSequenceTxID(seqID, position, fmt.Sprintf("tx%d", position), "", 0))
+		require.NoError(t, repo.SetSequenceTxConfirmedState(seqID, position, 10))
+	}
+	// confirming the confirmed tx again changes nothing
+	require.NoError(t, repo.SetSequenceTxConfirmedState(seqID, 1, 11))
+	requireCounts(2, 3)
+
+	// the pulled out tx and txs after it are not confirmed anymore
+	require.NoError(t, repo.SetSequenceTxsStateAfter(seqID, "tx1", TransactionStatePending))
+	requireCounts(1, 3)
+}
+
+func TestSkippedTxStateIsKept(t *testing.T) {
+	repo := newTestRepo(t)
+
+	seqID, err := repo.CreateSequence([]string{`{"id":"tx0"}`, `{"id":"tx1"}`}, SequenceOptions{Mode: SequenceModeIndependent})
+	require.NoError(t, err)
+
+	skipped, err := repo.SkipSequenceTx(seqID, 0)
+	require.NoError(t, err)
+	require.True(t, skipped)
+
+	// the worker which read the tx before it was skipped does not take it
+	updated, err := repo.SetSequenceTxState(seqID, 0, TransactionStateProcessing)
+	require.NoError(t, err)
+	require.False(t, updated)
+
+	updated, err = repo.SetSequenceTxState(seqID, 1, TransactionStateProcessing)
+	require.NoError(t, err)
+	require.True(t, updated)
+
+	// the processing tx cannot be skipped
+	skipped, err = repo.SkipSequenceTx(seqID, 1)
+	require.NoError(t, err)
+	require.False(t, skipped)
+}
+
+func TestSkipSequenceTo(t *testing.T) {
+	repo := newTestRepo(t)
+
+	seqID, err := repo.CreateSequence([]string{`{"id":"tx0"}`, `{"id":"tx1"}`, `{"id":"tx2"}`, `{"id":"tx3"}`, `{"id":"tx4"}`}, SequenceOptions{})
+	require.NoError(t, err)
+
+	claimed, err := repo.ClaimSequence(seqID, "a")
+	require.NoError(t, err)
+	require.True(t, claimed)
+
+	// tx0 is confirmed, tx1 is broadcasted and failed, tx2 is not broadcasted, tx3 failed validation
+	require.NoError(t, repo.SetSequenceTxID(seqID, 0, "tx0", "tx0", 0))
+	require.NoError(t, repo.SetSequenceTxConfirmedState(seqID, 0, 10))
+	require.NoError(t, repo.SetSequenceTxID(seqID, 1, "tx1", "tx1", 0))
+	_, err = repo.SetSequenceTxState(seqID, 1, TransactionStateError)
+	require.NoError(t, err)
+	_, err = repo.SetSequenceTxState(seqID, 3, TransactionStateError)
+	require.NoError(t, err)
+	_, err = repo.SetSequenceErrorStateByIDIf(seqID, StateProcessing, "failed", 0)
+	require.NoError(t, err)
+
+	skipped, err := repo.SkipSequenceTo(seqID, 3)
+	require.NoError(t, err)
+	require.True(t, skipped)
+
+	txs, err := repo.GetSequenceTxsByID(seqID)
+	require.NoError(t, err)
+	states := make([]TransactionState, len(txs))
+	for i, tx := range txs {
+		states[i] = tx.State
+	}
+	require.Equal(t, []TransactionState{TransactionStateConfirmed, TransactionStateUnconfirmed, TransactionStateSkipped, TransactionStatePending, TransactionStatePending}, states)
+	require.Equal(t, int32(10), txs[0].Height)
+
+	seq, err := repo.GetSequenceByID(seqID)
+	require.NoError(t, err)
+	require.Equal(t, StatePending, seq.State)
+	require.Empty(t, seq.ErrorMessage)
+	require.NotNil(t, seq.LastConfirmedPosition)
+	require.Equal(t, int16(0), *seq.LastConfirmedPosition)
+
+	// the previous owner does not process the sequence anymore
+	refreshed, err := repo.RefreshSequence(seqID, "a")
+	require.NoError(t, err)
+	require.False(t, refreshed)
+
+	// the pending sequence is not skipped
+	skipped, err = repo.SkipSequenceTo(seqID, 4)
+	require.NoError(t, err)
+	require.False(t, skipped)
+}
+
+func TestMaxSequenceTxsIsBounded(t *testing.T) {
+	// the max is checked by the repository, so no db is needed
+	for maxTxs, expected := range map[int]int{0: MaxSequenceTxs, -1: MaxSequenceTxs, 2: 2, MaxSequenceTxs + 1: MaxSequenceTxs} {
+		require.Equal(t, expected, New(nil, nil, maxTxs).(*repoImpl).maxSequenceTxs, "max %d", maxTxs)
+	}
+}
+
+func TestCreateSequenceMaxTxs(t *testing.T) {
+	repo := New(newTestRepo(t).Conn, nil, 2)
+
+	_, err := repo.CreateSequence([]string{`{"id":"tx0"}`, `{"id":"tx1"}`}, SequenceOptions{})
+	require.NoError(t, err)
+
+	_, err = repo.CreateSequence([]string{`{"id":"tx0"}`, `{"id":"tx1"}`, `{"id":"tx2"}`}, SequenceOptions{})
+	require.Equal(t, &MaxSequenceTxsError{MaxTxs: 2}, err)
+
+	// the sequence is not created partially
+	count, err := repo.CountSequencesInStates([]State{StatePending})
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+}
+
+func TestSetSequenceTxsStateAfterRejectsEmptyTxID(t *testing.T) {
+	// the id is checked before the query, so no db is needed
+	require.Equal(t, ErrEmptyTxID, (&repoImpl{}).SetSequenceTxsStateAfter(1, "", TransactionStatePending))
+}
+
+func TestSetSequenceTxsStateAfter(t *testing.T) {
+	repo := newTestRepo(t)
+
+	seqID := createConfirmedSequence(t, repo, 4)
+
+	requireStates := func(states ...TransactionState) {
+		t.Helper()
+		txs, err := repo.GetSequenceTxsByID(seqID)
+		require.NoError(t, err)
+		for i, tx := range txs {
+			require.Equal(t, states[i], tx.State, "position %d", i)
+		}
+	}
+
+	// not broadcasted txs have no id, they cannot be the starting point
+	require.Equal(t, ErrEmptyTxID, repo.SetSequenceTxsStateAfter(seqID, "", TransactionStatePending))
+	requireStates(TransactionStateConfirmed, TransactionStateConfirmed, TransactionStateConfirmed, TransactionStatePending)
+
+	// unknown id changes nothing
+	require.NoError(t, repo.SetSequenceTxsStateAfter(seqID, "unknown", TransactionStatePending))
+	requireStates(TransactionStateConfirmed, TransactionStateConfirmed, TransactionStateConfirmed, TransactionStatePending)
+
+	// the tx skipped by the client is not broadcasted again
+	skipped, err := repo.SkipSequenceTx(seqID, 3)
+	require.NoError(t, err)
+	require.True(t, skipped)
+
+	require.NoError(t, repo.SetSequenceTxsStateAfter(seqID, "tx1", TransactionStatePending))
+	requireStates(TransactionStateConfirmed, TransactionStatePending, TransactionStatePending, TransactionStateSkipped)
+}
+
+func TestBigTxIsStored(t *testing.T) {
+	repo := newTestRepo(t)
+
+	// big invoke txs fit the tx column
+	tx := `{"id":"tx0","attachment":"` + strings.Repeat("a", 2*1024*1024) + `"}`
+	seqID, err := repo.CreateSequence([]string{tx}, SequenceOptions{})
+	require.NoError(t, err)
+
+	stored, err := repo.GetSequenceTx(seqID, 0)
+	require.NoError(t, err)
+	require.Equal(t, tx, stored.Tx)
+
+	// there is no such tx
+	stored, err = repo.GetSequenceTx(seqID, 1)
+	require.NoError(t, err)
+	require.Nil(t, stored)
+}
+
+func TestCountSequenceTxsNotInState(t *testing.T) {
+	repo := newTestRepo(t)
+
+	// the last tx of the sequence is still pending
+	seqID := createConfirmedSequence(t, repo, 3)
+
+	count, err := repo.CountSequenceTxsNotInState(seqID, TransactionStateConfirmed)
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	// skipped txs are final
+	skipped, err := repo.SkipSequenceTx(seqID, 2)
+	require.NoError(t, err)
+	require.True(t, skipped)
+
+	count, err = repo.CountSequenceTxsNotInState(seqID, TransactionStateConfirmed)
+	require.NoError(t, err)
+	require.Equal(t, 0, count)
+}
+
+func TestGetNewSequenceIdsInterleavesLabels(t *testing.T) {
+	repo := newTestRepo(t)
+
+	// the tenant a has created its sequences before the others
+	for _, label := range []string{"a", "a", "a", "a", "b", "b", ""} {
+		_, err := repo.CreateSequence([]string{`{"id":"tx1"}`}, SequenceOptions{Label: label})
+		require.NoError(t, err)
+	}
+
+	options := ClaimOptions{LabelWeights: map[string]int{"a": 2}, Limit: 4}
+	ids, err := repo.GetNewSequenceIds(options)
+	require.NoError(t, err)
+	require.Equal(t, []int64{1, 2, 5, 7}, ids)
+
+	for _, id := range ids {
+		claimed, err := repo.ClaimSequence(id, "a")
+		require.NoError(t, err)
+		require.True(t, claimed)
+	}
+
+	ids, err = repo.GetNewSequenceIds(options)
+	require.NoError(t, err)
+	require.Equal(t, []int64{3, 4, 6}, ids)
+
+	// processing sequences of the label are counted against its limit
+	options.MaxProcessingPerLabel = 3
+	ids, err = repo.GetNewSequenceIds(options)
+	require.NoError(t, err)
+	require.Equal(t, []int64{3, 6}, ids)
+}
+
+// sqlError is the pg error of the given sql state and severity
+type sqlError struct {
+	code     string
+	severity string
+}
+
+func (e sqlError) Error() string {
+	return "ERROR #" + e.code
+}
+
+func (e sqlError) Field(field byte) string {
+	switch field {
+	case 'C':
+		return e.code
+	case 'S':
+		return e.severity
+	}
+	return ""
+}
+
+func (e sqlError) IntegrityViolation() bool {
+	return strings.HasPrefix(e.code, "23")
+}
+
+func TestIsConnectionError(t *testing.T) {
+	connectionErrors := []error{
+		io.EOF,
+		fmt.Errorf("reading response: %w", io.ErrUnexpectedEOF),
+		&net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")},
+		errors.New("pg: database is closed"),
+		errors.New("pg: connection pool timeout"),
+		sqlError{code: "08006", severity: "ERROR"},
+		sqlError{code: "57P03", severity: "FATAL"},
+		sqlError{code: "53300", severity: "ERROR"},
+		sqlError{code: "57P01", severity: "FATAL"},
+	}
+	for _, err := range connectionErrors {
+		require.True(t, IsConnectionError(err), err.Error())
+		require.False(t, IsTransientError(err), err.Error())
+	}
+
+	// errors of particular queries
+	queryErrors := []error{
+		errors.New("pg: no rows in result set"),
+		sqlError{code: "23505", severity: "ERROR"},
+		sqlError{code: "40001", severity: "ERROR"},
+		sqlError{code: "57014", severity: "ERROR"},
+	}
+	for _, err := range queryErrors {
+		require.False(t, IsConnectionError(err), err.Error())
+	}
+}
+
+func TestWrapUnavailable(t *testing.T) {
+	for _, code := range []string{"25006", "53300", "57P03"} {
+		err := wrapUnavailable(sqlError{code: code, severity: "ERROR"})
+		var dbErr *DBUnavailableError
+		require.True(t, errors.As(err, &dbErr), code)
+		require.Equal(t, sqlError{code: code, severity: "ERROR"}, dbErr.Err)
+	}
+
+	for _, err := range []error{
+		sqlError{code: "08006", severity: "ERROR"},
+		sqlError{code: "23505", severity: "ERROR"},
+		sqlError{code: "40001", severity: "ERROR"},
+		errors.New("pg: connection pool timeout"),
+		io.EOF,
+	} {
+		var dbErr *DBUnavailableError
+		require.False(t, errors.As(wrapUnavailable(err), &dbErr), err.Error())
+		require.Equal(t, err, wrapUnavailable(err))
+	}
+}
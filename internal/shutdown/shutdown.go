@@ -0,0 +1,69 @@
+package shutdown
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"go.uber.org/zap"
+)
+
+type namedCloser struct {
+	name  string
+	close func() error
+}
+
+// Sequence closes app components in the reverse order they were added, like deferred calls
+// components are added as they are initialized, so the ones which were not initialized are not closed
+type Sequence struct {
+	logger  *zap.Logger
+	mutex   sync.Mutex
+	closers []namedCloser
+	closed  bool
+}
+
+// NewSequence returns empty Sequence
+func NewSequence(logger *zap.Logger) *Sequence {
+	return &Sequence{logger: logger}
+}
+
+// Add adds the component closer, nil closer is ignored
+func (s *Sequence) Add(name string, close func() error) {
+	if close == nil {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.closers = append(s.closers, namedCloser{name: name, close: close})
+}
+
+// Close closes all components, errors are logged and do not stop closing the rest of them
+// it is idempotent, components are closed only once
+func (s *Sequence) Close() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return
+	}
+	s.closed = true
+
+	for i := len(s.closers) - 1; i >= 0; i-- {
+		c := s.closers[i]
+		if err := c.close(); err != nil {
+			s.logger.Error("error occurred while closing component", zap.String("component", c.name), zap.Error(err))
+			continue
+		}
+		s.logger.Info("component closed", zap.String("component", c.name))
+	}
+}
+
+// Signals returns channel receiving SIGINT and SIGTERM of the process
+func Signals() <-chan os.Signal {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	return signals
+}
@@ -0,0 +1,11 @@
+package tracing
+
+// Config of tracing
+type Config struct {
+	OTLPEndpoint string  `env:"TRACING_OTLP_ENDPOINT"`
+	OTLPInsecure bool    `env:"TRACING_OTLP_INSECURE" envDefault:"false"`
+	SampleRatio  float64 `env:"TRACING_SAMPLE_RATIO" envDefault:"1"`
+	// ExportTimeout in milliseconds
+	ExportTimeout int `env:"TRACING_EXPORT_TIMEOUT" envDefault:"5000"`
+	QueueSize     int `env:"TRACING_QUEUE_SIZE" envDefault:"2048"`
+}
@@ -0,0 +1,196 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+)
+
+const traceParentHeader = "traceparent"
+
+// Tracer starts spans of all the app components, spans are not recorded until Init is called
+var Tracer = otel.Tracer("github.com/wavesplatform/transaction-broadcaster")
+
+var propagator = propagation.TraceContext{}
+
+// reasons spans are dropped for
+const (
+	droppedByExport    = "export"
+	droppedByQueueFull = "queue_full"
+)
+
+// Init configures OTLP export of spans, tracing stays disabled if the endpoint is not set
+// spans are queued and exported in background, they are dropped if the queue is full or the export fails,
+// so an unavailable collector never blocks the app
+// returns function flushing spans which have not been exported yet
+func Init(cfg Config, serviceName string, registerer prometheus.Registerer) (func(context.Context) error, error) {
+	if cfg.OTLPEndpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	dropped := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "tracing_dropped_spans_total",
+		Help: "Count of spans dropped because their export failed or the export queue was full",
+	}, []string{"reason"})
+	if err := registerer.Register(dropped); err != nil {
+		return nil, err
+	}
+
+	exportTimeout := time.Duration(cfg.ExportTimeout) * time.Millisecond
+	opts := []otlptracehttp.Option{
+		otlptracehttp.WithEndpoint(cfg.OTLPEndpoint),
+		otlptracehttp.WithTimeout(exportTimeout),
+		// a failed batch is dropped instead of being retried while the queue fills up
+		otlptracehttp.WithRetry(otlptracehttp.RetryConfig{Enabled: false}),
+	}
+	if cfg.OTLPInsecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+
+	exporter, err := otlptracehttp.New(context.Background(), opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	// the batcher blocks on its full queue, so spans are dropped only by the queueing processor in front of it and are counted
+	batcher := sdktrace.NewBatchSpanProcessor(
+		&droppingExporter{SpanExporter: exporter, dropped: dropped.WithLabelValues(droppedByExport)},
+		sdktrace.WithMaxQueueSize(cfg.QueueSize),
+		sdktrace.WithExportTimeout(exportTimeout),
+		sdktrace.WithBlocking(),
+	)
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(newQueueingProcessor(batcher, cfg.QueueSize, dropped.WithLabelValues(droppedByQueueFull))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(serviceName))),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagator)
+
+	return provider.Shutdown, nil
+}
+
+type droppingExporter struct {
+	sdktrace.SpanExporter
+	dropped prometheus.Counter
+}
+
+func (e *droppingExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	err := e.SpanExporter.ExportSpans(ctx, spans)
+	if err != nil {
+		e.dropped.Add(float64(len(spans)))
+	}
+	return err
+}
+
+// queueingProcessor passes ended spans to the next processor in background, so the next one may block
+// spans ended while the queue is full are dropped and counted
+type queueingProcessor struct {
+	next    sdktrace.SpanProcessor
+	dropped prometheus.Counter
+
+	// mutex guards closing of the queue against concurrent OnEnd calls
+	mutex  sync.RWMutex
+	closed bool
+	queue  chan sdktrace.ReadOnlySpan
+	done   chan struct{}
+}
+
+func newQueueingProcessor(next sdktrace.SpanProcessor, queueSize int, dropped prometheus.Counter) *queueingProcessor {
+	p := &queueingProcessor{
+		next:    next,
+		dropped: dropped,
+		queue:   make(chan sdktrace.ReadOnlySpan, queueSize),
+		done:    make(chan struct{}),
+	}
+
+	go func() {
+		defer close(p.done)
+		for span := range p.queue {
+			p.next.OnEnd(span)
+		}
+	}()
+
+	return p
+}
+
+func (p *queueingProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	p.next.OnStart(parent, s)
+}
+
+func (p *queueingProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	if !s.SpanContext().IsSampled() {
+		return
+	}
+
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	if p.closed {
+		return
+	}
+
+	select {
+	case p.queue <- s:
+	default:
+		p.dropped.Inc()
+	}
+}
+
+// Shutdown passes the queued spans to the next processor and shuts it down
+func (p *queueingProcessor) Shutdown(ctx context.Context) error {
+	p.mutex.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.mutex.Unlock()
+
+	select {
+	case <-p.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	return p.next.Shutdown(ctx)
+}
+
+// ForceFlush flushes the next processor, spans still queued are exported by its next batches
+func (p *queueingProcessor) ForceFlush(ctx context.Context) error {
+	return p.next.ForceFlush(ctx)
+}
+
+// TraceParent returns W3C traceparent of the span in ctx, empty string if there is no sampled span
+// it is stored on the sequence, so the sequence processing continues the trace of its creation
+func TraceParent(ctx context.Context) string {
+	carrier := propagation.HeaderCarrier(http.Header{})
+	propagator.Inject(ctx, carrier)
+	return carrier.Get(traceParentHeader)
+}
+
+// ContextWithTraceParent returns ctx with the remote span described by traceParent
+func ContextWithTraceParent(ctx context.Context, traceParent string) context.Context {
+	if traceParent == "" {
+		return ctx
+	}
+	carrier := propagation.HeaderCarrier(http.Header{})
+	carrier.Set(traceParentHeader, traceParent)
+	return propagator.Extract(ctx, carrier)
+}
+
+// Extract returns ctx with the remote span passed in the request headers
+func Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	return propagator.Extract(ctx, carrier)
+}
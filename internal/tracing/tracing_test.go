@@ -0,0 +1,56 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// stalledProcessor blocks on every ended span until it is released, as the blocking batcher of a dead collector does
+type stalledProcessor struct {
+	received chan struct{}
+	release  chan struct{}
+}
+
+func (p *stalledProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {}
+
+func (p *stalledProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	p.received <- struct{}{}
+	<-p.release
+}
+
+func (p *stalledProcessor) Shutdown(ctx context.Context) error { return nil }
+
+func (p *stalledProcessor) ForceFlush(ctx context.Context) error { return nil }
+
+func TestQueueingProcessorDropsSpansOfFullQueue(t *testing.T) {
+	next := &stalledProcessor{received: make(chan struct{}, 10), release: make(chan struct{})}
+	dropped := prometheus.NewCounter(prometheus.CounterOpts{Name: "dropped"})
+	p := newQueueingProcessor(next, 2, dropped)
+
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(p)).Tracer("test")
+
+	// the first span is stuck in the stalled processor
+	_, span := tracer.Start(context.Background(), "first")
+	span.End()
+	<-next.received
+
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		_, span := tracer.Start(context.Background(), "next")
+		span.End()
+	}
+	require.Less(t, int64(time.Since(start)), int64(100*time.Millisecond), "ending spans is blocked by the stalled export")
+
+	// two spans fit the queue, the rest are dropped
+	require.Equal(t, float64(2), testutil.ToFloat64(dropped))
+
+	close(next.release)
+	require.NoError(t, p.Shutdown(context.Background()))
+	require.Len(t, next.received, 2)
+}
@@ -0,0 +1,206 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/wavesplatform/transaction-broadcaster/internal/repository"
+)
+
+// txCallbackClient is shared by all workers, so connections to the same receivers are reused
+var txCallbackClient = &http.Client{}
+
+// pendingTxCallbacks are callbacks being sent by all workers
+var pendingTxCallbacks = newTxCallbacksPending()
+
+// txCallbacksPending bounds the count of callbacks sent in the background and waits for them on the shutdown
+type txCallbacksPending struct {
+	mutex  sync.Mutex
+	count  int32
+	closed bool
+	wg     sync.WaitGroup
+	// ctx is cancelled on the shutdown, it aborts attempts and retries of callbacks which were not sent in time
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func newTxCallbacksPending() *txCallbacksPending {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &txCallbacksPending{ctx: ctx, cancel: cancel}
+}
+
+// start returns false if there are max pending callbacks already or the daemon is shutting down, max <= 0 means no limit
+func (p *txCallbacksPending) start(max int32) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.closed || (max > 0 && p.count >= max) {
+		return false
+	}
+	p.count++
+	p.wg.Add(1)
+	return true
+}
+
+func (p *txCallbacksPending) done() {
+	p.mutex.Lock()
+	p.count--
+	p.mutex.Unlock()
+	p.wg.Done()
+}
+
+// close rejects new callbacks and waits for the pending ones for timeout, the rest of them are aborted
+func (p *txCallbacksPending) close(timeout time.Duration) error {
+	p.mutex.Lock()
+	p.closed = true
+	p.mutex.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		return nil
+	case <-time.After(timeout):
+	}
+
+	p.mutex.Lock()
+	aborted := p.count
+	p.mutex.Unlock()
+	p.cancel()
+	<-finished
+	return fmt.Errorf("%d tx callbacks were not sent before the shutdown", aborted)
+}
+
+// CloseTxCallbacks stops sending tx callbacks, callbacks pending for longer than timeout are aborted, it is called on the daemon shutdown
+// callbacks of the confirmations made after it are dropped
+func CloseTxCallbacks(timeout time.Duration) error {
+	return pendingTxCallbacks.close(timeout)
+}
+
+// txCallback is the body of the per-tx callback request
+type txCallback struct {
+	SequenceID         int64  `json:"sequence_id"`
+	TxID               string `json:"tx_id"`
+	PositionInSequence int16  `json:"position_in_sequence"`
+	Height             int32  `json:"height"`
+}
+
+// txCallbackSender posts confirmed txs to the sequence callback url in the background with retries,
+// so slow or unavailable receivers do not delay the sequence processing
+// callbacks of different txs are sent independently, so they may arrive out of order
+// a tx confirmed again at the same height (e.g. after a rollback) is not posted twice by the same worker,
+// a tx confirmed at another height is posted again with the new height
+type txCallbackSender struct {
+	url           string
+	timeout       time.Duration
+	retries       int32
+	retryInterval time.Duration
+	maxPending    int32
+	logger        *zap.Logger
+
+	// sent are heights of txs posted by the worker by tx position
+	sent map[int16]int32
+}
+
+// newTxCallbackSender returns nil if the url is empty, nil sender sends nothing
+func newTxCallbackSender(url string, cfg Config, logger *zap.Logger) *txCallbackSender {
+	if url == "" {
+		return nil
+	}
+
+	return &txCallbackSender{
+		url:           url,
+		timeout:       time.Duration(cfg.TxCallbackTimeout) * time.Millisecond,
+		retries:       cfg.TxCallbackRetries,
+		retryInterval: time.Duration(cfg.TxCallbackRetryInterval) * time.Millisecond,
+		maxPending:    cfg.TxCallbackMaxPending,
+		logger:        logger,
+		sent:          make(map[int16]int32),
+	}
+}
+
+// send posts the confirmed tx in the background, the callback is dropped if there are too many pending ones
+func (s *txCallbackSender) send(tx *repository.SequenceTx) {
+	if s == nil {
+		return
+	}
+
+	if height, ok := s.sent[tx.PositionInSequence]; ok && height == tx.Height {
+		return
+	}
+
+	body, err := json.Marshal(txCallback{
+		SequenceID:         tx.SequenceID,
+		TxID:               tx.ID,
+		PositionInSequence: tx.PositionInSequence,
+		Height:             tx.Height,
+	})
+	if err != nil {
+		s.logger.Error("cannot encode tx callback", zap.Int64("sequence_id", tx.SequenceID), zap.Error(err))
+		return
+	}
+
+	if !pendingTxCallbacks.start(s.maxPending) {
+		s.logger.Warn("tx callback dropped, too many pending callbacks or shutting down", zap.Int64("sequence_id", tx.SequenceID), zap.Int16("position_in_sequence", tx.PositionInSequence))
+		return
+	}
+	s.sent[tx.PositionInSequence] = tx.Height
+
+	go func() {
+		defer pendingTxCallbacks.done()
+
+		for attempt := int32(0); ; attempt++ {
+			err := s.post(pendingTxCallbacks.ctx, body)
+			if err == nil {
+				return
+			}
+
+			if attempt >= s.retries || pendingTxCallbacks.ctx.Err() != nil {
+				s.logger.Warn("tx callback failed", zap.Int64("sequence_id", tx.SequenceID), zap.Int16("position_in_sequence", tx.PositionInSequence), zap.Int32("attempts", attempt+1), zap.Error(err))
+				return
+			}
+
+			select {
+			case <-time.After(s.retryInterval):
+			case <-pendingTxCallbacks.ctx.Done():
+			}
+		}
+	}()
+}
+
+// post makes a single attempt, any 2xx response is a success
+func (s *txCallbackSender) post(ctx context.Context, body []byte) error {
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := txCallbackClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package worker
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/wavesplatform/transaction-broadcaster/internal/repository"
+)
+
+// newCallbacksServer returns the receiver sending posted callbacks to the channel, release unblocks its responses
+func newCallbacksServer(t *testing.T, release chan struct{}) (*httptest.Server, chan txCallback) {
+	received := make(chan txCallback, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		callback := txCallback{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&callback))
+		received <- callback
+		if release != nil {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, received
+}
+
+func withPendingTxCallbacks(t *testing.T) {
+	previous := pendingTxCallbacks
+	pendingTxCallbacks = newTxCallbacksPending()
+	t.Cleanup(func() { pendingTxCallbacks = previous })
+}
+
+func TestTxCallbackSenderSkipsSameConfirmation(t *testing.T) {
+	withPendingTxCallbacks(t)
+	server, received := newCallbacksServer(t, nil)
+	sender := newTxCallbackSender(server.URL, Config{TxCallbackTimeout: 1000}, zap.NewNop())
+
+	tx := &repository.SequenceTx{SequenceID: 1, ID: "tx", PositionInSequence: 2, Height: 10}
+	sender.send(tx)
+	require.Equal(t, txCallback{SequenceID: 1, TxID: "tx", PositionInSequence: 2, Height: 10}, <-received)
+
+	// confirmed again at the same height after the rollback
+	sender.send(tx)
+
+	tx.Height = 11
+	sender.send(tx)
+	require.Equal(t, int32(11), (<-received).Height)
+
+	require.NoError(t, CloseTxCallbacks(time.Second))
+	require.Empty(t, received)
+}
+
+func TestTxCallbacksAreBounded(t *testing.T) {
+	withPendingTxCallbacks(t)
+	release := make(chan struct{})
+	server, received := newCallbacksServer(t, release)
+	sender := newTxCallbackSender(server.URL, Config{TxCallbackTimeout: 1000, TxCallbackMaxPending: 1}, zap.NewNop())
+
+	sender.send(&repository.SequenceTx{SequenceID: 1, ID: "tx1", PositionInSequence: 0, Height: 10})
+	<-received
+	sender.send(&repository.SequenceTx{SequenceID: 1, ID: "tx2", PositionInSequence: 1, Height: 10})
+	close(release)
+
+	require.NoError(t, CloseTxCallbacks(time.Second))
+	require.Empty(t, received)
+
+	// the dropped callback is not considered as sent
+	_, sent := sender.sent[1]
+	require.False(t, sent)
+}
+
+func TestCloseTxCallbacksAbortsPendingOnes(t *testing.T) {
+	withPendingTxCallbacks(t)
+	server, received := newCallbacksServer(t, make(chan struct{}))
+	sender := newTxCallbackSender(server.URL, Config{TxCallbackTimeout: 60000, TxCallbackRetries: 100, TxCallbackRetryInterval: 60000}, zap.NewNop())
+
+	sender.send(&repository.SequenceTx{SequenceID: 1, ID: "tx1", PositionInSequence: 0, Height: 10})
+	<-received
+
+	start := time.Now()
+	require.EqualError(t, CloseTxCallbacks(50*time.Millisecond), "1 tx callbacks were not sent before the shutdown")
+	require.Less(t, int64(time.Since(start)), int64(10*time.Second))
+
+	// confirmations made after the shutdown are not posted
+	sender.send(&repository.SequenceTx{SequenceID: 1, ID: "tx2", PositionInSequence: 1, Height: 10})
+	require.Empty(t, received)
+}
@@ -1,9 +1,211 @@
 package worker
 
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
 // Config of the worker
 type Config struct {
 	TxOutdateTime          int32 `env:"WORKER_TX_OUTDATE_TIME" envDefault:"14400000"`
 	TxProcessingTTL        int32 `env:"WORKER_TX_PROCESSING_TTL" envDefault:"3000"`
 	HeightsAfterLastTx     int32 `env:"WORKER_HEIGHTS_AFTER_LAST_TX" envDefault:"6"`
 	WaitForNextHeightDelay int32 `env:"WORKER_WAIT_FOR_NEXT_HEIGHT_DELAY" envDefaul:"1000"`
+	MinConfirmations       int32 `env:"WORKER_MIN_CONFIRMATIONS" envDefault:"0"`
+
+	TxConfirmationTimeoutHeights int32          `env:"WORKER_TX_CONFIRMATION_TIMEOUT_HEIGHTS" envDefault:"0"`
+	TxConfirmationTimeoutByType  TxTypeTimeouts `env:"WORKER_TX_CONFIRMATION_TIMEOUT_BY_TYPE"`
+
+	// whether to set the tx error message if the node returned tx id differs from the id in the submitted tx
+	FlagTxIDMismatch bool `env:"WORKER_FLAG_TX_ID_MISMATCH" envDefault:"false"`
+
+	// whether to validate txs by the node test broadcast, which also catches conflicts with utx
+	UseTestBroadcast bool `env:"WORKER_USE_TEST_BROADCAST" envDefault:"false"`
+
+	// whether statuses of several unconfirmed txs are polled by a single request
+	BatchStatusPolling bool `env:"WORKER_BATCH_STATUS_POLLING" envDefault:"false"`
+
+	// min interval between broadcasts of txs of a sequence in ms, 0 means no pacing
+	BroadcastInterval int32 `env:"WORKER_BROADCAST_INTERVAL" envDefault:"0"`
+
+	// whether a tx the node reports as already in the state is confirmed at the reported height without waiting for it
+	DuplicateAsConfirmed bool `env:"WORKER_DUPLICATE_AS_CONFIRMED" envDefault:"false"`
+
+	// whether a tx broadcasted by another processing sequence is waited for instead of being broadcasted again
+	AdoptDuplicateBroadcasts bool `env:"WORKER_ADOPT_DUPLICATE_BROADCASTS" envDefault:"false"`
+
+	// count of consecutive checks a confirmed tx has to be not found in to be considered pulled out
+	// node may return not_found for confirmed txs for a while after its restart
+	TxNotFoundChecks int32 `env:"WORKER_TX_NOT_FOUND_CHECKS" envDefault:"1"`
+
+	// retry budget of a single worker run, 0 means no limit
+	RetryBudget     int32 `env:"WORKER_RETRY_BUDGET" envDefault:"0"`
+	RetryBudgetTime int32 `env:"WORKER_RETRY_BUDGET_TIME" envDefault:"0"`
+
+	// whether the node script execution trace of failed validations is stored with the tx
+	PersistValidationTraces bool `env:"WORKER_PERSIST_VALIDATION_TRACES" envDefault:"false"`
+
+	// validated txs are validated again before the broadcast if they were validated more than RevalidateAfter ms ago, 0 means never
+	RevalidateAfter int32 `env:"WORKER_REVALIDATE_AFTER" envDefault:"0"`
+
+	// confirmed txs deeper than ReorgWindow heights are not checked for availability anymore, 0 means all txs are checked
+	// the node does not roll back deeper than its max rollback depth, 100 by default
+	ReorgWindow int32 `env:"WORKER_REORG_WINDOW" envDefault:"100"`
+
+	// whether ids of the blocks confirmed txs are at are tracked, txs of a replaced block are verified without tolerating not_found statuses
+	TrackBlockSignatures bool `env:"WORKER_TRACK_BLOCK_SIGNATURES" envDefault:"false"`
+
+	// timeout of a single attempt to post the per-tx callback (ms), count of retries of failed attempts and interval between them (ms)
+	TxCallbackTimeout       int32 `env:"WORKER_TX_CALLBACK_TIMEOUT" envDefault:"5000"`
+	TxCallbackRetries       int32 `env:"WORKER_TX_CALLBACK_RETRIES" envDefault:"3"`
+	TxCallbackRetryInterval int32 `env:"WORKER_TX_CALLBACK_RETRY_INTERVAL" envDefault:"1000"`
+	// max count of callbacks sent by all workers at once including their retries, callbacks over it are dropped, 0 means no limit
+	TxCallbackMaxPending int32 `env:"WORKER_TX_CALLBACK_MAX_PENDING" envDefault:"1000"`
+
+	// sequences whose txs have not reached new states for DeadlockTimeout ms while the node produced blocks are failed,
+	// e.g. sequences waiting for txs of each other, 0 means sequences wait forever
+	DeadlockTimeout int32 `env:"WORKER_DEADLOCK_TIMEOUT" envDefault:"0"`
+
+	// count of retries of state writes failed with transient db errors and interval between them (ms)
+	// writes still failing after the retries are recoverable errors, connection errors are fatal without retries
+	DBWriteRetries       int32 `env:"WORKER_DB_WRITE_RETRIES" envDefault:"3"`
+	DBWriteRetryInterval int32 `env:"WORKER_DB_WRITE_RETRY_INTERVAL" envDefault:"100"`
+
+	// whether warnings the node reports validating valid txs are stored with the tx
+	CaptureValidationWarnings bool `env:"WORKER_CAPTURE_VALIDATION_WARNINGS" envDefault:"false"`
+
+	// repeated identical recoverable errors of a sequence are logged not more often than once per ErrorLogInterval ms
+	// along with the count of suppressed ones, 0 means every error is logged
+	ErrorLogInterval int32 `env:"WORKER_ERROR_LOG_INTERVAL" envDefault:"0"`
+
+	// whether validate only sequences validate the rest of txs after an invalid one, so every tx gets its validation result
+	ValidateOnlyContinue bool `env:"WORKER_VALIDATE_ONLY_CONTINUE" envDefault:"false"`
+
+	// ConfirmationMode defines which txs are waited for HeightsAfterLastTx heights
+	ConfirmationMode ConfirmationMode `env:"WORKER_CONFIRMATION_MODE" envDefault:"all"`
+
+	// NodeErrorClassOverrides reclassify errors with the given node error codes
+	NodeErrorClassOverrides ErrorClassOverrides `env:"NODE_ERROR_CLASS_OVERRIDES"`
+}
+
+// ConfirmationMode represents which txs of the sequence require deep confirmation
+type ConfirmationMode uint8
+
+// Enum of ConfirmationMode
+const (
+	// ConfirmationModeAll waits for heights after the highest tx and watches all txs while waiting
+	ConfirmationModeAll ConfirmationMode = iota
+	// ConfirmationModeLastTx waits for heights after the last tx and watches only it,
+	// the rest of txs require just inclusion unless their confirmations are set by the sequence
+	ConfirmationModeLastTx
+)
+
+// UnmarshalText parses ConfirmationMode from its name
+func (m *ConfirmationMode) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "all":
+		*m = ConfirmationModeAll
+	case "last_tx":
+		*m = ConfirmationModeLastTx
+	default:
+		return fmt.Errorf("unknown confirmation mode: %s", text)
+	}
+	return nil
+}
+
+// ErrorClass is the class of the worker error a node error is mapped to
+type ErrorClass string
+
+// Enum of ErrorClass
+const (
+	ErrorClassRecoverable    ErrorClass = "recoverable"
+	ErrorClassNonRecoverable ErrorClass = "non_recoverable"
+)
+
+// ErrorClassOverrides represents map of node error code:error class
+type ErrorClassOverrides map[uint16]ErrorClass
+
+// UnmarshalText parses comma separated list of code:class pairs, e.g. 112:non_recoverable,199:recoverable
+func (o *ErrorClassOverrides) UnmarshalText(text []byte) error {
+	overrides := ErrorClassOverrides{}
+
+	for _, pair := range strings.Split(string(text), ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		parts := strings.Split(pair, ":")
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid node error class override: %s", pair)
+		}
+
+		code, err := strconv.ParseUint(parts[0], 10, 16)
+		if err != nil || code == 0 {
+			return fmt.Errorf("invalid node error code: %s", parts[0])
+		}
+
+		class := ErrorClass(parts[1])
+		if class != ErrorClassRecoverable && class != ErrorClassNonRecoverable {
+			return fmt.Errorf("invalid node error class: %s", parts[1])
+		}
+
+		overrides[uint16(code)] = class
+	}
+
+	*o = overrides
+	return nil
+}
+
+// String returns overrides in the config format ordered by codes
+func (o ErrorClassOverrides) String() string {
+	codes := make([]int, 0, len(o))
+	for code := range o {
+		codes = append(codes, int(code))
+	}
+	sort.Ints(codes)
+
+	pairs := make([]string, 0, len(codes))
+	for _, code := range codes {
+		pairs = append(pairs, fmt.Sprintf("%d:%s", code, o[uint16(code)]))
+	}
+	return strings.Join(pairs, ",")
+}
+
+// TxTypeTimeouts represents map of tx type:timeout
+type TxTypeTimeouts map[int32]time.Duration
+
+// UnmarshalText parses comma separated list of type:ms pairs, e.g. 16:300000,4:60000
+func (t *TxTypeTimeouts) UnmarshalText(text []byte) error {
+	timeouts := TxTypeTimeouts{}
+
+	for _, pair := range strings.Split(string(text), ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		parts := strings.Split(pair, ":")
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid tx type timeout: %s", pair)
+		}
+
+		txType, err := strconv.ParseInt(parts[0], 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid tx type: %s", parts[0])
+		}
+
+		timeout, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil || timeout <= 0 {
+			return fmt.Errorf("invalid tx type timeout: %s", parts[1])
+		}
+
+		timeouts[int32(txType)] = time.Duration(timeout) * time.Millisecond
+	}
+
+	*t = timeouts
+	return nil
 }
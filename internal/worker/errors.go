@@ -1,6 +1,32 @@
 package worker
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/wavesplatform/transaction-broadcaster/internal/node"
+	"github.com/wavesplatform/transaction-broadcaster/internal/repository"
+)
+
+// Sequence error codes set by the worker itself
+// they start from 1000 to not intersect with node error codes
+const (
+	DeadlineExceededErrorCode uint16 = iota + 1000
+	NoConfirmedTxsErrorCode
+	TxOutdatedErrorCode
+	ScriptedAccountErrorCode
+	RetryBudgetExhaustedErrorCode
+	FatalErrorCode
+	NoProgressErrorCode
+	InvalidTxsErrorCode
+)
+
+// scriptedAccountErrorGuidance is appended to the reason of txs rejected by the account script
+const scriptedAccountErrorGuidance = "the sender is a scripted account, the tx has to carry proofs expected by the account script and the fee has to include the script execution extra fee"
+
+// NewScriptedAccountError returns NonRecoverableError for tx rejected by the account script
+func NewScriptedAccountError(message string) ErrorWithReasonAndCode {
+	return NewNonRecoverableError(fmt.Sprintf("%s (%s)", message, scriptedAccountErrorGuidance), ScriptedAccountErrorCode)
+}
 
 // RecoverableError represents recoverable error
 type RecoverableError struct {
@@ -64,8 +90,10 @@ func (e NonRecoverableError) ErrorCode() uint16 {
 }
 
 // FatalError represents fatal error (aka exception)
+// it stops the process unless it is fatal only for the sequence and the dispatcher is configured to continue
 type FatalError struct {
-	reason string
+	reason       string
+	sequenceOnly bool
 }
 
 // NewFatalError returns new FatalError based on err
@@ -75,6 +103,23 @@ func NewFatalError(reason string) ErrorWithReason {
 	}
 }
 
+// NewSequenceFatalError returns new FatalError which is fatal only for the sequence being processed
+func NewSequenceFatalError(reason string) ErrorWithReason {
+	return FatalError{
+		reason:       reason,
+		sequenceOnly: true,
+	}
+}
+
+// NewDBError returns FatalError of the failed db call, it is fatal for the process if the db is gone
+// other db errors (e.g. of queries failed on the sequence data) are fatal only for the sequence
+func NewDBError(err error) ErrorWithReason {
+	if repository.IsConnectionError(err) {
+		return NewFatalError(err.Error())
+	}
+	return NewSequenceFatalError(err.Error())
+}
+
 func (e FatalError) Error() string {
 	return fmt.Sprintf("fatal error with reason: %s.", e.reason)
 }
@@ -83,3 +128,47 @@ func (e FatalError) Error() string {
 func (e FatalError) Reason() string {
 	return e.reason
 }
+
+// SequenceOnly returns whether the error is fatal only for the sequence being processed, not for the process
+func (e FatalError) SequenceOnly() bool {
+	return e.sequenceOnly
+}
+
+// Classify maps node error to the worker error, errors with overridden node error codes get the configured class
+// the rest are classified by ClassifyNodeError
+func (o ErrorClassOverrides) Classify(err node.Error) ErrorWithReason {
+	switch o[err.NodeErrorCode()] {
+	case ErrorClassRecoverable:
+		return NewRecoverableError(err.Error())
+	case ErrorClassNonRecoverable:
+		return NewNonRecoverableError(err.Error(), err.NodeErrorCode())
+	default:
+		return ClassifyNodeError(err)
+	}
+}
+
+// ClassifyNodeError maps node error to the worker error
+// it is the single place defining which node errors are recoverable
+func ClassifyNodeError(err node.Error) ErrorWithReason {
+	switch err.Code() {
+	case node.BroadcastClientError:
+		// node rejected tx, retrying the same tx makes no sense unless the node has no room for it now
+		switch node.ClassifyError(err.Error()) {
+		case node.ErrorClassScriptedAccount:
+			return NewScriptedAccountError(err.Error())
+		case node.ErrorClassUtxFull:
+			return NewRecoverableError(err.Error())
+		}
+		return NewNonRecoverableError(err.Error(), err.NodeErrorCode())
+	case node.BroadcastServerError, node.GetTxStatusError, node.WaitForTxStatusTimeoutError, node.TxNotFoundError, node.InternalError:
+		return NewRecoverableError(err.Error())
+	case node.ValidateEndpointUnavailableError:
+		// node misconfiguration, txs will be validated after it is fixed
+		return NewRecoverableError(err.Error())
+	case node.BlocksStreamUnavailableError:
+		// the node height is polled instead of streamed
+		return NewRecoverableError(err.Error())
+	default:
+		return NewRecoverableError(err.Error())
+	}
+}
@@ -0,0 +1,114 @@
+package worker
+
+import (
+	"errors"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/caarlos0/env/v6"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wavesplatform/transaction-broadcaster/internal/node"
+)
+
+// setEnv sets the env var for the test
+func setEnv(t *testing.T, name, value string) {
+	previous, ok := os.LookupEnv(name)
+	require.NoError(t, os.Setenv(name, value))
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(name, previous)
+		} else {
+			os.Unsetenv(name)
+		}
+	})
+}
+
+func TestErrorClassOverrides(t *testing.T) {
+	overrides := ErrorClassOverrides{}
+	require.NoError(t, overrides.UnmarshalText([]byte(" 112:recoverable, 199:non_recoverable ")))
+	require.Equal(t, "112:recoverable,199:non_recoverable", overrides.String())
+
+	// the rejected tx is not retried by default
+	rejected := node.WithNodeError(node.NewError(node.BroadcastClientError, "rejected"), 112)
+	require.IsType(t, NonRecoverableError{}, ClassifyNodeError(rejected))
+	require.IsType(t, RecoverableError{}, overrides.Classify(rejected))
+
+	// the failed status request is retried by default
+	failed := node.WithNodeError(node.NewError(node.GetTxStatusError, "failed"), 199)
+	require.IsType(t, RecoverableError{}, ClassifyNodeError(failed))
+	err := overrides.Classify(failed)
+	require.IsType(t, NonRecoverableError{}, err)
+	require.Equal(t, uint16(199), err.(ErrorWithReasonAndCode).ErrorCode())
+
+	// codes without overrides keep the default class
+	other := node.WithNodeError(node.NewError(node.BroadcastClientError, "rejected"), 113)
+	require.IsType(t, NonRecoverableError{}, overrides.Classify(other))
+	require.IsType(t, NonRecoverableError{}, ErrorClassOverrides(nil).Classify(other))
+}
+
+func TestErrorClassOverridesValidation(t *testing.T) {
+	for _, text := range []string{"112", "112:retry", "0:recoverable", "abc:recoverable", "70000:recoverable", "112:recoverable:1"} {
+		overrides := ErrorClassOverrides{}
+		require.Error(t, overrides.UnmarshalText([]byte(text)), text)
+	}
+
+	// overrides are parsed from env on startup
+	setEnv(t, "NODE_ERROR_CLASS_OVERRIDES", "112:non_recoverable")
+	cfg := Config{}
+	require.NoError(t, env.Parse(&cfg))
+	require.Equal(t, ErrorClassOverrides{112: ErrorClassNonRecoverable}, cfg.NodeErrorClassOverrides)
+
+	setEnv(t, "NODE_ERROR_CLASS_OVERRIDES", "112:sometimes")
+	require.Error(t, env.Parse(&Config{}))
+}
+
+func TestNewDBError(t *testing.T) {
+	// the db is gone, so no sequence can be processed
+	for _, err := range []error{io.EOF, errors.New("pg: connection pool timeout"), pgError("57P03")} {
+		dbErr := NewDBError(err)
+		require.IsType(t, FatalError{}, dbErr)
+		require.False(t, dbErr.(FatalError).SequenceOnly(), err.Error())
+	}
+
+	dbErr := NewDBError(pgError("22P02"))
+	require.IsType(t, FatalError{}, dbErr)
+	require.True(t, dbErr.(FatalError).SequenceOnly())
+	require.Equal(t, pgError("22P02").Error(), dbErr.Reason())
+}
+
+func TestClassifyNodeError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  node.Error
+		want ErrorWithReason
+	}{
+		{"rejected", node.NewError(node.BroadcastClientError, "negative waves balance"), NonRecoverableError{}},
+		{"scripted account", node.NewError(node.BroadcastClientError, "Transaction is not allowed by account-script"), NonRecoverableError{}},
+		{"utx full", node.NewError(node.BroadcastClientError, "Transaction pool size limit is reached"), RecoverableError{}},
+		{"broadcast server", node.NewError(node.BroadcastServerError, "bad gateway"), RecoverableError{}},
+		{"tx status", node.NewError(node.GetTxStatusError, "failed"), RecoverableError{}},
+		{"tx status timeout", node.NewError(node.WaitForTxStatusTimeoutError, "timeout"), RecoverableError{}},
+		{"tx not found", node.NewError(node.TxNotFoundError, "not found"), RecoverableError{}},
+		{"validate endpoint unavailable", node.NewError(node.ValidateEndpointUnavailableError, "forbidden"), RecoverableError{}},
+		{"blocks stream unavailable", node.NewError(node.BlocksStreamUnavailableError, "not configured"), RecoverableError{}},
+		{"internal", node.NewError(node.InternalError, "internal"), RecoverableError{}},
+	}
+
+	classified := map[uint16]bool{}
+	for _, tt := range tests {
+		err := ClassifyNodeError(tt.err)
+		require.IsType(t, tt.want, err, tt.name)
+		require.Equal(t, tt.err.Error(), err.Reason()[:len(tt.err.Error())], tt.name)
+		classified[tt.err.Code()] = true
+	}
+
+	scripted := ClassifyNodeError(node.NewError(node.BroadcastClientError, "Transaction is not allowed by account-script"))
+	require.Equal(t, ScriptedAccountErrorCode, scripted.(ErrorWithReasonAndCode).ErrorCode())
+
+	// every node error code has to be classified deliberately
+	for _, code := range node.ErrorCodes {
+		require.True(t, classified[code], "node error code %d is not classified", code)
+	}
+}
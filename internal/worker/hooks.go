@@ -0,0 +1,43 @@
+package worker
+
+import (
+	"sync"
+
+	"github.com/wavesplatform/transaction-broadcaster/internal/repository"
+)
+
+// StateHook is notified about tx state transitions made by workers, e.g. to collect metrics or send notifications
+// it is called synchronously after the new state is saved, so it has to return quickly
+type StateHook interface {
+	OnTxState(sequenceID int64, positionInSequence int16, state repository.TransactionState)
+}
+
+// StateHookFunc adapts a function to StateHook
+type StateHookFunc func(sequenceID int64, positionInSequence int16, state repository.TransactionState)
+
+// OnTxState calls f
+func (f StateHookFunc) OnTxState(sequenceID int64, positionInSequence int16, state repository.TransactionState) {
+	f(sequenceID, positionInSequence, state)
+}
+
+var (
+	stateHooksMutex sync.RWMutex
+	stateHooks      []StateHook
+)
+
+// RegisterStateHook adds the hook notified by all workers, there are no hooks by default
+func RegisterStateHook(hook StateHook) {
+	stateHooksMutex.Lock()
+	defer stateHooksMutex.Unlock()
+
+	stateHooks = append(stateHooks, hook)
+}
+
+func notifyStateHooks(sequenceID int64, positionInSequence int16, state repository.TransactionState) {
+	stateHooksMutex.RLock()
+	defer stateHooksMutex.RUnlock()
+
+	for _, hook := range stateHooks {
+		hook.OnTxState(sequenceID, positionInSequence, state)
+	}
+}
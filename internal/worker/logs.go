@@ -0,0 +1,97 @@
+package worker
+
+import (
+	"sync"
+	"time"
+
+	"github.com/wavesplatform/transaction-broadcaster/internal/node"
+	"go.uber.org/zap"
+)
+
+type errorLogKey struct {
+	sequenceID int64
+	message    string
+}
+
+type errorLogEntry struct {
+	loggedAt   time.Time
+	suppressed int
+}
+
+// errorLogSampler compacts repeated identical errors of a sequence: the first occurrence is logged,
+// the next ones are logged not more often than once per interval along with the count of suppressed ones
+// it is shared by all workers, since every retry of the sequence is made by a new worker
+type errorLogSampler struct {
+	mutex    sync.Mutex
+	entries  map[errorLogKey]*errorLogEntry
+	prunedAt time.Time
+}
+
+var recoverableErrorLogs = &errorLogSampler{
+	entries: make(map[errorLogKey]*errorLogEntry),
+}
+
+// sample returns whether the error has to be logged and count of its occurrences suppressed since it was logged last time
+func (s *errorLogSampler) sample(sequenceID int64, message string, interval time.Duration) (bool, int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	s.prune(now, interval)
+
+	key := errorLogKey{sequenceID: sequenceID, message: message}
+	entry, ok := s.entries[key]
+	if !ok {
+		s.entries[key] = &errorLogEntry{loggedAt: now}
+		return true, 0
+	}
+
+	if now.Sub(entry.loggedAt) < interval {
+		entry.suppressed++
+		return false, 0
+	}
+
+	suppressed := entry.suppressed
+	entry.loggedAt = now
+	entry.suppressed = 0
+	return true, suppressed
+}
+
+// prune forgets errors which were not repeated for a while, e.g. errors of finished sequences
+// counts of their suppressed occurrences are dropped
+func (s *errorLogSampler) prune(now time.Time, interval time.Duration) {
+	if now.Sub(s.prunedAt) < interval {
+		return
+	}
+	s.prunedAt = now
+
+	for key, entry := range s.entries {
+		if now.Sub(entry.loggedAt) >= 2*interval {
+			delete(s.entries, key)
+		}
+	}
+}
+
+// logError logs the error occurred processing the sequence
+// recoverable errors are sampled if the error log interval is set, fatal and non-recoverable errors are always logged
+func (w *workerImpl) logError(sequenceID int64, msg string, err ErrorWithReason, fields ...zap.Field) {
+	if _, ok := err.(RecoverableError); ok && w.errorLogInterval > 0 {
+		log, suppressed := recoverableErrorLogs.sample(sequenceID, msg+": "+err.Error(), w.errorLogInterval)
+		if !log {
+			return
+		}
+		if suppressed > 0 {
+			fields = append(fields, zap.Int("suppressed", suppressed))
+		}
+	}
+
+	fields = append([]zap.Field{zap.Int64("sequence_id", sequenceID)}, fields...)
+	w.logger.Error(msg, append(fields, zap.Error(err))...)
+}
+
+// logNodeError logs the node error occurred processing the sequence and returns its class
+func (w *workerImpl) logNodeError(sequenceID int64, msg string, wavesErr node.Error, fields ...zap.Field) ErrorWithReason {
+	err := w.errorClassOverrides.Classify(wavesErr)
+	w.logError(sequenceID, msg, err, fields...)
+	return err
+}
@@ -0,0 +1,39 @@
+package worker
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics collects statistics of txs processed by workers
+type Metrics struct {
+	confirmationBlocks prometheus.Histogram
+}
+
+// NewMetrics returns Metrics registered in registerer
+// registerer is optional, metrics are collected but not exposed if it is nil
+func NewMetrics(registerer prometheus.Registerer) (*Metrics, error) {
+	m := &Metrics{
+		confirmationBlocks: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "worker_tx_confirmation_blocks",
+			Help:    "Count of blocks between the node height at the tx broadcast and the tx height.",
+			Buckets: []float64{0, 1, 2, 3, 5, 10, 20, 50},
+		}),
+	}
+
+	if registerer != nil {
+		if err := registerer.Register(m.confirmationBlocks); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// observeConfirmation records count of blocks the tx broadcasted at broadcastHeight took to be confirmed at height
+// nothing is recorded if metrics are not set or the broadcast height is unknown
+func (m *Metrics) observeConfirmation(broadcastHeight, height int32) {
+	if m == nil || broadcastHeight <= 0 || height < broadcastHeight {
+		return
+	}
+	m.confirmationBlocks.Observe(float64(height - broadcastHeight))
+}
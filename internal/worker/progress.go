@@ -0,0 +1,90 @@
+package worker
+
+import (
+	"sync"
+	"time"
+
+	"github.com/wavesplatform/transaction-broadcaster/internal/repository"
+)
+
+// ProgressTracker remembers when txs of sequences reached new states last time and the node height seen after it
+// a tx going round the same states, e.g. rebroadcasted after the node dropped it, does not make progress
+// workers are recreated on every retry of the sequence, so the tracker is owned by their dispatcher,
+// which forgets the sequence when it is not processed by the instance anymore
+type ProgressTracker struct {
+	mutex    sync.Mutex
+	progress map[int64]sequenceProgress
+}
+
+type sequenceProgress struct {
+	at time.Time
+	// height is the first node height seen after the progress, 0 means it is not seen yet
+	height int32
+	// reached are the states txs have been in
+	reached map[txState]bool
+}
+
+type txState struct {
+	position int16
+	state    repository.TransactionState
+}
+
+// NewProgressTracker returns empty ProgressTracker
+func NewProgressTracker() *ProgressTracker {
+	return &ProgressTracker{progress: make(map[int64]sequenceProgress)}
+}
+
+// reached records the new state of the tx, it is the progress of the sequence if the tx has not been in the state before
+func (t *ProgressTracker) reached(sequenceID int64, position int16, state repository.TransactionState) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	p, ok := t.progress[sequenceID]
+	if !ok {
+		p.reached = make(map[txState]bool)
+	}
+
+	key := txState{position: position, state: state}
+	if p.reached[key] {
+		return
+	}
+	p.reached[key] = true
+	p.at = time.Now()
+	p.height = 0
+	t.progress[sequenceID] = p
+}
+
+// stalled returns whether the sequence has not made progress for timeout while the node produced blocks
+// the sequence seen for the first time is considered making progress since now
+func (t *ProgressTracker) stalled(sequenceID int64, height int32, timeout time.Duration) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	p, ok := t.progress[sequenceID]
+	if !ok {
+		p.at = time.Now()
+		p.reached = make(map[txState]bool)
+	}
+	if p.height == 0 {
+		p.height = height
+		t.progress[sequenceID] = p
+	}
+
+	return time.Since(p.at) >= timeout && height > p.height
+}
+
+// Forget drops the progress of the sequence the instance does not process anymore
+func (t *ProgressTracker) Forget(sequenceID int64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	delete(t.progress, sequenceID)
+}
+
+// size returns count of the tracked sequences
+func (t *ProgressTracker) size() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return len(t.progress)
+}
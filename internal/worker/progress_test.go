@@ -0,0 +1,37 @@
+package worker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/wavesplatform/transaction-broadcaster/internal/repository"
+)
+
+func TestProgressTracker(t *testing.T) {
+	tracker := NewProgressTracker()
+
+	// the sequence seen for the first time is making progress since now
+	require.False(t, tracker.stalled(1, 10, 0))
+	require.True(t, tracker.stalled(1, 11, 0))
+	require.False(t, tracker.stalled(1, 11, time.Hour))
+
+	tracker.reached(1, 0, repository.TransactionStateProcessing)
+	require.False(t, tracker.stalled(1, 12, 0))
+	require.True(t, tracker.stalled(1, 13, 0))
+
+	// the state reached again is not a progress
+	tracker.reached(1, 0, repository.TransactionStateProcessing)
+	require.True(t, tracker.stalled(1, 14, 0))
+
+	tracker.reached(1, 0, repository.TransactionStateValidated)
+	require.False(t, tracker.stalled(1, 14, 0))
+
+	tracker.reached(2, 0, repository.TransactionStateProcessing)
+	require.Equal(t, 2, tracker.size())
+
+	tracker.Forget(1)
+	tracker.Forget(2)
+	require.Equal(t, 0, tracker.size())
+}
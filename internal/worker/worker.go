@@ -1,22 +1,25 @@
 package worker
 
 import (
-	"encoding/json"
+	"context"
+	"fmt"
 	"regexp"
 	"time"
 
 	"github.com/wavesplatform/transaction-broadcaster/internal/log"
 	"github.com/wavesplatform/transaction-broadcaster/internal/node"
 	"github.com/wavesplatform/transaction-broadcaster/internal/repository"
+	"github.com/wavesplatform/transaction-broadcaster/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
 var transactionTimestampErrorRE = regexp.MustCompile("Transaction timestamp \\d+ is more than \\d+ms")
-var transactionDuplicateErrorRE = regexp.MustCompile("State check failed. Reason: Transaction (\\w+) is already in the state on a height of \\d+")
 
-type txWithTimestamp struct {
-	Timestamp int64 `json:"timestamp"`
-}
+// blockTimeEstimationDepth is count of the last blocks average block time is estimated by
+const blockTimeEstimationDepth = 10
 
 // Worker represents worker interface
 type Worker interface {
@@ -24,43 +27,227 @@ type Worker interface {
 }
 
 type workerImpl struct {
+	owner                  string
 	repo                   repository.Repository
 	nodeInteractor         node.Interactor
+	sequenceOptions        repository.SequenceOptions
 	logger                 *zap.Logger
 	txProcessingTTL        time.Duration
 	heightsAfterLastTx     int32
 	waitForNextHeightDelay time.Duration
 	txOutdateTime          time.Duration
+	minConfirmations       int32
+
+	txConfirmationTimeoutHeights int32
+	txConfirmationTimeoutByType  TxTypeTimeouts
+
+	flagTxIDMismatch bool
+	useTestBroadcast bool
+
+	batchStatusPolling bool
+
+	duplicateAsConfirmed bool
+
+	adoptDuplicateBroadcasts bool
+
+	// min interval between broadcasts of the sequence txs and time of the last broadcast
+	broadcastInterval time.Duration
+	lastBroadcast     time.Time
+	ctx               context.Context
+
+	errorClassOverrides ErrorClassOverrides
+
+	persistValidationTraces   bool
+	captureValidationWarnings bool
+
+	confirmationMode ConfirmationMode
+
+	// whether validate only sequences validate the rest of txs after an invalid one
+	validateOnlyContinue bool
+
+	errorLogInterval time.Duration
+
+	// txCallbacks posts confirmed txs to the per-tx callback url of the sequence, it is nil if the url is not set
+	txCallbacks *txCallbackSender
+
+	metrics *Metrics
+
+	txNotFoundChecks int32
+	// notFoundChecks is count of consecutive checks the confirmed tx was not found in
+	notFoundChecks map[string]int32
+
+	// validated txs are validated again before the broadcast if they were validated more than revalidateAfter ago
+	revalidateAfter time.Duration
+	validatedAt     map[int16]time.Time
+
+	// confirmed txs deeper than reorgWindow heights are not checked for availability, 0 means all txs are checked
+	reorgWindow int32
+
+	trackBlockSignatures bool
+	// blockSignatures are ids of the blocks at heights of confirmed txs seen during the run
+	blockSignatures map[int32]string
+
+	// sequences without progress for deadlockTimeout are failed, 0 means they are not
+	deadlockTimeout time.Duration
+	progressTracker *ProgressTracker
+
+	// state writes failed with transient db errors are retried dbWriteRetries times
+	dbWriteRetries       int32
+	dbWriteRetryInterval time.Duration
+
+	// retries made and time spent waiting for them during the run, they are limited by the retry budget
+	retryBudget     int32
+	retryBudgetTime time.Duration
+	retries         int32
+	retriesTime     time.Duration
+
+	// parsedTxs are the sequence txs parsed once during the run by their positions
+	parsedTxs map[int16]*node.Tx
+	// computedTxIDs are ids the node computed for the validated txs, they identify txs without the submitted id
+	computedTxIDs map[int16]string
+
+	// txs of the run and the saved sequence progress derived from them, see saveProgress
+	txs      []*repository.SequenceTx
+	progress int16
 }
 
 // New returns instance of Worker interface implementation
-func New(workerID string, repo repository.Repository, nodeInteractor node.Interactor, txOutdateTime, txProcessingTTL, heightsAfterLastTx, waitForNextHeightDelay int32) Worker {
+// metrics is optional, processed txs are not measured if it is nil
+// owner is the dispatcher instance the sequence is processed by, without it only the sequence state is checked before broadcasts
+// progressTracker is optional, without it the sequence progress is tracked during the run only
+func New(workerID, owner string, repo repository.Repository, nodeInteractor node.Interactor, sequenceOptions repository.SequenceOptions, cfg Config, metrics *Metrics, progressTracker *ProgressTracker) Worker {
 	logger := log.Logger.Named("worker-" + workerID)
 
+	if progressTracker == nil {
+		progressTracker = NewProgressTracker()
+	}
+
+	broadcastInterval := time.Duration(cfg.BroadcastInterval) * time.Millisecond
+	if sequenceOptions.BroadcastInterval > 0 {
+		broadcastInterval = time.Duration(sequenceOptions.BroadcastInterval) * time.Millisecond
+	}
+
 	return &workerImpl{
 		logger:                 logger,
+		owner:                  owner,
 		repo:                   repo,
 		nodeInteractor:         nodeInteractor,
-		txProcessingTTL:        time.Duration(txProcessingTTL) * time.Millisecond,
-		heightsAfterLastTx:     heightsAfterLastTx,
-		waitForNextHeightDelay: time.Duration(waitForNextHeightDelay) * time.Millisecond,
-		txOutdateTime:          time.Duration(txOutdateTime) * time.Millisecond,
+		sequenceOptions:        sequenceOptions,
+		txProcessingTTL:        time.Duration(cfg.TxProcessingTTL) * time.Millisecond,
+		heightsAfterLastTx:     cfg.HeightsAfterLastTx,
+		waitForNextHeightDelay: time.Duration(cfg.WaitForNextHeightDelay) * time.Millisecond,
+		txOutdateTime:          time.Duration(cfg.TxOutdateTime) * time.Millisecond,
+		minConfirmations:       cfg.MinConfirmations,
+
+		txConfirmationTimeoutHeights: cfg.TxConfirmationTimeoutHeights,
+		txConfirmationTimeoutByType:  cfg.TxConfirmationTimeoutByType,
+
+		flagTxIDMismatch: cfg.FlagTxIDMismatch,
+		useTestBroadcast: cfg.UseTestBroadcast,
+
+		batchStatusPolling: cfg.BatchStatusPolling,
+
+		duplicateAsConfirmed: cfg.DuplicateAsConfirmed,
+
+		adoptDuplicateBroadcasts: cfg.AdoptDuplicateBroadcasts,
+
+		broadcastInterval: broadcastInterval,
+		ctx:               context.Background(),
+
+		errorClassOverrides: cfg.NodeErrorClassOverrides,
+
+		persistValidationTraces:   cfg.PersistValidationTraces,
+		captureValidationWarnings: cfg.CaptureValidationWarnings,
+
+		confirmationMode: cfg.ConfirmationMode,
+
+		errorLogInterval: time.Duration(cfg.ErrorLogInterval) * time.Millisecond,
+
+		validateOnlyContinue: cfg.ValidateOnlyContinue,
+
+		txCallbacks: newTxCallbackSender(sequenceOptions.PerTxCallbackURL, cfg, logger),
+
+		metrics: metrics,
+
+		txNotFoundChecks: cfg.TxNotFoundChecks,
+		notFoundChecks:   make(map[string]int32),
+
+		revalidateAfter: time.Duration(cfg.RevalidateAfter) * time.Millisecond,
+		validatedAt:     make(map[int16]time.Time),
+
+		reorgWindow: cfg.ReorgWindow,
+
+		trackBlockSignatures: cfg.TrackBlockSignatures,
+		blockSignatures:      make(map[int32]string),
+
+		deadlockTimeout: time.Duration(cfg.DeadlockTimeout) * time.Millisecond,
+		progressTracker: progressTracker,
+
+		dbWriteRetries:       cfg.DBWriteRetries,
+		dbWriteRetryInterval: time.Duration(cfg.DBWriteRetryInterval) * time.Millisecond,
+
+		retryBudget:     cfg.RetryBudget,
+		retryBudgetTime: time.Duration(cfg.RetryBudgetTime) * time.Millisecond,
+
+		parsedTxs:     make(map[int16]*node.Tx),
+		computedTxIDs: make(map[int16]string),
 	}
 }
 
 // Run starts the worker processing sequenceID
+// processing is traced as a child of the sequence creation span, node calls are children of the processing span
 func (w *workerImpl) Run(sequenceID int64) ErrorWithReason {
+	ctx := tracing.ContextWithTraceParent(context.Background(), w.sequenceOptions.TraceParent)
+	ctx, span := tracing.Tracer.Start(ctx, "worker.Run", trace.WithAttributes(attribute.Int64("sequence_id", sequenceID)))
+	defer span.End()
+
+	w.nodeInteractor = w.nodeInteractor.WithContext(ctx)
+	w.ctx = ctx
+
+	err := w.run(sequenceID)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	return err
+}
+
+func (w *workerImpl) run(sequenceID int64) ErrorWithReason {
 	w.logger.Debug("start processing sequence", zap.Int64("sequence_id", sequenceID))
 
-	txs, err := w.repo.GetSequenceTxsByID(sequenceID)
+	txs, err := w.sequenceTxsWindow(sequenceID)
 	if err != nil {
-		w.logger.Error("error occurred while getting sequence txs", zap.Int64("sequence_id", sequenceID), zap.Error(err))
-		return NewFatalError(err.Error())
+		return err
+	}
+
+	// retries of a sequence whose tx is still under processing do not check availability of the confirmed txs
+	if nextTx := nextUnconfirmedTx(txs); nextTx != nil && nextTx.State == repository.TransactionStateProcessing && time.Now().Sub(nextTx.UpdatedAt) < w.txProcessingTTL {
+		w.logger.Debug("tx is under processing, processing ttl is not over", zap.Int64("sequence_id", sequenceID), zap.Int16("position_in_sequence", nextTx.PositionInSequence))
+		return NewRecoverableError("error occured while processing tx: tx is under processing, processing TTL is not over")
 	}
 
+	w.txs = txs
+	w.progress = confirmedPrefix(txs)
+
 	w.logger.Debug("going to process txs", zap.Int("txs_count", len(txs)))
 
+	if w.sequenceOptions.Mode == repository.SequenceModeIndependent {
+		// will mutate txs - sets states and IDs of the broadcasted ones
+		if err := w.broadcastPendingTxs(sequenceID, txs); err != nil {
+			w.logError(sequenceID, "error occured while broadcasting pending txs", err)
+			return err
+		}
+
+		// will mutate txs - sets states and heights of the confirmed ones
+		if err := w.confirmUnconfirmedTxs(sequenceID, txs); err != nil {
+			w.logError(sequenceID, "error occured while waiting for txs confirmation", err)
+			return err
+		}
+	}
+
 	var confirmedTxs = make(map[string]*repository.SequenceTx)
+	// invalid txs of the validate only sequence validating all txs
+	var invalidTxsCount int
 
 	for _, tx := range txs {
 		if tx.State == repository.TransactionStateConfirmed {
@@ -69,12 +256,7 @@ func (w *workerImpl) Run(sequenceID int64) ErrorWithReason {
 		}
 
 		if len(confirmedTxs) > 0 {
-			var confirmedTxIDs []string
-			for txID := range confirmedTxs {
-				confirmedTxIDs = append(confirmedTxIDs, txID)
-			}
-
-			if err := w.checkTxsAvailability(sequenceID, confirmedTxIDs); err != nil {
+			if err := w.checkTxsAvailability(sequenceID, confirmedTxs); err != nil {
 				return err
 			}
 		}
@@ -89,90 +271,193 @@ func (w *workerImpl) Run(sequenceID int64) ErrorWithReason {
 		case repository.TransactionStatePending, repository.TransactionStateValidated, repository.TransactionStateUnconfirmed:
 			// will mutate tx - sets ID and height
 			if err := w.processTx(tx); err != nil {
-				w.logger.Error("error occured while processing tx", zap.Int64("sequence_id", sequenceID), zap.Int16("position_in_sequence", tx.PositionInSequence), zap.Error(err))
+				if w.continuesValidation() && tx.State == repository.TransactionStateError {
+					invalidTxsCount++
+					continue
+				}
+				w.logError(sequenceID, "error occured while processing tx", err, zap.Int16("position_in_sequence", tx.PositionInSequence))
 				return err
 			}
+			if w.sequenceOptions.Mode == repository.SequenceModeValidateOnly || tx.State == repository.TransactionStateSkipped {
+				continue
+			}
 			confirmedTxs[tx.ID] = tx
 		case repository.TransactionStateError:
+			if w.continuesValidation() {
+				invalidTxsCount++
+				continue
+			}
 			return NewNonRecoverableError(tx.ErrorMessage, 0)
+		case repository.TransactionStateSkipped:
+			w.logger.Debug("tx is skipped", zap.Int64("sequence_id", sequenceID), zap.Int16("position_in_sequence", tx.PositionInSequence))
 		}
 	}
 
+	// all txs are validated, nothing was broadcasted, so there is nothing to wait for
+	if w.sequenceOptions.Mode == repository.SequenceModeValidateOnly {
+		if invalidTxsCount > 0 {
+			return NewNonRecoverableError(fmt.Sprintf("%d of %d txs are invalid", invalidTxsCount, len(txs)), InvalidTxsErrorCode)
+		}
+		w.logger.Debug("all sequence txs are valid", zap.Int64("sequence_id", sequenceID), zap.Int("txs_count", len(txs)))
+		return nil
+	}
+
+	// nothing was confirmed, so there is no height to wait for and the sequence cannot be considered done
+	if len(confirmedTxs) == 0 {
+		w.logger.Debug("sequence has no confirmed txs", zap.Int64("sequence_id", sequenceID), zap.Int("txs_count", len(txs)))
+		return NewNonRecoverableError("there are no confirmed txs in the sequence", NoConfirmedTxsErrorCode)
+	}
+
+	// all txs are confirmed or skipped here, so the last confirmed one is the last not skipped one
+	targetTxs := confirmedTxs
+	if w.confirmationMode == ConfirmationModeLastTx {
+		lastTx := lastConfirmedTx(txs)
+		targetTxs = map[string]*repository.SequenceTx{lastTx.ID: lastTx}
+	}
+
 	startHeight := int32(0)
-	var confirmedTxIDs []string
-	for txID, tx := range confirmedTxs {
+	for _, tx := range targetTxs {
 		if startHeight < tx.Height {
 			startHeight = tx.Height
 		}
-		confirmedTxIDs = append(confirmedTxIDs, txID)
 	}
 
 	targetHeight := startHeight + w.heightsAfterLastTx
 
-	if err := w.waitForTargetHeight(targetHeight, sequenceID, confirmedTxIDs); err != nil {
+	if err := w.waitForTargetHeight(targetHeight, sequenceID, targetTxs); err != nil {
 		return err
 	}
 
 	return nil
 }
 
+// sequenceTxsWindow reads txs of the sequence except the leading ones buried deeper than the reorg window
+// the availability of such txs is not checked anyway, see txsWithinReorgWindow
+func (w *workerImpl) sequenceTxsWindow(sequenceID int64) ([]*repository.SequenceTx, ErrorWithReason) {
+	window := repository.TxsWindow{
+		Depth:            w.reorgWindow,
+		MinConfirmations: w.requiredConfirmations(nil),
+	}
+
+	if window.Depth > 0 {
+		currentHeight, wavesErr := w.nodeInteractor.GetCurrentHeight()
+		if wavesErr != nil {
+			return nil, w.logNodeError(sequenceID, "error occurred while getting current height", wavesErr)
+		}
+		window.CurrentHeight = currentHeight
+	}
+
+	txs, err := w.repo.GetSequenceTxsWindow(sequenceID, window)
+	if err != nil {
+		w.logger.Error("error occurred while getting sequence txs", zap.Int64("sequence_id", sequenceID), zap.Error(err))
+		return nil, NewDBError(err)
+	}
+
+	return txs, nil
+}
+
+// nextUnconfirmedTx returns the first not confirmed and not skipped tx, nil if there is no one
+func nextUnconfirmedTx(txs []*repository.SequenceTx) *repository.SequenceTx {
+	for _, tx := range txs {
+		if tx.State != repository.TransactionStateConfirmed && tx.State != repository.TransactionStateSkipped {
+			return tx
+		}
+	}
+	return nil
+}
+
 // notes: mutate tx - sets State, ID and height
 func (w *workerImpl) processTx(tx *repository.SequenceTx) ErrorWithReason {
+	// the previous run may have broadcasted the tx and stopped before its id was saved
+	if tx.ID == "" && (tx.State == repository.TransactionStateValidated || tx.State == repository.TransactionStateUnconfirmed) && w.sequenceOptions.Mode != repository.SequenceModeValidateOnly {
+		if err := w.resumeUnsavedBroadcast(tx); err != nil {
+			return err
+		}
+	}
+
 	switch tx.State {
-	case repository.TransactionStatePending:
-		w.logger.Debug("process tx", zap.Int64("sequence_id", tx.SequenceID), zap.Int16("position_in_sequence", tx.PositionInSequence))
+	case repository.TransactionStatePending, repository.TransactionStateProcessing:
+		if err := w.prepareTx(tx); err != nil {
+			return err
+		}
 
-		if err := w.repo.SetSequenceTxState(tx.SequenceID, tx.PositionInSequence, repository.TransactionStateProcessing); err != nil {
-			return NewFatalError(err.Error())
+		// the tx was skipped before the worker took it
+		if tx.State == repository.TransactionStateSkipped {
+			return nil
 		}
-		tx.State = repository.TransactionStateProcessing
 
 		fallthrough
-	case repository.TransactionStateProcessing:
-		w.logger.Debug("validate tx", zap.Int64("sequence_id", tx.SequenceID), zap.Int16("position_in_sequence", tx.PositionInSequence))
+	case repository.TransactionStateValidated:
+		// validated is the final state of txs of validate only sequences, the next tx is validated against the same state
+		if w.sequenceOptions.Mode == repository.SequenceModeValidateOnly {
+			w.logger.Debug("tx is valid, validate only sequence", zap.Int64("sequence_id", tx.SequenceID), zap.Int16("position_in_sequence", tx.PositionInSequence))
+			return nil
+		}
 
-		if err := w.validateTx(tx); err != nil {
+		if deadline := w.sequenceOptions.Deadline; !deadline.IsZero() && time.Now().After(deadline) {
+			w.logger.Debug("sequence deadline exceeded", zap.Int64("sequence_id", tx.SequenceID), zap.Int16("position_in_sequence", tx.PositionInSequence), zap.Time("deadline", deadline))
+			return NewNonRecoverableError("deadline exceeded", DeadlineExceededErrorCode)
+		}
+
+		w.logger.Debug("broadcast tx", zap.Int64("sequence_id", tx.SequenceID), zap.Int16("position_in_sequence", tx.PositionInSequence))
+
+		if err := w.paceBroadcast(); err != nil {
 			return err
 		}
 
-		if err := w.repo.SetSequenceTxState(tx.SequenceID, tx.PositionInSequence, repository.TransactionStateValidated); err != nil {
-			return NewFatalError(err.Error())
+		if err := w.refreshSequence(tx.SequenceID); err != nil {
+			return err
 		}
-		tx.State = repository.TransactionStateValidated
 
-		fallthrough
-	case repository.TransactionStateValidated:
-		w.logger.Debug("broadcast tx", zap.Int64("sequence_id", tx.SequenceID), zap.Int16("position_in_sequence", tx.PositionInSequence))
+		// the state may have changed while the tx was waiting, e.g. its timestamp may have become too old
+		if w.isValidationStale(tx) {
+			w.logger.Debug("tx validation is stale, validate tx again", zap.Int64("sequence_id", tx.SequenceID), zap.Int16("position_in_sequence", tx.PositionInSequence))
+
+			if err := w.validateTx(tx); err != nil {
+				return err
+			}
+		}
 
 		// will mutate tx - sets ID
-		if err := w.broadcastTx(tx); err != nil {
+		duplicateHeight, err := w.broadcastTx(tx)
+		if err != nil {
 			return err
 		}
 
-		if err := w.repo.SetSequenceTxState(tx.SequenceID, tx.PositionInSequence, repository.TransactionStateUnconfirmed); err != nil {
-			return NewFatalError(err.Error())
+		// tx is already in the blockchain, there is nothing to wait for
+		if duplicateHeight > 0 {
+			if err := w.setTxConfirmed(tx, duplicateHeight); err != nil {
+				return err
+			}
+			w.logger.Debug("tx is already in the blockchain", zap.Int64("sequence_id", tx.SequenceID), zap.Int16("position_in_sequence", tx.PositionInSequence), zap.String("tx_id", tx.ID), zap.Int32("height", duplicateHeight))
+			return nil
+		}
+
+		if err := w.setTxState(tx, repository.TransactionStateUnconfirmed); err != nil {
+			return err
 		}
-		tx.State = repository.TransactionStateUnconfirmed
 
 		fallthrough
 	case repository.TransactionStateUnconfirmed:
 		w.logger.Debug("wait for tx confirmation", zap.Int64("sequence_id", tx.SequenceID), zap.Int16("position_in_sequence", tx.PositionInSequence), zap.String("tx_id", tx.ID))
 
-		height, err := w.waitForTxConfirmation(tx.ID)
+		height, err := w.waitForTxConfirmation(tx)
 		if err != nil {
 			if err.Code() == node.TxNotFoundError {
-				if err := w.repo.SetSequenceTxState(tx.SequenceID, tx.PositionInSequence, repository.TransactionStatePending); err != nil {
-					return NewFatalError(err.Error())
+				if err := w.setTxState(tx, repository.TransactionStatePending); err != nil {
+					return err
+				}
+				// the tx rebroadcasted again and again does not make progress
+				if err := w.checkProgress(tx.SequenceID); err != nil {
+					return err
 				}
 			}
-			return NewRecoverableError(err.Error())
+			return w.errorClassOverrides.Classify(err)
 		}
 
-		if err := w.repo.SetSequenceTxConfirmedState(tx.SequenceID, tx.PositionInSequence, height); err != nil {
-			return NewFatalError(err.Error())
+		if err := w.setTxConfirmed(tx, height); err != nil {
+			return err
 		}
-		tx.State = repository.TransactionStateConfirmed
-		tx.Height = height
 
 		fallthrough
 	case repository.TransactionStateConfirmed:
@@ -183,199 +468,1044 @@ func (w *workerImpl) processTx(tx *repository.SequenceTx) ErrorWithReason {
 	}
 }
 
-func (w *workerImpl) validateTx(tx *repository.SequenceTx) ErrorWithReason {
-	validationResult, wavesErr := w.nodeInteractor.ValidateTx(tx.Tx)
-	if wavesErr != nil {
-		w.logger.Error("error occurred while validating tx", zap.Int64("sequence_id", tx.SequenceID), zap.Int16("position_in_sequence", tx.PositionInSequence), zap.Error(wavesErr))
-		return NewRecoverableError(wavesErr.Error())
+// broadcastPendingTxs validates pending txs of the independent sequence and broadcasts them by a single node call
+// rejected txs get the node error message, non-recoverable rejections move them to the error state and the first one is returned
+// txs left validated (recoverable rejections or the whole batch failure) are broadcasted one by one afterwards
+// mutate txs
+func (w *workerImpl) broadcastPendingTxs(sequenceID int64, txs []*repository.SequenceTx) ErrorWithReason {
+	var batch []*repository.SequenceTx
+	for _, tx := range txs {
+		if tx.State != repository.TransactionStatePending {
+			continue
+		}
+		if err := w.prepareTx(tx); err != nil {
+			return err
+		}
+		if tx.State == repository.TransactionStateSkipped {
+			continue
+		}
+		batch = append(batch, tx)
 	}
 
-	if !validationResult.IsValid {
-		w.logger.Debug("invalid tx", zap.Int64("sequence_id", tx.SequenceID), zap.Int16("position_in_sequence", tx.PositionInSequence))
+	if len(batch) == 0 {
+		return nil
+	}
 
-		// check whether error is about transaction duplicate
-		matches := transactionDuplicateErrorRE.FindStringSubmatch(validationResult.ErrorMessage)
-		if len(matches) > 1 {
-			// transaction is already in the blockchain
-			return nil
-		}
+	// expired sequence is failed by the one by one broadcast
+	if deadline := w.sequenceOptions.Deadline; !deadline.IsZero() && time.Now().After(deadline) {
+		return nil
+	}
 
-		// check whether error is about transaction timestamp
-		isTimestampError := transactionTimestampErrorRE.MatchString(validationResult.ErrorMessage)
+	if err := w.paceBroadcast(); err != nil {
+		return err
+	}
+
+	if err := w.refreshSequence(sequenceID); err != nil {
+		return err
+	}
+
+	rawTxs := make([]string, len(batch))
+	for i, tx := range batch {
+		rawTxs[i] = tx.Tx
+	}
+
+	w.logger.Debug("broadcast txs at once", zap.Int64("sequence_id", sequenceID), zap.Int("txs_count", len(batch)))
+
+	broadcastHeight := w.broadcastHeight(sequenceID)
+
+	results, wavesErr := w.nodeInteractor.BroadcastTxs(rawTxs)
+	if wavesErr != nil {
+		w.logger.Warn("txs cannot be broadcasted at once, they are broadcasted one by one", zap.Int64("sequence_id", sequenceID), zap.Error(wavesErr))
+		return nil
+	}
 
-		isOutdated, err := w.isTxOutdated(tx.Tx)
+	var rejectionErr ErrorWithReason
+	for i, tx := range batch {
+		// will mutate tx - sets ID
+		duplicateHeight, err := w.setTxBroadcasted(tx, results[i].ID, results[i].Err, broadcastHeight)
 		if err != nil {
-			return NewNonRecoverableError(err.Error(), 0)
-		}
+			// errors of accepted txs are the ones of the db writes, they are not rejections of the txs
+			if _, ok := err.(FatalError); ok || !isRejection(results[i].Err) {
+				return err
+			}
 
-		if isOutdated {
-			w.logger.Debug("tx is outdated (local check)", zap.Int64("sequence_id", tx.SequenceID), zap.Int16("position_in_sequence", tx.PositionInSequence))
-		}
+			if err := w.persist(func() error {
+				return w.repo.SetSequenceTxErrorMessage(tx.SequenceID, tx.PositionInSequence, results[i].Err.Error())
+			}); err != nil {
+				return err
+			}
+			tx.ErrorMessage = results[i].Err.Error()
 
-		// write error message only if it was not set already
-		// otherwise root error will be overwritten by timestamp error
-		if len(tx.ErrorMessage) == 0 {
-			if err := w.repo.SetSequenceTxErrorMessage(tx.SequenceID, tx.PositionInSequence, validationResult.ErrorMessage); err != nil {
-				w.logger.Error("error occured while setting tx error message", zap.Int64("sequence_id", tx.SequenceID), zap.Int16("position_in_sequence", tx.PositionInSequence), zap.String("error_message", validationResult.ErrorMessage), zap.Error(err))
-				return NewFatalError(err.Error())
+			if _, ok := err.(RecoverableError); ok {
+				continue
 			}
-			tx.ErrorMessage = validationResult.ErrorMessage
-		}
 
-		if !isTimestampError && !isOutdated {
-			if err := w.nodeInteractor.WaitForNextHeight(); err != nil {
-				return NewRecoverableError(err.Error())
+			if err := w.setTxState(tx, repository.TransactionStateError); err != nil {
+				return err
+			}
+			if rejectionErr == nil {
+				rejectionErr = err
 			}
+			continue
+		}
 
-			return w.validateTx(tx)
+		if duplicateHeight > 0 {
+			if err := w.setTxConfirmed(tx, duplicateHeight); err != nil {
+				return err
+			}
+			continue
 		}
 
-		if err := w.repo.SetSequenceTxState(tx.SequenceID, tx.PositionInSequence, repository.TransactionStateError); err != nil {
-			w.logger.Error("error occured while setting tx error state", zap.Int64("sequence_id", tx.SequenceID), zap.Int16("position_in_sequence", tx.PositionInSequence), zap.Error(err))
-			return NewFatalError(err.Error())
+		if err := w.setTxState(tx, repository.TransactionStateUnconfirmed); err != nil {
+			return err
 		}
+	}
 
-		errorMessage := validationResult.ErrorMessage
-		if len(tx.ErrorMessage) > 0 {
-			errorMessage = tx.ErrorMessage
+	return rejectionErr
+}
+
+// confirmUnconfirmedTxs waits for confirmation of all unconfirmed txs of the independent sequence at once
+// txs confirmed before the wait failed are saved, the rest are waited for one by one, so not found ones are handled per tx
+// mutate txs
+func (w *workerImpl) confirmUnconfirmedTxs(sequenceID int64, txs []*repository.SequenceTx) ErrorWithReason {
+	var unconfirmed []*repository.SequenceTx
+	for _, tx := range txs {
+		if tx.State == repository.TransactionStateUnconfirmed && tx.ID != "" {
+			unconfirmed = append(unconfirmed, tx)
 		}
+	}
 
-		return NewNonRecoverableError(errorMessage, 0)
+	// a single tx is waited for as usual
+	if len(unconfirmed) < 2 {
+		return nil
 	}
 
-	// tx is valid, reset error message that may have been set
-	if err := w.repo.ResetSequenceTxErrorMessage(tx.SequenceID, tx.PositionInSequence); err != nil {
-		w.logger.Error("error occured while resetting tx error message after its validating", zap.Int64("sequence_id", tx.SequenceID), zap.Int16("position_in_sequence", tx.PositionInSequence), zap.Error(err))
-		return NewFatalError(err.Error())
+	w.logger.Debug("wait for txs confirmation", zap.Int64("sequence_id", sequenceID), zap.Int("txs_count", len(unconfirmed)))
+
+	heights, wavesErr := w.waitForTxsConfirmation(unconfirmed)
+
+	for _, tx := range unconfirmed {
+		if height, ok := heights[tx.ID]; ok {
+			if err := w.setTxConfirmed(tx, height); err != nil {
+				return err
+			}
+		}
+	}
+
+	if wavesErr != nil && wavesErr.Code() != node.TxNotFoundError {
+		return w.errorClassOverrides.Classify(wavesErr)
 	}
-	tx.ErrorMessage = ""
 
 	return nil
 }
 
-// broadcastTx broadcasts transaction to the blockhain
-// whether transaction successfully broadcasted, its sets tx.ID to retrieved txID
+// prepareTx validates the pending or processing tx and moves it to the validated state
 // mutate tx
-func (w *workerImpl) broadcastTx(tx *repository.SequenceTx) ErrorWithReason {
-	txID, wavesErr := w.nodeInteractor.BroadcastTx(tx.Tx)
+func (w *workerImpl) prepareTx(tx *repository.SequenceTx) ErrorWithReason {
+	if tx.State == repository.TransactionStatePending {
+		w.logger.Debug("process tx", zap.Int64("sequence_id", tx.SequenceID), zap.Int16("position_in_sequence", tx.PositionInSequence))
 
-	if wavesErr != nil {
-		// check whether error is about transaction duplicate
-		matches := transactionDuplicateErrorRE.FindStringSubmatch(wavesErr.Error())
-		if len(matches) > 1 {
-			// transaction is already in the blockchain
-			txID = matches[1]
-		} else {
-			w.logger.Error("error occurred while broadcasting tx", zap.Int64("sequence_id", tx.SequenceID), zap.Int16("position_in_sequence", tx.PositionInSequence), zap.Error(wavesErr))
+		if err := w.setTxState(tx, repository.TransactionStateProcessing); err != nil {
+			return err
+		}
+		// txs are skipped only while they are pending, so the processing tx is never skipped later
+		if tx.State == repository.TransactionStateSkipped {
+			return nil
+		}
+	}
 
-			if wavesErr.Code() == node.BroadcastClientError {
-				return NewNonRecoverableError(wavesErr.Error(), wavesErr.NodeErrorCode())
-			}
+	if w.sequenceOptions.SkipValidation {
+		w.logger.Debug("skip tx validation", zap.Int64("sequence_id", tx.SequenceID), zap.Int16("position_in_sequence", tx.PositionInSequence))
+	} else {
+		w.logger.Debug("validate tx", zap.Int64("sequence_id", tx.SequenceID), zap.Int16("position_in_sequence", tx.PositionInSequence))
 
-			return NewRecoverableError(wavesErr.Error())
+		if err := w.validateTx(tx); err != nil {
+			return err
 		}
 	}
 
-	// tx was broadcasted, reset error message that may have been set
-	if err := w.repo.ResetSequenceTxErrorMessage(tx.SequenceID, tx.PositionInSequence); err != nil {
-		w.logger.Error("error occured while resetting tx error message after its broadcasting", zap.Int64("sequence_id", tx.SequenceID), zap.Int16("position_in_sequence", tx.PositionInSequence), zap.Error(err))
-		return NewFatalError(err.Error())
-	}
-	tx.ErrorMessage = ""
+	return w.setTxState(tx, repository.TransactionStateValidated)
+}
 
-	if err := w.repo.SetSequenceTxID(tx.SequenceID, tx.PositionInSequence, txID); err != nil {
-		return NewFatalError(err.Error())
+// isValidationStale reports whether the validated tx has to be validated again before its broadcast
+// txs validated by the previous run are considered validated at their last update
+func (w *workerImpl) isValidationStale(tx *repository.SequenceTx) bool {
+	if w.revalidateAfter <= 0 || w.sequenceOptions.SkipValidation {
+		return false
 	}
-	tx.ID = txID
 
-	return nil
+	validatedAt, ok := w.validatedAt[tx.PositionInSequence]
+	if !ok {
+		validatedAt = tx.UpdatedAt
+	}
+	return time.Since(validatedAt) > w.revalidateAfter
 }
 
-func (w *workerImpl) waitForTxConfirmation(txID string) (int32, node.Error) {
-	height, wavesErr := w.nodeInteractor.WaitForTxStatus(txID, node.TransactionStatusConfirmed)
-	if wavesErr != nil {
-		return 0, wavesErr
+// resumeUnsavedBroadcast looks the tx up by its id, so the tx broadcasted by the previous run is not broadcasted again
+// found tx is waited for, the rest are validated again since the state may have changed after their validation
+// txs without submitted id are validated to get the id computed by the node, the tx is not looked up if the node does not return it
+// mutate tx
+func (w *workerImpl) resumeUnsavedBroadcast(tx *repository.SequenceTx) ErrorWithReason {
+	txID := w.txLookupID(tx)
+	if txID == "" {
+		validationResult, wavesErr := w.nodeInteractor.ValidateTx(tx.Tx)
+		if wavesErr != nil {
+			return w.logNodeError(tx.SequenceID, "error occurred while computing id of not saved broadcasted tx", wavesErr, zap.Int16("position_in_sequence", tx.PositionInSequence))
+		}
+		if validationResult.ID == "" {
+			return nil
+		}
+		txID = validationResult.ID
+		w.computedTxIDs[tx.PositionInSequence] = txID
 	}
-	return height, nil
-}
 
-// waitForTargetHeight waits for target height
-// and on each height checking its checks that none of confirmed txs was not pulled out from the blockchain
-func (w *workerImpl) waitForTargetHeight(targetHeight int32, seqID int64, confirmedTxIDs []string) ErrorWithReason {
-	currentHeight, wavesErr := w.nodeInteractor.GetCurrentHeight()
+	availability, wavesErr := w.nodeInteractor.GetTxsAvailability([]string{txID})
 	if wavesErr != nil {
-		w.logger.Error("error occurred while getting current height", zap.Error(wavesErr))
-		return NewRecoverableError(wavesErr.Error())
+		return w.logNodeError(tx.SequenceID, "error occurred while looking up not saved broadcasted tx", wavesErr, zap.Int16("position_in_sequence", tx.PositionInSequence))
 	}
 
-	w.logger.Debug("start waiting for target height", zap.Int("confirmed_txs_count", len(confirmedTxIDs)), zap.Int32("target_height", targetHeight), zap.Int32("current_height", currentHeight))
-
-	if currentHeight >= targetHeight {
-		return nil
-	}
+	if availability[txID].IsAvailable {
+		w.logger.Debug("tx was broadcasted by the previous run, wait for it", zap.Int64("sequence_id", tx.SequenceID), zap.Int16("position_in_sequence", tx.PositionInSequence), zap.String("tx_id", txID))
+
+		submittedTxID := w.submittedTxID(tx)
+		if err := w.persist(func() error {
+			return w.repo.SetSequenceTxID(tx.SequenceID, tx.PositionInSequence, txID, submittedTxID, 0)
+		}); err != nil {
+			return err
+		}
+		tx.ID = txID
+		tx.SubmittedID = submittedTxID
+
+		if err := w.setTxState(tx, repository.TransactionStateUnconfirmed); err != nil {
+			return err
+		}
+
+		return nil
+	}
+
+	w.logger.Debug("tx was not broadcasted by the previous run, validate it again", zap.Int64("sequence_id", tx.SequenceID), zap.Int16("position_in_sequence", tx.PositionInSequence), zap.String("tx_id", txID))
+
+	if err := w.setTxState(tx, repository.TransactionStateProcessing); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// paceBroadcast waits until the broadcast interval passes since the last broadcast of the sequence
+func (w *workerImpl) paceBroadcast() ErrorWithReason {
+	if w.broadcastInterval > 0 && !w.lastBroadcast.IsZero() {
+		if wait := w.broadcastInterval - time.Since(w.lastBroadcast); wait > 0 {
+			timer := time.NewTimer(wait)
+			defer timer.Stop()
+
+			select {
+			case <-timer.C:
+			case <-w.ctx.Done():
+				return NewRecoverableError(w.ctx.Err().Error())
+			}
+		}
+	}
+
+	w.lastBroadcast = time.Now()
+	return nil
+}
+
+// setTxState saves the new state of the tx and notifies state hooks about it
+// the tx skipped while it was processed keeps the skipped state, callers have to check it before the broadcast
+// mutate tx
+func (w *workerImpl) setTxState(tx *repository.SequenceTx, state repository.TransactionState) ErrorWithReason {
+	updated := false
+	if err := w.persist(func() (err error) {
+		updated, err = w.repo.SetSequenceTxState(tx.SequenceID, tx.PositionInSequence, state)
+		return err
+	}); err != nil {
+		return err
+	}
+	if !updated {
+		w.logger.Debug("tx is skipped", zap.Int64("sequence_id", tx.SequenceID), zap.Int16("position_in_sequence", tx.PositionInSequence))
+		tx.State = repository.TransactionStateSkipped
+		return nil
+	}
+	tx.State = state
+	notifyStateHooks(tx.SequenceID, tx.PositionInSequence, state)
+	w.progressTracker.reached(tx.SequenceID, tx.PositionInSequence, state)
+
+	return nil
+}
+
+// setTxConfirmed sets confirmed state and height of the tx and moves the sequence progress to it
+// mutate tx
+func (w *workerImpl) setTxConfirmed(tx *repository.SequenceTx, height int32) ErrorWithReason {
+	if err := w.persist(func() error {
+		return w.repo.SetSequenceTxConfirmedState(tx.SequenceID, tx.PositionInSequence, height)
+	}); err != nil {
+		return err
+	}
+	tx.State = repository.TransactionStateConfirmed
+	tx.Height = height
+	notifyStateHooks(tx.SequenceID, tx.PositionInSequence, tx.State)
+	w.progressTracker.reached(tx.SequenceID, tx.PositionInSequence, tx.State)
+	w.metrics.observeConfirmation(tx.BroadcastHeight, height)
+	w.txCallbacks.send(tx)
+
+	return w.saveProgress(tx.SequenceID)
+}
+
+// saveProgress moves the sequence progress to the last tx all txs up to which are confirmed or skipped
+// txs of independent sequences are confirmed in any order, so the progress is not the position of the last confirmed tx
+func (w *workerImpl) saveProgress(sequenceID int64) ErrorWithReason {
+	progress := confirmedPrefix(w.txs)
+	if progress == w.progress {
+		return nil
+	}
+
+	if err := w.persist(func() error {
+		return w.repo.SetSequenceProgress(sequenceID, progress)
+	}); err != nil {
+		return err
+	}
+	w.progress = progress
+
+	return nil
+}
+
+// confirmedPrefix returns position of the last tx all txs up to which are confirmed or skipped, -1 if there is no such tx
+// txs are ordered by positions, txs before the first one are confirmed, see GetSequenceTxsWindow
+func confirmedPrefix(txs []*repository.SequenceTx) int16 {
+	if nextTx := nextUnconfirmedTx(txs); nextTx != nil {
+		return nextTx.PositionInSequence - 1
+	}
+	if len(txs) == 0 {
+		return -1
+	}
+	return txs[len(txs)-1].PositionInSequence
+}
+
+// continuesValidation returns whether the validate only sequence validates the rest of txs after an invalid one
+func (w *workerImpl) continuesValidation() bool {
+	return w.validateOnlyContinue && w.sequenceOptions.Mode == repository.SequenceModeValidateOnly
+}
+
+// persist makes the state write, writes failed with transient db errors are retried
+// the write still failing after the retries is recoverable, so the sequence is processed again later
+func (w *workerImpl) persist(write func() error) ErrorWithReason {
+	for attempt := int32(0); ; attempt++ {
+		err := write()
+		if err == nil {
+			return nil
+		}
+		if !repository.IsTransientError(err) {
+			return NewDBError(err)
+		}
+		if attempt >= w.dbWriteRetries {
+			return NewRecoverableError(err.Error())
+		}
+
+		w.logger.Warn("transient db error occurred while writing the state, retrying", zap.Int32("attempt", attempt+1), zap.Error(err))
+
+		timer := time.NewTimer(w.dbWriteRetryInterval)
+		select {
+		case <-timer.C:
+		case <-w.ctx.Done():
+			timer.Stop()
+			return NewRecoverableError(w.ctx.Err().Error())
+		}
+	}
+}
+
+func (w *workerImpl) validateTx(tx *repository.SequenceTx) ErrorWithReason {
+	validate := w.nodeInteractor.ValidateTx
+	if w.useTestBroadcast {
+		validate = w.nodeInteractor.TestBroadcast
+	}
+
+	validationResult, wavesErr := validate(tx.Tx)
+	if wavesErr != nil {
+		return w.logNodeError(tx.SequenceID, "error occurred while validating tx", wavesErr, zap.Int16("position_in_sequence", tx.PositionInSequence))
+	}
+
+	if !validationResult.IsValid {
+		w.logger.Debug("invalid tx", zap.Int64("sequence_id", tx.SequenceID), zap.Int16("position_in_sequence", tx.PositionInSequence))
+
+		// check whether error is about transaction duplicate
+		if duplicateTxID, _ := node.AlreadyInState(validationResult.ErrorMessage); duplicateTxID != "" {
+			// transaction is already in the blockchain
+			return nil
+		}
+
+		// check whether error is about transaction timestamp
+		isTimestampError := transactionTimestampErrorRE.MatchString(validationResult.ErrorMessage)
+
+		// tx rejected by the account script will never become valid, unlike txs depending on the state
+		isScriptedAccountError := node.ClassifyError(validationResult.ErrorMessage) == node.ErrorClassScriptedAccount
+
+		isOutdated, err := w.isTxOutdated(tx)
+		if err != nil {
+			return NewNonRecoverableError(err.Error(), 0)
+		}
+
+		if isOutdated {
+			w.logger.Debug("tx is outdated (local check)", zap.Int64("sequence_id", tx.SequenceID), zap.Int16("position_in_sequence", tx.PositionInSequence))
+		}
+
+		// the latest trace is kept, it explains the current rejection reason
+		if w.persistValidationTraces && len(validationResult.Trace) > 0 {
+			if err := w.persist(func() error {
+				return w.repo.SetSequenceTxValidationTrace(tx.SequenceID, tx.PositionInSequence, validationResult.Trace)
+			}); err != nil {
+				w.logger.Error("error occured while setting tx validation trace", zap.Int64("sequence_id", tx.SequenceID), zap.Int16("position_in_sequence", tx.PositionInSequence), zap.Error(err))
+				return err
+			}
+			tx.ValidationTrace = validationResult.Trace
+		}
+
+		// write error message only if it was not set already
+		// otherwise root error will be overwritten by timestamp error
+		if len(tx.ErrorMessage) == 0 {
+			if err := w.persist(func() error {
+				return w.repo.SetSequenceTxErrorMessage(tx.SequenceID, tx.PositionInSequence, validationResult.ErrorMessage)
+			}); err != nil {
+				w.logger.Error("error occured while setting tx error message", zap.Int64("sequence_id", tx.SequenceID), zap.Int16("position_in_sequence", tx.PositionInSequence), zap.String("error_message", validationResult.ErrorMessage), zap.Error(err))
+				return err
+			}
+			tx.ErrorMessage = validationResult.ErrorMessage
+		}
+
+		// txs of validate only sequences are validated against the current state once, nothing broadcasted can make them valid
+		if !isTimestampError && !isOutdated && !isScriptedAccountError && w.sequenceOptions.Mode != repository.SequenceModeValidateOnly {
+			if err := w.waitForNextHeightRetry(tx.SequenceID); err != nil {
+				return err
+			}
+
+			return w.validateTx(tx)
+		}
+
+		if err := w.setTxState(tx, repository.TransactionStateError); err != nil {
+			w.logger.Error("error occured while setting tx error state", zap.Int64("sequence_id", tx.SequenceID), zap.Int16("position_in_sequence", tx.PositionInSequence), zap.Error(err))
+			return err
+		}
+
+		// outdated tx will never be valid, clients have to re-sign it
+		if isOutdated {
+			return NewNonRecoverableError("transaction timestamp too old", TxOutdatedErrorCode)
+		}
+
+		if isScriptedAccountError {
+			w.logger.Debug("tx is rejected by the account script", zap.Int64("sequence_id", tx.SequenceID), zap.Int16("position_in_sequence", tx.PositionInSequence))
+			return NewScriptedAccountError(validationResult.ErrorMessage)
+		}
+
+		errorMessage := validationResult.ErrorMessage
+		if len(tx.ErrorMessage) > 0 {
+			errorMessage = tx.ErrorMessage
+		}
+
+		return NewNonRecoverableError(errorMessage, 0)
+	}
+
+	// warnings do not affect processing, they are stored to be shown to the client
+	if w.captureValidationWarnings && len(validationResult.Warnings) > 0 {
+		w.logger.Debug("valid tx has warnings", zap.Int64("sequence_id", tx.SequenceID), zap.Int16("position_in_sequence", tx.PositionInSequence), zap.Strings("warnings", validationResult.Warnings))
+
+		if err := w.persist(func() error {
+			return w.repo.SetSequenceTxValidationWarnings(tx.SequenceID, tx.PositionInSequence, validationResult.Warnings)
+		}); err != nil {
+			return err
+		}
+		tx.ValidationWarnings = validationResult.Warnings
+	}
+
+	// tx is valid, reset error message that may have been set
+	if err := w.persist(func() error {
+		return w.repo.ResetSequenceTxErrorMessage(tx.SequenceID, tx.PositionInSequence)
+	}); err != nil {
+		w.logger.Error("error occured while resetting tx error message after its validating", zap.Int64("sequence_id", tx.SequenceID), zap.Int16("position_in_sequence", tx.PositionInSequence), zap.Error(err))
+		return err
+	}
+	tx.ErrorMessage = ""
+	w.validatedAt[tx.PositionInSequence] = time.Now()
+	if validationResult.ID != "" {
+		w.computedTxIDs[tx.PositionInSequence] = validationResult.ID
+	}
+
+	return nil
+}
+
+// broadcastTx broadcasts transaction to the blockhain
+// whether transaction successfully broadcasted, its sets tx.ID to retrieved txID
+// returns height of the tx if it is already in the blockchain and duplicates are treated as confirmed txs
+// mutate tx
+func (w *workerImpl) broadcastTx(tx *repository.SequenceTx) (int32, ErrorWithReason) {
+	if adopted, height, err := w.adoptOtherSequenceBroadcast(tx); err != nil || adopted {
+		return height, err
+	}
+
+	broadcastHeight := w.broadcastHeight(tx.SequenceID)
+
+	txID, wavesErr := w.nodeInteractor.BroadcastTx(tx.Tx)
+
+	duplicateHeight, err := w.setTxBroadcasted(tx, txID, wavesErr, broadcastHeight)
+
+	// utx pool is drained by blocks, so the broadcast is retried not earlier than the next height
+	if wavesErr != nil && node.ClassifyError(wavesErr.Error()) == node.ErrorClassUtxFull {
+		w.logger.Warn("node utx pool is full, broadcast is retried at the next height", zap.Int64("sequence_id", tx.SequenceID), zap.Int16("position_in_sequence", tx.PositionInSequence))
+		if err := w.waitForNextHeightRetry(tx.SequenceID); err != nil {
+			return 0, err
+		}
+	}
+
+	return duplicateHeight, err
+}
+
+// adoptOtherSequenceBroadcast looks the tx up in other processing sequences, so the same signed tx is not broadcasted by both of them
+// the tx broadcasted by another sequence is waited for instead, its height is returned if it is already confirmed
+// sequences checking the tx at the same time may both broadcast it, the node accepts the same tx once
+// mutate tx
+func (w *workerImpl) adoptOtherSequenceBroadcast(tx *repository.SequenceTx) (bool, int32, ErrorWithReason) {
+	if !w.adoptDuplicateBroadcasts {
+		return false, 0, nil
+	}
+
+	txID := w.txLookupID(tx)
+	if txID == "" {
+		return false, 0, nil
+	}
+
+	otherTx, err := w.repo.GetBroadcastedTxOfOtherSequence(txID, tx.SequenceID)
+	if err != nil {
+		return false, 0, NewDBError(err)
+	}
+	if otherTx == nil {
+		return false, 0, nil
+	}
+
+	w.logger.Info("tx is broadcasted by another sequence, wait for it", zap.Int64("sequence_id", tx.SequenceID), zap.Int16("position_in_sequence", tx.PositionInSequence), zap.String("tx_id", txID), zap.Int64("other_sequence_id", otherTx.SequenceID))
+
+	submittedTxID := w.submittedTxID(tx)
+	if err := w.persist(func() error {
+		return w.repo.SetSequenceTxID(tx.SequenceID, tx.PositionInSequence, otherTx.ID, submittedTxID, otherTx.BroadcastHeight)
+	}); err != nil {
+		return false, 0, err
+	}
+	tx.ID = otherTx.ID
+	tx.SubmittedID = submittedTxID
+	tx.BroadcastHeight = otherTx.BroadcastHeight
+
+	if otherTx.State == repository.TransactionStateConfirmed {
+		return true, otherTx.Height, nil
+	}
+	return true, 0, nil
+}
+
+// isRejection reports whether the node rejected the broadcasted tx, the tx already in the blockchain is not rejected
+func isRejection(wavesErr node.Error) bool {
+	if wavesErr == nil {
+		return false
+	}
+	duplicateTxID, _ := node.AlreadyInState(wavesErr.Error())
+	return duplicateTxID == ""
+}
+
+// broadcastHeight returns the current node height the next broadcast is made at, it is 0 if the node does not return it
+// the height is informational, so the broadcast is not failed by the node error
+func (w *workerImpl) broadcastHeight(sequenceID int64) int32 {
+	height, wavesErr := w.nodeInteractor.GetCurrentHeight()
+	if wavesErr != nil {
+		w.logger.Warn("cannot get broadcast height", zap.Int64("sequence_id", sequenceID), zap.Error(wavesErr))
+		return 0
+	}
+	return height
+}
+
+// setTxBroadcasted saves id of the tx the node accepted, txID and wavesErr are the node broadcast result
+// returns height of the tx if it is already in the blockchain and duplicates are treated as confirmed txs
+// mutate tx
+func (w *workerImpl) setTxBroadcasted(tx *repository.SequenceTx, txID string, wavesErr node.Error, broadcastHeight int32) (int32, ErrorWithReason) {
+	var duplicateHeight int32
+	if wavesErr != nil {
+		// check whether error is about transaction duplicate
+		if duplicateTxID, height := node.AlreadyInState(wavesErr.Error()); duplicateTxID != "" {
+			// transaction is already in the blockchain
+			txID = duplicateTxID
+			if w.duplicateAsConfirmed {
+				duplicateHeight = height
+			}
+		} else {
+			return 0, w.logNodeError(tx.SequenceID, "error occurred while broadcasting tx", wavesErr, zap.Int16("position_in_sequence", tx.PositionInSequence))
+		}
+	}
+
+	// the id is saved first, so the tx is not broadcasted again if the rest of writes fail
+	submittedTxID := w.submittedTxID(tx)
+	if err := w.persist(func() error {
+		return w.repo.SetSequenceTxID(tx.SequenceID, tx.PositionInSequence, txID, submittedTxID, broadcastHeight)
+	}); err != nil {
+		return 0, err
+	}
+	tx.ID = txID
+	tx.SubmittedID = submittedTxID
+	tx.BroadcastHeight = broadcastHeight
+
+	// tx was broadcasted, reset error message that may have been set
+	if err := w.persist(func() error {
+		return w.repo.ResetSequenceTxErrorMessage(tx.SequenceID, tx.PositionInSequence)
+	}); err != nil {
+		w.logger.Error("error occured while resetting tx error message after its broadcasting", zap.Int64("sequence_id", tx.SequenceID), zap.Int16("position_in_sequence", tx.PositionInSequence), zap.Error(err))
+		return 0, err
+	}
+	tx.ErrorMessage = ""
+
+	// ids differ if the tx was signed or serialized by the client not the way the node does it
+	if submittedTxID != "" && submittedTxID != txID {
+		w.logger.Warn("node tx id differs from the submitted tx id", zap.Int64("sequence_id", tx.SequenceID), zap.Int16("position_in_sequence", tx.PositionInSequence), zap.String("tx_id", txID), zap.String("submitted_tx_id", submittedTxID))
+
+		if w.flagTxIDMismatch {
+			errorMessage := fmt.Sprintf("node tx id %s differs from the submitted tx id %s", txID, submittedTxID)
+			if err := w.persist(func() error {
+				return w.repo.SetSequenceTxErrorMessage(tx.SequenceID, tx.PositionInSequence, errorMessage)
+			}); err != nil {
+				return 0, err
+			}
+			tx.ErrorMessage = errorMessage
+		}
+	}
+
+	return duplicateHeight, nil
+}
+
+func (w *workerImpl) waitForTxConfirmation(tx *repository.SequenceTx) (int32, node.Error) {
+	heights, wavesErr := w.waitForTxsConfirmation([]*repository.SequenceTx{tx})
+	if wavesErr != nil {
+		return 0, wavesErr
+	}
+	return heights[tx.ID], nil
+}
+
+// waitForTxsConfirmation waits for confirmation of all txs, returns heights by tx id
+// statuses of several txs are polled by a single request if batchStatusPolling is set, otherwise txs are waited for one by one
+func (w *workerImpl) waitForTxsConfirmation(txs []*repository.SequenceTx) (map[string]int32, node.Error) {
+	if len(txs) == 1 || !w.batchStatusPolling {
+		heights := make(map[string]int32, len(txs))
+		for _, tx := range txs {
+			height, wavesErr := w.nodeInteractor.WaitForTxStatus(tx.ID, node.TransactionStatusConfirmed, w.txConfirmationTimeout(tx))
+			if wavesErr != nil {
+				return heights, wavesErr
+			}
+			heights[tx.ID] = height
+		}
+		return heights, nil
+	}
+
+	txIDs := make([]string, 0, len(txs))
+	var timeout time.Duration
+	for _, tx := range txs {
+		txIDs = append(txIDs, tx.ID)
+		if txTimeout := w.txConfirmationTimeout(tx); txTimeout > timeout {
+			timeout = txTimeout
+		}
+	}
+
+	return w.nodeInteractor.WaitForTxsStatus(txIDs, node.TransactionStatusConfirmed, timeout)
+}
+
+// txConfirmationTimeout returns how long the tx confirmation is waited for, zero means the node default
+// per-type timeout has priority, otherwise the timeout may be estimated as txConfirmationTimeoutHeights blocks
+func (w *workerImpl) txConfirmationTimeout(tx *repository.SequenceTx) time.Duration {
+	if len(w.txConfirmationTimeoutByType) > 0 {
+		if t, err := w.parsedTx(tx); err == nil {
+			if timeout, ok := w.txConfirmationTimeoutByType[t.Type]; ok {
+				return timeout
+			}
+		}
+	}
+
+	if w.txConfirmationTimeoutHeights <= 0 {
+		return 0
+	}
+
+	blockTimes, wavesErr := w.nodeInteractor.GetRecentBlockTimes(blockTimeEstimationDepth)
+	if wavesErr != nil {
+		w.logger.Warn("cannot estimate tx confirmation timeout, the default one is used", zap.Int64("sequence_id", tx.SequenceID), zap.Int16("position_in_sequence", tx.PositionInSequence), zap.Error(wavesErr))
+		return 0
+	}
+
+	return time.Duration(w.txConfirmationTimeoutHeights) * node.AverageBlockTime(blockTimes)
+}
+
+// refreshSequence refreshes the sequence status before broadcasts and while the worker waits
+// the sequence is not processed anymore if its state was changed concurrently, e.g. it was skipped to a position by the admin,
+// or it was reclaimed by another instance after the lease of the owner expired;
+// the error is recoverable, so the dispatcher drops the sequence without changing its state
+func (w *workerImpl) refreshSequence(seqID int64) ErrorWithReason {
+	var updated bool
+	var err error
+	if w.owner != "" {
+		updated, err = w.repo.RefreshSequence(seqID, w.owner)
+	} else {
+		updated, err = w.repo.SetSequenceStateByIDIf(seqID, repository.StateProcessing, repository.StateProcessing)
+	}
+	if err != nil {
+		w.logger.Error("error occurred while updating sequence state", zap.Int64("sequence_id", seqID), zap.Error(err))
+		return NewDBError(err)
+	}
+	if !updated {
+		w.logger.Debug("sequence state was changed concurrently", zap.Int64("sequence_id", seqID))
+		return NewRecoverableError("sequence state was changed concurrently")
+	}
+	return nil
+}
+
+// waitForTargetHeight waits for target height
+// and on each height checking its checks that none of confirmed txs was not pulled out from the blockchain
+// new heights are read from the node blocks stream, the height is polled if the stream is unavailable or it ends
+func (w *workerImpl) waitForTargetHeight(targetHeight int32, seqID int64, confirmedTxs map[string]*repository.SequenceTx) ErrorWithReason {
+	ctx, cancel := context.WithCancel(w.ctx)
+	defer cancel()
+
+	// the subscription precedes the height request, so the block produced in between is not missed
+	heights, wavesErr := w.nodeInteractor.SubscribeBlocks(ctx)
+	if wavesErr != nil {
+		w.logger.Debug("blocks stream is unavailable, height is polled", zap.Int64("sequence_id", seqID), zap.Error(wavesErr))
+		heights = nil
+	}
+
+	currentHeight, wavesErr := w.nodeInteractor.GetCurrentHeight()
+	if wavesErr != nil {
+		return w.logNodeError(seqID, "error occurred while getting current height", wavesErr)
+	}
+
+	w.logger.Debug("start waiting for target height", zap.Int("confirmed_txs_count", len(confirmedTxs)), zap.Int32("target_height", targetHeight), zap.Int32("current_height", currentHeight))
+
+	if currentHeight >= targetHeight {
+		return nil
+	}
 
 	ticker := time.NewTicker(w.waitForNextHeightDelay)
 	defer ticker.Stop()
 
-	for range ticker.C {
-		// refresh sequence status
-		if err := w.repo.SetSequenceStateByID(seqID, repository.StateProcessing); err != nil {
-			w.logger.Error("error occurred while updating sequence state", zap.Int64("sequence_id", seqID), zap.Error(err))
-			return NewFatalError(err.Error())
+	for {
+		// the ticker is not listened to while the stream delivers heights, reading from the nil channel blocks forever
+		var ticks <-chan time.Time
+		if heights == nil {
+			ticks = ticker.C
 		}
 
-		if err := w.checkTxsAvailability(seqID, confirmedTxIDs); err != nil {
+		streamedHeight := int32(0)
+		select {
+		case height, ok := <-heights:
+			if !ok {
+				w.logger.Debug("blocks stream ended, height is polled", zap.Int64("sequence_id", seqID))
+				heights = nil
+				continue
+			}
+			streamedHeight = height
+		case <-ticks:
+		case <-w.ctx.Done():
+			return NewRecoverableError(w.ctx.Err().Error())
+		}
+
+		if err := w.refreshSequence(seqID); err != nil {
 			return err
 		}
 
-		currentHeight, wavesErr := w.nodeInteractor.GetCurrentHeight()
-		if wavesErr != nil {
-			w.logger.Error("error occurred while getting current height", zap.Error(wavesErr))
-			return NewRecoverableError(wavesErr.Error())
+		if err := w.checkProgress(seqID); err != nil {
+			return err
+		}
+
+		if err := w.checkTxsAvailability(seqID, confirmedTxs); err != nil {
+			return err
+		}
+
+		currentHeight = streamedHeight
+		if currentHeight == 0 {
+			if currentHeight, wavesErr = w.nodeInteractor.GetCurrentHeight(); wavesErr != nil {
+				return w.logNodeError(seqID, "error occurred while getting current height", wavesErr)
+			}
 		}
 
 		// success
 		if currentHeight >= targetHeight {
 			w.logger.Debug("blockchain reached target height")
-			break
+			return nil
 		}
 	}
+}
+
+// checkTxsAvailability checks that none of confirmed txs was pulled out from the blockchain
+// and waits until each of them has its required confirmations, so the worker does not advance past a tx that still can be reorged out
+func (w *workerImpl) checkTxsAvailability(sequenceID int64, confirmedTxs map[string]*repository.SequenceTx) ErrorWithReason {
+	for {
+		shallowTxsCount, err := w.checkTxsAvailabilityOnce(sequenceID, confirmedTxs)
+		if err != nil {
+			return err
+		}
+
+		if shallowTxsCount == 0 {
+			return nil
+		}
+
+		w.logger.Debug("some of confirmed txs do not have enough confirmations, wait for the next height", zap.Int64("sequence_id", sequenceID), zap.Int("shallow_txs_count", shallowTxsCount))
+
+		if err := w.checkProgress(sequenceID); err != nil {
+			return err
+		}
+
+		if err := w.waitForNextHeight(); err != nil {
+			return err
+		}
+	}
+}
+
+// detectRollbacks compares ids of the blocks confirmed txs are at with the ones seen before, returns heights of the replaced blocks
+// ids of the heights seen for the first time are remembered, nothing is detected unless block signatures are tracked
+func (w *workerImpl) detectRollbacks(sequenceID int64, confirmedTxs map[string]*repository.SequenceTx) (map[int32]bool, ErrorWithReason) {
+	if !w.trackBlockSignatures {
+		return nil, nil
+	}
+
+	rolledBack := make(map[int32]bool)
+	for _, tx := range confirmedTxs {
+		if _, checked := rolledBack[tx.Height]; checked || tx.Height <= 0 {
+			continue
+		}
+
+		signature, wavesErr := w.nodeInteractor.GetBlockSignatureAtHeight(tx.Height)
+		if wavesErr != nil {
+			return nil, w.logNodeError(sequenceID, "error occurred while getting block signature", wavesErr, zap.Int32("height", tx.Height))
+		}
+
+		knownSignature, known := w.blockSignatures[tx.Height]
+		w.blockSignatures[tx.Height] = signature
+		rolledBack[tx.Height] = known && knownSignature != signature
+
+		if rolledBack[tx.Height] {
+			w.logger.Warn("block of confirmed txs was replaced, presence of the txs is verified", zap.Int64("sequence_id", sequenceID), zap.Int32("height", tx.Height), zap.String("signature", knownSignature), zap.String("new_signature", signature))
+		}
+	}
+
+	return rolledBack, nil
+}
+
+// checkProgress returns NonRecoverableError if the sequence has not made progress for the deadlock timeout while the node produced blocks
+// e.g. txs of two sequences depend on each other, so neither of them is ever valid
+func (w *workerImpl) checkProgress(sequenceID int64) ErrorWithReason {
+	if w.deadlockTimeout <= 0 {
+		return nil
+	}
+
+	height, wavesErr := w.nodeInteractor.GetCurrentHeight()
+	if wavesErr != nil {
+		return w.logNodeError(sequenceID, "error occurred while getting current height", wavesErr)
+	}
+
+	if w.progressTracker.stalled(sequenceID, height, w.deadlockTimeout) {
+		w.logger.Warn("sequence has made no progress, it may be deadlocked", zap.Int64("sequence_id", sequenceID), zap.Duration("deadlock_timeout", w.deadlockTimeout), zap.Int32("height", height))
+		return NewNonRecoverableError("no progress, possible deadlock", NoProgressErrorCode)
+	}
+
+	return nil
+}
+
+// waitForNextHeightRetry waits for the next height before retrying validation or broadcast of a tx
+// returns NonRecoverableError if the run retry budget is exhausted, so a flaky node cannot make the worker retry forever
+// waits for confirmations are not retries, they do not consume the budget
+func (w *workerImpl) waitForNextHeightRetry(sequenceID int64) ErrorWithReason {
+	if (w.retryBudget > 0 && w.retries >= w.retryBudget) || (w.retryBudgetTime > 0 && w.retriesTime >= w.retryBudgetTime) {
+		w.logger.Debug("retry budget is exhausted", zap.Int64("sequence_id", sequenceID), zap.Int32("retries", w.retries), zap.Duration("retries_time", w.retriesTime))
+		return NewNonRecoverableError("retry budget is exhausted", RetryBudgetExhaustedErrorCode)
+	}
+
+	if err := w.checkProgress(sequenceID); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	defer func() {
+		w.retries++
+		w.retriesTime += time.Since(start)
+	}()
+
+	return w.waitForNextHeight()
+}
+
+// waitForNextHeight waits for the next height
+func (w *workerImpl) waitForNextHeight() ErrorWithReason {
+	if wavesErr := w.nodeInteractor.WaitForNextHeight(); wavesErr != nil {
+		return w.errorClassOverrides.Classify(wavesErr)
+	}
 
 	return nil
 }
 
-func (w *workerImpl) checkTxsAvailability(sequenceID int64, confirmedTxIDs []string) ErrorWithReason {
+// checkTxsAvailabilityOnce returns count of available txs which have less than required confirmations
+func (w *workerImpl) checkTxsAvailabilityOnce(sequenceID int64, confirmedTxs map[string]*repository.SequenceTx) (int, ErrorWithReason) {
+	checkedTxs, err := w.txsWithinReorgWindow(sequenceID, confirmedTxs)
+	if err != nil {
+		return 0, err
+	}
+	if len(checkedTxs) == 0 {
+		return 0, nil
+	}
+
+	confirmedTxIDs := make([]string, 0, len(checkedTxs))
+	for txID := range checkedTxs {
+		confirmedTxIDs = append(confirmedTxIDs, txID)
+	}
+
+	rolledBack, err := w.detectRollbacks(sequenceID, checkedTxs)
+	if err != nil {
+		return 0, err
+	}
+
 	availability, wavesErr := w.nodeInteractor.GetTxsAvailability(confirmedTxIDs)
 	if wavesErr != nil {
-		w.logger.Error("error occurred while fetching txs statuses", zap.Int64("sequence_id", sequenceID), zap.Error(wavesErr))
-		return NewRecoverableError(wavesErr.Error())
+		return 0, w.logNodeError(sequenceID, "error occurred while fetching txs statuses", wavesErr)
 	}
 
-	for txID, isAvailable := range availability {
-		if !isAvailable {
+	shallowTxsCount := 0
+	for txID, txAvailability := range availability {
+		// the block of the tx was replaced, so not_found is not transient
+		txRolledBack := false
+		if tx, ok := confirmedTxs[txID]; ok {
+			txRolledBack = rolledBack[tx.Height]
+		}
+
+		if !txAvailability.IsAvailable {
+			w.notFoundChecks[txID]++
+			if w.notFoundChecks[txID] < w.txNotFoundChecks && !txRolledBack {
+				// may be a transient not_found of the warming up node, the tx is checked again on the next height
+				w.logger.Debug("one of confirmed tx was not found", zap.Int64("sequence_id", sequenceID), zap.String("tx_id", txID), zap.Int32("not_found_checks", w.notFoundChecks[txID]))
+				shallowTxsCount++
+				continue
+			}
+
 			w.logger.Debug("one of confirmed tx was pulled out", zap.Int64("sequence_id", sequenceID), zap.String("tx_id", txID))
+			delete(w.notFoundChecks, txID)
 
-			if err := w.repo.SetSequenceTxsStateAfter(sequenceID, txID, repository.TransactionStatePending); err != nil {
+			if err := w.persist(func() error {
+				return w.repo.SetSequenceTxsStateAfter(sequenceID, txID, repository.TransactionStatePending)
+			}); err != nil {
 				w.logger.Error("error occured while setting txs pending state", zap.Int64("sequence_id", sequenceID), zap.String("after_tx_id", txID), zap.Error(err))
-				return NewFatalError(err.Error())
+				return 0, err
+			}
+
+			// txs before the pulled out one are still confirmed
+			if pulledOutTx, ok := confirmedTxs[txID]; ok {
+				if err := w.persist(func() error {
+					return w.repo.SetSequenceProgress(sequenceID, pulledOutTx.PositionInSequence-1)
+				}); err != nil {
+					return 0, err
+				}
+
+				for _, tx := range confirmedTxs {
+					if tx.PositionInSequence >= pulledOutTx.PositionInSequence {
+						notifyStateHooks(sequenceID, tx.PositionInSequence, repository.TransactionStatePending)
+					}
+				}
 			}
 
-			return NewRecoverableError("error occured while waiting for the Ns block after last tx: one of tx was pulled out from the blockchain")
+			return 0, NewRecoverableError("error occured while waiting for the Ns block after last tx: one of tx was pulled out from the blockchain")
+		}
+
+		delete(w.notFoundChecks, txID)
+
+		if txAvailability.Confirmations < w.requiredConfirmations(confirmedTxs[txID]) {
+			shallowTxsCount++
 		}
 	}
 
-	return nil
+	return shallowTxsCount, nil
+}
+
+// txsWithinReorgWindow returns confirmed txs which may still be pulled out by a rollback
+// txs buried deeper than the reorg window having required confirmations are not checked anymore
+func (w *workerImpl) txsWithinReorgWindow(sequenceID int64, confirmedTxs map[string]*repository.SequenceTx) (map[string]*repository.SequenceTx, ErrorWithReason) {
+	if w.reorgWindow <= 0 {
+		return confirmedTxs, nil
+	}
+
+	currentHeight, wavesErr := w.nodeInteractor.GetCurrentHeight()
+	if wavesErr != nil {
+		return nil, w.logNodeError(sequenceID, "error occurred while getting current height", wavesErr)
+	}
+
+	txs := make(map[string]*repository.SequenceTx, len(confirmedTxs))
+	for txID, tx := range confirmedTxs {
+		depth := currentHeight - tx.Height
+		if tx.Height > 0 && depth >= w.reorgWindow && depth >= w.requiredConfirmations(tx) {
+			continue
+		}
+		txs[txID] = tx
+	}
+	return txs, nil
+}
+
+// requiredConfirmations returns min confirmations of the tx, the tx setting overrides the worker one
+// the worker setting is not applied in the last tx confirmation mode, only the last tx is confirmed deeply
+func (w *workerImpl) requiredConfirmations(tx *repository.SequenceTx) int32 {
+	if tx != nil && tx.MinConfirmations != nil {
+		return *tx.MinConfirmations
+	}
+	if w.confirmationMode == ConfirmationModeLastTx {
+		return 0
+	}
+	return w.minConfirmations
 }
 
-// isTxOutdated retrieves timestamp from tx (via parsing json)
-// and checks whether tx is outdated
-func (w *workerImpl) isTxOutdated(tx string) (bool, error) {
-	t := txWithTimestamp{}
+// lastConfirmedTx returns the last confirmed tx of txs ordered by positions
+func lastConfirmedTx(txs []*repository.SequenceTx) *repository.SequenceTx {
+	for i := len(txs) - 1; i > 0; i-- {
+		if txs[i].State == repository.TransactionStateConfirmed {
+			return txs[i]
+		}
+	}
+	return txs[0]
+}
 
-	err := json.Unmarshal([]byte(tx), &t)
+// submittedTxID returns id embedded in the tx json, empty string if there is no one
+func (w *workerImpl) submittedTxID(tx *repository.SequenceTx) string {
+	t, err := w.parsedTx(tx)
+	if err != nil {
+		return ""
+	}
+	return t.ID
+}
+
+// txLookupID returns id the tx is looked up by before its broadcast, the submitted id or the one computed by the node
+// empty string means the tx was neither submitted with id nor validated by the node returning ids
+func (w *workerImpl) txLookupID(tx *repository.SequenceTx) string {
+	if txID := w.submittedTxID(tx); txID != "" {
+		return txID
+	}
+	return w.computedTxIDs[tx.PositionInSequence]
+}
+
+// parsedTx returns the parsed tx json, every tx is parsed once per run
+func (w *workerImpl) parsedTx(tx *repository.SequenceTx) (*node.Tx, error) {
+	if t, ok := w.parsedTxs[tx.PositionInSequence]; ok {
+		return t, nil
+	}
+
+	t, err := node.ParseTx(tx.Tx)
+	if err != nil {
+		return nil, err
+	}
+	w.parsedTxs[tx.PositionInSequence] = t
+	return t, nil
+}
+
+// isTxOutdated checks whether tx is outdated by its timestamp
+func (w *workerImpl) isTxOutdated(tx *repository.SequenceTx) (bool, error) {
+	t, err := w.parsedTx(tx)
 	if err != nil {
 		return false, err
 	}
 
-	return time.Now().Sub(time.Unix(0, t.Timestamp*int64(time.Millisecond))) >= w.txOutdateTime, nil
+	txOutdateTime := w.txOutdateTime
+	if w.sequenceOptions.TxOutdateTime > 0 {
+		txOutdateTime = time.Duration(w.sequenceOptions.TxOutdateTime) * time.Millisecond
+	}
+
+	return time.Now().Sub(time.Unix(0, t.Timestamp*int64(time.Millisecond))) >= txOutdateTime, nil
 }
@@ -0,0 +1,1388 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/wavesplatform/transaction-broadcaster/internal/log"
+	"github.com/wavesplatform/transaction-broadcaster/internal/node"
+	"github.com/wavesplatform/transaction-broadcaster/internal/repository"
+)
+
+// fakeRepo keeps txs of sequences in memory, methods which are not overridden panic
+type fakeRepo struct {
+	repository.Repository
+
+	mutex    sync.Mutex
+	txs      map[int64][]*repository.SequenceTx
+	progress map[int64]int16
+}
+
+func newFakeRepo() *fakeRepo {
+	return &fakeRepo{
+		txs:      make(map[int64][]*repository.SequenceTx),
+		progress: make(map[int64]int16),
+	}
+}
+
+// addSequence adds pending txs of the sequence
+func (r *fakeRepo) addSequence(sequenceID int64, txs ...string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for i, tx := range txs {
+		r.txs[sequenceID] = append(r.txs[sequenceID], &repository.SequenceTx{
+			SequenceID:         sequenceID,
+			PositionInSequence: int16(i),
+			State:              repository.TransactionStatePending,
+			Tx:                 tx,
+			UpdatedAt:          time.Now(),
+		})
+	}
+}
+
+// tx returns copy of the sequence tx
+func (r *fakeRepo) tx(sequenceID int64, positionInSequence int16) repository.SequenceTx {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return *r.txs[sequenceID][positionInSequence]
+}
+
+func (r *fakeRepo) update(sequenceID int64, positionInSequence int16, update func(tx *repository.SequenceTx)) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	txs := r.txs[sequenceID]
+	if int(positionInSequence) >= len(txs) {
+		return fmt.Errorf("tx %d of sequence %d does not exist", positionInSequence, sequenceID)
+	}
+	update(txs[positionInSequence])
+	txs[positionInSequence].UpdatedAt = time.Now()
+	return nil
+}
+
+func (r *fakeRepo) GetSequenceTxsWindow(sequenceID int64, window repository.TxsWindow) ([]*repository.SequenceTx, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	txs := make([]*repository.SequenceTx, 0, len(r.txs[sequenceID]))
+	for _, tx := range r.txs[sequenceID] {
+		copied := *tx
+		txs = append(txs, &copied)
+	}
+	return txs, nil
+}
+
+func (r *fakeRepo) GetBroadcastedTxOfOtherSequence(txID string, sequenceID int64) (*repository.SequenceTx, error) {
+	return nil, nil
+}
+
+func (r *fakeRepo) SetSequenceStateByIDIf(sequenceID int64, expectedState, newState repository.State) (bool, error) {
+	return true, nil
+}
+
+func (r *fakeRepo) SetSequenceTxState(sequenceID int64, positionInSequence int16, newState repository.TransactionState) (bool, error) {
+	updated := false
+	err := r.update(sequenceID, positionInSequence, func(tx *repository.SequenceTx) {
+		if tx.State != repository.TransactionStateSkipped {
+			tx.State = newState
+			updated = true
+		}
+	})
+	return updated, err
+}
+
+func (r *fakeRepo) SetSequenceTxID(sequenceID int64, positionInSequence int16, txID, submittedTxID string, broadcastHeight int32) error {
+	return r.update(sequenceID, positionInSequence, func(tx *repository.SequenceTx) {
+		tx.ID = txID
+		tx.SubmittedID = submittedTxID
+		tx.BroadcastHeight = broadcastHeight
+	})
+}
+
+func (r *fakeRepo) SetSequenceTxConfirmedState(sequenceID int64, positionInSequence int16, height int32) error {
+	return r.update(sequenceID, positionInSequence, func(tx *repository.SequenceTx) {
+		tx.State = repository.TransactionStateConfirmed
+		tx.Height = height
+	})
+}
+
+// SetSequenceTxsStateAfter sets the state of the tx with the id and all not skipped txs after it
+func (r *fakeRepo) SetSequenceTxsStateAfter(sequenceID int64, txID string, newState repository.TransactionState) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	found := false
+	for _, tx := range r.txs[sequenceID] {
+		found = found || tx.ID == txID
+		if found && tx.State != repository.TransactionStateSkipped {
+			tx.State = newState
+		}
+	}
+	return nil
+}
+
+func (r *fakeRepo) SetSequenceProgress(sequenceID int64, lastConfirmedPosition int16) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.progress[sequenceID] = lastConfirmedPosition
+	return nil
+}
+
+func (r *fakeRepo) SetSequenceTxErrorMessage(sequenceID int64, positionInSequence int16, errorMessage string) error {
+	return r.update(sequenceID, positionInSequence, func(tx *repository.SequenceTx) {
+		tx.ErrorMessage = errorMessage
+	})
+}
+
+func (r *fakeRepo) ResetSequenceTxErrorMessage(sequenceID int64, positionInSequence int16) error {
+	return r.update(sequenceID, positionInSequence, func(tx *repository.SequenceTx) {
+		tx.ErrorMessage = ""
+		tx.ValidationTrace = nil
+	})
+}
+
+func (r *fakeRepo) SetSequenceTxValidationTrace(sequenceID int64, positionInSequence int16, trace json.RawMessage) error {
+	return r.update(sequenceID, positionInSequence, func(tx *repository.SequenceTx) {
+		tx.ValidationTrace = trace
+	})
+}
+
+func (r *fakeRepo) SetSequenceTxValidationWarnings(sequenceID int64, positionInSequence int16, warnings []string) error {
+	return r.update(sequenceID, positionInSequence, func(tx *repository.SequenceTx) {
+		tx.ValidationWarnings = warnings
+	})
+}
+
+// countingInteractor counts node calls of the fake node
+type countingInteractor struct {
+	*node.FakeInteractor
+
+	mutex sync.Mutex
+	calls map[string]int
+}
+
+func newCountingInteractor(validator node.Interactor) *countingInteractor {
+	return &countingInteractor{
+		FakeInteractor: node.NewFakeInteractor(validator),
+		calls:          make(map[string]int),
+	}
+}
+
+func (i *countingInteractor) count(method string) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	i.calls[method]++
+}
+
+func (i *countingInteractor) callsOf(method string) int {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	return i.calls[method]
+}
+
+func (i *countingInteractor) WaitForTxStatus(txID string, waitForStatus node.TransactionStatus, timeout time.Duration) (int32, node.Error) {
+	i.count("WaitForTxStatus")
+	return i.FakeInteractor.WaitForTxStatus(txID, waitForStatus, timeout)
+}
+
+func (i *countingInteractor) WaitForTxsStatus(txIDs []string, waitForStatus node.TransactionStatus, timeout time.Duration) (map[string]int32, node.Error) {
+	i.count("WaitForTxsStatus")
+	return i.FakeInteractor.WaitForTxsStatus(txIDs, waitForStatus, timeout)
+}
+
+func (i *countingInteractor) BroadcastTx(tx string) (string, node.Error) {
+	i.count("BroadcastTx")
+	return i.FakeInteractor.BroadcastTx(tx)
+}
+
+func (i *countingInteractor) BroadcastTxs(txs []string) ([]node.BroadcastResult, node.Error) {
+	i.count("BroadcastTxs")
+	return i.FakeInteractor.BroadcastTxs(txs)
+}
+
+func (i *countingInteractor) WithContext(ctx context.Context) node.Interactor {
+	return i
+}
+
+func newTestWorker(repo repository.Repository, nodeInteractor node.Interactor, options repository.SequenceOptions, cfg Config) *workerImpl {
+	log.Logger = zap.NewNop()
+	return New("test", "", repo, nodeInteractor, options, cfg, nil, nil).(*workerImpl)
+}
+
+func TestRetryBudgetIsChargedOnlyByRetries(t *testing.T) {
+	w := &workerImpl{
+		nodeInteractor: node.NewFakeInteractor(nil),
+		logger:         zap.NewNop(),
+		retryBudget:    1,
+	}
+
+	// waits for confirmations are not retries
+	for i := 0; i < 3; i++ {
+		require.Nil(t, w.waitForNextHeight())
+	}
+	require.Equal(t, int32(0), w.retries)
+
+	require.Nil(t, w.waitForNextHeightRetry(1))
+	require.Equal(t, int32(1), w.retries)
+
+	err := w.waitForNextHeightRetry(1)
+	require.IsType(t, NonRecoverableError{}, err)
+	require.Equal(t, RetryBudgetExhaustedErrorCode, err.(ErrorWithReasonAndCode).ErrorCode())
+}
+
+func TestIndependentSequenceTxsAreConfirmedAtOnce(t *testing.T) {
+	repo := newFakeRepo()
+	repo.addSequence(1, `{"id":"a"}`, `{"id":"b"}`, `{"id":"c"}`)
+
+	nodeInteractor := newCountingInteractor(nil)
+	w := newTestWorker(repo, nodeInteractor, repository.SequenceOptions{Mode: repository.SequenceModeIndependent}, Config{BatchStatusPolling: true})
+
+	require.Nil(t, w.Run(1))
+
+	require.Equal(t, 1, nodeInteractor.callsOf("WaitForTxsStatus"))
+	require.Equal(t, 0, nodeInteractor.callsOf("WaitForTxStatus"))
+	for position := int16(0); position < 3; position++ {
+		require.Equal(t, repository.TransactionStateConfirmed, repo.tx(1, position).State)
+	}
+}
+
+func TestConfirmedPrefix(t *testing.T) {
+	txs := func(states ...repository.TransactionState) []*repository.SequenceTx {
+		result := make([]*repository.SequenceTx, len(states))
+		for i, state := range states {
+			// the window starts from the position 3
+			result[i] = &repository.SequenceTx{PositionInSequence: int16(i + 3), State: state}
+		}
+		return result
+	}
+
+	confirmed := repository.TransactionStateConfirmed
+	skipped := repository.TransactionStateSkipped
+	unconfirmed := repository.TransactionStateUnconfirmed
+
+	require.Equal(t, int16(-1), confirmedPrefix(nil))
+	require.Equal(t, int16(2), confirmedPrefix(txs(unconfirmed, confirmed)))
+	// txs of independent sequences are confirmed out of order
+	require.Equal(t, int16(3), confirmedPrefix(txs(confirmed, unconfirmed, confirmed)))
+	require.Equal(t, int16(5), confirmedPrefix(txs(confirmed, skipped, confirmed)))
+}
+
+func TestIndependentSequenceProgress(t *testing.T) {
+	repo := newFakeRepo()
+	repo.addSequence(1, `{"id":"a"}`, `{"id":"b"}`, `{"id":"c"}`)
+
+	w := newTestWorker(repo, newCountingInteractor(nil), repository.SequenceOptions{Mode: repository.SequenceModeIndependent}, Config{BatchStatusPolling: true})
+	require.Nil(t, w.Run(1))
+	require.Equal(t, int16(2), repo.progress[1])
+}
+
+// rejectingValidator rejects txs containing "invalid" by the error which does not depend on the tx timestamp
+type rejectingValidator struct {
+	node.Interactor
+}
+
+func (v *rejectingValidator) ValidateTx(tx string) (*node.ValidationResult, node.Error) {
+	if strings.Contains(tx, "invalid") {
+		return &node.ValidationResult{IsValid: false, ErrorMessage: "Attempt to transfer unavailable funds"}, nil
+	}
+	return &node.ValidationResult{IsValid: true}, nil
+}
+
+func validateOnlyTxs() []string {
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	return []string{
+		fmt.Sprintf(`{"id":"a","timestamp":%d}`, now),
+		fmt.Sprintf(`{"id":"b","timestamp":%d,"attachment":"invalid"}`, now),
+		fmt.Sprintf(`{"id":"c","timestamp":%d}`, now),
+		fmt.Sprintf(`{"id":"d","timestamp":%d,"attachment":"invalid"}`, now),
+	}
+}
+
+func TestValidateOnlySequenceFailsOnInvalidTx(t *testing.T) {
+	repo := newFakeRepo()
+	repo.addSequence(1, validateOnlyTxs()...)
+
+	nodeInteractor := newCountingInteractor(&rejectingValidator{})
+	w := newTestWorker(repo, nodeInteractor, repository.SequenceOptions{Mode: repository.SequenceModeValidateOnly}, Config{TxOutdateTime: 3600000})
+
+	// the invalid tx is not revalidated at the next heights
+	err := w.Run(1)
+	require.IsType(t, NonRecoverableError{}, err)
+	require.Equal(t, "Attempt to transfer unavailable funds", err.Reason())
+
+	require.Equal(t, repository.TransactionStateValidated, repo.tx(1, 0).State)
+	require.Equal(t, repository.TransactionStateError, repo.tx(1, 1).State)
+	require.Equal(t, "Attempt to transfer unavailable funds", repo.tx(1, 1).ErrorMessage)
+	require.Equal(t, repository.TransactionStatePending, repo.tx(1, 2).State)
+}
+
+func TestValidateOnlySequenceContinuesAfterInvalidTx(t *testing.T) {
+	repo := newFakeRepo()
+	repo.addSequence(1, validateOnlyTxs()...)
+
+	nodeInteractor := newCountingInteractor(&rejectingValidator{})
+	w := newTestWorker(repo, nodeInteractor, repository.SequenceOptions{Mode: repository.SequenceModeValidateOnly}, Config{TxOutdateTime: 3600000, ValidateOnlyContinue: true})
+
+	err := w.Run(1)
+	require.IsType(t, NonRecoverableError{}, err)
+	require.Equal(t, InvalidTxsErrorCode, err.(ErrorWithReasonAndCode).ErrorCode())
+	require.Equal(t, "2 of 4 txs are invalid", err.Reason())
+
+	states := make([]repository.TransactionState, 4)
+	for position := range states {
+		states[position] = repo.tx(1, int16(position)).State
+	}
+	require.Equal(t, []repository.TransactionState{
+		repository.TransactionStateValidated,
+		repository.TransactionStateError,
+		repository.TransactionStateValidated,
+		repository.TransactionStateError,
+	}, states)
+}
+
+func TestUnsavedBroadcastOfTxWithoutIDIsResumed(t *testing.T) {
+	tx := fmt.Sprintf(`{"type":4,"timestamp":%d}`, time.Now().UnixNano()/int64(time.Millisecond))
+
+	repo := newFakeRepo()
+	repo.addSequence(1, tx)
+	nodeInteractor := newCountingInteractor(nil)
+
+	// the previous run broadcasted the tx and stopped before its id was saved
+	txID, wavesErr := nodeInteractor.FakeInteractor.BroadcastTx(tx)
+	require.Nil(t, wavesErr)
+	_, err := repo.SetSequenceTxState(1, 0, repository.TransactionStateUnconfirmed)
+	require.Nil(t, err)
+
+	w := newTestWorker(repo, nodeInteractor, repository.SequenceOptions{}, Config{TxOutdateTime: 3600000})
+	require.Nil(t, w.Run(1))
+
+	require.Equal(t, 0, nodeInteractor.callsOf("BroadcastTx"))
+	require.Equal(t, repository.TransactionStateConfirmed, repo.tx(1, 0).State)
+	require.Equal(t, txID, repo.tx(1, 0).ID)
+	require.Empty(t, repo.tx(1, 0).SubmittedID)
+}
+
+func TestNotBroadcastedTxWithoutIDIsBroadcastedOnResume(t *testing.T) {
+	tx := fmt.Sprintf(`{"type":4,"timestamp":%d}`, time.Now().UnixNano()/int64(time.Millisecond))
+
+	repo := newFakeRepo()
+	repo.addSequence(1, tx)
+	_, err := repo.SetSequenceTxState(1, 0, repository.TransactionStateValidated)
+	require.Nil(t, err)
+	nodeInteractor := newCountingInteractor(nil)
+
+	w := newTestWorker(repo, nodeInteractor, repository.SequenceOptions{}, Config{TxOutdateTime: 3600000})
+	require.Nil(t, w.Run(1))
+
+	require.Equal(t, 1, nodeInteractor.callsOf("BroadcastTx"))
+	require.Equal(t, repository.TransactionStateConfirmed, repo.tx(1, 0).State)
+}
+
+// otherSequenceRepo has the tx broadcasted by another processing sequence
+type otherSequenceRepo struct {
+	*fakeRepo
+	otherTx *repository.SequenceTx
+}
+
+func (r *otherSequenceRepo) GetBroadcastedTxOfOtherSequence(txID string, sequenceID int64) (*repository.SequenceTx, error) {
+	if txID == r.otherTx.ID && sequenceID != r.otherTx.SequenceID {
+		return r.otherTx, nil
+	}
+	return nil, nil
+}
+
+func TestBroadcastOfOtherSequenceIsAdoptedByComputedID(t *testing.T) {
+	tx := fmt.Sprintf(`{"type":4,"timestamp":%d}`, time.Now().UnixNano()/int64(time.Millisecond))
+
+	nodeInteractor := newCountingInteractor(nil)
+	txID, wavesErr := nodeInteractor.FakeInteractor.BroadcastTx(tx)
+	require.Nil(t, wavesErr)
+
+	repo := &otherSequenceRepo{
+		fakeRepo: newFakeRepo(),
+		otherTx:  &repository.SequenceTx{SequenceID: 2, ID: txID, State: repository.TransactionStateUnconfirmed, BroadcastHeight: 1},
+	}
+	repo.addSequence(1, tx)
+
+	w := newTestWorker(repo, nodeInteractor, repository.SequenceOptions{}, Config{TxOutdateTime: 3600000, AdoptDuplicateBroadcasts: true})
+	require.Nil(t, w.Run(1))
+
+	require.Equal(t, 0, nodeInteractor.callsOf("BroadcastTx"))
+	require.Equal(t, repository.TransactionStateConfirmed, repo.tx(1, 0).State)
+	require.Equal(t, txID, repo.tx(1, 0).ID)
+	require.Equal(t, int32(1), repo.tx(1, 0).BroadcastHeight)
+}
+
+// skippingRepo skips the tx right after the worker read it, like the client skipping it while the worker runs
+type skippingRepo struct {
+	*fakeRepo
+	skippedPosition int16
+}
+
+func (r *skippingRepo) GetSequenceTxsWindow(sequenceID int64, window repository.TxsWindow) ([]*repository.SequenceTx, error) {
+	txs, err := r.fakeRepo.GetSequenceTxsWindow(sequenceID, window)
+	if err != nil {
+		return nil, err
+	}
+	err = r.update(sequenceID, r.skippedPosition, func(tx *repository.SequenceTx) {
+		tx.State = repository.TransactionStateSkipped
+	})
+	return txs, err
+}
+
+func TestTxSkippedWhileProcessedIsNotBroadcasted(t *testing.T) {
+	// txs of independent sequences are broadcasted by a single call, txs of other sequences one by one
+	for _, mode := range []repository.SequenceMode{repository.SequenceModeIndependent, repository.SequenceModeBroadcast} {
+		repo := &skippingRepo{fakeRepo: newFakeRepo(), skippedPosition: 1}
+		repo.addSequence(1, `{"id":"a"}`, `{"id":"b"}`, `{"id":"c"}`)
+
+		nodeInteractor := newCountingInteractor(nil)
+		w := newTestWorker(repo, nodeInteractor, repository.SequenceOptions{Mode: mode}, Config{})
+		require.Nil(t, w.Run(1))
+
+		require.Equal(t, repository.TransactionStateConfirmed, repo.tx(1, 0).State)
+		require.Equal(t, repository.TransactionStateSkipped, repo.tx(1, 1).State)
+		require.Empty(t, repo.tx(1, 1).ID)
+		require.Equal(t, repository.TransactionStateConfirmed, repo.tx(1, 2).State)
+		require.Equal(t, int16(2), repo.progress[1])
+	}
+}
+
+// movedRepo has the sequence moved out of the processing state, e.g. skipped to a position by the admin
+type movedRepo struct {
+	*fakeRepo
+}
+
+func (r *movedRepo) SetSequenceStateByIDIf(sequenceID int64, expectedState, newState repository.State) (bool, error) {
+	return false, nil
+}
+
+func TestWorkerStopsBeforeBroadcastOfMovedSequence(t *testing.T) {
+	for _, mode := range []repository.SequenceMode{repository.SequenceModeIndependent, repository.SequenceModeBroadcast} {
+		repo := &movedRepo{fakeRepo: newFakeRepo()}
+		repo.addSequence(1, `{"id":"a"}`, `{"id":"b"}`)
+
+		nodeInteractor := newCountingInteractor(nil)
+		w := newTestWorker(repo, nodeInteractor, repository.SequenceOptions{Mode: mode}, Config{})
+
+		// the dispatcher drops the sequence without changing its state
+		err := w.Run(1)
+		require.IsType(t, RecoverableError{}, err)
+		require.Equal(t, 0, nodeInteractor.callsOf("BroadcastTx"))
+		require.Equal(t, 0, nodeInteractor.callsOf("BroadcastTxs"))
+	}
+}
+
+// pgError is the postgres error of the given sql state
+type pgError string
+
+func (e pgError) Error() string {
+	return "ERROR #" + string(e)
+}
+
+func (e pgError) Field(field byte) string {
+	if field == 'C' {
+		return string(e)
+	}
+	return ""
+}
+
+func (e pgError) IntegrityViolation() bool {
+	return false
+}
+
+// flakyRepo fails the writes of tx error messages, traces and warnings failures times with err before they succeed
+type flakyRepo struct {
+	*fakeRepo
+	err      error
+	failures int
+	calls    map[string]int
+}
+
+func (r *flakyRepo) fail(method string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.calls[method]++
+	if r.calls[method] <= r.failures {
+		return r.err
+	}
+	return nil
+}
+
+func (r *flakyRepo) SetSequenceTxErrorMessage(sequenceID int64, positionInSequence int16, errorMessage string) error {
+	if err := r.fail("SetSequenceTxErrorMessage"); err != nil {
+		return err
+	}
+	return r.fakeRepo.SetSequenceTxErrorMessage(sequenceID, positionInSequence, errorMessage)
+}
+
+func (r *flakyRepo) ResetSequenceTxErrorMessage(sequenceID int64, positionInSequence int16) error {
+	if err := r.fail("ResetSequenceTxErrorMessage"); err != nil {
+		return err
+	}
+	return r.fakeRepo.ResetSequenceTxErrorMessage(sequenceID, positionInSequence)
+}
+
+func (r *flakyRepo) SetSequenceTxValidationTrace(sequenceID int64, positionInSequence int16, trace json.RawMessage) error {
+	if err := r.fail("SetSequenceTxValidationTrace"); err != nil {
+		return err
+	}
+	return r.fakeRepo.SetSequenceTxValidationTrace(sequenceID, positionInSequence, trace)
+}
+
+func (r *flakyRepo) SetSequenceTxValidationWarnings(sequenceID int64, positionInSequence int16, warnings []string) error {
+	if err := r.fail("SetSequenceTxValidationWarnings"); err != nil {
+		return err
+	}
+	return r.fakeRepo.SetSequenceTxValidationWarnings(sequenceID, positionInSequence, warnings)
+}
+
+// onceRejectingValidator rejects the first validation with the trace, the tx is valid with warnings afterwards
+type onceRejectingValidator struct {
+	node.Interactor
+	rejected bool
+}
+
+func (v *onceRejectingValidator) ValidateTx(tx string) (*node.ValidationResult, node.Error) {
+	if !v.rejected {
+		v.rejected = true
+		return &node.ValidationResult{IsValid: false, ErrorMessage: "Attempt to transfer unavailable funds", Trace: json.RawMessage(`[{"id":"3P"}]`)}, nil
+	}
+	return &node.ValidationResult{IsValid: true, Warnings: []string{"low fee"}}, nil
+}
+
+func runFlakySequence(t *testing.T, err error, failures int) (*flakyRepo, ErrorWithReason) {
+	repo := &flakyRepo{fakeRepo: newFakeRepo(), err: err, failures: failures, calls: make(map[string]int)}
+	repo.addSequence(1, fmt.Sprintf(`{"id":"a","timestamp":%d}`, time.Now().UnixNano()/int64(time.Millisecond)))
+
+	cfg := Config{TxOutdateTime: 3600000, PersistValidationTraces: true, CaptureValidationWarnings: true, DBWriteRetries: 2}
+	w := newTestWorker(repo, newCountingInteractor(&onceRejectingValidator{}), repository.SequenceOptions{}, cfg)
+	return repo, w.Run(1)
+}
+
+func TestTxMessagesWritesAreRetried(t *testing.T) {
+	repo, err := runFlakySequence(t, pgError("40001"), 2)
+	require.Nil(t, err)
+
+	tx := repo.tx(1, 0)
+	require.Equal(t, repository.TransactionStateConfirmed, tx.State)
+	require.Empty(t, tx.ErrorMessage)
+	require.Equal(t, []string{"low fee"}, tx.ValidationWarnings)
+	for _, method := range []string{"SetSequenceTxErrorMessage", "ResetSequenceTxErrorMessage", "SetSequenceTxValidationTrace", "SetSequenceTxValidationWarnings"} {
+		require.Greater(t, repo.calls[method], 2, method)
+	}
+}
+
+// scriptRejectingValidator rejects every tx as not allowed by the account script and counts validations
+type scriptRejectingValidator struct {
+	node.Interactor
+	validations int
+}
+
+func (v *scriptRejectingValidator) ValidateTx(tx string) (*node.ValidationResult, node.Error) {
+	v.validations++
+	return &node.ValidationResult{IsValid: false, ErrorMessage: "Transaction is not allowed by account-script"}, nil
+}
+
+func TestScriptedAccountRejection(t *testing.T) {
+	repo := newFakeRepo()
+	repo.addSequence(1, fmt.Sprintf(`{"id":"a","timestamp":%d}`, time.Now().UnixNano()/int64(time.Millisecond)))
+
+	validator := &scriptRejectingValidator{}
+	nodeInteractor := newCountingInteractor(validator)
+	w := newTestWorker(repo, nodeInteractor, repository.SequenceOptions{}, Config{TxOutdateTime: 3600000})
+
+	err := w.Run(1)
+	require.IsType(t, NonRecoverableError{}, err)
+	require.Equal(t, ScriptedAccountErrorCode, err.(ErrorWithReasonAndCode).ErrorCode())
+	require.Equal(t, "Transaction is not allowed by account-script ("+scriptedAccountErrorGuidance+")", err.Reason())
+
+	// the rejection is final, the tx is neither validated again nor broadcasted
+	require.Equal(t, 1, validator.validations)
+	require.Equal(t, 0, nodeInteractor.callsOf("BroadcastTx"))
+	require.Equal(t, repository.TransactionStateError, repo.tx(1, 0).State)
+}
+
+// warningValidator reports every tx as valid with warnings
+type warningValidator struct {
+	node.Interactor
+}
+
+func (v *warningValidator) ValidateTx(tx string) (*node.ValidationResult, node.Error) {
+	return &node.ValidationResult{IsValid: true, Warnings: []string{"complexity near limit"}}, nil
+}
+
+func TestValidationWarningsAreCaptured(t *testing.T) {
+	for _, capture := range []bool{true, false} {
+		repo := newFakeRepo()
+		repo.addSequence(1, fmt.Sprintf(`{"id":"a","timestamp":%d}`, time.Now().UnixNano()/int64(time.Millisecond)))
+
+		cfg := Config{TxOutdateTime: 3600000, CaptureValidationWarnings: capture}
+		w := newTestWorker(repo, newCountingInteractor(&warningValidator{}), repository.SequenceOptions{}, cfg)
+		require.Nil(t, w.Run(1))
+
+		// warnings do not prevent the broadcast
+		tx := repo.tx(1, 0)
+		require.Equal(t, repository.TransactionStateConfirmed, tx.State)
+		if capture {
+			require.Equal(t, []string{"complexity near limit"}, tx.ValidationWarnings)
+			continue
+		}
+		require.Empty(t, tx.ValidationWarnings)
+	}
+}
+
+func TestTxMessagesWritesFailures(t *testing.T) {
+	// the sequence is processed again later
+	_, err := runFlakySequence(t, pgError("40001"), 3)
+	require.IsType(t, RecoverableError{}, err)
+
+	// the unavailable db stops the dispatcher
+	_, err = runFlakySequence(t, pgError("08006"), 1)
+	require.IsType(t, FatalError{}, err)
+	require.False(t, err.(FatalError).SequenceOnly())
+
+	// the failed query fails the sequence only
+	_, err = runFlakySequence(t, pgError("23505"), 1)
+	require.IsType(t, FatalError{}, err)
+	require.True(t, err.(FatalError).SequenceOnly())
+}
+
+// droppingInteractor never confirms broadcasted txs as if the node dropped them from utx
+type droppingInteractor struct {
+	*node.FakeInteractor
+}
+
+func (i *droppingInteractor) WaitForTxStatus(txID string, waitForStatus node.TransactionStatus, timeout time.Duration) (int32, node.Error) {
+	return 0, node.NewError(node.TxNotFoundError, "tx not found")
+}
+
+func (i *droppingInteractor) WithContext(ctx context.Context) node.Interactor {
+	return i
+}
+
+func TestRebroadcastedSequenceFailsWithoutProgress(t *testing.T) {
+	repo := newFakeRepo()
+	repo.addSequence(1, fmt.Sprintf(`{"id":"a","timestamp":%d}`, time.Now().UnixNano()/int64(time.Millisecond)))
+
+	// workers of the restarted sequence share the tracker of the dispatcher
+	tracker := NewProgressTracker()
+	cfg := Config{TxOutdateTime: 3600000, DeadlockTimeout: 1}
+	nodeInteractor := &droppingInteractor{node.NewFakeInteractor(nil)}
+
+	var err ErrorWithReason
+	runs := 0
+	for ; runs < 5; runs++ {
+		log.Logger = zap.NewNop()
+		w := New("test", "", repo, nodeInteractor, repository.SequenceOptions{}, cfg, nil, tracker).(*workerImpl)
+		if err = w.Run(1); err == nil {
+			break
+		}
+		if _, ok := err.(NonRecoverableError); ok {
+			break
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	require.IsType(t, NonRecoverableError{}, err)
+	require.Equal(t, NoProgressErrorCode, err.(ErrorWithReasonAndCode).ErrorCode())
+	// the first run broadcasts the tx, the second one only repeats it
+	require.Equal(t, 1, runs)
+	require.Equal(t, repository.TransactionStatePending, repo.tx(1, 0).State)
+}
+
+// reclaimedRepo has the sequence reclaimed by the owner other than "b" after the lease of "b" expired
+type reclaimedRepo struct {
+	*fakeRepo
+}
+
+func (r *reclaimedRepo) RefreshSequence(sequenceID int64, owner string) (bool, error) {
+	return owner == "a", nil
+}
+
+func TestWorkerStopsBeforeBroadcastOfReclaimedSequence(t *testing.T) {
+	for _, owner := range []string{"a", "b"} {
+		repo := &reclaimedRepo{fakeRepo: newFakeRepo()}
+		repo.addSequence(1, `{"id":"a"}`)
+
+		log.Logger = zap.NewNop()
+		nodeInteractor := newCountingInteractor(nil)
+		w := New("test", owner, repo, nodeInteractor, repository.SequenceOptions{}, Config{}, nil, nil)
+
+		err := w.Run(1)
+		if owner == "a" {
+			require.Nil(t, err)
+			require.Equal(t, 1, nodeInteractor.callsOf("BroadcastTx"))
+			continue
+		}
+		require.IsType(t, RecoverableError{}, err)
+		require.Equal(t, 0, nodeInteractor.callsOf("BroadcastTx"))
+	}
+}
+
+func TestSequenceWithoutConfirmedTxsIsNotDone(t *testing.T) {
+	for _, mode := range []repository.SequenceMode{repository.SequenceModeIndependent, repository.SequenceModeBroadcast} {
+		repo := newFakeRepo()
+		repo.addSequence(1, `{"id":"a"}`, `{"id":"b"}`)
+		for position := int16(0); position < 2; position++ {
+			require.NoError(t, repo.update(1, position, func(tx *repository.SequenceTx) {
+				tx.State = repository.TransactionStateSkipped
+			}))
+		}
+
+		nodeInteractor := newCountingInteractor(nil)
+		w := newTestWorker(repo, nodeInteractor, repository.SequenceOptions{Mode: mode}, Config{})
+
+		// nothing was confirmed, so the height the sequence waits for is already passed
+		err := w.Run(1)
+		require.IsType(t, NonRecoverableError{}, err, mode)
+		require.Equal(t, NoConfirmedTxsErrorCode, err.(ErrorWithReasonAndCode).ErrorCode())
+		require.Equal(t, 0, nodeInteractor.callsOf("BroadcastTx")+nodeInteractor.callsOf("BroadcastTxs"))
+	}
+}
+
+// flappingInteractor reports availability of txs by the given sequence of checks, the last one is repeated
+type flappingInteractor struct {
+	*node.FakeInteractor
+	available []bool
+	checks    int
+}
+
+func (i *flappingInteractor) GetTxsAvailability(txIDs []string) (node.Availability, node.Error) {
+	check := i.checks
+	if check >= len(i.available) {
+		check = len(i.available) - 1
+	}
+	i.checks++
+
+	availability := node.Availability{}
+	for _, txID := range txIDs {
+		availability[txID] = node.TxAvailability{IsAvailable: i.available[check], Confirmations: 10}
+	}
+	return availability, nil
+}
+
+func TestConfirmedTxIsPulledOutAfterConsecutiveNotFoundChecks(t *testing.T) {
+	repo := newFakeRepo()
+	repo.addSequence(1, `{"id":"a"}`, `{"id":"b"}`)
+	for position, txID := range []string{"a", "b"} {
+		require.NoError(t, repo.SetSequenceTxID(1, int16(position), txID, txID, 5))
+		require.NoError(t, repo.SetSequenceTxConfirmedState(1, int16(position), 5))
+	}
+	confirmedTxs := map[string]*repository.SequenceTx{}
+	for position := int16(0); position < 2; position++ {
+		tx := repo.tx(1, position)
+		confirmedTxs[tx.ID] = &tx
+	}
+
+	// the warming up node does not find the confirmed tx twice, then it finds it again and then loses it for good
+	nodeInteractor := &flappingInteractor{FakeInteractor: node.NewFakeInteractor(nil), available: []bool{false, false, true, false, false, false}}
+	w := newTestWorker(repo, nodeInteractor, repository.SequenceOptions{}, Config{TxNotFoundChecks: 3})
+
+	for check := 0; check < 5; check++ {
+		_, err := w.checkTxsAvailabilityOnce(1, confirmedTxs)
+		require.Nil(t, err, "check %d", check)
+		require.Equal(t, repository.TransactionStateConfirmed, repo.tx(1, 0).State)
+	}
+
+	_, err := w.checkTxsAvailabilityOnce(1, confirmedTxs)
+	require.IsType(t, RecoverableError{}, err)
+	require.Equal(t, repository.TransactionStatePending, repo.tx(1, 1).State)
+
+	// a single not found is enough by default
+	require.NoError(t, repo.SetSequenceTxConfirmedState(1, 0, 5))
+	require.NoError(t, repo.SetSequenceTxConfirmedState(1, 1, 5))
+	nodeInteractor = &flappingInteractor{FakeInteractor: node.NewFakeInteractor(nil), available: []bool{false}}
+	w = newTestWorker(repo, nodeInteractor, repository.SequenceOptions{}, Config{TxNotFoundChecks: 1})
+	_, err = w.checkTxsAvailabilityOnce(1, confirmedTxs)
+	require.IsType(t, RecoverableError{}, err)
+}
+
+// duplicateInteractor rejects broadcasts of txs which are already in the blockchain at the given height
+type duplicateInteractor struct {
+	*countingInteractor
+	height int32
+}
+
+func (i *duplicateInteractor) BroadcastTx(tx string) (string, node.Error) {
+	i.count("BroadcastTx")
+	t, _ := node.ParseTx(tx)
+	return "", node.NewError(node.BroadcastClientError, fmt.Sprintf("State check failed. Reason: Transaction %s is already in the state on a height of %d", t.ID, i.height))
+}
+
+func (i *duplicateInteractor) WaitForTxStatus(txID string, waitForStatus node.TransactionStatus, timeout time.Duration) (int32, node.Error) {
+	i.count("WaitForTxStatus")
+	return i.height, nil
+}
+
+// GetCurrentHeight returns the height the duplicates are buried at
+func (i *duplicateInteractor) GetCurrentHeight() (int32, node.Error) {
+	return i.height + 10, nil
+}
+
+func (i *duplicateInteractor) WithContext(ctx context.Context) node.Interactor {
+	return i
+}
+
+func TestDuplicateTxIsConfirmedAtReportedHeight(t *testing.T) {
+	for _, duplicateAsConfirmed := range []bool{true, false} {
+		repo := newFakeRepo()
+		repo.addSequence(1, `{"id":"a"}`)
+
+		nodeInteractor := &duplicateInteractor{countingInteractor: newCountingInteractor(nil), height: 42}
+		w := newTestWorker(repo, nodeInteractor, repository.SequenceOptions{}, Config{DuplicateAsConfirmed: duplicateAsConfirmed})
+		require.Nil(t, w.Run(1))
+
+		tx := repo.tx(1, 0)
+		require.Equal(t, repository.TransactionStateConfirmed, tx.State)
+		require.Equal(t, "a", tx.ID)
+		require.Equal(t, int32(42), tx.Height)
+
+		// the confirmation is not waited for if the height is taken from the node error
+		waits := 0
+		if !duplicateAsConfirmed {
+			waits = 1
+		}
+		require.Equal(t, waits, nodeInteractor.callsOf("WaitForTxStatus"), "duplicate as confirmed: %v", duplicateAsConfirmed)
+	}
+}
+
+// timingInteractor records times of broadcasts
+type timingInteractor struct {
+	*node.FakeInteractor
+	broadcasts []time.Time
+}
+
+func (i *timingInteractor) BroadcastTx(tx string) (string, node.Error) {
+	i.broadcasts = append(i.broadcasts, time.Now())
+	return i.FakeInteractor.BroadcastTx(tx)
+}
+
+func (i *timingInteractor) WithContext(ctx context.Context) node.Interactor {
+	return i
+}
+
+func TestBroadcastsArePaced(t *testing.T) {
+	const interval = 30 * time.Millisecond
+
+	cases := []struct {
+		name    string
+		cfg     Config
+		options repository.SequenceOptions
+	}{
+		{name: "config", cfg: Config{BroadcastInterval: int32(interval / time.Millisecond)}},
+		{name: "sequence", cfg: Config{BroadcastInterval: 1}, options: repository.SequenceOptions{BroadcastInterval: int32(interval / time.Millisecond)}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			repo := newFakeRepo()
+			repo.addSequence(1, `{"id":"a"}`, `{"id":"b"}`, `{"id":"c"}`)
+
+			nodeInteractor := &timingInteractor{FakeInteractor: node.NewFakeInteractor(nil)}
+			w := newTestWorker(repo, nodeInteractor, c.options, c.cfg)
+			require.Nil(t, w.Run(1))
+
+			require.Len(t, nodeInteractor.broadcasts, 3)
+			for i := 1; i < len(nodeInteractor.broadcasts); i++ {
+				require.GreaterOrEqual(t, int64(nodeInteractor.broadcasts[i].Sub(nodeInteractor.broadcasts[i-1])), int64(interval))
+			}
+		})
+	}
+}
+
+// utxFullInteractor rejects the given count of broadcasts as if the node utx pool was full
+type utxFullInteractor struct {
+	*countingInteractor
+	rejections int
+}
+
+func (i *utxFullInteractor) BroadcastTx(tx string) (string, node.Error) {
+	if i.callsOf("BroadcastTx") < i.rejections {
+		i.count("BroadcastTx")
+		return "", node.NewError(node.BroadcastClientError, "Transaction pool size limit is reached")
+	}
+	return i.countingInteractor.BroadcastTx(tx)
+}
+
+func (i *utxFullInteractor) WithContext(ctx context.Context) node.Interactor {
+	return i
+}
+
+func TestUtxFullRejectionDoesNotFailSequence(t *testing.T) {
+	repo := newFakeRepo()
+	repo.addSequence(1, `{"id":"a"}`)
+
+	nodeInteractor := &utxFullInteractor{countingInteractor: newCountingInteractor(nil), rejections: 1}
+	w := newTestWorker(repo, nodeInteractor, repository.SequenceOptions{}, Config{})
+
+	// the worker is restarted by the dispatcher after the recoverable error
+	err := w.Run(1)
+	require.IsType(t, RecoverableError{}, err)
+	require.NotEqual(t, repository.TransactionStateError, repo.tx(1, 0).State)
+
+	w = newTestWorker(repo, nodeInteractor, repository.SequenceOptions{}, Config{})
+	require.Nil(t, w.Run(1))
+	require.Equal(t, repository.TransactionStateConfirmed, repo.tx(1, 0).State)
+	require.Equal(t, 2, nodeInteractor.callsOf("BroadcastTx"))
+}
+
+// staleValidator rejects txs as too old after the given count of successful validations
+type staleValidator struct {
+	node.Interactor
+	valid       int
+	validations int
+}
+
+func (v *staleValidator) ValidateTx(tx string) (*node.ValidationResult, node.Error) {
+	v.validations++
+	if v.validations > v.valid {
+		return &node.ValidationResult{IsValid: false, ErrorMessage: "Transaction timestamp 1 is more than 7200000ms in the past"}, nil
+	}
+	return &node.ValidationResult{IsValid: true}, nil
+}
+
+func TestStaleTxIsNotBroadcasted(t *testing.T) {
+	repo := newFakeRepo()
+	// the tx was validated by the previous run long ago, its timestamp has become too old meanwhile
+	repo.addSequence(1, fmt.Sprintf(`{"id":"a","timestamp":%d}`, time.Now().Add(-2*time.Hour).UnixNano()/int64(time.Millisecond)))
+	repo.txs[1][0].State = repository.TransactionStateValidated
+	repo.txs[1][0].UpdatedAt = time.Now().Add(-time.Hour)
+
+	validator := &staleValidator{}
+	nodeInteractor := newCountingInteractor(validator)
+	w := newTestWorker(repo, nodeInteractor, repository.SequenceOptions{}, Config{TxOutdateTime: 3600000, RevalidateAfter: 60000})
+
+	err := w.Run(1)
+	require.IsType(t, NonRecoverableError{}, err)
+	require.Equal(t, TxOutdatedErrorCode, err.(ErrorWithReasonAndCode).ErrorCode())
+	require.Equal(t, 1, validator.validations)
+	require.Zero(t, nodeInteractor.callsOf("BroadcastTx"))
+	require.Equal(t, repository.TransactionStateError, repo.tx(1, 0).State)
+}
+
+func TestRecentlyValidatedTxIsNotValidatedAgain(t *testing.T) {
+	for name, tc := range map[string]struct {
+		state           repository.TransactionState
+		revalidateAfter int32
+	}{
+		"validated by this run":         {state: repository.TransactionStatePending, revalidateAfter: 60000},
+		"validated by the previous run": {state: repository.TransactionStateValidated, revalidateAfter: 60000},
+		"revalidation is disabled":      {state: repository.TransactionStateValidated, revalidateAfter: 0},
+	} {
+		t.Run(name, func(t *testing.T) {
+			repo := newFakeRepo()
+			repo.addSequence(1, fmt.Sprintf(`{"id":"a","timestamp":%d}`, time.Now().UnixNano()/int64(time.Millisecond)))
+			repo.txs[1][0].State = tc.state
+			if tc.revalidateAfter == 0 {
+				repo.txs[1][0].UpdatedAt = time.Now().Add(-time.Hour)
+			}
+
+			validator := &staleValidator{valid: 1}
+			nodeInteractor := newCountingInteractor(validator)
+			w := newTestWorker(repo, nodeInteractor, repository.SequenceOptions{}, Config{TxOutdateTime: 3600000, RevalidateAfter: tc.revalidateAfter})
+
+			require.Nil(t, w.Run(1))
+			require.Equal(t, 1, nodeInteractor.callsOf("BroadcastTx"))
+			require.Equal(t, repository.TransactionStateConfirmed, repo.tx(1, 0).State)
+		})
+	}
+}
+
+// txIDFailingRepo fails writes of ids of broadcasted txs
+type txIDFailingRepo struct {
+	*fakeRepo
+	err error
+}
+
+func (r *txIDFailingRepo) SetSequenceTxID(sequenceID int64, positionInSequence int16, txID, submittedTxID string, broadcastHeight int32) error {
+	return r.err
+}
+
+func TestFailedTxIDWriteAfterBroadcast(t *testing.T) {
+	repo := &txIDFailingRepo{fakeRepo: newFakeRepo(), err: pgError("23505")}
+	repo.addSequence(1, `{"id":"a"}`)
+
+	nodeInteractor := newCountingInteractor(nil)
+	w := newTestWorker(repo, nodeInteractor, repository.SequenceOptions{}, Config{})
+
+	// the broadcast succeeded, so the failed write is not a node error
+	err := w.Run(1)
+	require.IsType(t, FatalError{}, err)
+	require.True(t, err.(FatalError).SequenceOnly())
+	require.Equal(t, 1, nodeInteractor.callsOf("BroadcastTx"))
+	require.Empty(t, repo.tx(1, 0).ID)
+}
+
+// slowConfirmationInteractor confirms broadcasted txs confirmIn after the broadcast on the simulated clock
+// waits shorter than that time out, blocks are produced every blockTime
+type slowConfirmationInteractor struct {
+	*node.FakeInteractor
+	confirmIn time.Duration
+	blockTime time.Duration
+	timeouts  []time.Duration
+}
+
+func (i *slowConfirmationInteractor) WaitForTxStatus(txID string, waitForStatus node.TransactionStatus, timeout time.Duration) (int32, node.Error) {
+	i.timeouts = append(i.timeouts, timeout)
+	if timeout < i.confirmIn {
+		return 0, node.NewError(node.WaitForTxStatusTimeoutError, fmt.Sprintf("tx %s is not confirmed in %s", txID, timeout))
+	}
+	return i.FakeInteractor.WaitForTxStatus(txID, waitForStatus, timeout)
+}
+
+func (i *slowConfirmationInteractor) GetRecentBlockTimes(n int) ([]int64, node.Error) {
+	blockTimes := make([]int64, n)
+	for j := range blockTimes {
+		blockTimes[j] = 1600000000000 + int64(j)*int64(i.blockTime/time.Millisecond)
+	}
+	return blockTimes, nil
+}
+
+func (i *slowConfirmationInteractor) WithContext(ctx context.Context) node.Interactor {
+	return i
+}
+
+func TestTxConfirmationTimeoutBoundary(t *testing.T) {
+	cases := []struct {
+		name    string
+		tx      string
+		cfg     Config
+		timeout time.Duration
+	}{
+		// 3 blocks of 60s
+		{name: "block time", tx: `{"id":"a","type":16}`, cfg: Config{TxConfirmationTimeoutHeights: 3}, timeout: 3 * time.Minute},
+		{name: "tx type", tx: `{"id":"a","type":4}`, cfg: Config{TxConfirmationTimeoutHeights: 3, TxConfirmationTimeoutByType: TxTypeTimeouts{4: 30 * time.Second}}, timeout: 30 * time.Second},
+		{name: "other tx type", tx: `{"id":"a","type":16}`, cfg: Config{TxConfirmationTimeoutHeights: 3, TxConfirmationTimeoutByType: TxTypeTimeouts{4: 30 * time.Second}}, timeout: 3 * time.Minute},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// the tx confirmed just before the deadline
+			repo := newFakeRepo()
+			repo.addSequence(1, c.tx)
+			nodeInteractor := &slowConfirmationInteractor{FakeInteractor: node.NewFakeInteractor(nil), confirmIn: c.timeout - time.Second, blockTime: time.Minute}
+			w := newTestWorker(repo, nodeInteractor, repository.SequenceOptions{}, c.cfg)
+
+			require.Nil(t, w.Run(1))
+			require.Equal(t, repository.TransactionStateConfirmed, repo.tx(1, 0).State)
+			require.Equal(t, []time.Duration{c.timeout}, nodeInteractor.timeouts)
+
+			// the tx confirmed just after the deadline is waited for again by the next run
+			repo = newFakeRepo()
+			repo.addSequence(1, c.tx)
+			nodeInteractor = &slowConfirmationInteractor{FakeInteractor: node.NewFakeInteractor(nil), confirmIn: c.timeout + time.Second, blockTime: time.Minute}
+			w = newTestWorker(repo, nodeInteractor, repository.SequenceOptions{}, c.cfg)
+
+			err := w.Run(1)
+			require.IsType(t, RecoverableError{}, err)
+			require.Equal(t, repository.TransactionStateUnconfirmed, repo.tx(1, 0).State)
+			require.Equal(t, "a", repo.tx(1, 0).ID)
+			require.Equal(t, []time.Duration{c.timeout}, nodeInteractor.timeouts)
+		})
+	}
+}
+
+func TestTxConfirmationTimeoutScalesWithBlockTime(t *testing.T) {
+	for _, blockTime := range []time.Duration{30 * time.Second, time.Minute, 2 * time.Minute} {
+		repo := newFakeRepo()
+		repo.addSequence(1, `{"id":"a","type":4}`)
+		nodeInteractor := &slowConfirmationInteractor{FakeInteractor: node.NewFakeInteractor(nil), blockTime: blockTime}
+		w := newTestWorker(repo, nodeInteractor, repository.SequenceOptions{}, Config{TxConfirmationTimeoutHeights: 5})
+
+		require.Nil(t, w.Run(1))
+		require.Equal(t, []time.Duration{5 * blockTime}, nodeInteractor.timeouts, blockTime)
+	}
+
+	// the node default timeout is used unless the timeout is configured
+	repo := newFakeRepo()
+	repo.addSequence(1, `{"id":"a","type":4}`)
+	nodeInteractor := &slowConfirmationInteractor{FakeInteractor: node.NewFakeInteractor(nil), blockTime: time.Minute}
+	w := newTestWorker(repo, nodeInteractor, repository.SequenceOptions{}, Config{})
+	require.Nil(t, w.Run(1))
+	require.Equal(t, []time.Duration{0}, nodeInteractor.timeouts)
+}
+
+// reorgInteractor serves the given block signatures, txs which are not in available are not found
+type reorgInteractor struct {
+	*node.FakeInteractor
+	signatures map[int32]string
+	available  map[string]bool
+}
+
+func (i *reorgInteractor) GetBlockSignatureAtHeight(height int32) (string, node.Error) {
+	return i.signatures[height], nil
+}
+
+func (i *reorgInteractor) GetTxsAvailability(txIDs []string) (node.Availability, node.Error) {
+	availability := node.Availability{}
+	for _, txID := range txIDs {
+		availability[txID] = node.TxAvailability{IsAvailable: i.available[txID], Confirmations: 10}
+	}
+	return availability, nil
+}
+
+func TestConfirmedTxsOfReplacedBlockArePulledOut(t *testing.T) {
+	for _, reorg := range []bool{true, false} {
+		repo := newFakeRepo()
+		repo.addSequence(1, `{"id":"a"}`, `{"id":"b"}`, `{"id":"c"}`)
+		for position, txID := range []string{"a", "b", "c"} {
+			require.NoError(t, repo.SetSequenceTxID(1, int16(position), txID, txID, 4))
+			require.NoError(t, repo.SetSequenceTxConfirmedState(1, int16(position), int32(4+position)))
+		}
+		confirmedTxs := map[string]*repository.SequenceTx{}
+		for position := int16(0); position < 3; position++ {
+			tx := repo.tx(1, position)
+			confirmedTxs[tx.ID] = &tx
+		}
+
+		nodeInteractor := &reorgInteractor{
+			FakeInteractor: node.NewFakeInteractor(nil),
+			signatures:     map[int32]string{4: "block-4", 5: "block-5", 6: "block-6"},
+			available:      map[string]bool{"a": true, "b": true, "c": true},
+		}
+		// a single not found status is tolerated unless the block was replaced
+		w := newTestWorker(repo, nodeInteractor, repository.SequenceOptions{}, Config{TxNotFoundChecks: 3, TrackBlockSignatures: true})
+
+		_, err := w.checkTxsAvailabilityOnce(1, confirmedTxs)
+		require.Nil(t, err)
+
+		// the block of b is replaced by the one without b
+		if reorg {
+			nodeInteractor.signatures[5] = "block-5-fork"
+		}
+		nodeInteractor.available["b"] = false
+
+		_, err = w.checkTxsAvailabilityOnce(1, confirmedTxs)
+		if !reorg {
+			require.Nil(t, err)
+			for position := int16(0); position < 3; position++ {
+				require.Equal(t, repository.TransactionStateConfirmed, repo.tx(1, position).State)
+			}
+			continue
+		}
+
+		require.IsType(t, RecoverableError{}, err)
+		require.Equal(t, repository.TransactionStateConfirmed, repo.tx(1, 0).State)
+		require.Equal(t, repository.TransactionStatePending, repo.tx(1, 1).State)
+		require.Equal(t, repository.TransactionStatePending, repo.tx(1, 2).State)
+		require.Equal(t, int16(0), repo.progress[1])
+	}
+}
+
+// streamingInteractor delivers heights the test sends to the blocks stream, the polled height is set by the test
+type streamingInteractor struct {
+	*node.FakeInteractor
+	blocks chan int32
+
+	mutex                sync.Mutex
+	height               int32
+	availabilityRequests int
+}
+
+func (i *streamingInteractor) setHeight(height int32) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	i.height = height
+}
+
+func (i *streamingInteractor) SubscribeBlocks(ctx context.Context) (<-chan int32, node.Error) {
+	return i.blocks, nil
+}
+
+func (i *streamingInteractor) GetCurrentHeight() (int32, node.Error) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	return i.height, nil
+}
+
+func (i *streamingInteractor) GetTxsAvailability(txIDs []string) (node.Availability, node.Error) {
+	i.mutex.Lock()
+	i.availabilityRequests++
+	i.mutex.Unlock()
+	return i.FakeInteractor.GetTxsAvailability(txIDs)
+}
+
+func TestWaitForTargetHeightByBlocksStream(t *testing.T) {
+	for _, streamEnds := range []bool{false, true} {
+		repo := newFakeRepo()
+		repo.addSequence(1, `{"id":"a"}`)
+
+		nodeInteractor := &streamingInteractor{FakeInteractor: node.NewFakeInteractor(nil), blocks: make(chan int32), height: 5}
+		txID, wavesErr := nodeInteractor.BroadcastTx(repo.tx(1, 0).Tx)
+		require.Nil(t, wavesErr)
+		require.NoError(t, repo.SetSequenceTxID(1, 0, txID, txID, 1))
+		require.NoError(t, repo.SetSequenceTxConfirmedState(1, 0, 1))
+		tx := repo.tx(1, 0)
+
+		// the height is polled only after the stream ends, otherwise the test would wait for an hour
+		delay := int32(3600 * 1000)
+		if streamEnds {
+			delay = 1
+		}
+		w := newTestWorker(repo, nodeInteractor, repository.SequenceOptions{}, Config{WaitForNextHeightDelay: delay})
+
+		done := make(chan ErrorWithReason, 1)
+		go func() {
+			done <- w.waitForTargetHeight(10, 1, map[string]*repository.SequenceTx{txID: &tx})
+		}()
+
+		if streamEnds {
+			nodeInteractor.blocks <- 6
+			nodeInteractor.setHeight(10)
+			close(nodeInteractor.blocks)
+
+			require.Nil(t, <-done)
+			// the streamed height and the polled one are checked
+			require.Equal(t, 2, nodeInteractor.availabilityRequests)
+			continue
+		}
+
+		for height := int32(6); height <= 10; height++ {
+			nodeInteractor.blocks <- height
+		}
+
+		require.Nil(t, <-done)
+		// txs are checked on every streamed height
+		require.Equal(t, 5, nodeInteractor.availabilityRequests)
+	}
+}
+
+// availabilityRecordingInteractor records ids of txs the availability is requested for and waits for the next height
+type availabilityRecordingInteractor struct {
+	*node.FakeInteractor
+	requests    [][]string
+	heightWaits int
+}
+
+func (i *availabilityRecordingInteractor) GetTxsAvailability(txIDs []string) (node.Availability, node.Error) {
+	request := append([]string{}, txIDs...)
+	sort.Strings(request)
+	i.requests = append(i.requests, request)
+	return i.FakeInteractor.GetTxsAvailability(txIDs)
+}
+
+func (i *availabilityRecordingInteractor) WaitForNextHeight() node.Error {
+	i.heightWaits++
+	return i.FakeInteractor.WaitForNextHeight()
+}
+
+func (i *availabilityRecordingInteractor) WithContext(ctx context.Context) node.Interactor {
+	return i
+}
+
+func TestLastTxConfirmationMode(t *testing.T) {
+	cfg := Config{MinConfirmations: 3, HeightsAfterLastTx: 3, WaitForNextHeightDelay: 1}
+
+	// every tx waits for its confirmations before the next one is broadcasted
+	repo := newFakeRepo()
+	repo.addSequence(1, `{"id":"a"}`, `{"id":"b"}`, `{"id":"c"}`)
+	nodeInteractor := &availabilityRecordingInteractor{FakeInteractor: node.NewFakeInteractor(nil)}
+	w := newTestWorker(repo, nodeInteractor, repository.SequenceOptions{}, cfg)
+	require.Nil(t, w.Run(1))
+	require.Positive(t, nodeInteractor.heightWaits)
+	require.Equal(t, []string{"a", "b", "c"}, nodeInteractor.requests[len(nodeInteractor.requests)-1])
+
+	// only the last tx is waited for
+	cfg.ConfirmationMode = ConfirmationModeLastTx
+	repo = newFakeRepo()
+	repo.addSequence(1, `{"id":"a"}`, `{"id":"b"}`, `{"id":"c"}`)
+	nodeInteractor = &availabilityRecordingInteractor{FakeInteractor: node.NewFakeInteractor(nil)}
+	w = newTestWorker(repo, nodeInteractor, repository.SequenceOptions{}, cfg)
+	require.Nil(t, w.Run(1))
+	require.Zero(t, nodeInteractor.heightWaits)
+	require.Equal(t, []string{"c"}, nodeInteractor.requests[len(nodeInteractor.requests)-1])
+
+	height, _ := nodeInteractor.GetCurrentHeight()
+	require.GreaterOrEqual(t, height, repo.tx(1, 2).Height+cfg.HeightsAfterLastTx)
+	for position := int16(0); position < 3; position++ {
+		require.Equal(t, repository.TransactionStateConfirmed, repo.tx(1, position).State)
+	}
+}
+
+func TestLastTxConfirmationModeWithSkippedLastTx(t *testing.T) {
+	repo := newFakeRepo()
+	repo.addSequence(1, `{"id":"a"}`, `{"id":"b"}`, `{"id":"c"}`)
+	require.NoError(t, repo.update(1, 2, func(tx *repository.SequenceTx) {
+		tx.State = repository.TransactionStateSkipped
+	}))
+
+	nodeInteractor := &availabilityRecordingInteractor{FakeInteractor: node.NewFakeInteractor(nil)}
+	w := newTestWorker(repo, nodeInteractor, repository.SequenceOptions{}, Config{MinConfirmations: 3, HeightsAfterLastTx: 3, WaitForNextHeightDelay: 1, ConfirmationMode: ConfirmationModeLastTx})
+	require.Nil(t, w.Run(1))
+
+	// the last not skipped tx is waited for instead
+	require.Equal(t, []string{"b"}, nodeInteractor.requests[len(nodeInteractor.requests)-1])
+	require.Equal(t, repository.TransactionStateSkipped, repo.tx(1, 2).State)
+	height, _ := nodeInteractor.GetCurrentHeight()
+	require.GreaterOrEqual(t, height, repo.tx(1, 1).Height+3)
+}
+
+// alreadyInStateValidator reports every tx as already confirmed at height 42
+type alreadyInStateValidator struct {
+	node.Interactor
+}
+
+func (v *alreadyInStateValidator) ValidateTx(tx string) (*node.ValidationResult, node.Error) {
+	t, _ := node.ParseTx(tx)
+	return &node.ValidationResult{IsValid: false, ErrorMessage: fmt.Sprintf("State check failed. Reason: Transaction %s is already in the state on a height of 42", t.ID)}, nil
+}
+
+func TestSequenceWithConfirmedFirstTxIsResumed(t *testing.T) {
+	// the sequence is re-submitted after its first tx was confirmed
+	repo := newFakeRepo()
+	repo.addSequence(1, `{"id":"a"}`)
+
+	nodeInteractor := &duplicateInteractor{countingInteractor: newCountingInteractor(&alreadyInStateValidator{}), height: 42}
+	w := newTestWorker(repo, nodeInteractor, repository.SequenceOptions{}, Config{DuplicateAsConfirmed: true})
+	require.Nil(t, w.Run(1))
+
+	tx := repo.tx(1, 0)
+	require.Equal(t, repository.TransactionStateConfirmed, tx.State)
+	require.Equal(t, "a", tx.ID)
+	require.Equal(t, int32(42), tx.Height)
+	require.Empty(t, tx.ErrorMessage)
+}
+
+func TestTxsDeeperThanReorgWindowAreNotChecked(t *testing.T) {
+	for _, reorgWindow := range []int32{10, 0} {
+		repo := newFakeRepo()
+		repo.addSequence(1, `{"id":"a"}`, `{"id":"b"}`, `{"id":"c"}`)
+
+		nodeInteractor := &availabilityRecordingInteractor{FakeInteractor: node.NewFakeInteractor(nil)}
+		confirmedTxs := map[string]*repository.SequenceTx{}
+		for position, height := range []int32{2, 20, 29} {
+			// the tx is put to the block at the height
+			require.Nil(t, nodeInteractor.WaitForTargetHeight(height-1))
+			txID, wavesErr := nodeInteractor.BroadcastTx(repo.tx(1, int16(position)).Tx)
+			require.Nil(t, wavesErr)
+			require.NoError(t, repo.SetSequenceTxID(1, int16(position), txID, txID, height))
+			require.NoError(t, repo.SetSequenceTxConfirmedState(1, int16(position), height))
+			tx := repo.tx(1, int16(position))
+			confirmedTxs[tx.ID] = &tx
+		}
+		require.Nil(t, nodeInteractor.WaitForTargetHeight(30))
+
+		w := newTestWorker(repo, nodeInteractor, repository.SequenceOptions{}, Config{ReorgWindow: reorgWindow})
+		_, err := w.checkTxsAvailabilityOnce(1, confirmedTxs)
+		require.Nil(t, err)
+
+		require.Len(t, nodeInteractor.requests, 1)
+		if reorgWindow > 0 {
+			// a and b are buried more than 10 blocks deep
+			require.Equal(t, []string{"c"}, nodeInteractor.requests[0])
+			continue
+		}
+		require.Equal(t, []string{"a", "b", "c"}, nodeInteractor.requests[0])
+	}
+}